@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/config"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/middleware"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/notifier"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+)
+
+// The notifier is a small downstream service called by the task server when
+// a task is completed, so traces span two services.
+func main() {
+	// Create a basic logger for startup (before OTel is initialized)
+	startupLogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		startupLogger.Error("invalid configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+	startupLogger.Info("starting notifier",
+		slog.String("service", cfg.ServiceName),
+		slog.String("environment", cfg.Environment),
+		slog.String("telemetry_profile", cfg.TelemetryProfile),
+		slog.String("port", cfg.ServerPort),
+	)
+
+	ctx := context.Background()
+	conns := telemetry.NewExporterConns(cfg.OTLPSharedConnection)
+	// Close the exporter connections after the providers are shut down
+	defer func() {
+		if err := conns.Close(context.Background()); err != nil {
+			startupLogger.Error("failed to close exporter connections", slog.Any("error", err))
+		}
+	}()
+	drops := telemetry.NewDropReporter(cfg.TelemetryDropWarnInterval)
+	mirror := telemetry.Mirror{
+		Endpoint: cfg.OTLPMirrorEndpoint,
+		Signals:  cfg.OTLPMirrorSignals,
+		OTLP: telemetry.OTLPConfig{
+			Headers:     cfg.OTLPMirrorHeaders,
+			Compression: cfg.OTLPMirrorCompression,
+			Insecure:    cfg.OTLPInsecure,
+		},
+	}
+
+	// Initialize OpenTelemetry providers
+	tp, err := telemetry.InitTracerProvider(ctx, cfg.ServiceName, cfg.OTLPTracesEndpoint, cfg.Environment, conns,
+		telemetry.WithTraceExporter(cfg.TracesExporter, cfg.TracesExporterEndpoint),
+		telemetry.WithSampleRatio(cfg.TracesSampleRatio),
+		telemetry.WithTraceOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPTracesHeaders,
+			Compression: cfg.OTLPTracesCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithBatchConfig(telemetry.BatchConfig{
+			Timeout:            cfg.TracesBatchTimeout,
+			MaxExportBatchSize: int(cfg.TracesMaxExportBatchSize),
+			MaxQueueSize:       int(cfg.TracesMaxQueueSize),
+			Blocking:           cfg.TracesBlockOnQueueFull,
+			BlockTimeout:       cfg.TelemetryBlockTimeout,
+		}),
+		telemetry.WithTraceDropReporter(drops),
+		telemetry.WithSpanLimits(telemetry.SpanLimits{
+			AttributeCount:       int(cfg.SpanAttributeCountLimit),
+			AttributeValueLength: int(cfg.SpanAttributeValueLengthLimit),
+			EventCount:           int(cfg.SpanEventCountLimit),
+		}),
+		telemetry.WithDeploymentAttributes(cfg.SpanDeploymentAttributes),
+		telemetry.WithTraceMirror(mirror),
+	)
+	if err != nil {
+		startupLogger.Error("failed to initialize tracer provider", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer func() {
+		if err := tp.Shutdown(ctx); err != nil {
+			startupLogger.Error("failed to shutdown tracer provider", slog.Any("error", err))
+		}
+	}()
+
+	mp, err := telemetry.InitMeterProvider(ctx, cfg.ServiceName, cfg.OTLPMetricsEndpoint, cfg.Environment, conns,
+		telemetry.WithMetricOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPMetricsHeaders,
+			Compression: cfg.OTLPMetricsCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithTemporality(cfg.MetricsTemporality),
+		telemetry.WithHistogramAggregation(cfg.MetricsHistogramAggregation, cfg.MetricsDurationBuckets),
+		telemetry.WithExportInterval(cfg.MetricsExportInterval),
+		telemetry.WithMetricMirror(mirror),
+	)
+	if err != nil {
+		startupLogger.Error("failed to initialize meter provider", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer func() {
+		if err := mp.Shutdown(ctx); err != nil {
+			startupLogger.Error("failed to shutdown meter provider", slog.Any("error", err))
+		}
+	}()
+
+	lp, logger, err := telemetry.InitLoggerProvider(ctx, cfg.ServiceName, cfg.OTLPLogsEndpoint, cfg.Environment, conns,
+		telemetry.WithLogLevel(cfg.LogLevel),
+		telemetry.WithLogOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPLogsHeaders,
+			Compression: cfg.OTLPLogsCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithLogBatchConfig(telemetry.BatchConfig{
+			MaxQueueSize: int(cfg.LogsMaxQueueSize),
+			Blocking:     cfg.LogsBlockOnQueueFull,
+			BlockTimeout: cfg.TelemetryBlockTimeout,
+		}),
+		telemetry.WithLogDropReporter(drops),
+		telemetry.WithLogMirror(mirror),
+	)
+	if err != nil {
+		startupLogger.Error("failed to initialize logger provider", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer func() {
+		if err := lp.Shutdown(ctx); err != nil {
+			startupLogger.Error("failed to shutdown logger provider", slog.Any("error", err))
+		}
+	}()
+
+	meter := otel.Meter(cfg.ServiceName)
+	if err := telemetry.RegisterDroppedCounter(meter, drops); err != nil {
+		logger.Error("failed to create dropped telemetry counter", slog.Any("error", err))
+		os.Exit(1)
+	}
+	notifyHandler, err := notifier.NewHandler(logger, meter)
+	if err != nil {
+		logger.Error("failed to create notifier handler", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	recoverer, err := middleware.Recoverer(logger, meter)
+	if err != nil {
+		logger.Error("failed to create recovery middleware", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Create router
+	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	r.Use(chimiddleware.RealIP)
+	r.Use(recoverer)
+
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	r.Post("/notify", notifyHandler.Notify)
+
+	// Wrap router with OpenTelemetry HTTP instrumentation; the incoming
+	// traceparent header continues the task server's trace.
+	otelHandler := otelhttp.NewHandler(r, "notifier",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/health"
+		}),
+	)
+
+	server := &http.Server{
+		Addr:         ":" + cfg.ServerPort,
+		Handler:      otelHandler,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	go func() { // async:ok: listener, not request work
+		logger.Info("notifier listening", slog.String("addr", server.Addr))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("server error", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}()
+
+	// Wait for interrupt signal for graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	logger.Info("shutting down notifier...")
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("server forced to shutdown", slog.Any("error", err))
+	}
+
+	logger.Info("notifier stopped")
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/config"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/eventbus"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"github.com/hiroki-koketsu/go-otel-sample/pkg/events"
+	"go.opentelemetry.io/otel"
+)
+
+// The task consumer processes task events published to NATS JetStream by the
+// task server, continuing each producer trace from the message headers.
+func main() {
+	// Create a basic logger for startup (before OTel is initialized)
+	startupLogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		startupLogger.Error("invalid configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+	startupLogger.Info("starting task consumer",
+		slog.String("service", cfg.ServiceName),
+		slog.String("environment", cfg.Environment),
+		slog.String("telemetry_profile", cfg.TelemetryProfile),
+		slog.String("nats_url", cfg.NATSURL),
+	)
+
+	if cfg.NATSURL == "" {
+		startupLogger.Error("NATS_URL is required")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	conns := telemetry.NewExporterConns(cfg.OTLPSharedConnection)
+	// Close the exporter connections after the providers are shut down
+	defer func() {
+		if err := conns.Close(context.Background()); err != nil {
+			startupLogger.Error("failed to close exporter connections", slog.Any("error", err))
+		}
+	}()
+	drops := telemetry.NewDropReporter(cfg.TelemetryDropWarnInterval)
+	mirror := telemetry.Mirror{
+		Endpoint: cfg.OTLPMirrorEndpoint,
+		Signals:  cfg.OTLPMirrorSignals,
+		OTLP: telemetry.OTLPConfig{
+			Headers:     cfg.OTLPMirrorHeaders,
+			Compression: cfg.OTLPMirrorCompression,
+			Insecure:    cfg.OTLPInsecure,
+		},
+	}
+
+	// Initialize OpenTelemetry providers
+	tp, err := telemetry.InitTracerProvider(ctx, cfg.ServiceName, cfg.OTLPTracesEndpoint, cfg.Environment, conns,
+		telemetry.WithTraceExporter(cfg.TracesExporter, cfg.TracesExporterEndpoint),
+		telemetry.WithSampleRatio(cfg.TracesSampleRatio),
+		telemetry.WithTraceOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPTracesHeaders,
+			Compression: cfg.OTLPTracesCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithBatchConfig(telemetry.BatchConfig{
+			Timeout:            cfg.TracesBatchTimeout,
+			MaxExportBatchSize: int(cfg.TracesMaxExportBatchSize),
+			MaxQueueSize:       int(cfg.TracesMaxQueueSize),
+			Blocking:           cfg.TracesBlockOnQueueFull,
+			BlockTimeout:       cfg.TelemetryBlockTimeout,
+		}),
+		telemetry.WithTraceDropReporter(drops),
+		telemetry.WithSpanLimits(telemetry.SpanLimits{
+			AttributeCount:       int(cfg.SpanAttributeCountLimit),
+			AttributeValueLength: int(cfg.SpanAttributeValueLengthLimit),
+			EventCount:           int(cfg.SpanEventCountLimit),
+		}),
+		telemetry.WithDeploymentAttributes(cfg.SpanDeploymentAttributes),
+		telemetry.WithTraceMirror(mirror),
+	)
+	if err != nil {
+		startupLogger.Error("failed to initialize tracer provider", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer func() {
+		if err := tp.Shutdown(context.Background()); err != nil {
+			startupLogger.Error("failed to shutdown tracer provider", slog.Any("error", err))
+		}
+	}()
+
+	mp, err := telemetry.InitMeterProvider(ctx, cfg.ServiceName, cfg.OTLPMetricsEndpoint, cfg.Environment, conns,
+		telemetry.WithMetricOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPMetricsHeaders,
+			Compression: cfg.OTLPMetricsCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithTemporality(cfg.MetricsTemporality),
+		telemetry.WithHistogramAggregation(cfg.MetricsHistogramAggregation, cfg.MetricsDurationBuckets),
+		telemetry.WithExportInterval(cfg.MetricsExportInterval),
+		telemetry.WithMetricMirror(mirror),
+	)
+	if err != nil {
+		startupLogger.Error("failed to initialize meter provider", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer func() {
+		if err := mp.Shutdown(context.Background()); err != nil {
+			startupLogger.Error("failed to shutdown meter provider", slog.Any("error", err))
+		}
+	}()
+
+	lp, logger, err := telemetry.InitLoggerProvider(ctx, cfg.ServiceName, cfg.OTLPLogsEndpoint, cfg.Environment, conns,
+		telemetry.WithLogLevel(cfg.LogLevel),
+		telemetry.WithLogOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPLogsHeaders,
+			Compression: cfg.OTLPLogsCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithLogBatchConfig(telemetry.BatchConfig{
+			MaxQueueSize: int(cfg.LogsMaxQueueSize),
+			Blocking:     cfg.LogsBlockOnQueueFull,
+			BlockTimeout: cfg.TelemetryBlockTimeout,
+		}),
+		telemetry.WithLogDropReporter(drops),
+		telemetry.WithLogMirror(mirror),
+	)
+	if err != nil {
+		startupLogger.Error("failed to initialize logger provider", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer func() {
+		if err := lp.Shutdown(context.Background()); err != nil {
+			startupLogger.Error("failed to shutdown logger provider", slog.Any("error", err))
+		}
+	}()
+
+	if err := telemetry.RegisterDroppedCounter(otel.Meter(cfg.ServiceName), drops); err != nil {
+		logger.Error("failed to create dropped telemetry counter", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	consumer, err := eventbus.NewNATSConsumer(ctx, eventbus.NATSConfig{
+		URL:           cfg.NATSURL,
+		Stream:        cfg.NATSStream,
+		SubjectPrefix: cfg.NATSSubjectPrefix,
+		Consumer:      cfg.NATSConsumer,
+	}, logger, otel.Meter(cfg.ServiceName))
+	if err != nil {
+		logger.Error("failed to create NATS consumer", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer consumer.Close()
+
+	logger.Info("consuming task events", slog.String("consumer", cfg.NATSConsumer))
+
+	err = consumer.Run(ctx, func(ctx context.Context, e events.Event) error {
+		logger.InfoContext(ctx, "task event processed",
+			slog.String("event_id", e.ID),
+			slog.String("type", string(e.Type)),
+			slog.Int("version", e.Version),
+			slog.String("task_id", e.TaskID),
+		)
+		return nil
+	})
+	if err != nil {
+		logger.Error("consumer error", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	logger.Info("task consumer stopped")
+}
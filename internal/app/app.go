@@ -0,0 +1,857 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/async"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/audit"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/config"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/dedup"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/eventbus"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/feature"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/handler"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/hedge"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/idgen"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jobs"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/notifier"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/overload"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/pagination"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/quota"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/reminder"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/retry"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/saga"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/scheduler"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/seed"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/snapshot"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/storage"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/tenant"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/ui"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/warmup"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/webhook"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// shutdownTimeout is the default time each lifecycle hook gets to stop.
+// The servers drain for ShutdownDrainTimeout and the telemetry providers
+// flush for TelemetryShutdownTimeout instead.
+const shutdownTimeout = 30 * time.Second
+
+// App wires the task API server and its subsystems.
+type App struct {
+	cfg       *config.Config
+	logger    *slog.Logger
+	lifecycle *Lifecycle
+
+	// flushers are the telemetry providers by signal, for manual flushes.
+	flushers map[string]handler.Flusher
+
+	// sampler is the adaptive trace sampler, nil unless enabled.
+	sampler *telemetry.AdaptiveSampler
+	// logs keeps the recent log records for support bundles, nil unless
+	// enabled.
+	logs *telemetry.LogBuffer
+	// tenants holds the per-tenant sampling and rate limit policies.
+	tenants *tenant.Policies
+	// cardinality reports metric series per instrument, nil unless enabled.
+	cardinality *telemetry.CardinalityReporter
+	// shedder sheds requests by priority under overload, nil unless
+	// enabled.
+	shedder *overload.Shedder
+
+	// errs receives fatal errors of running subsystems, e.g. a server that
+	// stopped serving.
+	errs chan error
+}
+
+// New initializes telemetry and all subsystems. Subsystems are registered
+// with the lifecycle and only started by Run. If New fails, everything
+// initialized so far is shut down.
+func New(ctx context.Context, cfg *config.Config, startupLogger *slog.Logger) (_ *App, err error) {
+	a := &App{
+		cfg:       cfg,
+		logger:    startupLogger,
+		lifecycle: NewLifecycle(startupLogger, shutdownTimeout),
+		errs:      make(chan error, 1),
+	}
+	defer func() {
+		if err != nil {
+			_ = a.lifecycle.Stop(context.Background())
+		}
+	}()
+
+	conns, degradation, err := a.initTelemetry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger := a.logger
+
+	meter := otel.Meter(cfg.ServiceName)
+
+	// Initialize task repository, optionally mirroring writes to a secondary
+	taskRepo, err := newTaskRepository(cfg, logger, meter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create metrics instruments
+	metrics, err := telemetry.NewMetrics(meter, taskRepo.Count, taskRepo.CountByState, cfg.HTTPMetricsLegacy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics: %w", err)
+	}
+	if err := telemetry.RegisterConnStateGauge(meter, conns); err != nil {
+		return nil, fmt.Errorf("failed to create connection state gauge: %w", err)
+	}
+	if err := telemetry.RegisterAssigneeGauge(meter, taskRepo.OpenByAssignee, int(cfg.AssigneeGaugeTopN)); err != nil {
+		return nil, err
+	}
+	if cfg.HostMetricsEnabled {
+		if err := telemetry.RegisterHostMetrics(otel.GetMeterProvider(), meter); err != nil {
+			return nil, err
+		}
+	}
+	if a.sampler != nil {
+		if err := telemetry.RegisterSamplingRatioGauge(meter, a.sampler); err != nil {
+			return nil, err
+		}
+	}
+	if a.cardinality != nil {
+		if err := telemetry.RegisterCardinalityGauge(meter, a.cardinality); err != nil {
+			return nil, err
+		}
+	}
+
+	// Optionally expose channelz for diagnosing exporter connectivity
+	if cfg.ChannelzAddr != "" {
+		channelzServer, err := telemetry.StartChannelzServer(cfg.ChannelzAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start channelz server: %w", err)
+		}
+		a.lifecycle.Register(Hook{
+			Name: "channelz",
+			Stop: func(context.Context) error {
+				channelzServer.GracefulStop()
+				return nil
+			},
+		})
+		logger.Info("channelz listening", slog.String("addr", cfg.ChannelzAddr))
+	}
+
+	// Optionally push continuous profiles to Pyroscope
+	if cfg.ProfilingEnabled {
+		profiler, err := telemetry.StartProfiler(cfg.ServiceName, cfg.Environment, telemetry.Profiling{
+			ServerAddress:     cfg.ProfilingServerAddress,
+			ProfileTypes:      cfg.ProfilingProfileTypes,
+			UploadInterval:    cfg.ProfilingUploadInterval,
+			BasicAuthUser:     cfg.ProfilingBasicAuthUser,
+			BasicAuthPassword: cfg.ProfilingBasicAuthPassword,
+		}, logger)
+		if err != nil {
+			return nil, err
+		}
+		a.lifecycle.Register(Hook{
+			Name: "profiler",
+			Stop: func(context.Context) error { return profiler.Stop() },
+		})
+		logger.Info("continuous profiling enabled",
+			slog.String("server_address", cfg.ProfilingServerAddress),
+			slog.Any("profile_types", cfg.ProfilingProfileTypes),
+		)
+	}
+
+	// Optionally share the tenant rate limits between replicas through Redis
+	switch cfg.TenantRateLimiter {
+	case "local":
+	case "redis":
+		url := cfg.TenantRateLimiterRedisURL
+		if url == "" {
+			url = cfg.RedisURL
+		}
+		if url == "" {
+			return nil, fmt.Errorf("tenant rate limiter %q requires TENANT_RATE_LIMITER_REDIS_URL or REDIS_URL", cfg.TenantRateLimiter)
+		}
+		limiter, err := tenant.NewRedisLimiter(url, "ratelimit:tenant:")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Redis rate limiter: %w", err)
+		}
+		a.tenants.SetLimiter(limiter)
+		a.lifecycle.Register(Hook{
+			Name: "redis-rate-limiter",
+			Stop: func(context.Context) error { return limiter.Close() },
+		})
+	default:
+		return nil, fmt.Errorf("unknown tenant rate limiter %q, must be one of local, redis", cfg.TenantRateLimiter)
+	}
+
+	// Initialize audit recorder with its own log scope (and optional audit table)
+	var auditStore *audit.Store
+	if cfg.AuditStoreEnabled {
+		if auditStore, err = audit.NewStore(int(cfg.AuditStoreSize)); err != nil {
+			return nil, fmt.Errorf("failed to create audit store: %w", err)
+		}
+	}
+	auditor := audit.NewRecorder(slog.New(telemetry.NewLogHandler(audit.ScopeName)), auditStore)
+
+	// Initialize request body decoder with size limits and optional strict mode
+	decoder, err := handler.NewRequestDecoder(meter, cfg.MaxRequestBodyBytes, cfg.StrictJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request decoder: %w", err)
+	}
+
+	// Retry policy of outbound calls to other services
+	outboundRetry := retry.Policy{
+		MaxAttempts: int(cfg.OutboundMaxAttempts),
+		Backoff:     cfg.OutboundRetryBackoff,
+		MaxBackoff:  cfg.OutboundRetryMaxBackoff,
+		Jitter:      cfg.RetryJitter,
+	}
+
+	// Initialize the downstream notifier client
+	var notifyClient *notifier.Client
+	if cfg.NotifierURL != "" {
+		var hedger *hedge.Hedger
+		if cfg.NotifierHedgingEnabled {
+			hedger, err = hedge.NewHedger("NotifierClient.Request", meter, cfg.NotifierHedgeMinDelay,
+				attribute.String("peer.service", notifier.PeerService))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create notifier hedger: %w", err)
+			}
+		}
+		notifyClient, err = notifier.NewClient(cfg.NotifierURL, meter, outboundRetry, hedger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create notifier client: %w", err)
+		}
+	}
+
+	// Initialize the task event publishers (NATS JetStream, Redis Streams)
+	var publishers eventbus.MultiPublisher
+	if cfg.NATSURL != "" {
+		natsPublisher, err := eventbus.NewNATSPublisher(ctx, eventbus.NATSConfig{
+			URL:           cfg.NATSURL,
+			Stream:        cfg.NATSStream,
+			SubjectPrefix: cfg.NATSSubjectPrefix,
+			PublishRetry:  outboundRetry,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create NATS publisher: %w", err)
+		}
+		a.lifecycle.Register(Hook{
+			Name: "nats-publisher",
+			Stop: func(context.Context) error {
+				natsPublisher.Close()
+				return nil
+			},
+		})
+		publishers = append(publishers, natsPublisher)
+	}
+	if cfg.RedisURL != "" {
+		redisPublisher, err := eventbus.NewRedisPublisher(ctx, eventbus.RedisConfig{
+			URL:    cfg.RedisURL,
+			Stream: cfg.RedisStream,
+			MaxLen: cfg.RedisStreamMaxLen,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Redis publisher: %w", err)
+		}
+		a.lifecycle.Register(Hook{
+			Name: "redis-publisher",
+			Stop: func(context.Context) error {
+				return redisPublisher.Close()
+			},
+		})
+		publishers = append(publishers, redisPublisher)
+	}
+	var publisher eventbus.Publisher
+	switch len(publishers) {
+	case 0:
+	case 1:
+		publisher = publishers[0]
+	default:
+		publisher = publishers
+	}
+
+	// Initialize per-user and per-tenant task quotas
+	quotas, err := quota.NewManager(taskRepo, meter, cfg.QuotaMaxTasksPerUser, cfg.QuotaMaxTasksPerTenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quota manager: %w", err)
+	}
+
+	// Initialize OpenFeature flags (file and FEATURE_FLAG_* environment variables)
+	flags, err := feature.New(cfg.FeatureFlagsFile, meter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize feature flags: %w", err)
+	}
+
+	// Initialize attachment storage (local disk or S3/MinIO)
+	objectStore, err := newObjectStore(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize attachment storage: %w", err)
+	}
+
+	// Initialize task snapshots, written to the attachment storage
+	snapshots, err := snapshot.NewSnapshotter(taskRepo, objectStore, meter, cfg.SnapshotPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshotter: %w", err)
+	}
+	var snapshotSchedule *scheduler.CronSchedule
+	if cfg.SnapshotSchedule != "" {
+		snapshotSchedule, err = scheduler.ParseCron(cfg.SnapshotSchedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize the task service holding the business rules
+	sagas, err := saga.NewCoordinator(meter)
+	if err != nil {
+		return nil, err
+	}
+	taskService := service.NewTaskService(taskRepo, logger, auditor, notifyClient, publisher, quotas, flags, sagas)
+	templateService, err := service.NewTemplateService(repository.NewTemplateRepository(), taskService, logger, meter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize handlers
+	cursors, err := pagination.NewCodec(cfg.CursorSecret, meter)
+	if err != nil {
+		return nil, err
+	}
+	projector, err := handler.NewProjector(meter)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := handler.NewEndpoints(decoder, meter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create handler endpoints: %w", err)
+	}
+	var deduplicator *dedup.Deduplicator
+	if cfg.DedupWindow > 0 {
+		deduplicator, err = dedup.NewDeduplicator(cfg.DedupWindow, cfg.MaxRequestBodyBytes, meter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	taskHandler := handler.NewTaskHandler(taskService, endpoints, cursors, projector, deduplicator)
+	templateHandler := handler.NewTemplateHandler(templateService, decoder)
+	attachmentHandler, err := handler.NewAttachmentHandler(taskRepo, objectStore, meter, cfg.AttachmentMaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attachment handler: %w", err)
+	}
+	importHandler, err := handler.NewImportHandler(taskService, meter, int(cfg.ImportBatchSize), int(cfg.ImportConcurrency), cfg.ImportMaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import handler: %w", err)
+	}
+	transitionHandler, err := handler.NewTransitionHandler(taskService, decoder, meter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transition handler: %w", err)
+	}
+
+	// Create tasks from the webhooks of the sources with a secret
+	var webhookHandler *handler.WebhookHandler
+	if cfg.WebhookSecrets != "" {
+		secrets, err := webhook.ParseSecrets(cfg.WebhookSecrets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_SECRETS: %w", err)
+		}
+		receiver, err := webhook.NewReceiver(secrets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create webhook receiver: %w", err)
+		}
+		if webhookHandler, err = handler.NewWebhookHandler(taskService, receiver, meter, cfg.MaxRequestBodyBytes); err != nil {
+			return nil, fmt.Errorf("failed to create webhook handler: %w", err)
+		}
+	}
+
+	changesHandler, err := handler.NewChangesHandler(taskRepo, meter, cfg.ChangesMaxWait)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create changes handler: %w", err)
+	}
+
+	queue, err := jobs.NewQueue(logger, meter, int(cfg.JobWorkers))
+	if err != nil {
+		return nil, err
+	}
+
+	uiHandler, err := ui.NewHandler(cfg.TraceUIURLTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ui handler: %w", err)
+	}
+
+	// Shed low-priority requests first when the server is overloaded
+	if cfg.OverloadSheddingEnabled {
+		a.shedder, err = overload.NewShedder(overload.Config{
+			MaxInFlight:      cfg.OverloadMaxInFlight,
+			LatencyThreshold: cfg.OverloadLatencyThreshold,
+			Interval:         cfg.OverloadInterval,
+		}, meter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create load shedder: %w", err)
+		}
+	}
+
+	router, err := a.newRouter(meter, metrics, routes{
+		health:      handler.NewHealthHandler(conns, degradation, cfg.ReadinessRequireExporters),
+		tasks:       taskHandler,
+		tasksV2:     handler.NewTaskV2Handler(taskService, decoder, cursors),
+		templates:   templateHandler,
+		attachments: attachmentHandler,
+		imports:     importHandler,
+		transitions: transitionHandler,
+		exports:     handler.NewExportHandler(taskService),
+		changes:     changesHandler,
+		stats:       handler.NewStatsHandler(taskRepo, cfg.StatsCacheTTL),
+		audit:       auditStore,
+		quotas:      handler.NewQuotaHandler(quotas, decoder),
+		tenants:     handler.NewTenantHandler(a.tenants, decoder),
+		telemetry:   handler.NewTelemetryHandler(a.flushers),
+		snapshots:   handler.NewSnapshotHandler(snapshots, queue),
+		jobs:        handler.NewJobHandler(queue),
+		config:      handler.NewConfigHandler(cfg),
+		support:     handler.NewSupportHandler(cfg, a.logs),
+		rum:         handler.NewRUMHandler(decoder, cfg.RUMBaggageKeys),
+		webhooks:    webhookHandler,
+		ui:          uiHandler,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Seed demo tasks before the servers start, so the first requests
+	// already see them
+	if cfg.SeedEnabled {
+		fixture, err := seed.Load(cfg.SeedFile)
+		if err != nil {
+			return nil, err
+		}
+		a.lifecycle.Register(Hook{
+			Name: "seed",
+			Start: func(ctx context.Context) error {
+				seed.Run(ctx, taskService, taskRepo.Count, fixture)
+				return nil
+			},
+		})
+	}
+
+	serverHandler, err := a.registerServers(router, meter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send synthetic requests so dashboards show traffic right after a
+	// deploy; registered after the servers so it runs once they are up
+	if cfg.WarmupEnabled {
+		a.registerWorker("warm-up", func(ctx context.Context) {
+			warmup.Run(ctx, serverHandler, warmup.Config{
+				Iterations:  int(cfg.WarmupIterations),
+				Concurrency: int(cfg.WarmupConcurrency),
+			})
+		})
+	}
+
+	// Run background jobs; registered after the servers so running jobs are
+	// cancelled before HTTP connections are drained
+	a.registerWorker("job-queue", queue.Run)
+
+	// Adjust the adaptive sampling ratio to the requests served
+	if a.sampler != nil {
+		a.registerWorker("adaptive-sampler", a.sampler.Run)
+	}
+
+	// Measure the p99 latency the load level follows
+	if a.shedder != nil {
+		a.registerWorker("load-shedder", a.shedder.Run)
+	}
+
+	// Report metric cardinality to catch attribute explosions
+	if a.cardinality != nil {
+		a.registerWorker("cardinality-report", a.cardinality.Run)
+	}
+
+	// Start the recurring task scheduler; registered after the servers so
+	// background workers stop before HTTP connections are drained
+	if cfg.SchedulerEnabled {
+		recurring, err := scheduler.NewRecurringScheduler(taskRepo, logger, meter, cfg.SchedulerInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create recurring scheduler: %w", err)
+		}
+		a.registerWorker("recurring-scheduler", recurring.Run)
+
+		if cfg.ReminderChannel != "" {
+			sender, err := reminder.NewSender(reminder.Config{
+				Channel:    cfg.ReminderChannel,
+				WebhookURL: cfg.ReminderWebhookURL,
+				EmailFrom:  cfg.ReminderEmailFrom,
+				EmailTo:    cfg.ReminderEmailTo,
+			}, logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create reminder sender: %w", err)
+			}
+			reminders, err := scheduler.NewReminderScheduler(taskRepo, sender, logger, meter,
+				cfg.SchedulerInterval, retry.Policy{
+					MaxAttempts: int(cfg.ReminderMaxAttempts),
+					Backoff:     cfg.ReminderRetryBackoff,
+					Jitter:      cfg.RetryJitter,
+				})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create reminder scheduler: %w", err)
+			}
+			a.registerWorker("reminder-scheduler", reminders.Run)
+		}
+
+		if snapshotSchedule != nil {
+			a.registerWorker("snapshot-scheduler", scheduler.NewSnapshotScheduler(snapshots, snapshotSchedule, logger).Run)
+		}
+	}
+
+	return a, nil
+}
+
+// Run starts all subsystems and blocks until ctx is cancelled or a
+// subsystem fails, then stops them in reverse order. Errors while stopping
+// are logged, not returned.
+func (a *App) Run(ctx context.Context) error {
+	if err := a.lifecycle.Start(ctx); err != nil {
+		return err
+	}
+	a.logStartup(ctx)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		a.logger.Info("shutting down server...")
+	case runErr = <-a.errs:
+		a.logger.Error("subsystem failed, shutting down", slog.Any("error", runErr))
+	}
+
+	if err := a.lifecycle.Stop(context.WithoutCancel(ctx)); err != nil {
+		a.logger.Error("shutdown incomplete", slog.Any("error", err))
+	}
+	a.logger.Info("server stopped")
+	return runErr
+}
+
+// initTelemetry initializes the tracer, meter and logger providers and
+// registers their shutdown. It switches a.logger to the bridged logger and
+// keeps the providers in a.flushers.
+// The returned Degradation is nil unless a telemetry fallback is configured.
+func (a *App) initTelemetry(ctx context.Context) (*telemetry.ExporterConns, *telemetry.Degradation, error) {
+	cfg := a.cfg
+
+	// Track exporter gRPC connections for readiness and connection state
+	// metrics, optionally sharing one connection between the signals. They
+	// are closed after the providers, which are registered later.
+	conns := telemetry.NewExporterConns(cfg.OTLPSharedConnection)
+	a.lifecycle.Register(Hook{Name: "otlp-connections", Stop: conns.Close, Phase: PhaseFlushTelemetry, Timeout: cfg.TelemetryShutdownTimeout})
+
+	// Count and warn about spans and log records dropped on full queues
+	drops := telemetry.NewDropReporter(cfg.TelemetryDropWarnInterval)
+
+	traceOpts := []telemetry.TracerOption{
+		telemetry.WithTraceExporter(cfg.TracesExporter, cfg.TracesExporterEndpoint),
+		telemetry.WithSampleRatio(cfg.TracesSampleRatio),
+		telemetry.WithTraceOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPTracesHeaders,
+			Compression: cfg.OTLPTracesCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithBatchConfig(telemetry.BatchConfig{
+			Timeout:            cfg.TracesBatchTimeout,
+			MaxExportBatchSize: int(cfg.TracesMaxExportBatchSize),
+			MaxQueueSize:       int(cfg.TracesMaxQueueSize),
+			Blocking:           cfg.TracesBlockOnQueueFull,
+			BlockTimeout:       cfg.TelemetryBlockTimeout,
+		}),
+		telemetry.WithTraceDropReporter(drops),
+		telemetry.WithSpanLimits(telemetry.SpanLimits{
+			AttributeCount:       int(cfg.SpanAttributeCountLimit),
+			AttributeValueLength: int(cfg.SpanAttributeValueLengthLimit),
+			EventCount:           int(cfg.SpanEventCountLimit),
+		}),
+		telemetry.WithDeploymentAttributes(cfg.SpanDeploymentAttributes),
+	}
+	meterOpts := []telemetry.MeterOption{
+		telemetry.WithMetricOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPMetricsHeaders,
+			Compression: cfg.OTLPMetricsCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithTemporality(cfg.MetricsTemporality),
+		telemetry.WithHistogramAggregation(cfg.MetricsHistogramAggregation, cfg.MetricsDurationBuckets),
+		telemetry.WithExportInterval(cfg.MetricsExportInterval),
+	}
+	loggerOpts := []telemetry.LoggerOption{
+		telemetry.WithLogLevel(cfg.LogLevel),
+		telemetry.WithLogOTLP(telemetry.OTLPConfig{
+			Headers:     cfg.OTLPLogsHeaders,
+			Compression: cfg.OTLPLogsCompression,
+			Insecure:    cfg.OTLPInsecure,
+		}),
+		telemetry.WithLogBatchConfig(telemetry.BatchConfig{
+			MaxQueueSize: int(cfg.LogsMaxQueueSize),
+			Blocking:     cfg.LogsBlockOnQueueFull,
+			BlockTimeout: cfg.TelemetryBlockTimeout,
+		}),
+		telemetry.WithLogDropReporter(drops),
+	}
+
+	// Optionally adapt the sampling ratio to the SLO burn rate
+	if cfg.AdaptiveSamplingEnabled {
+		sampler, err := telemetry.NewAdaptiveSampler(telemetry.AdaptiveSampling{
+			BaseRatio:        cfg.TracesSampleRatio,
+			MaxRatio:         cfg.AdaptiveSamplingMaxRatio,
+			SLOTarget:        cfg.AdaptiveSamplingSLOTarget,
+			LatencyThreshold: cfg.AdaptiveSamplingLatencyThreshold,
+			BurnRate:         cfg.AdaptiveSamplingBurnRate,
+			Interval:         cfg.AdaptiveSamplingInterval,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to configure adaptive sampling: %w", err)
+		}
+		a.sampler = sampler
+		traceOpts = append(traceOpts, telemetry.WithAdaptiveSampler(sampler))
+	}
+
+	// Sample the new traces of tenants with their own ratio
+	tenants, err := newTenantPolicies(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	a.tenants = tenants
+	traceOpts = append(traceOpts, telemetry.WithTenantSampling(tenants.SampleRatio))
+
+	// Keep the recent log records for support bundles
+	if cfg.SupportLogBufferSize > 0 {
+		a.logs = telemetry.NewLogBuffer(int(cfg.SupportLogBufferSize))
+		loggerOpts = append(loggerOpts, telemetry.WithLogBuffer(a.logs))
+	}
+
+	// Optionally report the number of metric series per instrument
+	if cfg.CardinalityReportEnabled {
+		cardinality, err := telemetry.NewCardinalityReporter(telemetry.CardinalityReport{
+			Interval:      cfg.CardinalityReportInterval,
+			TopN:          int(cfg.CardinalityReportTopN),
+			WarnThreshold: int(cfg.CardinalityWarnThreshold),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to configure cardinality report: %w", err)
+		}
+		a.cardinality = cardinality
+		meterOpts = append(meterOpts, telemetry.WithCardinalityReporter(cardinality))
+	}
+
+	// Optionally measure the duration and payload size of the OTLP exports.
+	// The instruments are created on the global meter, which forwards them
+	// to the meter provider once it is initialized below.
+	if cfg.TelemetryExportStatsEnabled {
+		stats, err := telemetry.NewExportStats(otel.Meter(cfg.ServiceName))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to configure telemetry export stats: %w", err)
+		}
+		traceOpts = append(traceOpts, telemetry.WithTraceExportStats(stats))
+		meterOpts = append(meterOpts, telemetry.WithMetricExportStats(stats))
+		loggerOpts = append(loggerOpts, telemetry.WithLogExportStats(stats))
+	}
+
+	// Optionally fall back to stdout (or drop) while the collector is unavailable
+	var degradation *telemetry.Degradation
+	if cfg.TelemetryFallback != "" {
+		var err error
+		degradation, err = telemetry.NewDegradation(cfg.TelemetryFallback, cfg.TelemetryProbeInterval)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to configure telemetry fallback: %w", err)
+		}
+		traceOpts = append(traceOpts, telemetry.WithTraceFallback(degradation))
+		meterOpts = append(meterOpts, telemetry.WithMetricFallback(degradation))
+		loggerOpts = append(loggerOpts, telemetry.WithLogFallback(degradation))
+	}
+
+	// Optionally mirror signals to a second collector
+	mirror := telemetry.Mirror{
+		Endpoint: cfg.OTLPMirrorEndpoint,
+		Signals:  cfg.OTLPMirrorSignals,
+		OTLP: telemetry.OTLPConfig{
+			Headers:     cfg.OTLPMirrorHeaders,
+			Compression: cfg.OTLPMirrorCompression,
+			Insecure:    cfg.OTLPInsecure,
+		},
+		Meter: otel.Meter(cfg.ServiceName),
+	}
+	traceOpts = append(traceOpts, telemetry.WithTraceMirror(mirror))
+	meterOpts = append(meterOpts, telemetry.WithMetricMirror(mirror))
+	loggerOpts = append(loggerOpts, telemetry.WithLogMirror(mirror))
+
+	// Optionally write OTLP JSON files instead of exporting to a collector.
+	// The file collector is registered first so it stops after the providers.
+	if cfg.TelemetryFileDir != "" {
+		collector, err := telemetry.NewFileCollector(cfg.TelemetryFileDir, cfg.TelemetryFileMaxBytes, int(cfg.TelemetryFileMaxBackups))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to start telemetry file collector: %w", err)
+		}
+		a.lifecycle.Register(Hook{Name: "telemetry-file-collector", Stop: collector.Shutdown, Phase: PhaseFlushTelemetry, Timeout: cfg.TelemetryShutdownTimeout})
+		traceOpts = append(traceOpts, telemetry.WithTraceFileCollector(collector))
+		meterOpts = append(meterOpts, telemetry.WithMetricFileCollector(collector))
+		loggerOpts = append(loggerOpts, telemetry.WithLogFileCollector(collector))
+	}
+
+	// Initialize OpenTelemetry tracer provider
+	tp, err := telemetry.InitTracerProvider(ctx, cfg.ServiceName, cfg.OTLPTracesEndpoint, cfg.Environment, conns, traceOpts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize tracer provider: %w", err)
+	}
+	a.lifecycle.Register(Hook{Name: "tracer-provider", Stop: tp.Shutdown, Phase: PhaseFlushTelemetry, Timeout: cfg.TelemetryShutdownTimeout})
+
+	// Initialize OpenTelemetry meter provider
+	mp, err := telemetry.InitMeterProvider(ctx, cfg.ServiceName, cfg.OTLPMetricsEndpoint, cfg.Environment, conns, meterOpts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize meter provider: %w", err)
+	}
+	a.lifecycle.Register(Hook{Name: "meter-provider", Stop: mp.Shutdown, Phase: PhaseFlushTelemetry, Timeout: cfg.TelemetryShutdownTimeout})
+
+	// Initialize OpenTelemetry logger provider (after other providers for log-trace correlation)
+	lp, logger, err := telemetry.InitLoggerProvider(ctx, cfg.ServiceName, cfg.OTLPLogsEndpoint, cfg.Environment, conns, loggerOpts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize logger provider: %w", err)
+	}
+	a.lifecycle.Register(Hook{Name: "logger-provider", Stop: lp.Shutdown, Phase: PhaseFlushTelemetry, Timeout: cfg.TelemetryShutdownTimeout})
+
+	if err := telemetry.RegisterDroppedCounter(otel.Meter(cfg.ServiceName), drops); err != nil {
+		return nil, nil, err
+	}
+
+	a.logger = logger
+	a.lifecycle.logger = logger
+	a.flushers = map[string]handler.Flusher{"traces": tp, "metrics": mp, "logs": lp}
+	return conns, degradation, nil
+}
+
+// registerWorker registers a background worker that runs until stopped.
+// The worker runs in an App.Worker span below its start hook; a panic ends
+// the worker and is recorded on that span.
+func (a *App) registerWorker(name string, run func(ctx context.Context)) {
+	var (
+		cancel context.CancelFunc
+		done   <-chan struct{}
+	)
+	a.lifecycle.Register(Hook{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			// The worker outlives the start timeout, so only keep the values.
+			var workerCtx context.Context
+			workerCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+			done = async.Go(workerCtx, "App.Worker", run, async.WithSpanOptions(
+				trace.WithAttributes(attribute.String("worker.name", name)),
+			))
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// fail reports a fatal error of a running subsystem to Run.
+func (a *App) fail(err error) {
+	select {
+	case a.errs <- err:
+	default:
+	}
+}
+
+// newTaskRepository returns the in-memory task repository or, in dual-write
+// mode, a repository mirroring its writes to a second in-memory repository.
+// Each backend records operation metrics, and reads are optionally coalesced.
+func newTaskRepository(cfg *config.Config, logger *slog.Logger, meter metric.Meter) (repository.Repository, error) {
+	newID, err := idgen.New(cfg.TaskIDStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := repository.NewMetricsRepository(repository.NewTaskRepository(newID, cfg.UniqueTaskTitles), meter, repository.BackendMemory, "primary")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository metrics: %w", err)
+	}
+
+	var repo repository.Repository = primary
+	if cfg.DualWriteEnabled {
+		secondary, err := repository.NewMetricsRepository(repository.NewTaskRepository(newID, cfg.UniqueTaskTitles), meter, repository.BackendMemory, "secondary")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create repository metrics: %w", err)
+		}
+		dual, err := repository.NewDualWriteRepository(repo, secondary, logger, meter, cfg.DualWriteReadFrom == "secondary")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dual write repository: %w", err)
+		}
+		logger.Info("dual write enabled", slog.String("read_from", cfg.DualWriteReadFrom))
+		repo = dual
+	}
+
+	if cfg.RepositoryMaxTasks > 0 {
+		bounded, err := repository.NewBoundedRepository(repo, logger, meter, int(cfg.RepositoryMaxTasks), repository.FullPolicy(cfg.RepositoryFullPolicy))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bounded repository: %w", err)
+		}
+		logger.Info("task repository bounded",
+			slog.Int64("max_tasks", cfg.RepositoryMaxTasks),
+			slog.String("full_policy", cfg.RepositoryFullPolicy),
+		)
+		repo = bounded
+	}
+
+	if cfg.ReadCoalescingEnabled {
+		coalescing, err := repository.NewCoalescingRepository(repo, meter, cfg.NegativeCacheTTL, repository.Consistency(cfg.CacheConsistency), cfg.ReadYourWritesWindow)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create coalescing repository: %w", err)
+		}
+		repo = coalescing
+	}
+	return repo, nil
+}
+
+func newObjectStore(ctx context.Context, cfg *config.Config) (storage.ObjectStore, error) {
+	switch cfg.AttachmentStorage {
+	case "s3":
+		return storage.NewS3Store(ctx, storage.S3Config{
+			Bucket:       cfg.S3Bucket,
+			Region:       cfg.S3Region,
+			Endpoint:     cfg.S3Endpoint,
+			UsePathStyle: cfg.S3UsePathStyle,
+		})
+	default:
+		return storage.NewLocalStore(cfg.AttachmentDir)
+	}
+}
+
+// newTenantPolicies creates the tenant policies with the configured default
+// rate limit and the overrides of the tenant policies file, if any.
+func newTenantPolicies(cfg *config.Config) (*tenant.Policies, error) {
+	var overrides map[string]tenant.Policy
+	if cfg.TenantPoliciesFile != "" {
+		var err error
+		if overrides, err = tenant.LoadFile(cfg.TenantPoliciesFile); err != nil {
+			return nil, err
+		}
+	}
+	policies, err := tenant.NewPolicies(tenant.Policy{
+		RateLimit: cfg.TenantRateLimit,
+		Burst:     int(cfg.TenantRateBurst),
+	}, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure tenant policies: %w", err)
+	}
+	return policies, nil
+}
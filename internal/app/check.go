@@ -0,0 +1,178 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/config"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/scheduler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// checkTimeout bounds each step of the self-test.
+const checkTimeout = 5 * time.Second
+
+// Check runs the startup self-test: it validates the configuration, checks
+// that the OTLP endpoints accept connections, initializes telemetry,
+// writes, reads and deletes a probe object in the attachment storage and
+// exports a test span, metric and log record. Every step runs even if an
+// earlier one failed; each is logged to startupLogger and the failures are
+// returned joined. Telemetry is shut down before Check returns.
+func Check(ctx context.Context, cfg *config.Config, startupLogger *slog.Logger) (err error) {
+	a := &App{
+		cfg:       cfg,
+		logger:    startupLogger,
+		lifecycle: NewLifecycle(startupLogger, shutdownTimeout),
+		errs:      make(chan error, 1),
+	}
+	defer func() {
+		// Bound the whole shutdown, which waits for exports to time out if
+		// the collector is down
+		stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), checkTimeout)
+		defer cancel()
+		if stopErr := a.lifecycle.Stop(stopCtx); stopErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to shut down telemetry: %w", stopErr))
+		}
+	}()
+
+	var errs []error
+	step := func(name string, fn func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		defer cancel()
+
+		start := time.Now()
+		if err := fn(ctx); err != nil {
+			startupLogger.Error("self-check step failed", slog.String("step", name), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		startupLogger.Info("self-check step passed", slog.String("step", name), slog.Duration("duration", time.Since(start)))
+	}
+
+	step("config", func(context.Context) error {
+		if cfg.SnapshotSchedule != "" {
+			if _, err := scheduler.ParseCron(cfg.SnapshotSchedule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	// The in-process file collector replaces the OTLP endpoints, but not
+	// the mirror
+	var endpoints []string
+	if cfg.TelemetryFileDir == "" {
+		endpoints = append(endpoints, cfg.OTLPTracesEndpoint, cfg.OTLPMetricsEndpoint, cfg.OTLPLogsEndpoint)
+	}
+	if cfg.OTLPMirrorEndpoint != "" && len(cfg.OTLPMirrorSignals) > 0 {
+		endpoints = append(endpoints, cfg.OTLPMirrorEndpoint)
+	}
+	slices.Sort(endpoints)
+	if endpoints = slices.Compact(endpoints); len(endpoints) > 0 {
+		step("otlp_endpoint", func(ctx context.Context) error {
+			var errs []error
+			for _, endpoint := range endpoints {
+				errs = append(errs, checkReachable(ctx, endpoint))
+			}
+			return errors.Join(errs...)
+		})
+	}
+
+	// The providers live until Check returns, so they are initialized with
+	// ctx rather than the step context
+	var telemetryErr error
+	step("telemetry", func(context.Context) error {
+		_, _, telemetryErr = a.initTelemetry(ctx)
+		return telemetryErr
+	})
+
+	step("storage", func(ctx context.Context) error {
+		return checkObjectStore(ctx, cfg)
+	})
+
+	if telemetryErr == nil {
+		step("test_signals", a.emitTestSignals)
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkReachable dials endpoint, a gRPC target such as localhost:4317 or
+// dns:///collector:4317.
+func checkReachable(ctx context.Context, endpoint string) error {
+	if _, target, ok := strings.Cut(endpoint, "://"); ok {
+		endpoint = strings.TrimLeft(target, "/")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", endpoint, err)
+	}
+	return conn.Close()
+}
+
+// checkObjectStore writes, reads back and deletes a probe object, which
+// needs read and write access to the configured store.
+func checkObjectStore(ctx context.Context, cfg *config.Config) error {
+	store, err := newObjectStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize attachment storage: %w", err)
+	}
+
+	const probe = "self-check"
+	key := fmt.Sprintf("selfcheck-%d", time.Now().UnixNano())
+	if _, err := store.Put(ctx, key, strings.NewReader(probe), "text/plain"); err != nil {
+		return fmt.Errorf("failed to write probe object: %w", err)
+	}
+	defer store.Delete(context.WithoutCancel(ctx), key)
+
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read probe object: %w", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read probe object: %w", err)
+	}
+	if string(got) != probe {
+		return fmt.Errorf("probe object read back as %q", got)
+	}
+	return nil
+}
+
+// emitTestSignals records an App.Check span, a self_check_runs_total
+// measurement and a log record, then flushes every provider, so export
+// errors fail the step.
+func (a *App) emitTestSignals(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "App.Check")
+
+	runs, err := otel.Meter(a.cfg.ServiceName).Int64Counter(
+		"self_check_runs_total",
+		metric.WithDescription("Total number of startup self-tests run"),
+		metric.WithUnit("{run}"),
+	)
+	if err != nil {
+		span.End()
+		return fmt.Errorf("failed to create self-check counter: %w", err)
+	}
+	runs.Add(ctx, 1)
+	a.logger.InfoContext(ctx, "self-check test record")
+	span.End()
+
+	var errs []error
+	for signal, f := range a.flushers {
+		if err := f.ForceFlush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to export %s: %w", signal, err))
+		}
+	}
+	return errors.Join(errs...)
+}
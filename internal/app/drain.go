@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// drainLogInterval is how often the progress of a drain is logged.
+const drainLogInterval = time.Second
+
+// drainer counts the in-flight requests of the servers and reports them
+// while the servers drain on shutdown.
+type drainer struct {
+	logger   *slog.Logger
+	inFlight atomic.Int64
+	draining atomic.Bool
+}
+
+// newDrainer creates a new drainer and registers the server_inflight_requests
+// gauge, labelled with whether the servers are draining.
+func newDrainer(logger *slog.Logger, meter metric.Meter) (*drainer, error) {
+	d := &drainer{logger: logger}
+	_, err := meter.Int64ObservableGauge(
+		"server_inflight_requests",
+		metric.WithDescription("Number of requests being served, by whether the servers are draining for shutdown"),
+		metric.WithUnit("{request}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(d.inFlight.Load(), metric.WithAttributes(attribute.Bool("server.draining", d.draining.Load())))
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create in-flight requests gauge: %w", err)
+	}
+	return d, nil
+}
+
+// Handler returns next counting its in-flight requests.
+func (d *drainer) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.inFlight.Add(1)
+		defer d.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StopAccepting marks the servers as draining and closes ln, so no new
+// connections are accepted while the in-flight requests finish.
+func (d *drainer) StopAccepting(ctx context.Context, ln net.Listener) error {
+	d.draining.Store(true)
+	n := d.inFlight.Load()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("server.inflight_requests", n))
+	d.logger.InfoContext(ctx, "stopped accepting connections", slog.Int64("in_flight", n))
+	if ln == nil {
+		return nil
+	}
+	return ln.Close()
+}
+
+// Drain calls shutdown, which waits for the in-flight requests of a server
+// until ctx is done, logging the requests still in flight every
+// drainLogInterval. The hook span records the requests in flight before and
+// after.
+func (d *drainer) Drain(ctx context.Context, shutdown func(context.Context) error) error {
+	d.draining.Store(true)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("server.inflight_requests", d.inFlight.Load()))
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { // async:ok: shutdown is bounded by ctx and awaited below
+		done <- shutdown(ctx)
+	}()
+
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			remaining := d.inFlight.Load()
+			span.SetAttributes(attribute.Int64("server.inflight_requests.remaining", remaining))
+			if err != nil {
+				d.logger.WarnContext(ctx, "drain timed out",
+					slog.Int64("in_flight", remaining),
+					slog.Duration("duration", time.Since(start)),
+				)
+				return fmt.Errorf("failed to drain requests: %w", err)
+			}
+			d.logger.InfoContext(ctx, "requests drained", slog.Duration("duration", time.Since(start)))
+			return nil
+		case <-ticker.C:
+			d.logger.InfoContext(ctx, "draining requests",
+				slog.Int64("in_flight", d.inFlight.Load()),
+				slog.Duration("elapsed", time.Since(start)),
+			)
+		}
+	}
+}
+
+// onceCloseListener is a net.Listener that can be closed more than once,
+// since both the drainer and http.Server.Shutdown close it.
+type onceCloseListener struct {
+	net.Listener
+	once sync.Once
+	err  error
+}
+
+func (l *onceCloseListener) Close() error {
+	l.once.Do(func() { l.err = l.Listener.Close() })
+	return l.err
+}
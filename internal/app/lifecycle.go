@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/app")
+
+// Phases of the shutdown, recorded as events of the Lifecycle.Stop span
+// when the first hook of the phase is stopped.
+const (
+	PhaseStopAccepting  = "stop accepting"
+	PhaseDrain          = "drain"
+	PhaseFlushTelemetry = "flush telemetry"
+)
+
+// Hook is a subsystem managed by a Lifecycle. Start and Stop may be nil.
+// Start must not block: long-running work is started in a goroutine and
+// ended by Stop.
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+	// Phase is the shutdown phase Stop belongs to, if any.
+	Phase string
+	// Timeout bounds Start and Stop; 0 uses the Lifecycle default.
+	Timeout time.Duration
+}
+
+// Lifecycle starts hooks in registration order and stops them in reverse
+// order, so a subsystem is stopped before the subsystems it depends on.
+type Lifecycle struct {
+	logger  *slog.Logger
+	timeout time.Duration
+	hooks   []Hook
+	started int
+}
+
+// NewLifecycle creates a new Lifecycle whose hooks time out after timeout
+// unless they set their own.
+func NewLifecycle(logger *slog.Logger, timeout time.Duration) *Lifecycle {
+	return &Lifecycle{
+		logger:  logger,
+		timeout: timeout,
+	}
+}
+
+// Register appends a hook.
+func (l *Lifecycle) Register(h Hook) {
+	l.hooks = append(l.hooks, h)
+}
+
+// Start starts the hooks that haven't been started yet. If a hook fails,
+// the hooks started so far are stopped and the error is returned.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Lifecycle.Start")
+	defer span.End()
+
+	for l.started < len(l.hooks) {
+		h := l.hooks[l.started]
+		if err := l.run(ctx, "start", h, h.Start); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to start "+h.Name)
+			if stopErr := l.Stop(ctx); stopErr != nil {
+				err = errors.Join(err, stopErr)
+			}
+			return fmt.Errorf("failed to start %s: %w", h.Name, err)
+		}
+		l.started++
+	}
+	return nil
+}
+
+// Stop stops the started hooks, and any registered hook without a Start
+// function, in reverse order. All hooks are stopped even if some fail.
+// The span of the shutdown records an event per phase and ends when the
+// telemetry flush starts, so it is still exported.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Lifecycle.Stop")
+	defer span.End()
+
+	var (
+		errs  []error
+		phase string
+	)
+	for i := len(l.hooks) - 1; i >= 0; i-- {
+		h := l.hooks[i]
+		if i >= l.started && h.Start != nil {
+			continue
+		}
+		if h.Phase != "" && h.Phase != phase {
+			phase = h.Phase
+			span.AddEvent(phase, trace.WithAttributes(attribute.String("lifecycle.hook", h.Name)))
+			l.logger.InfoContext(ctx, "shutdown phase", slog.String("phase", phase))
+			if phase == PhaseFlushTelemetry {
+				recordStopErrors(span, errs)
+				span.End()
+			}
+		}
+		if err := l.run(ctx, "stop", h, h.Stop); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", h.Name, err))
+		}
+	}
+	l.hooks = l.hooks[:0]
+	l.started = 0
+
+	recordStopErrors(span, errs)
+	return errors.Join(errs...)
+}
+
+// recordStopErrors records the errors of stopped hooks on span, unless it
+// has already ended.
+func recordStopErrors(span trace.Span, errs []error) {
+	if err := errors.Join(errs...); err != nil && span.IsRecording() {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to stop hooks")
+	}
+}
+
+// run calls fn with the hook's timeout in its own span. phase is "start"
+// or "stop".
+func (l *Lifecycle) run(ctx context.Context, phase string, h Hook, fn func(context.Context) error) error {
+	if fn == nil {
+		return nil
+	}
+
+	spanName := "Lifecycle.StartHook"
+	if phase == "stop" {
+		spanName = "Lifecycle.StopHook"
+	}
+
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = l.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, spanName,
+		trace.WithAttributes(attribute.String("lifecycle.hook", h.Name)),
+	)
+	defer span.End()
+
+	start := time.Now()
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		l.logger.ErrorContext(ctx, "lifecycle hook failed",
+			slog.String("hook", h.Name),
+			slog.String("phase", phase),
+			slog.Any("error", err),
+		)
+		return err
+	}
+
+	l.logger.InfoContext(ctx, "lifecycle hook done",
+		slog.String("hook", h.Name),
+		slog.String("phase", phase),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
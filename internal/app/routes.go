@@ -0,0 +1,293 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/audit"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/config"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/handler"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/i18n"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/middleware"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/overload"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/ui"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// requestTimeout bounds every request, and the timeout budget a caller may
+// send.
+const requestTimeout = 60 * time.Second
+
+// routes holds the handlers served by the router. audit and webhooks may be
+// nil.
+type routes struct {
+	health      *handler.HealthHandler
+	tasks       *handler.TaskHandler
+	tasksV2     *handler.TaskV2Handler
+	templates   *handler.TemplateHandler
+	attachments *handler.AttachmentHandler
+	imports     *handler.ImportHandler
+	transitions *handler.TransitionHandler
+	exports     *handler.ExportHandler
+	changes     *handler.ChangesHandler
+	stats       *handler.StatsHandler
+	audit       *audit.Store
+	quotas      *handler.QuotaHandler
+	tenants     *handler.TenantHandler
+	telemetry   *handler.TelemetryHandler
+	snapshots   *handler.SnapshotHandler
+	jobs        *handler.JobHandler
+	config      *handler.ConfigHandler
+	support     *handler.SupportHandler
+	rum         *handler.RUMHandler
+	webhooks    *handler.WebhookHandler
+	ui          *ui.Handler
+}
+
+// newRouter creates the router with the middleware stack and all routes.
+func (a *App) newRouter(meter metric.Meter, metrics *telemetry.Metrics, h routes) (chi.Router, error) {
+	cfg, logger := a.cfg, a.logger
+
+	// Create router
+	r := chi.NewRouter()
+
+	// Apply standard middleware
+	r.Use(chimiddleware.RequestID)
+	r.Use(chimiddleware.RealIP)
+	if cfg.AccessLogEnabled {
+		// Emit access logs as their own OTel log records, health checks excluded
+		r.Use(middleware.AccessLog(global.GetLoggerProvider(), "/health", "/ready"))
+	} else {
+		r.Use(middleware.Logger(logger))
+	}
+
+	// Provide a request-scoped logger to handlers via logging.FromContext
+	r.Use(middleware.ContextLogger(logger))
+
+	// Record the negotiated HTTP protocol on spans and metrics
+	protocolMiddleware, err := middleware.Protocol(meter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create protocol middleware: %w", err)
+	}
+	r.Use(protocolMiddleware)
+
+	// Name server spans after the matched route
+	r.Use(middleware.SpanName)
+
+	// Return the trace ID of every request in X-Trace-Id
+	r.Use(middleware.TraceID)
+
+	// Link error responses to their trace in the trace UI
+	if cfg.ErrorTraceLinks && cfg.TraceUIURLTemplate != "" {
+		r.Use(middleware.TraceLinks(cfg.TraceUIURLTemplate))
+	}
+
+	// Translate error responses into the locale of Accept-Language
+	bundle, err := i18n.Load()
+	if err != nil {
+		return nil, err
+	}
+	r.Use(middleware.Locale(bundle))
+
+	// Record HTTP server metrics (health checks excluded)
+	r.Use(middleware.Metrics(metrics, "/health", "/ready"))
+
+	// Feed the adaptive sampler with the outcome of every request
+	if a.sampler != nil {
+		r.Use(middleware.AdaptiveSampling(a.sampler, "/health", "/ready"))
+	}
+
+	// Record JSON encoding and decoding durations and payload sizes
+	jsonCodecMiddleware, err := middleware.JSONCodec(meter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create json codec middleware: %w", err)
+	}
+	r.Use(jsonCodecMiddleware)
+
+	// Add the allowed members of the incoming baggage to server spans
+	if len(cfg.RUMBaggageKeys) > 0 {
+		r.Use(middleware.Baggage(cfg.RUMBaggageKeys))
+	}
+
+	// Allow browser frontends on the configured origins
+	corsMiddleware, err := newCORS(cfg, meter)
+	if err != nil {
+		return nil, err
+	}
+	if corsMiddleware != nil {
+		r.Use(corsMiddleware)
+	}
+
+	// Recover panics into the active span; registered after the metrics
+	// middleware so recovered requests are counted as 500s
+	recoverer, err := middleware.Recoverer(logger, meter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recovery middleware: %w", err)
+	}
+	r.Use(recoverer)
+
+	r.Use(chimiddleware.CleanPath)
+
+	// Shed lists, exports and bulk operations first under overload. Health
+	// checks must keep answering, and long polls wait by design, so their
+	// duration says nothing about the load.
+	if a.shedder != nil {
+		r.Use(middleware.Shed(a.shedder, requestPriority, "/health", "/ready", "/api/v1/tasks/changes"))
+	}
+
+	r.Use(chimiddleware.Timeout(requestTimeout))
+
+	// Shorten the deadline to the timeout budget sent by the caller
+	r.Use(middleware.TimeoutBudget(requestTimeout))
+
+	// Record the policy of the caller's tenant and enforce its rate limit
+	tenantMiddleware, err := middleware.TenantPolicy(a.tenants, meter, "/health", "/ready")
+	if err != nil {
+		return nil, err
+	}
+	r.Use(tenantMiddleware)
+
+	// Health check endpoints (excluded from tracing)
+	r.Get("/health", h.health.Health)
+	r.Get("/ready", h.health.Ready)
+
+	// Build information
+	r.Get("/version", handler.Version)
+
+	// Dashboard UI
+	r.Mount("/", h.ui.Routes())
+
+	deprecation, err := newDeprecation(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// API routes
+	r.Route("/api/v1", func(r chi.Router) {
+		if deprecation != nil {
+			r.Use(deprecation)
+		}
+		taskRoutes := h.tasks.Routes()
+		taskRoutes.Mount("/import", h.imports.Routes())
+		taskRoutes.Mount("/transition", h.transitions.Routes())
+		taskRoutes.Mount("/export", h.exports.Routes())
+		taskRoutes.Mount("/changes", h.changes.Routes())
+		taskRoutes.Mount("/stats", h.stats.Routes())
+		taskRoutes.Mount("/{id}/attachments", h.attachments.Routes())
+		r.Mount("/tasks", taskRoutes)
+		r.Mount("/templates", h.templates.Routes())
+		r.Mount("/rum", h.rum.Routes())
+		r.Get("/events/schemas", handler.EventSchemas)
+		r.Get("/events/schemas/{name}", handler.EventSchema)
+		if h.audit != nil {
+			r.Mount("/audit", handler.NewAuditHandler(h.audit).Routes())
+		}
+		if h.webhooks != nil {
+			r.Mount("/hooks", h.webhooks.Routes())
+		}
+		if cfg.AdminToken != "" {
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(middleware.AdminAuth(cfg.AdminToken))
+				r.Mount("/quotas", h.quotas.Routes())
+				r.Mount("/tenants", h.tenants.Routes())
+				r.Mount("/telemetry", h.telemetry.Routes())
+				r.Mount("/snapshots", h.snapshots.Routes())
+				r.Mount("/jobs", h.jobs.Routes())
+				r.Mount("/config", h.config.Routes())
+				r.Mount("/support-bundle", h.support.Routes())
+			})
+		}
+	})
+	r.Route("/api/v2", func(r chi.Router) {
+		r.Mount("/tasks", h.tasksV2.Routes())
+	})
+
+	return r, nil
+}
+
+// listPaths are the collections whose GET lists them, shed first under
+// overload.
+var listPaths = []string{"/api/v1/tasks", "/api/v2/tasks", "/api/v1/templates", "/api/v1/audit"}
+
+// bulkPaths are the prefixes of the bulk and aggregate operations, shed first
+// under overload.
+var bulkPaths = []string{"/api/v1/tasks/export", "/api/v1/tasks/import", "/api/v1/tasks/transition", "/api/v1/tasks/stats"}
+
+// requestPriority classifies requests for load shedding: lists and bulk
+// operations have low priority, other reads high priority and writes normal
+// priority.
+func requestPriority(r *http.Request) overload.Priority {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if read && slices.Contains(listPaths, path) {
+		return overload.PriorityLow
+	}
+	for _, prefix := range bulkPaths {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return overload.PriorityLow
+		}
+	}
+	if read {
+		return overload.PriorityHigh
+	}
+	return overload.PriorityNormal
+}
+
+// newDeprecation returns the middleware marking the v1 API as deprecated,
+// or nil if no deprecation date is configured.
+func newDeprecation(cfg *config.Config) (func(http.Handler) http.Handler, error) {
+	if cfg.APIV1DeprecatedAt == "" {
+		return nil, nil
+	}
+	deprecatedAt, err := time.Parse(time.DateOnly, cfg.APIV1DeprecatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_V1_DEPRECATED_AT: %w", err)
+	}
+	var sunset time.Time
+	if cfg.APIV1SunsetAt != "" {
+		if sunset, err = time.Parse(time.DateOnly, cfg.APIV1SunsetAt); err != nil {
+			return nil, fmt.Errorf("invalid API_V1_SUNSET_AT: %w", err)
+		}
+	}
+	return middleware.Deprecation(deprecatedAt, sunset, "/api/v2/tasks"), nil
+}
+
+// newCORS returns the CORS middleware, or nil if no origins are allowed.
+func newCORS(cfg *config.Config, meter metric.Meter) (func(http.Handler) http.Handler, error) {
+	c := middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		ExposedHeaders:   cfg.CORSExposedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           middleware.Duration(cfg.CORSMaxAge),
+	}
+	if cfg.CORSConfigFile != "" {
+		var err error
+		c, err = middleware.LoadCORSConfig(cfg.CORSConfigFile, c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.AllowedOrigins) == 0 {
+		return nil, nil
+	}
+
+	cors, err := middleware.CORS(c, meter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cors middleware: %w", err)
+	}
+	return cors, nil
+}
+
+// isHealthCheck reports whether r is a health check, which isn't traced.
+func isHealthCheck(r *http.Request) bool {
+	return r.URL.Path == "/health" || r.URL.Path == "/ready"
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/middleware"
+	"github.com/quic-go/quic-go/http3"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// registerServers registers the HTTP server, and the experimental HTTP/3
+// server if configured, serving router. It returns the instrumented handler
+// the servers serve. On shutdown, the HTTP server stops accepting
+// connections first and then drains its in-flight requests for up to
+// ShutdownDrainTimeout.
+func (a *App) registerServers(router http.Handler, meter metric.Meter) (http.Handler, error) {
+	cfg, logger := a.cfg, a.logger
+
+	// Wrap router with OpenTelemetry HTTP instrumentation, with the tenant
+	// of the caller in the context so new traces are sampled with its ratio
+	otelHandler := middleware.Tenant(otelhttp.NewHandler(router, "http-server",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			// Skip tracing for health checks
+			return !isHealthCheck(r)
+		}),
+	))
+
+	// Let allowed callers force their request to be traced, and only them
+	// mark their requests as synthetic
+	networks, err := middleware.ParseNetworks(cfg.ForceTraceAllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FORCE_TRACE_ALLOWED_NETWORKS: %w", err)
+	}
+	trusted := middleware.ForceTraceConfig{
+		Token:           cfg.ForceTraceToken,
+		AllowedNetworks: networks,
+	}
+	if cfg.ForceTraceToken != "" || len(networks) > 0 {
+		forceTrace, err := middleware.ForceTrace(trusted, meter)
+		if err != nil {
+			return nil, err
+		}
+		otelHandler = forceTrace(otelHandler)
+	}
+	synthetic, err := middleware.SyntheticBaggage(trusted, meter)
+	if err != nil {
+		return nil, err
+	}
+	otelHandler = synthetic(otelHandler)
+
+	// Count the in-flight requests for draining on shutdown
+	drain, err := newDrainer(logger, meter)
+	if err != nil {
+		return nil, err
+	}
+	otelHandler = drain.Handler(otelHandler)
+
+	// Create HTTP server
+	server := &http.Server{
+		Addr:         ":" + cfg.ServerPort,
+		Handler:      otelHandler,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	// Serve HTTP/2 over cleartext (h2c) alongside HTTP/1.1, e.g. behind a proxy
+	if cfg.H2CEnabled {
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	var ln net.Listener
+	a.lifecycle.Register(Hook{
+		Name:  "http-server",
+		Phase: PhaseDrain,
+		Start: func(context.Context) error {
+			// Listen synchronously so a bind error fails the startup
+			l, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return fmt.Errorf("failed to listen: %w", err)
+			}
+			ln = &onceCloseListener{Listener: l}
+			go func() { // async:ok: listener, not request work
+				logger.Info("server listening", slog.String("addr", server.Addr))
+				// The listener is closed before the shutdown when draining
+				if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+					a.fail(fmt.Errorf("server error: %w", err))
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return drain.Drain(ctx, server.Shutdown)
+		},
+		Timeout: cfg.ShutdownDrainTimeout,
+	})
+
+	// Start the experimental HTTP/3 (QUIC) listener
+	if cfg.HTTP3Addr != "" {
+		h3Server := &http3.Server{
+			Addr:    cfg.HTTP3Addr,
+			Handler: otelHandler,
+		}
+		a.lifecycle.Register(Hook{
+			Name:  "http3-server",
+			Phase: PhaseDrain,
+			Start: func(context.Context) error {
+				go func() { // async:ok: listener, not request work
+					logger.Info("http3 server listening", slog.String("addr", h3Server.Addr))
+					if err := h3Server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+						logger.Error("http3 server error", slog.Any("error", err))
+					}
+				}()
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				return drain.Drain(ctx, h3Server.Shutdown)
+			},
+			Timeout: cfg.ShutdownDrainTimeout,
+		})
+	}
+
+	// Stop accepting connections before the servers drain
+	a.lifecycle.Register(Hook{
+		Name:  "http-listener",
+		Phase: PhaseStopAccepting,
+		Stop: func(ctx context.Context) error {
+			return drain.StopAccepting(ctx, ln)
+		},
+	})
+
+	return otelHandler, nil
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/buildinfo"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// logStartup emits a startup span and log record with the build
+// information and the effective configuration, secrets redacted.
+func (a *App) logStartup(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "App.Startup")
+	defer span.End()
+
+	info := buildinfo.Get()
+	span.SetAttributes(
+		semconv.ServiceVersion(info.Version),
+		attribute.String("build.commit", info.Commit),
+		attribute.String("build.date", info.Date),
+		attribute.String("build.go_version", info.GoVersion),
+	)
+	for name, value := range a.cfg.Redacted() {
+		span.SetAttributes(configAttribute("config."+name, value))
+	}
+
+	a.logger.InfoContext(ctx, "application started",
+		slog.String("version", info.Version),
+		slog.String("commit", info.Commit),
+		slog.String("build_date", info.Date),
+		slog.Any("config", a.cfg),
+	)
+}
+
+// configAttribute converts a configuration value to a span attribute.
+func configAttribute(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case []float64:
+		return attribute.Float64Slice(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,113 @@
+package apperr
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Kind classifies an error by how the caller should react to it.
+type Kind int
+
+// Error kinds. The zero value is Internal, so unclassified errors are
+// treated as bugs or unexpected failures.
+const (
+	Internal Kind = iota
+	NotFound
+	Conflict
+	Validation
+	PermissionDenied
+	ResourceExhausted
+	Unavailable
+)
+
+// String returns the name of k as used in the error.type attribute.
+func (k Kind) String() string {
+	switch k {
+	case NotFound:
+		return "not_found"
+	case Conflict:
+		return "conflict"
+	case Validation:
+		return "validation"
+	case PermissionDenied:
+		return "permission_denied"
+	case ResourceExhausted:
+		return "resource_exhausted"
+	case Unavailable:
+		return "unavailable"
+	}
+	return "internal"
+}
+
+// Expected reports whether errors of kind k are an expected outcome of a
+// client request rather than a failure of the service.
+func (k Kind) Expected() bool {
+	return k != Internal && k != Unavailable
+}
+
+// Error is a domain error with a kind and a message safe to show to
+// clients. Errors are comparable, so sentinels declared with New can be
+// matched with errors.Is.
+type Error struct {
+	Kind    Kind
+	Message string
+}
+
+// New returns a domain error of kind with message.
+func New(kind Kind, message string) Error {
+	return Error{Kind: kind, Message: message}
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+func (e Error) kind() Kind { return e.Kind }
+
+// Wrap classifies err as kind while keeping it in the chain for errors.Is
+// and errors.As. It returns nil if err is nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &wrapped{k: kind, err: err}
+}
+
+type wrapped struct {
+	k   Kind
+	err error
+}
+
+func (w *wrapped) Error() string { return w.err.Error() }
+func (w *wrapped) Unwrap() error { return w.err }
+func (w *wrapped) kind() Kind    { return w.k }
+
+type classified interface {
+	kind() Kind
+}
+
+// KindOf returns the kind of the outermost classified error in err's chain,
+// or Internal if there is none.
+func KindOf(err error) Kind {
+	var c classified
+	if errors.As(err, &c) {
+		return c.kind()
+	}
+	return Internal
+}
+
+// Record records err on span by its kind. Every error sets error.type to
+// the kind; expected kinds stop there, while Unavailable and Internal
+// errors are also recorded as an exception and set the span status.
+func Record(span trace.Span, err error) {
+	kind := KindOf(err)
+	span.SetAttributes(attribute.String("error.type", kind.String()))
+	if kind.Expected() {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
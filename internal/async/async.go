@@ -0,0 +1,89 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/async")
+
+type options struct {
+	detached bool
+	spanOpts []trace.SpanStartOption
+}
+
+// Option configures Go.
+type Option func(*options)
+
+// Detached runs the goroutine with a context that keeps the values of the
+// caller's context but is not cancelled with it, for work that outlives the
+// request that started it.
+func Detached() Option {
+	return func(o *options) {
+		o.detached = true
+	}
+}
+
+// WithSpanOptions passes opts to the span of the goroutine, e.g. links or
+// attributes.
+func WithSpanOptions(opts ...trace.SpanStartOption) Option {
+	return func(o *options) {
+		o.spanOpts = append(o.spanOpts, opts...)
+	}
+}
+
+// Go runs fn in a new goroutine, in a span named name that is a child of the
+// span in ctx, so the background work stays part of the trace that started
+// it. A panic in fn is recovered, recorded with its stack trace on the span
+// and logged; it ends the goroutine but not the process. The returned
+// channel is closed once fn has returned.
+func Go(ctx context.Context, name string, fn func(ctx context.Context), opts ...Option) <-chan struct{} {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.detached {
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ctx, span := tracer.Start(ctx, name, o.spanOpts...)
+		defer span.End()
+		defer recoverInto(ctx, span)
+
+		fn(ctx)
+	}()
+	return done
+}
+
+// recoverInto recovers a panic and records it on span.
+func recoverInto(ctx context.Context, span trace.Span) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+
+	stack := string(debug.Stack())
+	err := fmt.Errorf("panic: %v", rec)
+
+	span.RecordError(err, trace.WithAttributes(
+		attribute.String("exception.stacktrace", stack),
+	))
+	span.SetStatus(codes.Error, err.Error())
+
+	logging.FromContext(ctx).ErrorContext(ctx, "panic recovered in goroutine",
+		slog.Any("error", err),
+		slog.String("stacktrace", stack),
+	)
+}
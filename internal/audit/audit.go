@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope used for audit log records.
+// Using a dedicated scope lets backends route audit records separately
+// from regular application logs.
+const ScopeName = "github.com/hiroki-koketsu/go-otel-sample/internal/audit"
+
+// Action identifies the kind of mutation recorded in an audit entry.
+type Action string
+
+const (
+	ActionCreate Action = "task.create"
+	ActionUpdate Action = "task.update"
+	ActionDelete Action = "task.delete"
+
+	ActionArchive   Action = "task.archive"
+	ActionUnarchive Action = "task.unarchive"
+
+	ActionAssign Action = "task.assign"
+
+	ActionClaim   Action = "task.claim"
+	ActionRelease Action = "task.release"
+)
+
+// Change describes a single field change between two task states.
+type Change = model.Change
+
+// Entry is a single audit record describing who did what to which task.
+type Entry struct {
+	ID        string            `json:"id"`
+	Timestamp time.Time         `json:"timestamp"`
+	Actor     string            `json:"actor"`
+	Action    Action            `json:"action"`
+	TaskID    string            `json:"task_id"`
+	Before    *model.Task       `json:"before,omitempty"`
+	After     *model.Task       `json:"after,omitempty"`
+	Changes   map[string]Change `json:"changes,omitempty"`
+	TraceID   string            `json:"trace_id,omitempty"`
+}
+
+// Recorder writes audit entries to a dedicated logger and, optionally, a store.
+type Recorder struct {
+	logger *slog.Logger
+	store  *Store
+}
+
+// NewRecorder creates a new Recorder. The store may be nil, in which case
+// entries are only emitted as log records.
+func NewRecorder(logger *slog.Logger, store *Store) *Recorder {
+	return &Recorder{
+		logger: logger,
+		store:  store,
+	}
+}
+
+// Record completes the entry with an ID, timestamp, trace ID and field diff,
+// then logs and stores it.
+func (r *Recorder) Record(ctx context.Context, e Entry) {
+	e.ID = uuid.New().String()
+	e.Timestamp = time.Now()
+	if e.Before != nil && e.After != nil {
+		e.Changes = model.Diff(e.Before, e.After)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		e.TraceID = sc.TraceID().String()
+	}
+
+	r.logger.InfoContext(ctx, "audit",
+		slog.String("audit.id", e.ID),
+		slog.String("audit.actor", e.Actor),
+		slog.String("audit.action", string(e.Action)),
+		slog.String("task.id", e.TaskID),
+		slog.Any("audit.changes", e.Changes),
+	)
+
+	if r.store != nil {
+		r.store.Append(e)
+	}
+
+	span.AddEvent("audit.recorded", trace.WithAttributes(
+		attribute.String("audit.id", e.ID),
+		attribute.String("audit.action", string(e.Action)),
+	))
+}
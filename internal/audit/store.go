@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/audit")
+
+// Filter narrows down the entries returned by Store.Query.
+// Empty fields match everything.
+type Filter struct {
+	TaskID string
+	Actor  string
+	Action Action
+	Limit  int
+}
+
+// Store is an in-memory audit table. It keeps the latest entries up to its
+// size, dropping the oldest ones.
+type Store struct {
+	mu      sync.RWMutex
+	entries []Entry
+	next    int // index of the next entry once entries is full
+}
+
+// NewStore creates a new Store keeping up to size entries.
+func NewStore(size int) (*Store, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid audit store size %d", size)
+	}
+	return &Store{entries: make([]Entry, 0, size)}, nil
+}
+
+// Append adds an entry to the store, replacing the oldest one if the store
+// is full.
+func (s *Store) Append(e Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) < cap(s.entries) {
+		s.entries = append(s.entries, e)
+		return
+	}
+	s.entries[s.next] = e
+	s.next = (s.next + 1) % len(s.entries)
+}
+
+// Query returns the entries matching the filter, newest first.
+func (s *Store) Query(ctx context.Context, f Filter) []Entry {
+	_, span := tracer.Start(ctx, "AuditStore.Query")
+	defer span.End()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Entry, 0)
+	for i := range len(s.entries) {
+		// The newest entry is the one before next
+		e := s.entries[(s.next-1-i+2*len(s.entries))%len(s.entries)]
+		if f.TaskID != "" && e.TaskID != f.TaskID {
+			continue
+		}
+		if f.Actor != "" && e.Actor != f.Actor {
+			continue
+		}
+		if f.Action != "" && e.Action != f.Action {
+			continue
+		}
+		result = append(result, e)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+
+	span.SetAttributes(attribute.Int("audit.count", len(result)))
+	return result
+}
@@ -1,28 +1,665 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// Config holds the application configuration.
+// Config holds the application configuration. The env tag of a field names
+// the environment variable it is loaded from, followed by the variable it
+// falls back to, if any; Settings reports it with the source of the value.
 type Config struct {
-	// Server settings
-	ServerPort string
+	// Server settings. On shutdown, the servers drain their in-flight
+	// requests for up to ShutdownDrainTimeout.
+	ServerPort           string        `env:"SERVER_PORT"`
+	ShutdownDrainTimeout time.Duration `env:"SHUTDOWN_DRAIN_TIMEOUT"`
 
-	// OpenTelemetry settings
-	OTLPEndpoint string
-	ServiceName  string
-	Environment  string
+	// Load shedding rejects low-priority requests when OverloadMaxInFlight
+	// requests are in flight or the p99 latency over OverloadInterval
+	// exceeds OverloadLatencyThreshold, and normal-priority requests when
+	// both are exceeded. A zero threshold disables its check.
+	OverloadSheddingEnabled  bool          `env:"OVERLOAD_SHEDDING_ENABLED"`
+	OverloadMaxInFlight      int64         `env:"OVERLOAD_MAX_INFLIGHT"`
+	OverloadLatencyThreshold time.Duration `env:"OVERLOAD_LATENCY_THRESHOLD"`
+	OverloadInterval         time.Duration `env:"OVERLOAD_INTERVAL"`
+
+	// Protocol settings. H2C serves cleartext HTTP/2 on ServerPort; HTTP3Addr
+	// enables an experimental QUIC listener that requires a TLS certificate.
+	H2CEnabled  bool   `env:"H2C_ENABLED"`
+	HTTP3Addr   string `env:"HTTP3_ADDR"`
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+
+	// CORS settings. CORS is enabled when allowed origins are set here or in
+	// CORSConfigFile (JSON); environment values take precedence over the file.
+	CORSAllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods   []string      `env:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS"`
+	CORSExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS"`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE"`
+	CORSConfigFile       string        `env:"CORS_CONFIG_FILE"`
+
+	// RUMBaggageKeys lists the members of incoming W3C baggage, e.g. from
+	// browser frontends, that are added to server and RUM spans. Set it
+	// empty to ignore baggage.
+	RUMBaggageKeys []string `env:"RUM_BAGGAGE_KEYS"`
+
+	// Request body settings
+	MaxRequestBodyBytes int64 `env:"MAX_REQUEST_BODY_BYTES"`
+	StrictJSON          bool  `env:"STRICT_JSON"`
+
+	// DedupWindow is how long a task creation is answered with its response
+	// when the same client sends a byte-identical body again; 0 disables
+	// duplicate suppression.
+	DedupWindow time.Duration `env:"DEDUP_WINDOW"`
+
+	// CursorSecret signs the pagination cursors of the task list. If empty,
+	// a random key is used, so cursors don't survive restarts and only work
+	// on the replica that issued them.
+	CursorSecret string `env:"CURSOR_SECRET"`
+
+	// OpenTelemetry settings. Environment selects the TelemetryProfile
+	// whose defaults apply to the trace exporter, sampling ratio, log level
+	// and metric export interval.
+	OTLPEndpoint     string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName      string `env:"OTEL_SERVICE_NAME"`
+	Environment      string `env:"ENVIRONMENT"`
+	TelemetryProfile string
+
+	// TracesSampleRatio is the fraction of new traces that are sampled;
+	// spans with a parent follow the parent's decision. LogLevel is the
+	// minimum level of exported logs (debug, info, warn or error).
+	TracesSampleRatio float64 `env:"TRACES_SAMPLE_RATIO"`
+	LogLevel          string  `env:"LOG_LEVEL"`
+
+	// Adaptive sampling raises the sampling ratio from TracesSampleRatio to
+	// AdaptiveSamplingMaxRatio while the error or latency SLO burns at
+	// AdaptiveSamplingBurnRate or faster, evaluated every
+	// AdaptiveSamplingInterval.
+	AdaptiveSamplingEnabled          bool          `env:"ADAPTIVE_SAMPLING_ENABLED"`
+	AdaptiveSamplingMaxRatio         float64       `env:"ADAPTIVE_SAMPLING_MAX_RATIO"`
+	AdaptiveSamplingSLOTarget        float64       `env:"ADAPTIVE_SAMPLING_SLO_TARGET"`
+	AdaptiveSamplingLatencyThreshold time.Duration `env:"ADAPTIVE_SAMPLING_LATENCY_THRESHOLD"`
+	AdaptiveSamplingBurnRate         float64       `env:"ADAPTIVE_SAMPLING_BURN_RATE"`
+	AdaptiveSamplingInterval         time.Duration `env:"ADAPTIVE_SAMPLING_INTERVAL"`
+
+	// Requests with an X-Force-Trace header are sampled regardless of the
+	// sampling ratio if the header carries ForceTraceToken or they come
+	// from one of ForceTraceAllowedNetworks (CIDR prefixes or addresses).
+	ForceTraceToken           string   `env:"FORCE_TRACE_TOKEN"`
+	ForceTraceAllowedNetworks []string `env:"FORCE_TRACE_ALLOWED_NETWORKS"`
+
+	// The cardinality report logs the number of metric series per
+	// instrument every CardinalityReportInterval: the top
+	// CardinalityReportTopN instruments, and a warning for every instrument
+	// with CardinalityWarnThreshold series or more (0 disables warnings).
+	CardinalityReportEnabled  bool          `env:"CARDINALITY_REPORT_ENABLED"`
+	CardinalityReportInterval time.Duration `env:"CARDINALITY_REPORT_INTERVAL"`
+	CardinalityReportTopN     int64         `env:"CARDINALITY_REPORT_TOP_N"`
+	CardinalityWarnThreshold  int64         `env:"CARDINALITY_WARN_THRESHOLD"`
+
+	// AccessLogEnabled replaces the request line in the application log
+	// with an OTel access log record per request.
+	AccessLogEnabled bool `env:"ACCESS_LOG_ENABLED"`
+
+	// OTLP exporter connection settings per signal. Headers are
+	// comma-separated key=value pairs sent with every export (e.g. API
+	// keys); compression is gzip or none. The signal-specific variables
+	// override OTEL_EXPORTER_OTLP_HEADERS and OTEL_EXPORTER_OTLP_COMPRESSION.
+	OTLPTracesHeaders      string `env:"OTEL_EXPORTER_OTLP_TRACES_HEADERS,OTEL_EXPORTER_OTLP_HEADERS"`
+	OTLPMetricsHeaders     string `env:"OTEL_EXPORTER_OTLP_METRICS_HEADERS,OTEL_EXPORTER_OTLP_HEADERS"`
+	OTLPLogsHeaders        string `env:"OTEL_EXPORTER_OTLP_LOGS_HEADERS,OTEL_EXPORTER_OTLP_HEADERS"`
+	OTLPTracesCompression  string `env:"OTEL_EXPORTER_OTLP_TRACES_COMPRESSION,OTEL_EXPORTER_OTLP_COMPRESSION"`
+	OTLPMetricsCompression string `env:"OTEL_EXPORTER_OTLP_METRICS_COMPRESSION,OTEL_EXPORTER_OTLP_COMPRESSION"`
+	OTLPLogsCompression    string `env:"OTEL_EXPORTER_OTLP_LOGS_COMPRESSION,OTEL_EXPORTER_OTLP_COMPRESSION"`
+	OTLPInsecure           bool   `env:"OTEL_EXPORTER_OTLP_INSECURE"`
+
+	// Per-signal OTLP endpoints, defaulting to OTLPEndpoint, for backends
+	// split across vendors.
+	OTLPTracesEndpoint  string `env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT,OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTLPMetricsEndpoint string `env:"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT,OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTLPLogsEndpoint    string `env:"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT,OTEL_EXPORTER_OTLP_ENDPOINT"`
+
+	// OTLPSharedConnection makes the exporters of signals with the same
+	// endpoint, compression and security share one gRPC connection.
+	OTLPSharedConnection bool `env:"OTLP_SHARED_CONNECTION"`
+
+	// OTLPMirrorEndpoint receives a copy of the OTLPMirrorSignals (empty
+	// disables mirroring), with its own headers and compression.
+	OTLPMirrorEndpoint    string   `env:"OTLP_MIRROR_ENDPOINT"`
+	OTLPMirrorSignals     []string `env:"OTLP_MIRROR_SIGNALS"`
+	OTLPMirrorHeaders     string   `env:"OTLP_MIRROR_HEADERS"`
+	OTLPMirrorCompression string   `env:"OTLP_MIRROR_COMPRESSION"`
+
+	// Trace exporter settings (otlp, zipkin, jaeger-thrift-http)
+	TracesExporter         string `env:"TRACES_EXPORTER"`
+	TracesExporterEndpoint string `env:"TRACES_EXPORTER_ENDPOINT"`
+
+	// Span export queue tuning (0 keeps the SDK default)
+	TracesBatchTimeout       time.Duration `env:"TRACES_BATCH_TIMEOUT"`
+	TracesMaxExportBatchSize int64         `env:"TRACES_MAX_EXPORT_BATCH_SIZE"`
+	TracesMaxQueueSize       int64         `env:"TRACES_MAX_QUEUE_SIZE"`
+	TracesBlockOnQueueFull   bool          `env:"TRACES_BLOCK_ON_QUEUE_FULL"`
+
+	// Log record export queue tuning (0 keeps the SDK default)
+	LogsMaxQueueSize     int64 `env:"LOGS_MAX_QUEUE_SIZE"`
+	LogsBlockOnQueueFull bool  `env:"LOGS_BLOCK_ON_QUEUE_FULL"`
+
+	// SupportLogBufferSize is the number of recent log records kept in
+	// memory for support bundles; 0 keeps none.
+	SupportLogBufferSize int64 `env:"SUPPORT_LOG_BUFFER_SIZE"`
+
+	// TelemetryBlockTimeout bounds how long a span or log record waits for
+	// queue space when blocking on a full queue; 0 waits until there is
+	// space. Dropped telemetry is warned about at most once per
+	// TelemetryDropWarnInterval and signal.
+	TelemetryBlockTimeout     time.Duration `env:"TELEMETRY_BLOCK_TIMEOUT"`
+	TelemetryDropWarnInterval time.Duration `env:"TELEMETRY_DROP_WARN_INTERVAL"`
+
+	// TelemetryExportStatsEnabled measures the duration and payload size,
+	// before and after compression, of the OTLP exports of each signal.
+	TelemetryExportStatsEnabled bool `env:"TELEMETRY_EXPORT_STATS_ENABLED"`
+
+	// TelemetryShutdownTimeout bounds the final flush of each telemetry
+	// provider on shutdown, so an unreachable collector doesn't hold it up
+	// for long.
+	TelemetryShutdownTimeout time.Duration `env:"TELEMETRY_SHUTDOWN_TIMEOUT"`
+
+	// Span limits (0 keeps the SDK default)
+	SpanAttributeCountLimit       int64 `env:"SPAN_ATTRIBUTE_COUNT_LIMIT"`
+	SpanAttributeValueLengthLimit int64 `env:"SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT"`
+	SpanEventCountLimit           int64 `env:"SPAN_EVENT_COUNT_LIMIT"`
+
+	// SpanDeploymentAttributes lists the deployment attributes added to
+	// every span as attribute=ENV_VAR pairs; see
+	// telemetry.WithDeploymentAttributes.
+	SpanDeploymentAttributes string `env:"SPAN_DEPLOYMENT_ATTRIBUTES"`
+
+	// MetricsTemporality is the OTLP metric temporality preference
+	// (cumulative, delta or lowmemory).
+	MetricsTemporality string `env:"OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE"`
+
+	// Histogram aggregation: explicit_bucket_histogram or
+	// base2_exponential_bucket_histogram. MetricsDurationBuckets overrides
+	// the explicit boundaries of duration histograms.
+	MetricsHistogramAggregation string    `env:"OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION"`
+	MetricsDurationBuckets      []float64 `env:"METRICS_DURATION_BUCKETS"`
+
+	// MetricsExportInterval is how often metrics are exported.
+	MetricsExportInterval time.Duration `env:"METRICS_EXPORT_INTERVAL"`
+
+	// HTTPMetricsLegacy keeps the pre-semconv http_requests_total and
+	// http_request_duration_seconds metric names for existing dashboards.
+	HTTPMetricsLegacy bool `env:"HTTP_METRICS_LEGACY"`
+
+	// AssigneeGaugeTopN caps the assignees reported by the open tasks per
+	// assignee gauge; the others are summed up as "other".
+	AssigneeGaugeTopN int64 `env:"ASSIGNEE_GAUGE_TOP_N"`
+
+	// HostMetricsEnabled reports CPU, memory, file descriptor and network
+	// metrics of the process and the host.
+	HostMetricsEnabled bool `env:"HOST_METRICS_ENABLED"`
+
+	// Warm-up settings. With WarmupEnabled, WarmupIterations synthetic
+	// create, get, update, list and delete cycles (WarmupConcurrency at a
+	// time) are sent through the HTTP handler on startup.
+	WarmupEnabled     bool  `env:"WARMUP_ENABLED"`
+	WarmupIterations  int64 `env:"WARMUP_ITERATIONS"`
+	WarmupConcurrency int64 `env:"WARMUP_CONCURRENCY"`
+
+	// Seeding settings. With SeedEnabled, the tasks of SeedFile (JSON or
+	// YAML; empty uses the embedded demo fixture) are created on startup
+	// if the repository is empty.
+	SeedEnabled bool   `env:"SEED_ENABLED"`
+	SeedFile    string `env:"SEED_FILE"`
+
+	// Continuous profiling settings. With ProfilingEnabled, the
+	// ProfilingProfileTypes are pushed to the Pyroscope server at
+	// ProfilingServerAddress every ProfilingUploadInterval.
+	ProfilingEnabled           bool          `env:"PROFILING_ENABLED"`
+	ProfilingServerAddress     string        `env:"PROFILING_SERVER_ADDRESS"`
+	ProfilingProfileTypes      []string      `env:"PROFILING_PROFILE_TYPES"`
+	ProfilingUploadInterval    time.Duration `env:"PROFILING_UPLOAD_INTERVAL"`
+	ProfilingBasicAuthUser     string        `env:"PROFILING_BASIC_AUTH_USER"`
+	ProfilingBasicAuthPassword string        `env:"PROFILING_BASIC_AUTH_PASSWORD"`
+
+	// Exporter connection health settings
+	ReadinessRequireExporters bool   `env:"READINESS_REQUIRE_EXPORTERS"`
+	ChannelzAddr              string `env:"CHANNELZ_ADDR"`
+
+	// TelemetryFallback (stdout or drop) is used while exports to the
+	// collector fail; empty disables degraded mode. The collector is retried
+	// every TelemetryProbeInterval.
+	TelemetryFallback      string        `env:"TELEMETRY_FALLBACK"`
+	TelemetryProbeInterval time.Duration `env:"TELEMETRY_PROBE_INTERVAL"`
+
+	// TelemetryFileDir, when set, replaces the OTLP collector with an
+	// in-process one writing OTLP JSON lines to rotating files in this
+	// directory. Files rotate at TelemetryFileMaxBytes; at most
+	// TelemetryFileMaxBackups rotated files are kept per signal.
+	TelemetryFileDir        string `env:"TELEMETRY_FILE_DIR"`
+	TelemetryFileMaxBytes   int64  `env:"TELEMETRY_FILE_MAX_BYTES"`
+	TelemetryFileMaxBackups int64  `env:"TELEMETRY_FILE_MAX_BACKUPS"`
+
+	// NotifierURL is the base URL of the downstream notifier service.
+	// Task completion notifications are disabled when empty.
+	NotifierURL string `env:"NOTIFIER_URL"`
+
+	// Retries of outbound calls to the notifier and NATS. RetryJitter
+	// randomizes every retry wait, including reminder retries, by up to
+	// this fraction.
+	OutboundMaxAttempts     int64         `env:"OUTBOUND_MAX_ATTEMPTS"`
+	OutboundRetryBackoff    time.Duration `env:"OUTBOUND_RETRY_BACKOFF"`
+	OutboundRetryMaxBackoff time.Duration `env:"OUTBOUND_RETRY_MAX_BACKOFF"`
+	RetryJitter             float64       `env:"RETRY_JITTER"`
+
+	// Hedging of notifier calls: a second request is sent once a call takes
+	// longer than the p95 latency of recent calls, but not before
+	// NotifierHedgeMinDelay.
+	NotifierHedgingEnabled bool          `env:"NOTIFIER_HEDGING_ENABLED"`
+	NotifierHedgeMinDelay  time.Duration `env:"NOTIFIER_HEDGE_MIN_DELAY"`
+
+	// NATS JetStream settings. Task events are published when NATSURL is set.
+	NATSURL           string `env:"NATS_URL"`
+	NATSStream        string `env:"NATS_STREAM"`
+	NATSSubjectPrefix string `env:"NATS_SUBJECT_PREFIX"`
+	NATSConsumer      string `env:"NATS_CONSUMER"`
+
+	// Redis Streams settings. Task events are also added to RedisStream
+	// (trimmed to about RedisStreamMaxLen entries) when RedisURL is set.
+	// cmd/streamworker reads them as RedisConsumerName in
+	// RedisConsumerGroup and claims entries left pending by other consumers
+	// for RedisClaimMinIdle.
+	RedisURL           string        `env:"REDIS_URL"`
+	RedisStream        string        `env:"REDIS_STREAM"`
+	RedisStreamMaxLen  int64         `env:"REDIS_STREAM_MAXLEN"`
+	RedisConsumerGroup string        `env:"REDIS_CONSUMER_GROUP"`
+	RedisConsumerName  string        `env:"REDIS_CONSUMER_NAME"`
+	RedisClaimMinIdle  time.Duration `env:"REDIS_CLAIM_MIN_IDLE"`
+
+	// Dual-write settings. When enabled, task writes are mirrored to a
+	// secondary repository; DualWriteReadFrom (primary or secondary)
+	// selects the repository serving reads.
+	DualWriteEnabled  bool   `env:"DUAL_WRITE_ENABLED"`
+	DualWriteReadFrom string `env:"DUAL_WRITE_READ_FROM"`
+
+	// ChangesMaxWait caps the wait of change feed long polls. It must stay
+	// below the 60s request timeout.
+	ChangesMaxWait time.Duration `env:"CHANGES_MAX_WAIT"`
+
+	// StatsCacheTTL is how long task statistics are cached; 0 disables
+	// caching.
+	StatsCacheTTL time.Duration `env:"STATS_CACHE_TTL"`
+
+	// TraceUIURLTemplate links trace IDs shown by the dashboard to a trace
+	// UI; {trace_id} is replaced by the trace ID. Empty shows no links.
+	TraceUIURLTemplate string `env:"TRACE_UI_URL_TEMPLATE"`
+	// ErrorTraceLinks adds a trace_url linking to the trace UI to error
+	// responses.
+	ErrorTraceLinks bool `env:"ERROR_TRACE_LINKS"`
+
+	// Bulk import settings
+	ImportBatchSize   int64 `env:"IMPORT_BATCH_SIZE"`
+	ImportConcurrency int64 `env:"IMPORT_CONCURRENCY"`
+	ImportMaxBytes    int64 `env:"IMPORT_MAX_BYTES"`
+
+	// WebhookSecrets holds the secrets webhook deliveries are signed with,
+	// as comma-separated source=secret pairs (e.g. github=s3cret). Only
+	// the sources with a secret are served.
+	WebhookSecrets string `env:"WEBHOOK_SECRETS"`
+
+	// Attachment storage settings (local or s3). S3Endpoint and
+	// S3UsePathStyle allow pointing at MinIO.
+	AttachmentStorage  string `env:"ATTACHMENT_STORAGE"`
+	AttachmentDir      string `env:"ATTACHMENT_DIR"`
+	AttachmentMaxBytes int64  `env:"ATTACHMENT_MAX_BYTES"`
+	S3Bucket           string `env:"S3_BUCKET"`
+	S3Region           string `env:"S3_REGION"`
+	S3Endpoint         string `env:"S3_ENDPOINT"`
+	S3UsePathStyle     bool   `env:"S3_USE_PATH_STYLE"`
+
+	// Task snapshots are written as NDJSON to the attachment storage below
+	// SnapshotPrefix, on the cron SnapshotSchedule (empty disables
+	// scheduled snapshots) and on demand through the admin API.
+	SnapshotSchedule string `env:"SNAPSHOT_SCHEDULE"`
+	SnapshotPrefix   string `env:"SNAPSHOT_PREFIX"`
+
+	// JobWorkers is the number of workers of the background job queue.
+	JobWorkers int64 `env:"JOB_WORKERS"`
+
+	// FeatureFlagsFile is an optional JSON file of OpenFeature flag values.
+	FeatureFlagsFile string `env:"FEATURE_FLAGS_FILE"`
+
+	// Audit settings
+	AuditStoreEnabled bool `env:"AUDIT_STORE_ENABLED"`
+	// AuditStoreSize is the number of latest entries the audit table keeps.
+	AuditStoreSize int64 `env:"AUDIT_STORE_SIZE"`
+
+	// Recurring task scheduler settings
+	SchedulerEnabled  bool          `env:"SCHEDULER_ENABLED"`
+	SchedulerInterval time.Duration `env:"SCHEDULER_INTERVAL"`
+
+	// Read coalescing collapses concurrent identical task reads into one
+	// repository call; NegativeCacheTTL caches not-found lookups (0 disables).
+	ReadCoalescingEnabled bool          `env:"READ_COALESCING_ENABLED"`
+	NegativeCacheTTL      time.Duration `env:"NEGATIVE_CACHE_TTL"`
+
+	// CacheConsistency (eventual or read_your_writes) decides whether reads
+	// may miss preceding writes; with read_your_writes, a writer's reads
+	// bypass coalescing and the negative cache for ReadYourWritesWindow.
+	CacheConsistency     string        `env:"CACHE_CONSISTENCY"`
+	ReadYourWritesWindow time.Duration `env:"READ_YOUR_WRITES_WINDOW"`
+
+	// API v1 deprecation: with APIV1DeprecatedAt set (a date, e.g.
+	// 2026-10-01), v1 responses carry Deprecation, Sunset (APIV1SunsetAt,
+	// if set) and successor Link headers pointing to the v2 API.
+	APIV1DeprecatedAt string `env:"API_V1_DEPRECATED_AT"`
+	APIV1SunsetAt     string `env:"API_V1_SUNSET_AT"`
+
+	// TaskIDStrategy generates task IDs: uuidv7 (default), ulid, ksuid or
+	// uuidv4.
+	TaskIDStrategy string `env:"TASK_ID_STRATEGY"`
+
+	// UniqueTaskTitles rejects tasks whose title the owner already uses.
+	UniqueTaskTitles bool `env:"UNIQUE_TASK_TITLES"`
+
+	// RepositoryMaxTasks caps the number of stored tasks (0 = unbounded);
+	// RepositoryFullPolicy (lru or reject) decides whether creating a task
+	// in a full repository evicts the least recently used task or fails.
+	RepositoryMaxTasks   int64  `env:"REPOSITORY_MAX_TASKS"`
+	RepositoryFullPolicy string `env:"REPOSITORY_FULL_POLICY"`
+
+	// Task reminder settings. Reminders are delivered by the scheduler over
+	// ReminderChannel (log, webhook or email); an empty channel disables them.
+	ReminderChannel      string        `env:"REMINDER_CHANNEL"`
+	ReminderWebhookURL   string        `env:"REMINDER_WEBHOOK_URL"`
+	ReminderEmailFrom    string        `env:"REMINDER_EMAIL_FROM"`
+	ReminderEmailTo      string        `env:"REMINDER_EMAIL_TO"`
+	ReminderMaxAttempts  int64         `env:"REMINDER_MAX_ATTEMPTS"`
+	ReminderRetryBackoff time.Duration `env:"REMINDER_RETRY_BACKOFF"`
+
+	// Task quotas (0 means unlimited). AdminToken protects the admin API,
+	// which is only served when it is set.
+	QuotaMaxTasksPerUser   int64  `env:"QUOTA_MAX_TASKS_PER_USER"`
+	QuotaMaxTasksPerTenant int64  `env:"QUOTA_MAX_TASKS_PER_TENANT"`
+	AdminToken             string `env:"ADMIN_TOKEN"`
+
+	// Per-tenant policies. TenantRateLimit (requests per second, 0 means
+	// unlimited) and TenantRateBurst are the default rate limit of every
+	// tenant; TenantPoliciesFile (JSON) overrides the sampling ratio and
+	// rate limit of individual tenants. TenantRateLimiter is "local" to
+	// enforce rate limits per replica or "redis" to share them between
+	// replicas through TenantRateLimiterRedisURL (RedisURL if empty).
+	TenantRateLimit           float64 `env:"TENANT_RATE_LIMIT"`
+	TenantRateBurst           int64   `env:"TENANT_RATE_BURST"`
+	TenantPoliciesFile        string  `env:"TENANT_POLICIES_FILE"`
+	TenantRateLimiter         string  `env:"TENANT_RATE_LIMITER"`
+	TenantRateLimiterRedisURL string  `env:"TENANT_RATE_LIMITER_REDIS_URL"`
 }
 
-// Load returns configuration from environment variables with sensible defaults.
-func Load() *Config {
-	return &Config{
-		ServerPort:   getEnv("SERVER_PORT", "8080"),
+// Load returns configuration from environment variables with sensible
+// defaults. It fails if a setting has a value that can't work, so the
+// process stops at start instead of when the setting is used.
+func Load() (*Config, error) {
+	environment := getEnv("ENVIRONMENT", "development")
+	profile := ProfileFor(environment)
+
+	cfg := &Config{
+		ServerPort:           getEnv("SERVER_PORT", "8080"),
+		ShutdownDrainTimeout: getEnvDuration("SHUTDOWN_DRAIN_TIMEOUT", 30*time.Second),
+
+		OverloadSheddingEnabled:  getEnvBool("OVERLOAD_SHEDDING_ENABLED", false),
+		OverloadMaxInFlight:      getEnvInt64("OVERLOAD_MAX_INFLIGHT", 100),
+		OverloadLatencyThreshold: getEnvDuration("OVERLOAD_LATENCY_THRESHOLD", time.Second),
+		OverloadInterval:         getEnvDuration("OVERLOAD_INTERVAL", 5*time.Second),
+
+		H2CEnabled:  getEnvBool("H2C_ENABLED", false),
+		HTTP3Addr:   getEnv("HTTP3_ADDR", ""),
+		TLSCertFile: getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:  getEnv("TLS_KEY_FILE", ""),
+
+		CORSAllowedOrigins:   getEnvStrings("CORS_ALLOWED_ORIGINS", nil),
+		CORSAllowedMethods:   getEnvStrings("CORS_ALLOWED_METHODS", nil),
+		CORSAllowedHeaders:   getEnvStrings("CORS_ALLOWED_HEADERS", nil),
+		CORSExposedHeaders:   getEnvStrings("CORS_EXPOSED_HEADERS", nil),
+		CORSAllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		CORSMaxAge:           getEnvDuration("CORS_MAX_AGE", 0),
+		CORSConfigFile:       getEnv("CORS_CONFIG_FILE", ""),
+
+		RUMBaggageKeys: getEnvStrings("RUM_BAGGAGE_KEYS", []string{"session.id"}),
+
+		MaxRequestBodyBytes: getEnvInt64("MAX_REQUEST_BODY_BYTES", 1<<20),
+		StrictJSON:          getEnvBool("STRICT_JSON", false),
+		DedupWindow:         getEnvDuration("DEDUP_WINDOW", 0),
+
+		CursorSecret: getEnv("CURSOR_SECRET", ""),
+
 		OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4317"),
 		ServiceName:  getEnv("OTEL_SERVICE_NAME", "go-samples"),
-		Environment:  getEnv("ENVIRONMENT", "development"),
+		Environment:  environment,
+
+		TelemetryProfile:  profile.Name,
+		TracesSampleRatio: getEnvFloat("TRACES_SAMPLE_RATIO", profile.TracesSampleRatio),
+		LogLevel:          getEnv("LOG_LEVEL", profile.LogLevel),
+
+		AdaptiveSamplingEnabled:          getEnvBool("ADAPTIVE_SAMPLING_ENABLED", false),
+		AdaptiveSamplingMaxRatio:         getEnvFloat("ADAPTIVE_SAMPLING_MAX_RATIO", 1),
+		AdaptiveSamplingSLOTarget:        getEnvFloat("ADAPTIVE_SAMPLING_SLO_TARGET", 0.99),
+		AdaptiveSamplingLatencyThreshold: getEnvDuration("ADAPTIVE_SAMPLING_LATENCY_THRESHOLD", 500*time.Millisecond),
+		AdaptiveSamplingBurnRate:         getEnvFloat("ADAPTIVE_SAMPLING_BURN_RATE", 2),
+		AdaptiveSamplingInterval:         getEnvDuration("ADAPTIVE_SAMPLING_INTERVAL", 10*time.Second),
+
+		ForceTraceToken:           getEnv("FORCE_TRACE_TOKEN", ""),
+		ForceTraceAllowedNetworks: getEnvStrings("FORCE_TRACE_ALLOWED_NETWORKS", nil),
+
+		CardinalityReportEnabled:  getEnvBool("CARDINALITY_REPORT_ENABLED", true),
+		CardinalityReportInterval: getEnvDuration("CARDINALITY_REPORT_INTERVAL", time.Minute),
+		CardinalityReportTopN:     getEnvInt64("CARDINALITY_REPORT_TOP_N", 5),
+		CardinalityWarnThreshold:  getEnvInt64("CARDINALITY_WARN_THRESHOLD", 1000),
+
+		AccessLogEnabled: getEnvBool("ACCESS_LOG_ENABLED", false),
+
+		OTLPTracesHeaders:      getEnv("OTEL_EXPORTER_OTLP_TRACES_HEADERS", getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")),
+		OTLPMetricsHeaders:     getEnv("OTEL_EXPORTER_OTLP_METRICS_HEADERS", getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")),
+		OTLPLogsHeaders:        getEnv("OTEL_EXPORTER_OTLP_LOGS_HEADERS", getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")),
+		OTLPTracesCompression:  getEnv("OTEL_EXPORTER_OTLP_TRACES_COMPRESSION", getEnv("OTEL_EXPORTER_OTLP_COMPRESSION", "none")),
+		OTLPMetricsCompression: getEnv("OTEL_EXPORTER_OTLP_METRICS_COMPRESSION", getEnv("OTEL_EXPORTER_OTLP_COMPRESSION", "none")),
+		OTLPLogsCompression:    getEnv("OTEL_EXPORTER_OTLP_LOGS_COMPRESSION", getEnv("OTEL_EXPORTER_OTLP_COMPRESSION", "none")),
+		OTLPInsecure:           getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", true),
+
+		OTLPTracesEndpoint:   getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4317")),
+		OTLPMetricsEndpoint:  getEnv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4317")),
+		OTLPLogsEndpoint:     getEnv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4317")),
+		OTLPSharedConnection: getEnvBool("OTLP_SHARED_CONNECTION", false),
+
+		OTLPMirrorEndpoint:    getEnv("OTLP_MIRROR_ENDPOINT", ""),
+		OTLPMirrorSignals:     getEnvStrings("OTLP_MIRROR_SIGNALS", []string{"traces", "metrics", "logs"}),
+		OTLPMirrorHeaders:     getEnv("OTLP_MIRROR_HEADERS", ""),
+		OTLPMirrorCompression: getEnv("OTLP_MIRROR_COMPRESSION", "none"),
+
+		TracesExporter:         getEnv("TRACES_EXPORTER", profile.TracesExporter),
+		TracesExporterEndpoint: getEnv("TRACES_EXPORTER_ENDPOINT", ""),
+
+		TracesBatchTimeout:       getEnvDuration("TRACES_BATCH_TIMEOUT", 0),
+		TracesMaxExportBatchSize: getEnvInt64("TRACES_MAX_EXPORT_BATCH_SIZE", 0),
+		TracesMaxQueueSize:       getEnvInt64("TRACES_MAX_QUEUE_SIZE", 0),
+		TracesBlockOnQueueFull:   getEnvBool("TRACES_BLOCK_ON_QUEUE_FULL", false),
+
+		LogsMaxQueueSize:     getEnvInt64("LOGS_MAX_QUEUE_SIZE", 0),
+		LogsBlockOnQueueFull: getEnvBool("LOGS_BLOCK_ON_QUEUE_FULL", false),
+
+		SupportLogBufferSize: getEnvInt64("SUPPORT_LOG_BUFFER_SIZE", 1000),
+
+		TelemetryBlockTimeout:     getEnvDuration("TELEMETRY_BLOCK_TIMEOUT", 100*time.Millisecond),
+		TelemetryDropWarnInterval: getEnvDuration("TELEMETRY_DROP_WARN_INTERVAL", time.Minute),
+		TelemetryShutdownTimeout:  getEnvDuration("TELEMETRY_SHUTDOWN_TIMEOUT", 10*time.Second),
+
+		TelemetryExportStatsEnabled: getEnvBool("TELEMETRY_EXPORT_STATS_ENABLED", true),
+
+		SpanAttributeCountLimit:       getEnvInt64("SPAN_ATTRIBUTE_COUNT_LIMIT", 0),
+		SpanAttributeValueLengthLimit: getEnvInt64("SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT", 0),
+		SpanEventCountLimit:           getEnvInt64("SPAN_EVENT_COUNT_LIMIT", 0),
+
+		SpanDeploymentAttributes: getEnv("SPAN_DEPLOYMENT_ATTRIBUTES",
+			"cloud.region=DEPLOYMENT_REGION,cloud.availability_zone=DEPLOYMENT_ZONE,service.instance.id=HOSTNAME"),
+
+		MetricsTemporality: getEnv("OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE", "cumulative"),
+
+		MetricsHistogramAggregation: getEnv("OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION", "explicit_bucket_histogram"),
+		MetricsDurationBuckets:      getEnvFloats("METRICS_DURATION_BUCKETS", nil),
+
+		MetricsExportInterval: getEnvDuration("METRICS_EXPORT_INTERVAL", profile.MetricsExportInterval),
+
+		HTTPMetricsLegacy: getEnvBool("HTTP_METRICS_LEGACY", false),
+
+		AssigneeGaugeTopN: getEnvInt64("ASSIGNEE_GAUGE_TOP_N", 10),
+
+		HostMetricsEnabled: getEnvBool("HOST_METRICS_ENABLED", true),
+
+		WarmupEnabled:     getEnvBool("WARMUP_ENABLED", false),
+		WarmupIterations:  getEnvInt64("WARMUP_ITERATIONS", 20),
+		WarmupConcurrency: getEnvInt64("WARMUP_CONCURRENCY", 4),
+
+		SeedEnabled: getEnvBool("SEED_ENABLED", false),
+		SeedFile:    getEnv("SEED_FILE", ""),
+
+		ProfilingEnabled:       getEnvBool("PROFILING_ENABLED", false),
+		ProfilingServerAddress: getEnv("PROFILING_SERVER_ADDRESS", "http://localhost:4040"),
+		ProfilingProfileTypes: getEnvStrings("PROFILING_PROFILE_TYPES", []string{
+			"cpu", "alloc_objects", "alloc_space", "inuse_objects", "inuse_space",
+		}),
+		ProfilingUploadInterval:    getEnvDuration("PROFILING_UPLOAD_INTERVAL", 15*time.Second),
+		ProfilingBasicAuthUser:     getEnv("PROFILING_BASIC_AUTH_USER", ""),
+		ProfilingBasicAuthPassword: getEnv("PROFILING_BASIC_AUTH_PASSWORD", ""),
+
+		ReadinessRequireExporters: getEnvBool("READINESS_REQUIRE_EXPORTERS", false),
+		ChannelzAddr:              getEnv("CHANNELZ_ADDR", ""),
+
+		TelemetryFallback:      getEnv("TELEMETRY_FALLBACK", ""),
+		TelemetryProbeInterval: getEnvDuration("TELEMETRY_PROBE_INTERVAL", 30*time.Second),
+
+		TelemetryFileDir:        getEnv("TELEMETRY_FILE_DIR", ""),
+		TelemetryFileMaxBytes:   getEnvInt64("TELEMETRY_FILE_MAX_BYTES", 100<<20),
+		TelemetryFileMaxBackups: getEnvInt64("TELEMETRY_FILE_MAX_BACKUPS", 5),
+
+		NotifierURL: getEnv("NOTIFIER_URL", ""),
+
+		OutboundMaxAttempts:     getEnvInt64("OUTBOUND_MAX_ATTEMPTS", 3),
+		OutboundRetryBackoff:    getEnvDuration("OUTBOUND_RETRY_BACKOFF", 100*time.Millisecond),
+		OutboundRetryMaxBackoff: getEnvDuration("OUTBOUND_RETRY_MAX_BACKOFF", 2*time.Second),
+		RetryJitter:             getEnvFloat("RETRY_JITTER", 0.2),
+
+		NotifierHedgingEnabled: getEnvBool("NOTIFIER_HEDGING_ENABLED", false),
+		NotifierHedgeMinDelay:  getEnvDuration("NOTIFIER_HEDGE_MIN_DELAY", 10*time.Millisecond),
+
+		NATSURL:           getEnv("NATS_URL", ""),
+		NATSStream:        getEnv("NATS_STREAM", "TASKS"),
+		NATSSubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", "tasks"),
+		NATSConsumer:      getEnv("NATS_CONSUMER", "task-consumer"),
+
+		RedisURL:           getEnv("REDIS_URL", ""),
+		RedisStream:        getEnv("REDIS_STREAM", "tasks:events"),
+		RedisStreamMaxLen:  getEnvInt64("REDIS_STREAM_MAXLEN", 10000),
+		RedisConsumerGroup: getEnv("REDIS_CONSUMER_GROUP", "streamworker"),
+		RedisConsumerName:  getEnv("REDIS_CONSUMER_NAME", ""),
+		RedisClaimMinIdle:  getEnvDuration("REDIS_CLAIM_MIN_IDLE", 30*time.Second),
+
+		DualWriteEnabled:  getEnvBool("DUAL_WRITE_ENABLED", false),
+		DualWriteReadFrom: getEnv("DUAL_WRITE_READ_FROM", "primary"),
+
+		ChangesMaxWait: getEnvDuration("CHANGES_MAX_WAIT", 50*time.Second),
+
+		StatsCacheTTL: getEnvDuration("STATS_CACHE_TTL", 5*time.Second),
+
+		TraceUIURLTemplate: getEnv("TRACE_UI_URL_TEMPLATE", "http://localhost:16686/trace/{trace_id}"),
+		ErrorTraceLinks:    getEnvBool("ERROR_TRACE_LINKS", false),
+
+		ImportBatchSize:   getEnvInt64("IMPORT_BATCH_SIZE", 100),
+		ImportConcurrency: getEnvInt64("IMPORT_CONCURRENCY", 4),
+		ImportMaxBytes:    getEnvInt64("IMPORT_MAX_BYTES", 10<<20),
+
+		WebhookSecrets: getEnv("WEBHOOK_SECRETS", ""),
+
+		AttachmentStorage:  getEnv("ATTACHMENT_STORAGE", "local"),
+		AttachmentDir:      getEnv("ATTACHMENT_DIR", "data/attachments"),
+		AttachmentMaxBytes: getEnvInt64("ATTACHMENT_MAX_BYTES", 10<<20),
+		S3Bucket:           getEnv("S3_BUCKET", "go-otel-sample"),
+		S3Region:           getEnv("S3_REGION", "us-east-1"),
+		S3Endpoint:         getEnv("S3_ENDPOINT", ""),
+		S3UsePathStyle:     getEnvBool("S3_USE_PATH_STYLE", false),
+
+		SnapshotSchedule: getEnv("SNAPSHOT_SCHEDULE", ""),
+		SnapshotPrefix:   getEnv("SNAPSHOT_PREFIX", "snapshots/"),
+
+		JobWorkers: getEnvInt64("JOB_WORKERS", 2),
+
+		FeatureFlagsFile: getEnv("FEATURE_FLAGS_FILE", ""),
+
+		AuditStoreEnabled: getEnvBool("AUDIT_STORE_ENABLED", true),
+		AuditStoreSize:    getEnvInt64("AUDIT_STORE_SIZE", 10000),
+
+		SchedulerEnabled:  getEnvBool("SCHEDULER_ENABLED", true),
+		SchedulerInterval: getEnvDuration("SCHEDULER_INTERVAL", 10*time.Second),
+
+		ReadCoalescingEnabled: getEnvBool("READ_COALESCING_ENABLED", true),
+		NegativeCacheTTL:      getEnvDuration("NEGATIVE_CACHE_TTL", 0),
+
+		CacheConsistency:     getEnv("CACHE_CONSISTENCY", "eventual"),
+		ReadYourWritesWindow: getEnvDuration("READ_YOUR_WRITES_WINDOW", 5*time.Second),
+
+		APIV1DeprecatedAt: getEnv("API_V1_DEPRECATED_AT", "2026-10-01"),
+		APIV1SunsetAt:     getEnv("API_V1_SUNSET_AT", "2027-04-01"),
+
+		TaskIDStrategy:   getEnv("TASK_ID_STRATEGY", "uuidv7"),
+		UniqueTaskTitles: getEnvBool("UNIQUE_TASK_TITLES", false),
+
+		RepositoryMaxTasks:   getEnvInt64("REPOSITORY_MAX_TASKS", 0),
+		RepositoryFullPolicy: getEnv("REPOSITORY_FULL_POLICY", "lru"),
+
+		ReminderChannel:      getEnv("REMINDER_CHANNEL", "log"),
+		ReminderWebhookURL:   getEnv("REMINDER_WEBHOOK_URL", ""),
+		ReminderEmailFrom:    getEnv("REMINDER_EMAIL_FROM", ""),
+		ReminderEmailTo:      getEnv("REMINDER_EMAIL_TO", ""),
+		ReminderMaxAttempts:  getEnvInt64("REMINDER_MAX_ATTEMPTS", 3),
+		ReminderRetryBackoff: getEnvDuration("REMINDER_RETRY_BACKOFF", time.Second),
+
+		QuotaMaxTasksPerUser:   getEnvInt64("QUOTA_MAX_TASKS_PER_USER", 0),
+		QuotaMaxTasksPerTenant: getEnvInt64("QUOTA_MAX_TASKS_PER_TENANT", 0),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+
+		TenantRateLimit:           getEnvFloat("TENANT_RATE_LIMIT", 0),
+		TenantRateBurst:           getEnvInt64("TENANT_RATE_BURST", 0),
+		TenantPoliciesFile:        getEnv("TENANT_POLICIES_FILE", ""),
+		TenantRateLimiter:         getEnv("TENANT_RATE_LIMITER", "local"),
+		TenantRateLimiterRedisURL: getEnv("TENANT_RATE_LIMITER_REDIS_URL", ""),
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate returns the errors of the settings that can't work.
+func (c *Config) validate() error {
+	var errs []error
+	switch c.DualWriteReadFrom {
+	case "primary", "secondary":
+	default:
+		errs = append(errs, fmt.Errorf("invalid DUAL_WRITE_READ_FROM %q, must be one of primary, secondary", c.DualWriteReadFrom))
+	}
+	if c.CORSAllowCredentials && slices.Contains(c.CORSAllowedOrigins, "*") {
+		errs = append(errs, errors.New("CORS_ALLOW_CREDENTIALS can't be combined with CORS_ALLOWED_ORIGINS=*"))
 	}
+	if c.HTTP3Addr != "" && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("HTTP3_ADDR requires TLS_CERT_FILE and TLS_KEY_FILE"))
+	}
+	return errors.Join(errs...)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -31,3 +668,70 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloats parses a comma-separated list of floats, e.g. "0.01,0.1,1".
+func getEnvFloats(key string, defaultValue []float64) []float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var floats []float64
+	for _, part := range strings.Split(value, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return defaultValue
+		}
+		floats = append(floats, f)
+	}
+	return floats
+}
+
+// getEnvStrings parses a comma-separated list, skipping empty entries.
+func getEnvStrings(key string, defaultValue []string) []string {
+	if _, ok := os.LookupEnv(key); !ok {
+		return defaultValue
+	}
+	var values []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Names of the telemetry profiles.
+const (
+	ProfileDev     = "dev"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
+)
+
+// TelemetryProfile bundles the telemetry defaults of an environment. Each
+// value can still be overridden by its own environment variable.
+type TelemetryProfile struct {
+	Name                  string
+	TracesExporter        string
+	TracesSampleRatio     float64
+	LogLevel              string
+	MetricsExportInterval time.Duration
+}
+
+// profiles holds the telemetry profiles by name. Development keeps every
+// trace and debug log for learning; staging and production sample and
+// export less to bound the cost of the telemetry backend.
+var profiles = map[string]TelemetryProfile{
+	ProfileDev: {
+		Name:                  ProfileDev,
+		TracesExporter:        "otlp",
+		TracesSampleRatio:     1,
+		LogLevel:              "debug",
+		MetricsExportInterval: 10 * time.Second,
+	},
+	ProfileStaging: {
+		Name:                  ProfileStaging,
+		TracesExporter:        "otlp",
+		TracesSampleRatio:     0.5,
+		LogLevel:              "info",
+		MetricsExportInterval: 30 * time.Second,
+	},
+	ProfileProd: {
+		Name:                  ProfileProd,
+		TracesExporter:        "otlp",
+		TracesSampleRatio:     0.1,
+		LogLevel:              "info",
+		MetricsExportInterval: time.Minute,
+	},
+}
+
+// ProfileFor returns the telemetry profile of environment. The usual long
+// and short names are accepted (development/dev, staging/stage,
+// production/prod); other environments get the dev profile.
+func ProfileFor(environment string) TelemetryProfile {
+	switch strings.ToLower(environment) {
+	case "staging", "stage":
+		return profiles[ProfileStaging]
+	case "production", "prod":
+		return profiles[ProfileProd]
+	default:
+		return profiles[ProfileDev]
+	}
+}
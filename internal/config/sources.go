@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sources of configuration values.
+const (
+	SourceEnv     = "env"
+	SourceProfile = "profile"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// profileFields lists the fields whose defaults come from the telemetry
+// profile of the environment.
+var profileFields = map[string]bool{
+	"TelemetryProfile":      true,
+	"TracesExporter":        true,
+	"TracesSampleRatio":     true,
+	"LogLevel":              true,
+	"MetricsExportInterval": true,
+}
+
+// corsFileFields lists the fields that CORSConfigFile sets when their
+// environment variables are not set.
+var corsFileFields = map[string]bool{
+	"CORSAllowedOrigins":   true,
+	"CORSAllowedMethods":   true,
+	"CORSAllowedHeaders":   true,
+	"CORSExposedHeaders":   true,
+	"CORSAllowCredentials": true,
+	"CORSMaxAge":           true,
+}
+
+// Setting is a configuration value, redacted like in Redacted, with where it
+// comes from. Env is the environment variable that set the value or would
+// set it. Ignored holds the value of a variable that was set but could not
+// be parsed, so the default applies instead.
+type Setting struct {
+	Value   any    `json:"value"`
+	Source  string `json:"source"`
+	Env     string `json:"env,omitempty"`
+	File    string `json:"file,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	Ignored string `json:"ignored,omitempty"`
+}
+
+// Settings returns the effective configuration keyed by field name, with
+// the source of every value: an environment variable, the telemetry
+// profile, a configuration file or the built-in default. Values from a
+// file are applied after loading, so the value of a file setting is the one
+// it overrides.
+func (c *Config) Settings() map[string]Setting {
+	values := c.Redacted()
+	t := reflect.TypeOf(*c)
+
+	settings := make(map[string]Setting, len(values))
+	for i := range t.NumField() {
+		f := t.Field(i)
+		s := Setting{Value: values[f.Name], Source: SourceDefault}
+
+		keys := strings.Split(f.Tag.Get("env"), ",")
+		s.Env = keys[0]
+		for _, key := range keys {
+			if key == "" {
+				continue
+			}
+			if raw, ok := os.LookupEnv(key); ok && (raw != "" || f.Type == reflect.TypeFor[[]string]()) {
+				s.Env = key
+				if parses(f.Type, raw) {
+					s.Source = SourceEnv
+				} else if secretFields[f.Name] {
+					s.Ignored = redacted
+				} else {
+					s.Ignored = raw
+				}
+				break
+			}
+		}
+
+		if s.Source == SourceDefault {
+			switch {
+			case profileFields[f.Name]:
+				s.Source = SourceProfile
+				s.Profile = c.TelemetryProfile
+			case corsFileFields[f.Name] && c.CORSConfigFile != "":
+				s.Source = SourceFile
+				s.File = c.CORSConfigFile
+			}
+		}
+		settings[f.Name] = s
+	}
+	return settings
+}
+
+// parses reports whether raw is a valid value for a field of type t, the
+// way Load parses it.
+func parses(t reflect.Type, raw string) bool {
+	var err error
+	switch t {
+	case reflect.TypeFor[bool]():
+		_, err = strconv.ParseBool(raw)
+	case reflect.TypeFor[time.Duration]():
+		_, err = time.ParseDuration(raw)
+	case reflect.TypeFor[int64]():
+		_, err = strconv.ParseInt(raw, 10, 64)
+	case reflect.TypeFor[float64]():
+		_, err = strconv.ParseFloat(raw, 64)
+	case reflect.TypeFor[[]float64]():
+		for part := range strings.SplitSeq(raw, ",") {
+			if _, err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+				break
+			}
+		}
+	}
+	return err == nil
+}
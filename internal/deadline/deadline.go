@@ -0,0 +1,158 @@
+// Package deadline propagates a timeout budget across services. A caller
+// sends the time it is willing to wait in the X-Request-Timeout header (or
+// the grpc-timeout header of gRPC); the server turns it into a context
+// deadline, and outbound calls forward what is left of it, so each hop
+// stops working once the original caller has given up.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers carrying a timeout budget. Header takes a Go duration such as
+// "1.5s" or a number of milliseconds; GRPCHeader takes the grpc-timeout
+// format of at most 8 digits and a unit (H, M, S, m, u or n).
+const (
+	Header     = "X-Request-Timeout"
+	GRPCHeader = "Grpc-Timeout"
+)
+
+// ErrInvalidBudget is returned for a malformed or non-positive budget header.
+var ErrInvalidBudget = errors.New("invalid timeout budget")
+
+// ErrBudgetExhausted is returned by Transport for calls made after the
+// budget ran out.
+var ErrBudgetExhausted = errors.New("timeout budget exhausted")
+
+// grpcUnits maps the units of the grpc-timeout header to durations.
+var grpcUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// Parse returns the budget of the request headers h, preferring Header
+// over GRPCHeader. ok is false if neither is set.
+func Parse(h http.Header) (budget time.Duration, ok bool, err error) {
+	if v := h.Get(Header); v != "" {
+		budget, err = parseDuration(v)
+	} else if v := h.Get(GRPCHeader); v != "" {
+		budget, err = parseGRPC(v)
+	} else {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return budget, true, nil
+}
+
+// parseDuration parses the value of Header.
+func parseDuration(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		ms, perr := strconv.ParseInt(v, 10, 64)
+		if perr != nil {
+			return 0, fmt.Errorf("%w: %s %q", ErrInvalidBudget, Header, v)
+		}
+		d = time.Duration(ms) * time.Millisecond
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%w: %s %q", ErrInvalidBudget, Header, v)
+	}
+	return d, nil
+}
+
+// parseGRPC parses the value of GRPCHeader.
+func parseGRPC(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("%w: %s %q", ErrInvalidBudget, GRPCHeader, v)
+	}
+	unit, ok := grpcUnits[v[len(v)-1]]
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+	if !ok || err != nil || n == 0 {
+		return 0, fmt.Errorf("%w: %s %q", ErrInvalidBudget, GRPCHeader, v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// Format formats a budget for Header, in whole milliseconds.
+func Format(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
+
+type budgetKey struct{}
+
+// budget is the timeout budget a request arrived with.
+type budget struct {
+	total time.Duration
+	start time.Time
+}
+
+// WithBudget returns a context with a deadline d from now that records d as
+// the budget of this hop, and its cancel function.
+func WithBudget(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, budgetKey{}, budget{total: d, start: time.Now()})
+	return context.WithTimeout(ctx, d)
+}
+
+// Consumed returns the time spent since the budget of ctx was received, and
+// false if ctx has no budget.
+func Consumed(ctx context.Context) (time.Duration, bool) {
+	b, ok := ctx.Value(budgetKey{}).(budget)
+	if !ok {
+		return 0, false
+	}
+	return time.Since(b.start), true
+}
+
+// Transport forwards the remaining budget of the request context in Header
+// and records it as timeout.remaining_ms on the active span, together with
+// timeout.consumed_ms, the budget this hop consumed before the call, if the
+// request arrived with one. Calls made with an expired deadline fail with
+// ErrBudgetExhausted without being sent. Requests without a deadline pass
+// through unchanged. Transport belongs below otelhttp.NewTransport, so the
+// active span is the client span of the call.
+type Transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport creates a new Transport sending requests with base.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	dl, ok := ctx.Deadline()
+	if !ok {
+		return t.base.RoundTrip(r)
+	}
+
+	remaining := time.Until(dl)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("timeout.remaining_ms", remaining.Milliseconds()))
+	if consumed, ok := Consumed(ctx); ok {
+		span.SetAttributes(attribute.Int64("timeout.consumed_ms", consumed.Milliseconds()))
+	}
+	if remaining < time.Millisecond {
+		return nil, ErrBudgetExhausted
+	}
+
+	// RoundTrip must not modify the request, so the header goes on a clone
+	r = r.Clone(ctx)
+	r.Header.Set(Header, Format(remaining))
+	return t.base.RoundTrip(r)
+}
@@ -0,0 +1,236 @@
+// Package dedup suppresses duplicate requests: a request whose body is
+// byte-identical to one the same client sent within a time window is
+// answered with the response of the first request instead of being served
+// again, e.g. a form submitted twice by a double click.
+package dedup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// replayedHeaders are the response headers replayed for a duplicate.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// entry is the response to a request, pending until done is closed.
+type entry struct {
+	done chan struct{}
+
+	// Set before done is closed; ok is false if the response wasn't
+	// successful and so isn't replayed.
+	ok      bool
+	status  int
+	header  http.Header
+	body    []byte
+	spanCtx trace.SpanContext
+	expires time.Time
+}
+
+// Deduplicator answers duplicate requests with the response of the first
+// one. Requests are duplicates if they come from the same client (the
+// X-User-ID and X-Tenant-ID headers and the client address), have the same
+// method, path, query and byte-identical bodies, and arrive within the
+// window of the first one. Only successful (2xx) responses are replayed, so
+// a failed request can be retried right away; a duplicate arriving while
+// the first request is still served waits for its response.
+type Deduplicator struct {
+	window   time.Duration
+	maxBytes int64
+
+	mu        sync.Mutex
+	entries   map[[sha256.Size]byte]*entry
+	nextSweep time.Time
+
+	deduplicated metric.Int64Counter
+}
+
+// NewDeduplicator creates a new Deduplicator with the given window. Bodies
+// larger than maxBytes are not deduplicated; 0 disables the limit.
+func NewDeduplicator(window time.Duration, maxBytes int64, meter metric.Meter) (*Deduplicator, error) {
+	deduplicated, err := meter.Int64Counter(
+		"requests_deduplicated_total",
+		metric.WithDescription("Total number of duplicate requests answered with the response of the first request"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deduplicated request counter: %w", err)
+	}
+	return &Deduplicator{
+		window:       window,
+		maxBytes:     maxBytes,
+		entries:      make(map[[sha256.Size]byte]*entry),
+		deduplicated: deduplicated,
+	}, nil
+}
+
+// Handler returns next wrapped with duplicate suppression. The server span
+// of every request records request.deduplicated, and the span of a
+// duplicate links to the span of the first request.
+func (d *Deduplicator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+
+		body, complete, err := d.readBody(r)
+		if err != nil || !complete {
+			// Leave oversized or unreadable bodies to the handler, which
+			// rejects them
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		key := d.key(r, body)
+
+		for {
+			e, leader := d.acquire(key)
+			if leader {
+				span.SetAttributes(attribute.Bool("request.deduplicated", false))
+				d.serve(w, r, next, key, e)
+				return
+			}
+
+			select {
+			case <-e.done:
+			case <-ctx.Done():
+				return
+			}
+			if !e.ok {
+				// The first request failed; serve this one instead
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				continue
+			}
+
+			span.SetAttributes(attribute.Bool("request.deduplicated", true))
+			span.AddLink(trace.Link{SpanContext: e.spanCtx})
+			d.deduplicated.Add(ctx, 1)
+
+			for _, h := range replayedHeaders {
+				if v := e.header.Get(h); v != "" {
+					w.Header().Set(h, v)
+				}
+			}
+			w.WriteHeader(e.status)
+			_, _ = w.Write(e.body)
+			return
+		}
+	})
+}
+
+// readBody reads the body of r. complete is false if the body is larger
+// than maxBytes; r.Body then still yields the whole body.
+func (d *Deduplicator) readBody(r *http.Request) (body []byte, complete bool, err error) {
+	if d.maxBytes <= 0 {
+		body, err = io.ReadAll(r.Body)
+		return body, err == nil, err
+	}
+	body, err = io.ReadAll(io.LimitReader(r.Body, d.maxBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(body)) > d.maxBytes {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false, nil
+	}
+	return body, true, nil
+}
+
+// key returns the hash identifying the request r of a client with body.
+func (d *Deduplicator) key(r *http.Request, body []byte) [sha256.Size]byte {
+	addr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		addr = r.RemoteAddr
+	}
+	h := sha256.New()
+	for _, part := range []string{r.Header.Get("X-User-ID"), r.Header.Get("X-Tenant-ID"), addr, r.Method, r.URL.RequestURI()} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// acquire returns the entry of key. leader is true if there was none or
+// it expired, in which case the caller must serve the request and complete
+// the new entry.
+func (d *Deduplicator) acquire(key [sha256.Size]byte) (e *entry, leader bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if now.After(d.nextSweep) {
+		for k, e := range d.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(d.entries, k)
+			}
+		}
+		d.nextSweep = now.Add(d.window)
+	}
+
+	if e, ok := d.entries[key]; ok && (e.expires.IsZero() || now.Before(e.expires)) {
+		return e, false
+	}
+	e = &entry{done: make(chan struct{})}
+	d.entries[key] = e
+	return e, true
+}
+
+// serve serves r with next, recording the response in e. A successful
+// response is kept for the window; otherwise e is removed.
+func (d *Deduplicator) serve(w http.ResponseWriter, r *http.Request, next http.Handler, key [sha256.Size]byte, e *entry) {
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		d.mu.Lock()
+		// A handler that panicked wrote nothing and isn't replayed either
+		if rec.wroteHeader && rec.status >= 200 && rec.status < 300 {
+			e.ok = true
+			e.status = rec.status
+			e.header = w.Header().Clone()
+			e.body = rec.body.Bytes()
+			e.spanCtx = trace.SpanContextFromContext(r.Context())
+			e.expires = time.Now().Add(d.window)
+		} else {
+			delete(d.entries, key)
+		}
+		d.mu.Unlock()
+		close(e.done)
+	}()
+
+	next.ServeHTTP(rec, r)
+}
+
+// recorder records the status and body of a response while writing it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
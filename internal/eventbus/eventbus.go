@@ -0,0 +1,32 @@
+// Package eventbus publishes the task events of pkg/events to message
+// brokers (NATS JetStream and Redis streams) and consumes them, with the
+// trace context carried in the message headers.
+package eventbus
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hiroki-koketsu/go-otel-sample/pkg/events"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/eventbus")
+
+// Publisher publishes task events.
+type Publisher interface {
+	Publish(ctx context.Context, e events.Event) error
+}
+
+// MultiPublisher publishes every event to all of its publishers, e.g. to
+// both NATS and a Redis stream. A failing publisher doesn't stop the others.
+type MultiPublisher []Publisher
+
+// Publish publishes e to all publishers and returns their joined errors.
+func (m MultiPublisher) Publish(ctx context.Context, e events.Event) error {
+	var errs []error
+	for _, p := range m {
+		errs = append(errs, p.Publish(ctx, e))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,259 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/retry"
+	"github.com/hiroki-koketsu/go-otel-sample/pkg/events"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NATSConfig holds the JetStream settings shared by publisher and consumer.
+type NATSConfig struct {
+	URL           string
+	Stream        string
+	SubjectPrefix string
+	Consumer      string
+	// PublishRetry retries failed publishes. Retried messages carry the
+	// event ID as message ID, so JetStream discards duplicates.
+	PublishRetry retry.Policy
+}
+
+func (c NATSConfig) subject(t events.Type) string {
+	return c.SubjectPrefix + "." + string(t)
+}
+
+// connect opens a NATS connection and ensures the task event stream exists.
+func connect(ctx context.Context, cfg NATSConfig) (*nats.Conn, jetstream.JetStream, jetstream.Stream, error) {
+	nc, err := nats.Connect(cfg.URL)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, nil, nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	stream, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.Stream,
+		Subjects: []string{cfg.SubjectPrefix + ".>"},
+	})
+	if err != nil {
+		nc.Close()
+		return nil, nil, nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+
+	return nc, js, stream, nil
+}
+
+// NATSPublisher publishes task events to a JetStream stream with the trace
+// context injected into the message headers.
+type NATSPublisher struct {
+	cfg NATSConfig
+	nc  *nats.Conn
+	js  jetstream.JetStream
+}
+
+// NewNATSPublisher connects to NATS and ensures the stream exists.
+func NewNATSPublisher(ctx context.Context, cfg NATSConfig) (*NATSPublisher, error) {
+	nc, js, _, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSPublisher{cfg: cfg, nc: nc, js: js}, nil
+}
+
+// Publish sends the event and waits for the JetStream acknowledgement.
+func (p *NATSPublisher) Publish(ctx context.Context, e events.Event) error {
+	subject := p.cfg.subject(e.Type)
+
+	ctx, span := tracer.Start(ctx, subject+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("nats"),
+			semconv.MessagingDestinationName(subject),
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingMessageID(e.ID),
+			attribute.String("task.id", e.TaskID),
+		),
+	)
+	defer span.End()
+
+	data, err := events.Marshal(e)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to encode event")
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	msg.Header.Set(jetstream.MsgIDHeader, e.ID)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(http.Header(msg.Header)))
+
+	var ack *jetstream.PubAck
+	err = retry.Do(ctx, "NATSPublisher.Attempt", p.cfg.PublishRetry, func(ctx context.Context) error {
+		ack, err = p.js.PublishMsg(ctx, msg)
+		return err
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to publish event")
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int64("messaging.nats.sequence", int64(ack.Sequence)))
+	return nil
+}
+
+// Close drains and closes the NATS connection.
+func (p *NATSPublisher) Close() error {
+	return p.nc.Drain()
+}
+
+// Handler processes a consumed event.
+type Handler func(ctx context.Context, e events.Event) error
+
+// NATSConsumer consumes task events from a durable JetStream consumer,
+// restoring the producer's trace context from the message headers.
+type NATSConsumer struct {
+	cfg      NATSConfig
+	nc       *nats.Conn
+	consumer jetstream.Consumer
+	logger   *slog.Logger
+
+	processed metric.Int64Counter
+}
+
+// NewNATSConsumer connects to NATS and creates (or updates) the durable consumer.
+// Consumer lag is exposed as the nats_consumer_pending_messages gauge.
+func NewNATSConsumer(ctx context.Context, cfg NATSConfig, logger *slog.Logger, meter metric.Meter) (*NATSConsumer, error) {
+	nc, _, stream, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:   cfg.Consumer,
+		AckPolicy: jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	c := &NATSConsumer{cfg: cfg, nc: nc, consumer: consumer, logger: logger}
+
+	c.processed, err = meter.Int64Counter(
+		"nats_messages_processed_total",
+		metric.WithDescription("Total number of task events processed from NATS"),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("failed to create processed counter: %w", err)
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"nats_consumer_pending_messages",
+		metric.WithDescription("Number of stream messages not yet delivered to the consumer"),
+		metric.WithUnit("{message}"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			info, err := c.consumer.Info(ctx)
+			if err != nil {
+				return nil
+			}
+			o.Observe(int64(info.NumPending), metric.WithAttributes(
+				attribute.String("messaging.consumer.group.name", cfg.Consumer),
+			))
+			return nil
+		}),
+	)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("failed to create pending messages gauge: %w", err)
+	}
+
+	return c, nil
+}
+
+// Run consumes messages until ctx is cancelled.
+func (c *NATSConsumer) Run(ctx context.Context, handle Handler) error {
+	cc, err := c.consumer.Consume(func(msg jetstream.Msg) {
+		c.process(msg, handle)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start consuming: %w", err)
+	}
+	defer cc.Stop()
+
+	<-ctx.Done()
+	return nil
+}
+
+func (c *NATSConsumer) process(msg jetstream.Msg, handle Handler) {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(http.Header(msg.Headers())))
+
+	ctx, span := tracer.Start(ctx, msg.Subject()+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("nats"),
+			semconv.MessagingDestinationName(msg.Subject()),
+			semconv.MessagingOperationTypeDeliver,
+			attribute.String("messaging.consumer.group.name", c.cfg.Consumer),
+		),
+	)
+	defer span.End()
+
+	outcome := "success"
+	defer func() {
+		c.processed.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	}()
+
+	e, err := events.Unmarshal(msg.Data())
+	if err != nil {
+		outcome = "invalid"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid event")
+		c.logger.ErrorContext(ctx, "failed to decode event", slog.Any("error", err))
+		// Terminate rather than redeliver a message that can never be decoded.
+		msg.Term()
+		return
+	}
+	span.SetAttributes(
+		semconv.MessagingMessageID(e.ID),
+		attribute.String("task.id", e.TaskID),
+	)
+
+	if err := handle(ctx, e); err != nil {
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to handle event")
+		c.logger.ErrorContext(ctx, "failed to handle event", slog.String("event_id", e.ID), slog.Any("error", err))
+		msg.Nak()
+		return
+	}
+
+	msg.Ack()
+}
+
+// Close drains and closes the NATS connection.
+func (c *NATSConsumer) Close() error {
+	return c.nc.Drain()
+}
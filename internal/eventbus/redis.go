@@ -0,0 +1,346 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/pkg/events"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisEventField is the stream entry field holding the JSON event. The
+// other fields of an entry carry the trace context.
+const redisEventField = "event"
+
+// Read sizes of the Redis consumer.
+const (
+	redisReadCount  = 10
+	redisReadBlock  = 2 * time.Second
+	redisClaimCount = 100
+)
+
+// RedisConfig holds the Redis Streams settings shared by publisher and
+// consumer.
+type RedisConfig struct {
+	URL    string
+	Stream string
+	// MaxLen approximately caps the stream length; 0 keeps every entry.
+	MaxLen int64
+	// Group and Consumer name the consumer group and this member of it.
+	Group    string
+	Consumer string
+	// ClaimMinIdle is how long an entry must be pending with another
+	// consumer before this one claims it.
+	ClaimMinIdle time.Duration
+}
+
+// connectRedis opens a Redis client for url and checks the connection.
+func connectRedis(ctx context.Context, url string) (*redis.Client, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return client, nil
+}
+
+// RedisPublisher adds task events to a Redis stream with the trace context
+// stored in fields next to the event.
+type RedisPublisher struct {
+	cfg    RedisConfig
+	client *redis.Client
+}
+
+// NewRedisPublisher connects to Redis.
+func NewRedisPublisher(ctx context.Context, cfg RedisConfig) (*RedisPublisher, error) {
+	client, err := connectRedis(ctx, cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisPublisher{cfg: cfg, client: client}, nil
+}
+
+// Publish adds the event to the stream.
+func (p *RedisPublisher) Publish(ctx context.Context, e events.Event) error {
+	ctx, span := tracer.Start(ctx, p.cfg.Stream+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("redis"),
+			semconv.MessagingDestinationName(p.cfg.Stream),
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingMessageID(e.ID),
+			attribute.String("task.id", e.TaskID),
+		),
+	)
+	defer span.End()
+
+	data, err := events.Marshal(e)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to encode event")
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	values := map[string]any{redisEventField: data}
+	for k, v := range carrier {
+		values[k] = v
+	}
+
+	id, err := p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.cfg.Stream,
+		MaxLen: p.cfg.MaxLen,
+		Approx: true,
+		Values: values,
+	}).Result()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to publish event")
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("messaging.redis.entry_id", id))
+	return nil
+}
+
+// Close closes the Redis client.
+func (p *RedisPublisher) Close() error {
+	return p.client.Close()
+}
+
+// RedisConsumer consumes task events from a Redis stream as a member of a
+// consumer group, restoring the producer's trace context from the entry
+// fields. Entries are acknowledged once handled; entries left pending by a
+// crashed or failing consumer are claimed after RedisConfig.ClaimMinIdle.
+type RedisConsumer struct {
+	cfg    RedisConfig
+	client *redis.Client
+	logger *slog.Logger
+
+	processed metric.Int64Counter
+	claimed   metric.Int64Counter
+}
+
+// NewRedisConsumer connects to Redis and creates the consumer group (and
+// the stream) if they don't exist. The group's pending entries are exposed
+// as the redis_stream_pending_messages gauge.
+func NewRedisConsumer(ctx context.Context, cfg RedisConfig, logger *slog.Logger, meter metric.Meter) (*RedisConsumer, error) {
+	client, err := connectRedis(ctx, cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		client.Close()
+		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	c := &RedisConsumer{cfg: cfg, client: client, logger: logger}
+	groupAttr := metric.WithAttributes(attribute.String("messaging.consumer.group.name", cfg.Group))
+
+	c.processed, err = meter.Int64Counter(
+		"redis_stream_messages_processed_total",
+		metric.WithDescription("Total number of task events processed from the Redis stream"),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create processed counter: %w", err)
+	}
+
+	c.claimed, err = meter.Int64Counter(
+		"redis_stream_messages_claimed_total",
+		metric.WithDescription("Total number of pending task events claimed from other consumers"),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create claimed counter: %w", err)
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"redis_stream_pending_messages",
+		metric.WithDescription("Number of stream entries delivered to the consumer group but not yet acknowledged"),
+		metric.WithUnit("{message}"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			pending, err := c.client.XPending(ctx, cfg.Stream, cfg.Group).Result()
+			if err != nil {
+				return nil
+			}
+			o.Observe(pending.Count, groupAttr)
+			return nil
+		}),
+	)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create pending messages gauge: %w", err)
+	}
+
+	return c, nil
+}
+
+// Run consumes entries until ctx is cancelled. Pending entries idle for
+// ClaimMinIdle are claimed before reading new ones, at most once per
+// ClaimMinIdle.
+func (c *RedisConsumer) Run(ctx context.Context, handle Handler) error {
+	var nextClaim time.Time
+	for ctx.Err() == nil {
+		if time.Now().After(nextClaim) {
+			c.claim(ctx, handle)
+			nextClaim = time.Now().Add(c.cfg.ClaimMinIdle)
+		}
+
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			Streams:  []string{c.cfg.Stream, ">"},
+			Count:    redisReadCount,
+			Block:    redisReadBlock,
+		}).Result()
+		switch {
+		case errors.Is(err, redis.Nil), ctx.Err() != nil:
+			continue
+		case err != nil:
+			c.logger.ErrorContext(ctx, "failed to read from stream", slog.Any("error", err))
+			c.wait(ctx, redisReadBlock)
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				c.process(ctx, msg, false, handle)
+			}
+		}
+	}
+	return nil
+}
+
+// claim takes over and processes the group's entries that have been
+// pending for at least ClaimMinIdle.
+func (c *RedisConsumer) claim(ctx context.Context, handle Handler) {
+	start := "0-0"
+	for {
+		msgs, next, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.cfg.Stream,
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			MinIdle:  c.cfg.ClaimMinIdle,
+			Start:    start,
+			Count:    redisClaimCount,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.ErrorContext(ctx, "failed to claim pending entries", slog.Any("error", err))
+			}
+			return
+		}
+		if len(msgs) > 0 {
+			c.claimed.Add(ctx, int64(len(msgs)), metric.WithAttributes(
+				attribute.String("messaging.consumer.group.name", c.cfg.Group),
+			))
+		}
+		for _, msg := range msgs {
+			c.process(ctx, msg, true, handle)
+		}
+		if next == "0-0" || len(msgs) == 0 {
+			return
+		}
+		start = next
+	}
+}
+
+func (c *RedisConsumer) process(ctx context.Context, msg redis.XMessage, claimed bool, handle Handler) {
+	carrier := propagation.MapCarrier{}
+	for k, v := range msg.Values {
+		if s, ok := v.(string); ok && k != redisEventField {
+			carrier[k] = s
+		}
+	}
+	// Keep the cancellation of ctx, but start a new trace unless the entry
+	// carries one
+	ctx = otel.GetTextMapPropagator().Extract(trace.ContextWithSpanContext(ctx, trace.SpanContext{}), carrier)
+
+	ctx, span := tracer.Start(ctx, c.cfg.Stream+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("redis"),
+			semconv.MessagingDestinationName(c.cfg.Stream),
+			semconv.MessagingOperationTypeDeliver,
+			attribute.String("messaging.consumer.group.name", c.cfg.Group),
+			attribute.String("messaging.redis.entry_id", msg.ID),
+			attribute.Bool("messaging.redis.claimed", claimed),
+		),
+	)
+	defer span.End()
+
+	outcome := "success"
+	defer func() {
+		c.processed.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	}()
+
+	data, _ := msg.Values[redisEventField].(string)
+	e, err := events.Unmarshal([]byte(data))
+	if err != nil {
+		outcome = "invalid"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid event")
+		c.logger.ErrorContext(ctx, "failed to decode event", slog.String("entry_id", msg.ID), slog.Any("error", err))
+		// Acknowledge rather than reclaim an entry that can never be decoded.
+		c.ack(ctx, msg.ID)
+		return
+	}
+	span.SetAttributes(
+		semconv.MessagingMessageID(e.ID),
+		attribute.String("task.id", e.TaskID),
+	)
+
+	if err := handle(ctx, e); err != nil {
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to handle event")
+		c.logger.ErrorContext(ctx, "failed to handle event", slog.String("event_id", e.ID), slog.Any("error", err))
+		// Left pending, to be claimed again after ClaimMinIdle.
+		return
+	}
+
+	c.ack(ctx, msg.ID)
+}
+
+func (c *RedisConsumer) ack(ctx context.Context, id string) {
+	if err := c.client.XAck(ctx, c.cfg.Stream, c.cfg.Group, id).Err(); err != nil {
+		c.logger.ErrorContext(ctx, "failed to acknowledge entry", slog.String("entry_id", id), slog.Any("error", err))
+	}
+}
+
+// wait sleeps for d or until ctx is cancelled.
+func (c *RedisConsumer) wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// Close closes the Redis client.
+func (c *RedisConsumer) Close() error {
+	return c.client.Close()
+}
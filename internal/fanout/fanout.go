@@ -0,0 +1,60 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/fanout")
+
+// Group runs branches of a fan-out concurrently, each in its own child span.
+// The first failing branch cancels the context of the others, and Wait
+// returns its error.
+type Group struct {
+	g   *errgroup.Group
+	ctx context.Context
+}
+
+// New creates a Group running at most limit branches at a time (limit <= 0
+// means no limit). The returned context is cancelled when a branch fails or
+// Wait returns.
+func New(ctx context.Context, limit int) (*Group, context.Context) {
+	g, ctx := errgroup.WithContext(ctx)
+	if limit > 0 {
+		g.SetLimit(limit)
+	}
+	return &Group{g: g, ctx: ctx}, ctx
+}
+
+// Go runs fn in a new branch with a span named spanName. It blocks while
+// the limit of running branches is reached. A branch that fails because
+// another one cancelled it is marked with fanout.cancelled instead of an
+// error status.
+func (g *Group) Go(spanName string, fn func(ctx context.Context) error, opts ...trace.SpanStartOption) {
+	g.g.Go(func() error {
+		ctx, span := tracer.Start(g.ctx, spanName, opts...)
+		defer span.End()
+
+		err := fn(ctx)
+		switch {
+		case err == nil:
+		case errors.Is(err, context.Canceled) && context.Cause(g.ctx) != nil:
+			span.SetAttributes(attribute.Bool("fanout.cancelled", true))
+		default:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	})
+}
+
+// Wait waits for all branches and returns the first error.
+func (g *Group) Wait() error {
+	return g.g.Wait()
+}
@@ -0,0 +1,56 @@
+package feature
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Flags gating features of the task API.
+const (
+	// FlagTaskArchiving enables the archive and unarchive endpoints.
+	FlagTaskArchiving = "task-archiving"
+	// FlagQuotaEnforcement enables per-user and per-tenant task quotas.
+	FlagQuotaEnforcement = "quota-enforcement"
+)
+
+// defaults are the values used for flags that aren't configured.
+var defaults = map[string]bool{
+	FlagTaskArchiving:    true,
+	FlagQuotaEnforcement: true,
+}
+
+// Flags evaluates feature flags through OpenFeature.
+type Flags struct {
+	client *openfeature.Client
+}
+
+// New registers the flags from file and the environment as the global
+// OpenFeature provider, installs the telemetry hook and returns a client.
+func New(file string, meter metric.Meter) (*Flags, error) {
+	provider, err := LoadProvider(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := openfeature.SetProviderAndWait(provider); err != nil {
+		return nil, fmt.Errorf("failed to set feature flag provider: %w", err)
+	}
+
+	hook, err := NewTelemetryHook(meter)
+	if err != nil {
+		return nil, err
+	}
+	openfeature.AddHooks(hook)
+
+	return &Flags{client: openfeature.NewClient("go-otel-sample")}, nil
+}
+
+// Enabled evaluates a boolean flag for the given caller. Unknown flags and
+// evaluation errors fall back to the flag's default.
+func (f *Flags) Enabled(ctx context.Context, flag, targetingKey string) bool {
+	evalCtx := openfeature.NewEvaluationContext(targetingKey, nil)
+	enabled, _ := f.client.BooleanValue(ctx, flag, defaults[flag], evalCtx)
+	return enabled
+}
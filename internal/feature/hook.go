@@ -0,0 +1,54 @@
+package feature
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	oftelemetry "github.com/open-feature/go-sdk/openfeature/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TelemetryHook records every flag evaluation as a feature_flag.evaluation
+// event on the active span and counts it in feature_flag_evaluations_total.
+type TelemetryHook struct {
+	openfeature.UnimplementedHook
+
+	evaluations metric.Int64Counter
+}
+
+// NewTelemetryHook creates a new TelemetryHook.
+func NewTelemetryHook(meter metric.Meter) (*TelemetryHook, error) {
+	evaluations, err := meter.Int64Counter(
+		"feature_flag_evaluations_total",
+		metric.WithDescription("Total number of feature flag evaluations"),
+		metric.WithUnit("{evaluation}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create flag evaluation counter: %w", err)
+	}
+	return &TelemetryHook{evaluations: evaluations}, nil
+}
+
+// Finally runs after every evaluation, including failed ones.
+func (h *TelemetryHook) Finally(ctx context.Context, hookContext openfeature.HookContext, details openfeature.InterfaceEvaluationDetails, _ openfeature.HookHints) {
+	event := oftelemetry.CreateEvaluationEvent(hookContext, details)
+
+	attrs := make([]attribute.KeyValue, 0, len(event.Attributes))
+	for k, v := range event.Attributes {
+		attrs = append(attrs, attribute.String(k, fmt.Sprint(v)))
+	}
+	trace.SpanFromContext(ctx).AddEvent(event.Name, trace.WithAttributes(attrs...))
+
+	// Only low-cardinality attributes on the counter; the value may be arbitrary.
+	counterAttrs := []attribute.KeyValue{
+		attribute.String(oftelemetry.FlagKey, hookContext.FlagKey()),
+		attribute.String(oftelemetry.ResultReasonKey, fmt.Sprint(event.Attributes[oftelemetry.ResultReasonKey])),
+	}
+	if details.Variant != "" {
+		counterAttrs = append(counterAttrs, attribute.String(oftelemetry.ResultVariantKey, details.Variant))
+	}
+	h.evaluations.Add(ctx, 1, metric.WithAttributes(counterAttrs...))
+}
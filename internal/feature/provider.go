@@ -0,0 +1,76 @@
+package feature
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/open-feature/go-sdk/openfeature/memprovider"
+)
+
+// EnvPrefix is the prefix of environment variables that override flags,
+// e.g. FEATURE_FLAG_TASK_ARCHIVING=false overrides "task-archiving".
+const EnvPrefix = "FEATURE_FLAG_"
+
+// LoadProvider builds an in-memory OpenFeature provider from a JSON file
+// mapping flag keys to values (e.g. {"task-archiving": true}) and from
+// FEATURE_FLAG_* environment variables, which take precedence. The file is
+// optional.
+func LoadProvider(file string) (memprovider.InMemoryProvider, error) {
+	values := make(map[string]any)
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return memprovider.InMemoryProvider{}, fmt.Errorf("failed to read feature flag file: %w", err)
+		}
+		if err := json.Unmarshal(data, &values); err != nil {
+			return memprovider.InMemoryProvider{}, fmt.Errorf("failed to parse feature flag file: %w", err)
+		}
+	}
+
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		key, ok := strings.CutPrefix(name, EnvPrefix)
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+		if b, err := strconv.ParseBool(value); err == nil {
+			values[key] = b
+		} else {
+			values[key] = value
+		}
+	}
+
+	flags := make(map[string]memprovider.InMemoryFlag, len(values))
+	for key, value := range values {
+		flags[key] = newFlag(key, value)
+	}
+	return memprovider.NewInMemoryProvider(flags), nil
+}
+
+// newFlag creates a static flag. Boolean flags get "on" and "off" variants
+// so evaluations report a variant rather than a raw value.
+func newFlag(key string, value any) memprovider.InMemoryFlag {
+	if b, ok := value.(bool); ok {
+		variant := "off"
+		if b {
+			variant = "on"
+		}
+		return memprovider.InMemoryFlag{
+			Key:            key,
+			State:          memprovider.Enabled,
+			DefaultVariant: variant,
+			Variants:       map[string]any{"on": true, "off": false},
+		}
+	}
+	return memprovider.InMemoryFlag{
+		Key:            key,
+		State:          memprovider.Enabled,
+		DefaultVariant: "value",
+		Variants:       map[string]any{"value": value},
+	}
+}
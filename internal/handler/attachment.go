@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AttachmentHandler handles HTTP requests for task attachments.
+type AttachmentHandler struct {
+	repo     repository.Repository
+	store    storage.ObjectStore
+	maxBytes int64
+
+	sizes metric.Int64Histogram
+}
+
+// NewAttachmentHandler creates a new AttachmentHandler. Uploads larger than
+// maxBytes are rejected with 413.
+func NewAttachmentHandler(repo repository.Repository, store storage.ObjectStore, meter metric.Meter, maxBytes int64) (*AttachmentHandler, error) {
+	sizes, err := meter.Int64Histogram(
+		"attachment_size_bytes",
+		metric.WithDescription("Size of uploaded and downloaded task attachments"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(1<<10, 1<<14, 1<<17, 1<<20, 1<<22, 1<<24, 1<<26),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attachment size histogram: %w", err)
+	}
+
+	return &AttachmentHandler{
+		repo:     repo,
+		store:    store,
+		maxBytes: maxBytes,
+		sizes:    sizes,
+	}, nil
+}
+
+// Routes returns the chi router with attachment routes, to be mounted
+// below /tasks/{id}/attachments.
+func (h *AttachmentHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(withActor)
+
+	r.With(withDryRun).Post("/", h.Upload)
+	r.Get("/{attachmentID}", h.Download)
+
+	return r
+}
+
+// Upload stores the multipart "file" field as a new attachment of the task.
+// A dry run reads the file to validate the body and responds with the
+// attachment as it would be stored, without storing it.
+func (h *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	dryRun := repository.IsDryRun(ctx)
+
+	ctx, span := tracer.Start(ctx, "AttachmentHandler.Upload",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.Bool("request.dry_run", dryRun),
+		),
+	)
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	if _, err := h.repo.GetByID(ctx, id); err != nil {
+		writeError(ctx, w, r, err, "failed to get task")
+		return
+	}
+
+	if h.maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		logger.WarnContext(ctx, "invalid multipart request", slog.Any("error", err))
+		response.Error(w, r, http.StatusBadRequest, "multipart/form-data body required")
+		return
+	}
+
+	// Stream the first "file" part straight into the object store
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			logger.WarnContext(ctx, "missing file field")
+			response.Error(w, r, http.StatusBadRequest, "missing file field")
+			return
+		}
+		if err != nil {
+			h.respondUploadError(w, r, err)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		att := model.Attachment{
+			ID:          uuid.New().String(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			CreatedAt:   time.Now(),
+		}
+		if att.ContentType == "" {
+			att.ContentType = "application/octet-stream"
+		}
+		att.StorageKey = "tasks/" + id + "/" + att.ID
+
+		if dryRun {
+			if att.Size, err = io.Copy(io.Discard, part); err != nil {
+				h.respondUploadError(w, r, err)
+				return
+			}
+			span.SetAttributes(attribute.Int64("attachment.size", att.Size))
+			response.JSON(w, r, http.StatusOK, att)
+			return
+		}
+
+		att.Size, err = h.store.Put(ctx, att.StorageKey, part, att.ContentType)
+		if err != nil {
+			h.store.Delete(ctx, att.StorageKey)
+			h.respondUploadError(w, r, err)
+			return
+		}
+
+		if err := h.repo.AddAttachment(ctx, id, att); err != nil {
+			h.store.Delete(ctx, att.StorageKey)
+			writeError(ctx, w, r, err, "failed to add attachment")
+			return
+		}
+
+		span.SetAttributes(
+			attribute.String("attachment.id", att.ID),
+			attribute.Int64("attachment.size", att.Size),
+		)
+		h.sizes.Record(ctx, att.Size, metric.WithAttributes(attribute.String("operation", "upload")))
+		logger.InfoContext(ctx, "attachment uploaded",
+			slog.String("id", id),
+			slog.String("attachment_id", att.ID),
+			slog.Int64("size", att.Size),
+		)
+
+		response.JSON(w, r, http.StatusCreated, att)
+		return
+	}
+}
+
+func (h *AttachmentHandler) respondUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		logger.WarnContext(ctx, "attachment too large", slog.Int64("limit", maxErr.Limit))
+		response.Error(w, r, http.StatusRequestEntityTooLarge, "attachment too large")
+		return
+	}
+	writeError(ctx, w, r, err, "failed to store attachment")
+}
+
+// Download streams an attachment to the client. The copy stops as soon as
+// the request context is cancelled (e.g. the client disconnects).
+func (h *AttachmentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	attachmentID := chi.URLParam(r, "attachmentID")
+
+	ctx, span := tracer.Start(ctx, "AttachmentHandler.Download",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.String("attachment.id", attachmentID),
+		),
+	)
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	att, err := h.repo.GetAttachment(ctx, id, attachmentID)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to get attachment")
+		return
+	}
+
+	body, err := h.store.Get(ctx, att.StorageKey)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to open attachment")
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", att.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(att.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": att.Filename}))
+	w.WriteHeader(http.StatusOK)
+
+	n, err := io.Copy(w, storage.NewContextReader(ctx, body))
+	span.SetAttributes(attribute.Int64("attachment.bytes_sent", n))
+
+	outcome := "complete"
+	if err != nil {
+		outcome = "aborted"
+		span.AddEvent("download.aborted", trace.WithAttributes(attribute.String("error", err.Error())))
+		logger.WarnContext(ctx, "attachment download aborted", slog.String("attachment_id", attachmentID), slog.Any("error", err))
+	}
+	h.sizes.Record(ctx, n, metric.WithAttributes(
+		attribute.String("operation", "download"),
+		attribute.String("outcome", outcome),
+	))
+}
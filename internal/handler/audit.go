@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/audit"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AuditHandler handles HTTP requests for audit entries.
+type AuditHandler struct {
+	store *audit.Store
+}
+
+// NewAuditHandler creates a new AuditHandler.
+func NewAuditHandler(store *audit.Store) *AuditHandler {
+	return &AuditHandler{
+		store: store,
+	}
+}
+
+// Routes returns the chi router with audit routes.
+func (h *AuditHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.List)
+
+	return r
+}
+
+// List returns audit entries filtered by the task_id, actor, action and limit query parameters.
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "AuditHandler.List")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	q := r.URL.Query()
+	filter := audit.Filter{
+		TaskID: q.Get("task_id"),
+		Actor:  q.Get("actor"),
+		Action: audit.Action(q.Get("action")),
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			logger.WarnContext(ctx, "invalid limit", slog.String("limit", v))
+			response.Error(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries := h.store.Query(ctx, filter)
+
+	span.SetAttributes(attribute.Int("audit.count", len(entries)))
+	logger.InfoContext(ctx, "audit entries listed", slog.Int("count", len(entries)))
+
+	response.JSON(w, r, http.StatusOK, entries)
+}
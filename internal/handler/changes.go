@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// defaultChangesWait is the wait used when the wait query parameter is absent.
+const defaultChangesWait = 30 * time.Second
+
+// changesWriteSlack is the time a poll has to write its response after
+// waiting; the write deadline of a waiting poll is extended by its wait
+// plus this slack, since the server's WriteTimeout is shorter than most
+// waits.
+const changesWriteSlack = 5 * time.Second
+
+// Outcomes of a changes poll.
+const (
+	pollImmediate = "immediate"
+	pollChanged   = "changed"
+	pollTimeout   = "timeout"
+	pollCancelled = "cancelled"
+)
+
+// ChangesResponse is the response of a changes poll. Clients pass Cursor as
+// the since parameter of the next poll.
+type ChangesResponse struct {
+	Changes   []model.TaskChange `json:"changes"`
+	Cursor    int64              `json:"cursor"`
+	Truncated bool               `json:"truncated,omitempty"`
+}
+
+// ChangesHandler serves the task change feed with long polling.
+type ChangesHandler struct {
+	repo    repository.Repository
+	maxWait time.Duration
+
+	pollers metric.Int64UpDownCounter
+}
+
+// NewChangesHandler creates a new ChangesHandler. Requested waits are capped
+// at maxWait.
+func NewChangesHandler(repo repository.Repository, meter metric.Meter, maxWait time.Duration) (*ChangesHandler, error) {
+	pollers, err := meter.Int64UpDownCounter(
+		"task_change_pollers",
+		metric.WithDescription("Number of clients waiting for task changes"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create change pollers counter: %w", err)
+	}
+
+	return &ChangesHandler{
+		repo:    repo,
+		maxWait: maxWait,
+		pollers: pollers,
+	}, nil
+}
+
+// Routes returns the chi router with the changes route, to be mounted below
+// /tasks/changes.
+func (h *ChangesHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.Poll)
+
+	return r
+}
+
+// Poll returns the changes after the since cursor. If there are none, it
+// waits up to the wait duration for the next change; a wait of 0 returns
+// immediately.
+func (h *ChangesHandler) Poll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "ChangesHandler.Poll")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	q := r.URL.Query()
+	var since int64
+	if v := q.Get("since"); v != "" {
+		var err error
+		since, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || since < 0 {
+			logger.WarnContext(ctx, "invalid since", slog.String("since", v))
+			response.Error(w, r, http.StatusBadRequest, "invalid since")
+			return
+		}
+	}
+	wait := defaultChangesWait
+	if v := q.Get("wait"); v != "" {
+		var err error
+		wait, err = time.ParseDuration(v)
+		if err != nil || wait < 0 {
+			logger.WarnContext(ctx, "invalid wait", slog.String("wait", v))
+			response.Error(w, r, http.StatusBadRequest, "invalid wait")
+			return
+		}
+	}
+	wait = min(wait, h.maxWait)
+
+	span.SetAttributes(
+		attribute.Int64("changes.since", since),
+		attribute.Float64("changes.wait", wait.Seconds()),
+	)
+
+	changes, cursor, truncated, changed := h.repo.Changes(ctx, since)
+	outcome := pollImmediate
+
+	if len(changes) == 0 && wait > 0 {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + changesWriteSlack)); err != nil {
+			logger.WarnContext(ctx, "failed to extend write deadline of changes poll", slog.Any("error", err))
+		}
+		start := time.Now()
+		outcome = h.wait(ctx, changed, wait)
+		span.SetAttributes(attribute.Float64("changes.waited", time.Since(start).Seconds()))
+
+		if outcome == pollCancelled {
+			span.SetAttributes(attribute.String("changes.outcome", outcome))
+			logger.InfoContext(ctx, "changes poll cancelled")
+			return
+		}
+		if outcome == pollChanged {
+			changes, cursor, truncated, _ = h.repo.Changes(ctx, since)
+		}
+	}
+
+	span.SetAttributes(
+		attribute.String("changes.outcome", outcome),
+		attribute.Int("changes.count", len(changes)),
+		attribute.Int64("changes.cursor", cursor),
+	)
+	logger.InfoContext(ctx, "changes polled",
+		slog.String("outcome", outcome),
+		slog.Int("count", len(changes)),
+		slog.Int64("cursor", cursor),
+	)
+
+	response.JSON(w, r, http.StatusOK, ChangesResponse{
+		Changes:   changes,
+		Cursor:    cursor,
+		Truncated: truncated,
+	})
+}
+
+// wait blocks until changed is closed, the wait elapses or the request is
+// context is cancelled, and returns the outcome.
+func (h *ChangesHandler) wait(ctx context.Context, changed <-chan struct{}, wait time.Duration) string {
+	h.pollers.Add(ctx, 1)
+	defer h.pollers.Add(ctx, -1)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-changed:
+		return pollChanged
+	case <-timer.C:
+		return pollTimeout
+	case <-ctx.Done():
+		return pollCancelled
+	}
+}
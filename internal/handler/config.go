@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/config"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ConfigHandler handles admin HTTP requests for the effective configuration.
+type ConfigHandler struct {
+	cfg *config.Config
+}
+
+// NewConfigHandler creates a new ConfigHandler for cfg.
+func NewConfigHandler(cfg *config.Config) *ConfigHandler {
+	return &ConfigHandler{
+		cfg: cfg,
+	}
+}
+
+// Routes returns the chi router with configuration routes.
+func (h *ConfigHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.Get)
+
+	return r
+}
+
+// Get returns the effective configuration by field name, secrets redacted,
+// with the source of every value. The source query parameter (env,
+// profile, file or default) limits it to the values from that source.
+func (h *ConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	_, span := tracer.Start(ctx, "ConfigHandler.Get")
+	defer span.End()
+
+	settings := h.cfg.Settings()
+	if source := r.URL.Query().Get("source"); source != "" {
+		span.SetAttributes(attribute.String("config.source", source))
+		for name, s := range settings {
+			if s.Source != source {
+				delete(settings, name)
+			}
+		}
+	}
+
+	span.SetAttributes(attribute.Int("config.settings", len(settings)))
+	response.JSON(w, r, http.StatusOK, settings)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jsoncodec"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Reasons a request body can be rejected.
+const (
+	rejectTooLarge     = "too_large"
+	rejectUnknownField = "unknown_field"
+	rejectMalformed    = "malformed"
+)
+
+// DecodeError describes why a request body was rejected.
+type DecodeError struct {
+	Status int
+	Reason string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+// Detail returns the client-facing description of the rejection.
+func (e *DecodeError) Detail() string {
+	switch e.Reason {
+	case rejectTooLarge:
+		return "request body too large"
+	case rejectUnknownField:
+		return strings.TrimPrefix(e.Err.Error(), "json: ")
+	default:
+		return "invalid request body"
+	}
+}
+
+// RequestDecoder decodes JSON request bodies with a size limit and an
+// optional strict mode that rejects unknown fields.
+type RequestDecoder struct {
+	maxBytes   int64
+	strict     bool
+	rejections metric.Int64Counter
+}
+
+// NewRequestDecoder creates a new RequestDecoder. A maxBytes of 0 disables the size limit.
+func NewRequestDecoder(meter metric.Meter, maxBytes int64, strict bool) (*RequestDecoder, error) {
+	rejections, err := meter.Int64Counter(
+		"http_request_body_rejections_total",
+		metric.WithDescription("Total number of rejected HTTP request bodies"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create body rejection counter: %w", err)
+	}
+
+	return &RequestDecoder{
+		maxBytes:   maxBytes,
+		strict:     strict,
+		rejections: rejections,
+	}, nil
+}
+
+// Decode reads the JSON request body and decodes it into v. The body is
+// read in full before decoding, so the decoding duration recorded with the
+// jsoncodec metrics of the request excludes reading from the network.
+func (d *RequestDecoder) Decode(ctx context.Context, w http.ResponseWriter, r *http.Request, v interface{}) *DecodeError {
+	body := r.Body
+	if d.maxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, d.maxBytes)
+	}
+
+	data, err := io.ReadAll(body)
+	if err == nil {
+		start := time.Now()
+		dec := jsoncodec.NewDecoder(bytes.NewReader(data))
+		if d.strict {
+			dec.DisallowUnknownFields()
+		}
+		err = dec.Decode(v)
+		jsoncodec.Record(ctx, jsoncodec.OperationDecode, start, len(data))
+	}
+	if err == nil {
+		return nil
+	}
+
+	derr := &DecodeError{Status: http.StatusBadRequest, Reason: rejectMalformed, Err: err}
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		derr.Status = http.StatusRequestEntityTooLarge
+		derr.Reason = rejectTooLarge
+	case strings.HasPrefix(err.Error(), "json: unknown field"):
+		derr.Reason = rejectUnknownField
+	}
+
+	d.rejections.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", derr.Reason)))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("http.request.body.rejected", derr.Reason))
+
+	return derr
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Outcomes of an endpoint call besides the error kinds, as recorded in
+// handler_requests_total.
+const (
+	outcomeSuccess        = "success"
+	outcomeInvalidRequest = "invalid_request"
+)
+
+// NoBody is the body type of endpoints without a request body, and the
+// response type of endpoints answering 204 No Content.
+type NoBody struct{}
+
+// Request is the request of an Endpoint: the HTTP request and its decoded
+// body.
+type Request[B any] struct {
+	*http.Request
+	Body B
+
+	w http.ResponseWriter
+}
+
+// Param returns the value of the URL parameter name.
+func (r *Request[B]) Param(name string) string {
+	return chi.URLParam(r.Request, name)
+}
+
+// SetHeader sets a header of the response.
+func (r *Request[B]) SetHeader(key, value string) {
+	r.w.Header().Set(key, value)
+}
+
+// Endpoint describes a JSON endpoint whose Handle func only holds the
+// business logic: Endpoints.Handle creates its span, decodes the request
+// body into B, maps errors to problem responses and encodes the returned
+// R.
+type Endpoint[B, R any] struct {
+	// Name names the span, e.g. TaskHandler.Create. Empty names it after
+	// the method and route, e.g. "GET /api/v1/tasks/{id}".
+	Name string
+	// Error is the message of unexpected errors (see writeError).
+	Error string
+	// Status is the status of successful responses, 200 if 0. A dry run
+	// (see withDryRun) answers 201 Created with 200, since nothing was
+	// created.
+	Status int
+	// IDAttribute is the span attribute recording the id URL parameter,
+	// e.g. task.id; empty doesn't record it.
+	IDAttribute string
+	// OptionalBody accepts requests without a body, leaving Body zero.
+	OptionalBody bool
+
+	Handle func(ctx context.Context, r *Request[B]) (R, error)
+}
+
+// Endpoints serves Endpoints, decoding their requests with a shared
+// RequestDecoder and counting their calls per outcome.
+type Endpoints struct {
+	decoder  *RequestDecoder
+	requests metric.Int64Counter
+}
+
+// NewEndpoints creates a new Endpoints decoding request bodies with decoder.
+func NewEndpoints(decoder *RequestDecoder, meter metric.Meter) (*Endpoints, error) {
+	requests, err := meter.Int64Counter(
+		"handler_requests_total",
+		metric.WithDescription("Total number of handler calls by outcome (success, invalid_request or the error kind)"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create handler request counter: %w", err)
+	}
+	return &Endpoints{decoder: decoder, requests: requests}, nil
+}
+
+// Handle returns the http.HandlerFunc serving ep. It is a function rather
+// than a method of Endpoints, since methods can't have type parameters.
+func Handle[B, R any](e *Endpoints, ep Endpoint[B, R]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		route := routePattern(r)
+		name := ep.Name
+		if name == "" {
+			name = r.Method + " " + route
+		}
+		attrs := []attribute.KeyValue{attribute.String("http.route", route)}
+		if ep.IDAttribute != "" {
+			attrs = append(attrs, attribute.String(ep.IDAttribute, chi.URLParam(r, "id")))
+		}
+
+		ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+		defer span.End()
+
+		outcome := outcomeSuccess
+		defer func() {
+			e.requests.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("handler", name),
+				attribute.String("outcome", outcome),
+			))
+		}()
+
+		req := &Request[B]{Request: r.WithContext(ctx), w: w}
+		if _, noBody := any(req.Body).(NoBody); !noBody && !(ep.OptionalBody && r.ContentLength == 0) {
+			if err := e.decoder.Decode(ctx, w, r, &req.Body); err != nil {
+				outcome = outcomeInvalidRequest
+				logging.FromContext(ctx).WarnContext(ctx, "invalid request body", slog.Any("error", err))
+				response.Error(w, r, err.Status, err.Detail())
+				return
+			}
+		}
+
+		resp, err := ep.Handle(ctx, req)
+		if err != nil {
+			outcome = apperr.KindOf(err).String()
+			writeError(ctx, w, r, err, ep.Error)
+			return
+		}
+
+		if _, noContent := any(resp).(NoBody); noContent {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		status := ep.Status
+		if status == 0 || (status == http.StatusCreated && repository.IsDryRun(ctx)) {
+			status = http.StatusOK
+		}
+		response.JSON(w, r, status, resp)
+	}
+}
+
+// routePattern returns the chi route pattern matched by r, or its path if
+// it wasn't routed by chi.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return r.URL.Path
+}
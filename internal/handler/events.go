@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/pkg/events"
+)
+
+// eventSchemaRegistry is the document listing the event schemas.
+type eventSchemaRegistry struct {
+	// Version is the schema version events are currently published with.
+	Version int              `json:"version"`
+	Schemas []*events.Schema `json:"schemas"`
+}
+
+// EventSchemas returns the JSON Schemas of every task event type and
+// version, for consumers of the NATS subjects and Redis stream.
+func EventSchemas(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, r, http.StatusOK, eventSchemaRegistry{
+		Version: events.SchemaVersion,
+		Schemas: events.Schemas(),
+	})
+}
+
+// EventSchema returns the JSON Schema named by the name URL parameter, e.g.
+// task.created.v1.
+func EventSchema(w http.ResponseWriter, r *http.Request) {
+	s, ok := events.LookupSchema(chi.URLParam(r, "name"))
+	if !ok {
+		response.Error(w, r, http.StatusNotFound, "event schema not found")
+		return
+	}
+	response.JSON(w, r, http.StatusOK, s.Schema)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// ExportHandler streams all tasks as NDJSON.
+type ExportHandler struct {
+	tasks *service.TaskService
+}
+
+// NewExportHandler creates a new ExportHandler.
+func NewExportHandler(tasks *service.TaskService) *ExportHandler {
+	return &ExportHandler{tasks: tasks}
+}
+
+// Routes returns the chi router with export routes, to be mounted below
+// /tasks/export.
+func (h *ExportHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.Export)
+
+	return r
+}
+
+// Export writes the tasks matching the include_archived and assignee query
+// parameters as NDJSON (application/x-ndjson), one task per line, as they
+// are read from the repository. Once the first line is written the status
+// can no longer change, so a failure midway ends the response early and is
+// only recorded on the span and in the log.
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "ExportHandler.Export")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	opts, err := listOptions(r)
+	if err != nil {
+		logger.WarnContext(ctx, "invalid include_archived", slog.String("include_archived", r.URL.Query().Get("include_archived")))
+		response.Error(w, r, http.StatusBadRequest, "invalid include_archived")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+
+	count := 0
+	for task, err := range h.tasks.ListIter(ctx, opts) {
+		if err == nil {
+			err = enc.Encode(task)
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "export interrupted")
+			span.SetAttributes(attribute.Int("export.count", count))
+			logger.ErrorContext(ctx, "task export interrupted", slog.Int("exported", count), slog.Any("error", err))
+			if count == 0 {
+				response.Error(w, r, http.StatusInternalServerError, "failed to export tasks")
+			}
+			return
+		}
+		count++
+	}
+
+	span.SetAttributes(attribute.Int("export.count", count))
+	logger.InfoContext(ctx, "tasks exported", slog.Int("count", count))
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+)
+
+// HealthHandler handles liveness and readiness checks.
+type HealthHandler struct {
+	conns            *telemetry.ExporterConns
+	degradation      *telemetry.Degradation
+	requireExporters bool
+}
+
+// NewHealthHandler creates a new HealthHandler. When requireExporters is true,
+// readiness fails while any OTLP exporter connection is failing. The
+// degradation may be nil if no telemetry fallback is configured.
+func NewHealthHandler(conns *telemetry.ExporterConns, degradation *telemetry.Degradation, requireExporters bool) *HealthHandler {
+	return &HealthHandler{
+		conns:            conns,
+		degradation:      degradation,
+		requireExporters: requireExporters,
+	}
+}
+
+// Health returns a health check response.
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Ready returns the readiness state including the OTLP exporter connection
+// states and, with a telemetry fallback, which signals are degraded.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	exporters := make(map[string]string)
+	for signal, state := range h.conns.States() {
+		exporters[signal] = state.String()
+	}
+
+	status := http.StatusOK
+	body := map[string]interface{}{
+		"status":    "ready",
+		"exporters": exporters,
+	}
+	if h.degradation != nil {
+		body["telemetry"] = map[string]interface{}{
+			"degraded": h.degradation.Degraded(),
+			"fallback": h.degradation.Fallback(),
+			"signals":  h.degradation.Signals(),
+		}
+	}
+	if !h.conns.Healthy() || h.degradation.Degraded() {
+		body["status"] = "degraded"
+		if h.requireExporters {
+			body["status"] = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	response.JSON(w, r, status, body)
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/httpstatus"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/tenant"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// actorFromRequest returns the caller identity used for auditing.
+// The sample has no authentication, so the identity is taken from the
+// X-User-ID header and falls back to "anonymous". The reserved actor of
+// changes without a caller, repository.SystemActor, is read as "anonymous"
+// too, so clients can't pass themselves off as the service.
+func actorFromRequest(r *http.Request) string {
+	actor := r.Header.Get("X-User-ID")
+	if actor == "" || strings.EqualFold(actor, repository.SystemActor) {
+		return "anonymous"
+	}
+	return actor
+}
+
+// tenantFromRequest returns the tenant of the caller from the X-Tenant-ID
+// header, or "" if the request isn't scoped to a tenant.
+func tenantFromRequest(r *http.Request) string {
+	return r.Header.Get(tenant.Header)
+}
+
+// withActor attributes repository changes made while serving the request
+// to the caller, so they show up in the task history.
+func withActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := repository.WithActor(r.Context(), actorFromRequest(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withDryRun turns the request into a dry run if the dry_run query parameter
+// is true: the change is checked and returned but not applied.
+func withDryRun(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query().Get("dry_run")
+		if v == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			response.Error(w, r, http.StatusBadRequest, "invalid dry_run")
+			return
+		}
+		if !dryRun {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("request.dry_run", true))
+		next.ServeHTTP(w, r.WithContext(repository.WithDryRun(ctx)))
+	})
+}
+
+// ConsistencyHeader overrides the configured cache consistency of the task
+// repository for one request: eventual or read_your_writes.
+const ConsistencyHeader = "X-Consistency"
+
+// withConsistency applies the cache consistency requested in the
+// X-Consistency header to the repository calls of the request.
+func withConsistency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get(ConsistencyHeader)
+		if v == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		consistency, err := repository.ParseConsistency(v)
+		if err != nil {
+			response.Error(w, r, http.StatusBadRequest, "invalid "+ConsistencyHeader)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(repository.WithConsistency(r.Context(), consistency)))
+	})
+}
+
+// writeError records err on the current span and writes the problem
+// response for it, with the status from httpstatus.FromError. Expected
+// errors (see apperr.Kind.Expected) are logged as warnings and answered
+// with their own message; other errors are logged as errors and answered
+// with msg, so internal details don't leak to clients. Duplicate titles
+// point to the existing task in the Location header.
+func writeError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error, msg string) {
+	apperr.Record(trace.SpanFromContext(ctx), err)
+
+	logger := logging.FromContext(ctx)
+	status := httpstatus.FromError(err)
+	var dup *model.DuplicateTitleError
+	if errors.As(err, &dup) {
+		w.Header().Set("Location", taskLocation(r, dup.TaskID))
+	}
+	if apperr.KindOf(err).Expected() {
+		logger.WarnContext(ctx, "request rejected", slog.Int("status", status), slog.Any("error", err))
+		response.Error(w, r, status, err.Error())
+		return
+	}
+	logger.ErrorContext(ctx, msg, slog.Any("error", err))
+	response.Error(w, r, status, msg)
+}
+
+// taskLocation returns the path of the task with the given ID in the API
+// version of r.
+func taskLocation(r *http.Request, id string) string {
+	if strings.HasPrefix(r.URL.Path, "/api/v2/") {
+		return "/api/v2/tasks/" + id
+	}
+	return "/api/v1/tasks/" + id
+}
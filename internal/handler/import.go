@@ -0,0 +1,393 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/fanout"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/i18n"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jsoncodec"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxImportErrors caps the number of row errors returned in an import report.
+const maxImportErrors = 100
+
+// ImportRowError describes a rejected import row.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportReport summarizes a bulk import.
+type ImportReport struct {
+	Imported int              `json:"imported"`
+	Rejected int              `json:"rejected"`
+	TaskIDs  []string         `json:"task_ids"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
+}
+
+// importRow is a parsed row waiting to be created. err is set if the row
+// could not be parsed.
+type importRow struct {
+	line int
+	req  model.CreateTaskRequest
+	err  error
+}
+
+// ImportHandler handles bulk imports of tasks from CSV or NDJSON.
+type ImportHandler struct {
+	tasks       *service.TaskService
+	batchSize   int
+	concurrency int
+	maxBytes    int64
+
+	rows metric.Int64Counter
+}
+
+// NewImportHandler creates a new ImportHandler that creates tasks through
+// the task service in batches of batchSize, running up to concurrency
+// batches at a time. Bodies larger than maxBytes are rejected; a maxBytes of 0
+// disables the limit.
+func NewImportHandler(tasks *service.TaskService, meter metric.Meter, batchSize, concurrency int, maxBytes int64) (*ImportHandler, error) {
+	rows, err := meter.Int64Counter(
+		"task_import_rows_total",
+		metric.WithDescription("Total number of rows processed by bulk task imports"),
+		metric.WithUnit("{row}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import rows counter: %w", err)
+	}
+
+	return &ImportHandler{
+		tasks:       tasks,
+		batchSize:   max(batchSize, 1),
+		concurrency: max(concurrency, 1),
+		maxBytes:    maxBytes,
+		rows:        rows,
+	}, nil
+}
+
+// Routes returns the chi router with import routes, to be mounted below
+// /tasks/import.
+func (h *ImportHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", h.Import)
+
+	return r
+}
+
+// Import streams a CSV (text/csv, with a header row) or NDJSON
+// (application/x-ndjson) body, creating a task per valid row, and responds
+// with a report of imported and rejected rows. Rows are created in
+// concurrent batches as they are read, so tasks of a body that turns out to
+// be too large are kept: the 413 problem carries the report of the rows read
+// until then. The report lists tasks and errors in row order.
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "ImportHandler.Import")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	span.SetAttributes(telemetry.UserString("import.format", mediaType))
+
+	body := r.Body
+	if h.maxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+	}
+
+	var next func() (importRow, error)
+	switch mediaType {
+	case "text/csv":
+		var err error
+		next, err = csvRows(body)
+		if err != nil {
+			logger.WarnContext(ctx, "invalid csv header", slog.Any("error", err))
+			response.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	case "application/x-ndjson", "application/jsonl":
+		next = ndjsonRows(body)
+	default:
+		response.Error(w, r, http.StatusUnsupportedMediaType, "content type must be text/csv or application/x-ndjson")
+		return
+	}
+
+	owner, tenant := actorFromRequest(r), tenantFromRequest(r)
+	report := &ImportReport{TaskIDs: make([]string, 0)}
+	batch := make([]importRow, 0, h.batchSize)
+
+	// Each batch reports into its own result, merged in order by wait. If a
+	// batch fails, the request was cancelled and the other batches stop.
+	g, _ := fanout.New(ctx, h.concurrency)
+	var results []*ImportReport
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		rows, result := batch, &ImportReport{}
+		g.Go("ImportHandler.Batch", func(ctx context.Context) error {
+			return h.createBatch(ctx, rows, result)
+		}, trace.WithAttributes(
+			attribute.Int("import.batch.index", len(results)),
+			attribute.Int("import.batch.size", len(rows)),
+		))
+		results = append(results, result)
+		batch = make([]importRow, 0, h.batchSize)
+	}
+	wait := func() error {
+		flush()
+		err := g.Wait()
+		mergeReports(report, results)
+		return err
+	}
+
+	for {
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if err := wait(); err != nil {
+				h.interrupted(ctx, w, r, err)
+				return
+			}
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				logger.WarnContext(ctx, "import body too large", slog.Int("imported", report.Imported))
+				writeImportProblem(w, r, http.StatusRequestEntityTooLarge, "request body too large", report)
+				return
+			}
+			logger.ErrorContext(ctx, "failed to read import body", slog.Any("error", err))
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			writeImportProblem(w, r, http.StatusBadRequest, "failed to read import body", report)
+			return
+		}
+
+		if row.err == nil {
+			row.err = row.req.Validate()
+		}
+		if row.err != nil {
+			h.reject(ctx, report, row)
+			continue
+		}
+
+		row.req.Owner = owner
+		row.req.Tenant = tenant
+		batch = append(batch, row)
+		if len(batch) == h.batchSize {
+			flush()
+		}
+	}
+	if err := wait(); err != nil {
+		h.interrupted(ctx, w, r, err)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("import.batches", len(results)),
+		attribute.Int("import.imported", report.Imported),
+		attribute.Int("import.rejected", report.Rejected),
+	)
+	logger.InfoContext(ctx, "tasks imported",
+		slog.Int("imported", report.Imported),
+		slog.Int("rejected", report.Rejected),
+	)
+
+	response.JSON(w, r, http.StatusOK, report)
+}
+
+// createBatch creates the tasks of a batch in one transaction, reporting
+// into report. Rejected rows don't roll back the batch. It stops and returns
+// the context error if ctx is cancelled, which rolls the batch back on
+// backends that have transactions.
+func (h *ImportHandler) createBatch(ctx context.Context, batch []importRow, report *ImportReport) error {
+	imported := 0
+	defer func() {
+		h.rows.Add(ctx, int64(imported), metric.WithAttributes(attribute.String("result", "imported")))
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int("import.batch.imported", imported))
+	}()
+
+	return h.tasks.WithTx(ctx, func(tasks *service.TaskService) error {
+		for _, row := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			task, err := tasks.Create(ctx, &row.req)
+			if err != nil {
+				row.err = err
+				h.reject(ctx, report, row)
+				continue
+			}
+
+			report.Imported++
+			report.TaskIDs = append(report.TaskIDs, task.ID)
+			imported++
+		}
+		return nil
+	})
+}
+
+// mergeReports adds the batch results to report in batch order and sorts
+// the errors by row.
+func mergeReports(report *ImportReport, results []*ImportReport) {
+	for _, result := range results {
+		report.Imported += result.Imported
+		report.Rejected += result.Rejected
+		report.TaskIDs = append(report.TaskIDs, result.TaskIDs...)
+		report.Errors = append(report.Errors, result.Errors...)
+	}
+	slices.SortStableFunc(report.Errors, func(a, b ImportRowError) int {
+		return a.Row - b.Row
+	})
+	if len(report.Errors) > maxImportErrors {
+		report.Errors = report.Errors[:maxImportErrors]
+	}
+}
+
+// importProblem is the problem of an import that stopped reading its body.
+// Report lists the rows read before, whose tasks are kept.
+type importProblem struct {
+	response.Problem
+	Report *ImportReport `json:"report"`
+}
+
+// writeImportProblem writes a problem+json response with the report of the
+// rows imported before the body could no longer be read.
+func writeImportProblem(w http.ResponseWriter, r *http.Request, status int, detail string, report *ImportReport) {
+	body, _ := jsoncodec.Marshal(importProblem{
+		Problem: response.NewProblem(r, status, detail),
+		Report:  report,
+	})
+	w.Header().Set("Content-Type", response.ProblemContentType)
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// interrupted responds to an import whose batches were cancelled. Tasks of
+// batches committed before the cancellation are kept.
+func (h *ImportHandler) interrupted(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "import interrupted")
+	logging.FromContext(ctx).WarnContext(ctx, "import interrupted", slog.Any("error", err))
+	response.Error(w, r, http.StatusServiceUnavailable, "import interrupted")
+}
+
+// reject records a rejected row on the active span and in the report.
+func (h *ImportHandler) reject(ctx context.Context, report *ImportReport, row importRow) {
+	report.Rejected++
+	if len(report.Errors) < maxImportErrors {
+		report.Errors = append(report.Errors, ImportRowError{Row: row.line, Error: i18n.T(ctx, row.err.Error())})
+	}
+
+	h.rows.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "rejected")))
+	trace.SpanFromContext(ctx).AddEvent("import.row.rejected", trace.WithAttributes(
+		attribute.Int("import.row", row.line),
+		attribute.String("error.message", row.err.Error()),
+	))
+}
+
+// csvRows reads the header of a CSV body and returns a function yielding
+// its rows. The title column is required; description, recurrence and
+// depends_on (semicolon-separated IDs) are optional.
+func csvRows(body io.Reader) (func() (importRow, error), error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("csv header must contain a title column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	line := 1
+	return func() (importRow, error) {
+		record, err := cr.Read()
+		line++
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return importRow{line: line, err: parseErr.Err}, nil
+		}
+		if err != nil {
+			return importRow{}, err
+		}
+
+		row := importRow{line: line}
+		row.req.Title = field(record, "title")
+		row.req.Description = field(record, "description")
+		if v := field(record, "recurrence"); v != "" {
+			row.req.Recurrence = &model.RecurrenceRule{Interval: v}
+		}
+		if v := field(record, "depends_on"); v != "" {
+			row.req.DependsOn = strings.Split(v, ";")
+		}
+		return row, nil
+	}, nil
+}
+
+// ndjsonRows returns a function yielding the rows of an NDJSON body, one
+// CreateTaskRequest per line. Blank lines are skipped.
+func ndjsonRows(body io.Reader) func() (importRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	line := 0
+	return func() (importRow, error) {
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			row := importRow{line: line}
+			if err := json.Unmarshal([]byte(text), &row.req); err != nil {
+				row.err = errors.New("invalid json")
+			}
+			return row, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return importRow{}, err
+		}
+		return importRow{}, io.EOF
+	}
+}
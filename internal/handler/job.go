@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jobs"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// JobHandler handles admin HTTP requests for the background job queue.
+type JobHandler struct {
+	queue *jobs.Queue
+}
+
+// NewJobHandler creates a new JobHandler.
+func NewJobHandler(queue *jobs.Queue) *JobHandler {
+	return &JobHandler{queue: queue}
+}
+
+// Routes returns the chi router with job routes.
+func (h *JobHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.List)
+	r.Delete("/{id}", h.Cancel)
+
+	return r
+}
+
+// List returns the pending jobs in the order they will run.
+func (h *JobHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	_, span := tracer.Start(ctx, "JobHandler.List")
+	defer span.End()
+
+	pending := h.queue.Pending()
+	span.SetAttributes(attribute.Int("job.pending", len(pending)))
+
+	response.JSON(w, r, http.StatusOK, pending)
+}
+
+// Cancel removes a pending job. Running jobs can't be cancelled.
+func (h *JobHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	ctx, span := tracer.Start(ctx, "JobHandler.Cancel",
+		trace.WithAttributes(attribute.String("job.id", id)),
+	)
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	info, err := h.queue.Cancel(ctx, id)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to cancel job")
+		return
+	}
+
+	logger.InfoContext(ctx, "job cancelled",
+		slog.String("job_id", id),
+		slog.String("job_name", info.Name),
+		slog.String("actor", actorFromRequest(r)),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jsoncodec"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FieldsParam is the query parameter selecting the fields of the returned
+// tasks, e.g. fields=id,title,done.
+const FieldsParam = "fields"
+
+// taskFields are the JSON names of the task fields that can be selected.
+var taskFields = jsonFields(reflect.TypeFor[model.Task]())
+
+// jsonFields returns the JSON names of the exported fields of struct type t.
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// Projector returns partial representations of tasks (sparse fieldsets) and
+// records how many response bytes that saves.
+type Projector struct {
+	saved metric.Int64Histogram
+}
+
+// NewProjector creates a new Projector.
+func NewProjector(meter metric.Meter) (*Projector, error) {
+	saved, err := meter.Int64Histogram(
+		"task_projection_saved_bytes",
+		metric.WithDescription("Bytes saved per task response by selecting fields with the fields query parameter"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(64, 256, 1<<10, 4<<10, 16<<10, 64<<10, 256<<10, 1<<20),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create projection savings histogram: %w", err)
+	}
+	return &Projector{saved: saved}, nil
+}
+
+// fields returns the task fields selected by the fields query parameter, or
+// nil if it is absent. The count is recorded on the current span.
+func (p *Projector) fields(ctx context.Context, r *http.Request) ([]string, error) {
+	v := r.URL.Query().Get(FieldsParam)
+	if v == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for field := range strings.SplitSeq(v, ",") {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(taskFields, field) {
+			return nil, apperr.New(apperr.Validation, fmt.Sprintf("unknown field %q, must be one of %s", field, strings.Join(taskFields, ", ")))
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("task.projection.fields", len(fields)))
+	return fields, nil
+}
+
+// Project returns the JSON objects of tasks with only the given fields. The
+// difference to the encoded size of the full tasks is recorded by operation.
+func (p *Projector) Project(ctx context.Context, operation string, fields []string, tasks ...*model.Task) ([]map[string]json.RawMessage, error) {
+	projected := make([]map[string]json.RawMessage, 0, len(tasks))
+	saved := 0
+	for _, task := range tasks {
+		full, err := jsoncodec.Marshal(task)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode task: %w", err)
+		}
+		var all map[string]json.RawMessage
+		if err := jsoncodec.NewDecoder(bytes.NewReader(full)).Decode(&all); err != nil {
+			return nil, fmt.Errorf("failed to decode task: %w", err)
+		}
+
+		partial := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if v, ok := all[field]; ok {
+				partial[field] = v
+			}
+		}
+		body, err := jsoncodec.Marshal(partial)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode task: %w", err)
+		}
+		saved += len(full) - len(body)
+		projected = append(projected, partial)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("task.projection.saved_bytes", saved))
+	p.saved.Record(ctx, int64(saved), metric.WithAttributes(attribute.String("operation", operation)))
+	return projected, nil
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/quota"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetQuotaRequest represents the request body for changing a quota.
+type SetQuotaRequest struct {
+	Limit *int64 `json:"limit"`
+}
+
+// QuotaHandler handles admin HTTP requests for task quotas.
+type QuotaHandler struct {
+	quotas  *quota.Manager
+	decoder *RequestDecoder
+}
+
+// NewQuotaHandler creates a new QuotaHandler.
+func NewQuotaHandler(quotas *quota.Manager, decoder *RequestDecoder) *QuotaHandler {
+	return &QuotaHandler{
+		quotas:  quotas,
+		decoder: decoder,
+	}
+}
+
+// Routes returns the chi router with quota routes.
+func (h *QuotaHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.List)
+	r.Put("/{scope}/{subject}", h.Set)
+
+	return r
+}
+
+// List returns the configured quotas.
+func (h *QuotaHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	_, span := tracer.Start(ctx, "QuotaHandler.List")
+	defer span.End()
+
+	response.JSON(w, r, http.StatusOK, h.quotas.Limits())
+}
+
+// Set changes the quota of a user or tenant. The "default" subject changes
+// the default limit of the scope, and a negative limit removes an override.
+func (h *QuotaHandler) Set(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := quota.Scope(chi.URLParam(r, "scope"))
+	subject := chi.URLParam(r, "subject")
+
+	ctx, span := tracer.Start(ctx, "QuotaHandler.Set",
+		trace.WithAttributes(
+			attribute.String("quota.scope", string(scope)),
+			telemetry.UserString("quota.subject", subject),
+		),
+	)
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	var req SetQuotaRequest
+	if err := h.decoder.Decode(ctx, w, r, &req); err != nil {
+		logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
+		response.Error(w, r, err.Status, err.Detail())
+		return
+	}
+	if req.Limit == nil {
+		response.Error(w, r, http.StatusBadRequest, "limit is required")
+		return
+	}
+
+	if err := h.quotas.SetLimit(scope, subject, *req.Limit); err != nil {
+		logger.WarnContext(ctx, "invalid quota scope", slog.String("scope", string(scope)))
+		response.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logger.InfoContext(ctx, "quota updated",
+		slog.String("scope", string(scope)),
+		slog.String("subject", subject),
+		slog.Int64("limit", *req.Limit),
+		slog.String("actor", actorFromRequest(r)),
+	)
+
+	response.JSON(w, r, http.StatusOK, h.quotas.Limits())
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Limits of RUM events. Events are reported by untrusted clients, so their
+// names, attributes and timestamps are bounded.
+const (
+	maxRUMAttributes = 16
+	maxRUMDuration   = time.Hour
+	maxRUMAge        = 24 * time.Hour
+	maxRUMClockSkew  = time.Minute
+)
+
+// rumNamePattern matches RUM event names and attribute keys.
+var rumNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_.]{0,63}$`)
+
+// RUMEvent is a client-side timing event reported by a browser, e.g. a page
+// load or the rendering of a task list. TraceParent is the traceparent of
+// the API request the event belongs to, if any.
+type RUMEvent struct {
+	Name        string            `json:"name"`
+	StartTime   time.Time         `json:"start_time"`
+	DurationMs  float64           `json:"duration_ms"`
+	Page        string            `json:"page,omitempty"`
+	TraceParent string            `json:"traceparent,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+// validate checks e against the limits of RUM events relative to now.
+func (e *RUMEvent) validate(now time.Time) error {
+	if !rumNamePattern.MatchString(e.Name) {
+		return apperr.New(apperr.Validation, "name must match "+rumNamePattern.String())
+	}
+	if e.StartTime.IsZero() || e.StartTime.Before(now.Add(-maxRUMAge)) || e.StartTime.After(now.Add(maxRUMClockSkew)) {
+		return apperr.New(apperr.Validation, fmt.Sprintf("start_time must be within the last %s", maxRUMAge))
+	}
+	if e.DurationMs < 0 || e.DurationMs > float64(maxRUMDuration/time.Millisecond) {
+		return apperr.New(apperr.Validation, fmt.Sprintf("duration_ms must be between 0 and %d", maxRUMDuration/time.Millisecond))
+	}
+	if len(e.Attributes) > maxRUMAttributes {
+		return apperr.New(apperr.Validation, fmt.Sprintf("at most %d attributes are allowed", maxRUMAttributes))
+	}
+	for key := range e.Attributes {
+		if !rumNamePattern.MatchString(key) {
+			return apperr.New(apperr.Validation, fmt.Sprintf("invalid attribute key %q", key))
+		}
+	}
+	return nil
+}
+
+// RUMHandler records real user monitoring events from browser frontends as
+// spans, linked to the traces of the API requests they belong to.
+type RUMHandler struct {
+	decoder     *RequestDecoder
+	baggageKeys []string
+}
+
+// NewRUMHandler creates a new RUMHandler. The members of the request
+// baggage named in baggageKeys are added to the recorded spans.
+func NewRUMHandler(decoder *RequestDecoder, baggageKeys []string) *RUMHandler {
+	return &RUMHandler{decoder: decoder, baggageKeys: baggageKeys}
+}
+
+// Routes returns the chi router with RUM routes.
+func (h *RUMHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/event", h.Event)
+
+	return r
+}
+
+// Event records a RUM event as a span named rum.{name} with the client's
+// start time and duration. The span starts a new trace, since the event
+// happened before this request, and links to the trace of TraceParent.
+// The body is read regardless of its content type, so browsers can send
+// events as text/plain (e.g. with navigator.sendBeacon) without a CORS
+// preflight.
+func (h *RUMHandler) Event(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "RUMHandler.Event")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	var e RUMEvent
+	if err := h.decoder.Decode(ctx, w, r, &e); err != nil {
+		logger.WarnContext(ctx, "invalid rum event", slog.Any("error", err))
+		response.Error(w, r, err.Status, err.Detail())
+		return
+	}
+	if err := e.validate(time.Now()); err != nil {
+		writeError(ctx, w, r, err, "invalid rum event")
+		return
+	}
+
+	// Client attributes come first, so they can't override ours
+	attrs := make([]attribute.KeyValue, 0, len(e.Attributes)+4)
+	for key, value := range e.Attributes {
+		attrs = append(attrs, telemetry.UserString("rum."+key, value))
+	}
+	attrs = append(attrs, telemetry.BaggageAttributes(ctx, h.baggageKeys)...)
+	attrs = append(attrs,
+		attribute.String("rum.event.name", e.Name),
+		attribute.Float64("rum.duration_ms", e.DurationMs),
+	)
+	if e.Page != "" {
+		attrs = append(attrs, telemetry.UserString("url.path", e.Page))
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(e.StartTime),
+		trace.WithAttributes(attrs...),
+		// Link back to this request, e.g. to find the client's address
+		trace.WithLinks(trace.Link{SpanContext: span.SpanContext()}),
+	}
+	linked := false
+	if e.TraceParent != "" {
+		carrier := propagation.MapCarrier{"traceparent": e.TraceParent}
+		sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(ctx, carrier))
+		if sc.IsValid() && sc.TraceID() != span.SpanContext().TraceID() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+			linked = true
+		}
+	}
+
+	_, rumSpan := tracer.Start(ctx, "rum."+e.Name, opts...)
+	rumSpan.End(trace.WithTimestamp(e.StartTime.Add(time.Duration(e.DurationMs * float64(time.Millisecond)))))
+
+	span.SetAttributes(
+		attribute.String("rum.event.name", e.Name),
+		attribute.String("rum.trace_id", rumSpan.SpanContext().TraceID().String()),
+		attribute.Bool("rum.linked", linked),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jobs"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/snapshot"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SnapshotHandler handles admin HTTP requests for task snapshots.
+type SnapshotHandler struct {
+	snapshots *snapshot.Snapshotter
+	queue     *jobs.Queue
+}
+
+// NewSnapshotHandler creates a new SnapshotHandler. Scheduled snapshots are
+// run by queue.
+func NewSnapshotHandler(snapshots *snapshot.Snapshotter, queue *jobs.Queue) *SnapshotHandler {
+	return &SnapshotHandler{snapshots: snapshots, queue: queue}
+}
+
+// Routes returns the chi router with snapshot routes.
+func (h *SnapshotHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", h.Take)
+
+	return r
+}
+
+// Take writes a snapshot of all tasks to object storage now and responds
+// with its key and size. The snapshot is finished even if the client goes
+// away. With a delay or priority query parameter, the snapshot is instead
+// enqueued as a background job and the job is returned with 202 Accepted.
+func (h *SnapshotHandler) Take(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "SnapshotHandler.Take")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	if q := r.URL.Query(); q.Has("delay") || q.Has("priority") {
+		opts, err := jobOptions(q.Get("delay"), q.Get("priority"))
+		if err != nil {
+			writeError(ctx, w, r, err, "invalid job options")
+			return
+		}
+		job := h.queue.Enqueue(ctx, "snapshot", func(ctx context.Context) error {
+			result, err := h.snapshots.Take(ctx, snapshot.TriggerManual)
+			if err != nil {
+				return err
+			}
+			logging.FromContext(ctx).InfoContext(ctx, "task snapshot taken",
+				slog.String("key", result.Key),
+				slog.Int("tasks", result.Tasks),
+				slog.Int64("bytes", result.Bytes),
+			)
+			return nil
+		}, opts...)
+		span.SetAttributes(attribute.String("job.id", job.ID))
+
+		logger.InfoContext(ctx, "task snapshot enqueued",
+			slog.String("job_id", job.ID),
+			slog.Time("run_at", job.RunAt),
+		)
+		response.JSON(w, r, http.StatusAccepted, job)
+		return
+	}
+
+	result, err := h.snapshots.Take(context.WithoutCancel(ctx), snapshot.TriggerManual)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to take snapshot")
+		return
+	}
+
+	logger.InfoContext(ctx, "task snapshot taken",
+		slog.String("key", result.Key),
+		slog.Int("tasks", result.Tasks),
+		slog.Int64("bytes", result.Bytes),
+	)
+	response.JSON(w, r, http.StatusCreated, result)
+}
+
+// jobOptions parses the delay (a duration such as 10m) and priority (low,
+// normal or high) of a job; either may be empty.
+func jobOptions(delay, priority string) ([]jobs.Option, error) {
+	var opts []jobs.Option
+	if delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return nil, apperr.New(apperr.Validation, "invalid delay")
+		}
+		opts = append(opts, jobs.WithDelay(d))
+	}
+	if priority != "" {
+		p, err := jobs.ParsePriority(priority)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, jobs.WithPriority(p))
+	}
+	return opts, nil
+}
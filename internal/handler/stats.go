@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/fanout"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Bounds of the days query parameter of the stats endpoint.
+const (
+	defaultStatsDays = 7
+	maxStatsDays     = 90
+)
+
+// StatsHandler serves task statistics aggregated by the repository. Results
+// are cached per number of days for a short TTL, since dashboards poll them.
+type StatsHandler struct {
+	repo repository.Repository
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	cache map[int]*model.TaskStats
+}
+
+// NewStatsHandler creates a new StatsHandler caching results for ttl; a ttl
+// of 0 disables caching.
+func NewStatsHandler(repo repository.Repository, ttl time.Duration) *StatsHandler {
+	return &StatsHandler{
+		repo:  repo,
+		ttl:   ttl,
+		cache: make(map[int]*model.TaskStats),
+	}
+}
+
+// Routes returns the chi router with the stats route, to be mounted below
+// /tasks/stats.
+func (h *StatsHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.Get)
+
+	return r
+}
+
+// Get returns the task statistics, with created tasks per day for the last
+// days (query parameter, default 7).
+func (h *StatsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "StatsHandler.Get")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	days := defaultStatsDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		days, err = strconv.Atoi(v)
+		if err != nil || days < 1 || days > maxStatsDays {
+			logger.WarnContext(ctx, "invalid days", slog.String("days", v))
+			response.Error(w, r, http.StatusBadRequest, "invalid days")
+			return
+		}
+	}
+	span.SetAttributes(attribute.Int("stats.days", days))
+
+	now := time.Now()
+	stats, cached := h.cached(days, now)
+	span.SetAttributes(attribute.Bool("cache.hit", cached))
+	if !cached {
+		var err error
+		stats, err = h.compute(ctx, now, days)
+		if err != nil {
+			writeError(ctx, w, r, err, "failed to compute task stats")
+			return
+		}
+		h.store(days, stats)
+	}
+
+	response.JSON(w, r, http.StatusOK, stats)
+}
+
+// compute queries the aggregates and the created tasks per day concurrently.
+func (h *StatsHandler) compute(ctx context.Context, now time.Time, days int) (*model.TaskStats, error) {
+	var (
+		stats  *model.TaskStats
+		counts []model.DailyCount
+	)
+	g, _ := fanout.New(ctx, 0)
+	g.Go("StatsHandler.Aggregate", func(ctx context.Context) error {
+		var err error
+		stats, err = h.repo.Stats(ctx, now)
+		return err
+	})
+	g.Go("StatsHandler.CreatedPerDay", func(ctx context.Context) error {
+		var err error
+		counts, err = h.repo.CreatedPerDay(ctx, now, days)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	stats.CreatedPerDay = counts
+	return stats, nil
+}
+
+// cached returns the cached stats for days if they are younger than the TTL.
+func (h *StatsHandler) cached(days int, now time.Time) (*model.TaskStats, bool) {
+	if h.ttl <= 0 {
+		return nil, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats, ok := h.cache[days]
+	if !ok || now.Sub(stats.GeneratedAt) >= h.ttl {
+		return nil, false
+	}
+	return stats, true
+}
+
+func (h *StatsHandler) store(days int, stats *model.TaskStats) {
+	if h.ttl <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cache[days] = stats
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/buildinfo"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/config"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SupportBundle is the diagnostic bundle of a request, to attach to a bug
+// report.
+type SupportBundle struct {
+	GeneratedAt time.Time                 `json:"generated_at"`
+	TraceID     string                    `json:"trace_id,omitempty"`
+	Build       buildinfo.Info            `json:"build"`
+	Runtime     RuntimeStats              `json:"runtime"`
+	Config      map[string]config.Setting `json:"config"`
+	Logs        []telemetry.BufferedLog   `json:"logs"`
+}
+
+// RuntimeStats are the Go runtime statistics of a support bundle.
+type RuntimeStats struct {
+	Uptime       string `json:"uptime"`
+	Goroutines   int    `json:"goroutines"`
+	GOMAXPROCS   int    `json:"gomaxprocs"`
+	NumCPU       int    `json:"num_cpu"`
+	HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+	HeapInuse    uint64 `json:"heap_inuse_bytes"`
+	Sys          uint64 `json:"sys_bytes"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"gc_pause_total_ns"`
+}
+
+// SupportHandler handles admin HTTP requests for support bundles.
+type SupportHandler struct {
+	cfg     *config.Config
+	logs    *telemetry.LogBuffer
+	started time.Time
+}
+
+// NewSupportHandler creates a new SupportHandler collecting the recent log
+// records of logs, which may be nil, and the configuration cfg.
+func NewSupportHandler(cfg *config.Config, logs *telemetry.LogBuffer) *SupportHandler {
+	return &SupportHandler{
+		cfg:     cfg,
+		logs:    logs,
+		started: time.Now(),
+	}
+}
+
+// Routes returns the chi router with support routes.
+func (h *SupportHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.Bundle)
+
+	return r
+}
+
+// Bundle returns a support bundle as a JSON download: the buffered log
+// records of the trace named by the trace_id query parameter (all buffered
+// records without it), the effective configuration with secrets redacted
+// and runtime statistics.
+func (h *SupportHandler) Bundle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	_, span := tracer.Start(ctx, "SupportHandler.Bundle")
+	defer span.End()
+
+	var traceID trace.TraceID
+	if v := r.URL.Query().Get("trace_id"); v != "" {
+		var err error
+		if traceID, err = trace.TraceIDFromHex(v); err != nil {
+			response.Error(w, r, http.StatusBadRequest, "invalid trace_id")
+			return
+		}
+		span.SetAttributes(attribute.String("support.trace_id", v))
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	bundle := SupportBundle{
+		GeneratedAt: time.Now().UTC(),
+		Build:       buildinfo.Get(),
+		Runtime: RuntimeStats{
+			Uptime:       time.Since(h.started).Round(time.Second).String(),
+			Goroutines:   runtime.NumGoroutine(),
+			GOMAXPROCS:   runtime.GOMAXPROCS(0),
+			NumCPU:       runtime.NumCPU(),
+			HeapAlloc:    mem.HeapAlloc,
+			HeapInuse:    mem.HeapInuse,
+			Sys:          mem.Sys,
+			NumGC:        mem.NumGC,
+			PauseTotalNs: mem.PauseTotalNs,
+		},
+		Config: h.cfg.Settings(),
+		Logs:   []telemetry.BufferedLog{},
+	}
+	if traceID.IsValid() {
+		bundle.TraceID = traceID.String()
+	}
+	if h.logs != nil {
+		if logs := h.logs.Records(traceID); logs != nil {
+			bundle.Logs = logs
+		}
+	}
+	span.SetAttributes(attribute.Int("support.log_records", len(bundle.Logs)))
+
+	name := "support-bundle.json"
+	if bundle.TraceID != "" {
+		name = fmt.Sprintf("support-bundle-%s.json", bundle.TraceID)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	response.JSON(w, r, http.StatusOK, bundle)
+}
@@ -2,251 +2,334 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/dedup"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
 	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/pagination"
 	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
-	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
 var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/handler")
 
-// TaskHandler handles HTTP requests for tasks.
+// TaskHandler handles HTTP requests for tasks. Business rules live in the
+// task service; the handler decodes requests and maps errors to responses.
 type TaskHandler struct {
-	repo    *repository.TaskRepository
-	logger  *slog.Logger
-	metrics *telemetry.Metrics
+	tasks     *service.TaskService
+	endpoints *Endpoints
+	cursors   *pagination.Codec
+	projector *Projector
+	dedup     *dedup.Deduplicator
 }
 
-// NewTaskHandler creates a new TaskHandler.
-func NewTaskHandler(repo *repository.TaskRepository, logger *slog.Logger, metrics *telemetry.Metrics) *TaskHandler {
+// NewTaskHandler creates a new TaskHandler serving its endpoints with
+// endpoints, encoding list cursors with cursors and selecting fields of the
+// returned tasks with projector. Duplicate task creations are answered by
+// deduplicator, unless it is nil.
+func NewTaskHandler(tasks *service.TaskService, endpoints *Endpoints, cursors *pagination.Codec, projector *Projector, deduplicator *dedup.Deduplicator) *TaskHandler {
 	return &TaskHandler{
-		repo:    repo,
-		logger:  logger,
-		metrics: metrics,
+		tasks:     tasks,
+		endpoints: endpoints,
+		cursors:   cursors,
+		projector: projector,
+		dedup:     deduplicator,
 	}
 }
 
+// NextCursorHeader carries the cursor of the next page of a paginated task
+// list; it is absent on the last page.
+const NextCursorHeader = "X-Next-Cursor"
+
+// maxPageSize bounds the limit query parameter of the task list.
+const maxPageSize = 1000
+
 // Routes returns the chi router with task routes.
 func (h *TaskHandler) Routes() chi.Router {
 	r := chi.NewRouter()
+	r.Use(withActor)
+	r.Use(withConsistency)
+
+	// Answer duplicate creations with the task created first
+	create := r.With(withDryRun)
+	if h.dedup != nil {
+		create = r.With(h.dedup.Handler, withDryRun)
+	}
 
-	r.Get("/", h.List)
-	r.Post("/", h.Create)
-	r.Get("/{id}", h.GetByID)
-	r.Put("/{id}", h.Update)
-	r.Delete("/{id}", h.Delete)
+	e := h.endpoints
+	r.Get("/", Handle(e, Endpoint[NoBody, any]{
+		Name:   "TaskHandler.List",
+		Error:  "failed to list tasks",
+		Handle: h.list,
+	}))
+	create.Post("/", Handle(e, Endpoint[model.CreateTaskRequest, *model.Task]{
+		Name:   "TaskHandler.Create",
+		Error:  "failed to create task",
+		Status: http.StatusCreated,
+		Handle: h.create,
+	}))
+	r.Post("/with-reminder", Handle(e, Endpoint[model.CreateTaskRequest, *model.Task]{
+		Name:   "TaskHandler.CreateWithReminder",
+		Error:  "failed to create task with reminder",
+		Status: http.StatusCreated,
+		Handle: h.createWithReminder,
+	}))
+	r.Get("/{id}", Handle(e, Endpoint[NoBody, any]{
+		Name:        "TaskHandler.GetByID",
+		Error:       "failed to get task",
+		IDAttribute: "task.id",
+		Handle:      h.get,
+	}))
+	r.With(withDryRun).Put("/{id}", Handle(e, Endpoint[model.UpdateTaskRequest, *model.Task]{
+		Name:        "TaskHandler.Update",
+		Error:       "failed to update task",
+		IDAttribute: "task.id",
+		Handle:      h.update,
+	}))
+	r.With(withDryRun).Delete("/{id}", Handle(e, Endpoint[NoBody, NoBody]{
+		Name:        "TaskHandler.Delete",
+		Error:       "failed to delete task",
+		IDAttribute: "task.id",
+		Handle:      h.delete,
+	}))
+	r.Get("/{id}/dependencies", Handle(e, Endpoint[NoBody, *model.DependencyStatus]{
+		Name:        "TaskHandler.Dependencies",
+		Error:       "failed to get task dependencies",
+		IDAttribute: "task.id",
+		Handle:      h.dependencies,
+	}))
+	r.Get("/{id}/history", Handle(e, Endpoint[NoBody, []model.Revision]{
+		Name:        "TaskHandler.History",
+		Error:       "failed to get task history",
+		IDAttribute: "task.id",
+		Handle:      h.history,
+	}))
+	r.With(withDryRun).Post("/{id}/archive", Handle(e, Endpoint[NoBody, *model.Task]{
+		Name:        "TaskHandler.Archive",
+		Error:       "failed to archive task",
+		IDAttribute: "task.id",
+		Handle:      h.setArchived(true),
+	}))
+	r.With(withDryRun).Post("/{id}/unarchive", Handle(e, Endpoint[NoBody, *model.Task]{
+		Name:        "TaskHandler.Unarchive",
+		Error:       "failed to unarchive task",
+		IDAttribute: "task.id",
+		Handle:      h.setArchived(false),
+	}))
+	r.With(withDryRun).Put("/{id}/assign", Handle(e, Endpoint[model.AssignTaskRequest, *model.Task]{
+		Name:        "TaskHandler.Assign",
+		Error:       "failed to assign task",
+		IDAttribute: "task.id",
+		Handle:      h.assign,
+	}))
+	r.Post("/{id}/claim", Handle(e, Endpoint[model.ClaimTaskRequest, *model.Task]{
+		Name:         "TaskHandler.Claim",
+		Error:        "failed to claim task",
+		IDAttribute:  "task.id",
+		OptionalBody: true,
+		Handle:       h.claim,
+	}))
+	r.Post("/{id}/release", Handle(e, Endpoint[NoBody, *model.Task]{
+		Name:        "TaskHandler.Release",
+		Error:       "failed to release task",
+		IDAttribute: "task.id",
+		Handle:      h.release,
+	}))
 
 	return r
 }
 
-// List returns all tasks.
-func (h *TaskHandler) List(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	start := time.Now()
+// list returns all tasks. Archived tasks are only included with the
+// include_archived query parameter; the assignee query parameter limits the
+// tasks to those assigned to a user. With the limit query parameter the
+// tasks are returned a page at a time, with the cursor of the next page in
+// X-Next-Cursor, to be passed as the cursor query parameter. The fields
+// query parameter selects the returned task fields.
+func (h *TaskHandler) list(ctx context.Context, r *Request[NoBody]) (any, error) {
+	logger := logging.FromContext(ctx)
 
-	ctx, span := tracer.Start(ctx, "TaskHandler.List")
-	defer span.End()
-
-	h.logger.InfoContext(ctx, "listing all tasks")
-
-	tasks, err := h.repo.List(ctx)
+	opts, err := listOptions(r.Request)
 	if err != nil {
-		h.logger.ErrorContext(ctx, "failed to list tasks", slog.Any("error", err))
-		h.respondError(w, http.StatusInternalServerError, "failed to list tasks")
-		h.recordMetrics(ctx, "GET", "/api/v1/tasks", http.StatusInternalServerError, start)
-		return
+		return nil, errInvalidIncludeArchived
 	}
-
-	span.SetAttributes(attribute.Int("task.count", len(tasks)))
-	h.logger.InfoContext(ctx, "tasks listed", slog.Int("count", len(tasks)))
-
-	h.respondJSON(w, http.StatusOK, tasks)
-	h.recordMetrics(ctx, "GET", "/api/v1/tasks", http.StatusOK, start)
-}
-
-// Create adds a new task.
-func (h *TaskHandler) Create(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	start := time.Now()
-
-	ctx, span := tracer.Start(ctx, "TaskHandler.Create")
-	defer span.End()
-
-	var req model.CreateTaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
-		h.respondError(w, http.StatusBadRequest, "invalid request body")
-		h.recordMetrics(ctx, "POST", "/api/v1/tasks", http.StatusBadRequest, start)
-		return
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return nil, errInvalidLimit
+		}
+		opts.Limit = limit
 	}
-
-	if err := req.Validate(); err != nil {
-		h.logger.WarnContext(ctx, "validation failed", slog.Any("error", err))
-		h.respondError(w, http.StatusBadRequest, err.Error())
-		h.recordMetrics(ctx, "POST", "/api/v1/tasks", http.StatusBadRequest, start)
-		return
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		after, err := h.cursors.Decode(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		opts.After = &after
 	}
-
-	h.logger.InfoContext(ctx, "creating task", slog.String("title", req.Title))
-
-	task, err := h.repo.Create(ctx, &req)
+	fields, err := h.projector.fields(ctx, r.Request)
 	if err != nil {
-		h.logger.ErrorContext(ctx, "failed to create task", slog.Any("error", err))
-		h.respondError(w, http.StatusInternalServerError, "failed to create task")
-		h.recordMetrics(ctx, "POST", "/api/v1/tasks", http.StatusInternalServerError, start)
-		return
+		return nil, err
 	}
 
-	span.SetAttributes(attribute.String("task.id", task.ID))
-	h.logger.InfoContext(ctx, "task created", slog.String("id", task.ID))
-
-	h.respondJSON(w, http.StatusCreated, task)
-	h.recordMetrics(ctx, "POST", "/api/v1/tasks", http.StatusCreated, start)
-}
-
-// GetByID returns a task by ID.
-func (h *TaskHandler) GetByID(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	start := time.Now()
-	id := chi.URLParam(r, "id")
-
-	ctx, span := tracer.Start(ctx, "TaskHandler.GetByID",
-		trace.WithAttributes(attribute.String("task.id", id)),
+	logger.InfoContext(ctx, "listing all tasks",
+		slog.Bool("include_archived", opts.IncludeArchived),
+		slog.String("assignee", opts.Assignee),
+		slog.Int("limit", opts.Limit),
 	)
-	defer span.End()
-
-	h.logger.InfoContext(ctx, "getting task", slog.String("id", id))
 
-	task, err := h.repo.GetByID(ctx, id)
+	// Fetch one task more than the page to know whether there is a next one.
+	page := opts.Limit
+	if page > 0 {
+		opts.Limit++
+	}
+	tasks, err := h.tasks.List(ctx, opts)
 	if err != nil {
-		if errors.Is(err, model.ErrTaskNotFound) {
-			h.logger.WarnContext(ctx, "task not found", slog.String("id", id))
-			h.respondError(w, http.StatusNotFound, "task not found")
-			h.recordMetrics(ctx, "GET", "/api/v1/tasks/{id}", http.StatusNotFound, start)
-			return
-		}
-		h.logger.ErrorContext(ctx, "failed to get task", slog.Any("error", err))
-		h.respondError(w, http.StatusInternalServerError, "failed to get task")
-		h.recordMetrics(ctx, "GET", "/api/v1/tasks/{id}", http.StatusInternalServerError, start)
-		return
+		return nil, err
+	}
+	if page > 0 && len(tasks) > page {
+		tasks = tasks[:page]
+		r.SetHeader(NextCursorHeader, h.cursors.Encode(repository.CursorOf(tasks[page-1])))
 	}
 
-	h.logger.InfoContext(ctx, "task retrieved", slog.String("id", id))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("task.count", len(tasks)))
+	logger.InfoContext(ctx, "tasks listed", slog.Int("count", len(tasks)))
 
-	h.respondJSON(w, http.StatusOK, task)
-	h.recordMetrics(ctx, "GET", "/api/v1/tasks/{id}", http.StatusOK, start)
+	if fields != nil {
+		return h.projector.Project(ctx, "list", fields, tasks...)
+	}
+	return tasks, nil
 }
 
-// Update modifies an existing task.
-func (h *TaskHandler) Update(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	start := time.Now()
-	id := chi.URLParam(r, "id")
-
-	ctx, span := tracer.Start(ctx, "TaskHandler.Update",
-		trace.WithAttributes(attribute.String("task.id", id)),
-	)
-	defer span.End()
+// List query parameter errors.
+var (
+	errInvalidIncludeArchived = apperr.New(apperr.Validation, "invalid include_archived")
+	errInvalidLimit           = apperr.New(apperr.Validation, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+)
 
-	var req model.UpdateTaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
-		h.respondError(w, http.StatusBadRequest, "invalid request body")
-		h.recordMetrics(ctx, "PUT", "/api/v1/tasks/{id}", http.StatusBadRequest, start)
-		return
+// listOptions reads the include_archived and assignee query parameters.
+func listOptions(r *http.Request) (repository.ListOptions, error) {
+	opts := repository.ListOptions{Assignee: r.URL.Query().Get("assignee")}
+	if v := r.URL.Query().Get("include_archived"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.IncludeArchived = include
 	}
+	return opts, nil
+}
 
-	h.logger.InfoContext(ctx, "updating task", slog.String("id", id))
+// create adds a new task owned by the caller. With dry_run=true it responds
+// with the task that would be created, without creating it.
+func (h *TaskHandler) create(ctx context.Context, r *Request[model.CreateTaskRequest]) (*model.Task, error) {
+	r.Body.Owner = actorFromRequest(r.Request)
+	r.Body.Tenant = tenantFromRequest(r.Request)
 
-	task, err := h.repo.Update(ctx, id, &req)
+	task, err := h.tasks.Create(ctx, &r.Body)
 	if err != nil {
-		if errors.Is(err, model.ErrTaskNotFound) {
-			h.logger.WarnContext(ctx, "task not found", slog.String("id", id))
-			h.respondError(w, http.StatusNotFound, "task not found")
-			h.recordMetrics(ctx, "PUT", "/api/v1/tasks/{id}", http.StatusNotFound, start)
-			return
-		}
-		h.logger.ErrorContext(ctx, "failed to update task", slog.Any("error", err))
-		h.respondError(w, http.StatusInternalServerError, "failed to update task")
-		h.recordMetrics(ctx, "PUT", "/api/v1/tasks/{id}", http.StatusInternalServerError, start)
-		return
+		return nil, err
 	}
-
-	h.logger.InfoContext(ctx, "task updated", slog.String("id", id))
-
-	h.respondJSON(w, http.StatusOK, task)
-	h.recordMetrics(ctx, "PUT", "/api/v1/tasks/{id}", http.StatusOK, start)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("task.id", task.ID))
+	return task, nil
 }
 
-// Delete removes a task.
-func (h *TaskHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	start := time.Now()
-	id := chi.URLParam(r, "id")
+// createWithReminder creates a task and schedules its reminder as a saga,
+// undoing the steps taken so far if one fails.
+func (h *TaskHandler) createWithReminder(ctx context.Context, r *Request[model.CreateTaskRequest]) (*model.Task, error) {
+	r.Body.Owner = actorFromRequest(r.Request)
+	r.Body.Tenant = tenantFromRequest(r.Request)
 
-	ctx, span := tracer.Start(ctx, "TaskHandler.Delete",
-		trace.WithAttributes(attribute.String("task.id", id)),
-	)
-	defer span.End()
+	task, err := h.tasks.CreateWithReminder(ctx, &r.Body)
+	if err != nil {
+		return nil, err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("task.id", task.ID))
+	return task, nil
+}
 
-	h.logger.InfoContext(ctx, "deleting task", slog.String("id", id))
+// get returns a task by ID. The fields query parameter selects the
+// returned task fields.
+func (h *TaskHandler) get(ctx context.Context, r *Request[NoBody]) (any, error) {
+	fields, err := h.projector.fields(ctx, r.Request)
+	if err != nil {
+		return nil, err
+	}
 
-	err := h.repo.Delete(ctx, id)
+	task, err := h.tasks.Get(ctx, r.Param("id"))
 	if err != nil {
-		if errors.Is(err, model.ErrTaskNotFound) {
-			h.logger.WarnContext(ctx, "task not found", slog.String("id", id))
-			h.respondError(w, http.StatusNotFound, "task not found")
-			h.recordMetrics(ctx, "DELETE", "/api/v1/tasks/{id}", http.StatusNotFound, start)
-			return
+		return nil, err
+	}
+
+	if fields != nil {
+		projected, err := h.projector.Project(ctx, "get", fields, task)
+		if err != nil {
+			return nil, err
 		}
-		h.logger.ErrorContext(ctx, "failed to delete task", slog.Any("error", err))
-		h.respondError(w, http.StatusInternalServerError, "failed to delete task")
-		h.recordMetrics(ctx, "DELETE", "/api/v1/tasks/{id}", http.StatusInternalServerError, start)
-		return
+		return projected[0], nil
 	}
+	return task, nil
+}
 
-	h.logger.InfoContext(ctx, "task deleted", slog.String("id", id))
+// update modifies an existing task.
+func (h *TaskHandler) update(ctx context.Context, r *Request[model.UpdateTaskRequest]) (*model.Task, error) {
+	return h.tasks.Update(ctx, r.Param("id"), &r.Body)
+}
 
-	w.WriteHeader(http.StatusNoContent)
-	h.recordMetrics(ctx, "DELETE", "/api/v1/tasks/{id}", http.StatusNoContent, start)
+// delete removes a task.
+func (h *TaskHandler) delete(ctx context.Context, r *Request[NoBody]) (NoBody, error) {
+	return NoBody{}, h.tasks.Delete(ctx, r.Param("id"))
 }
 
-// Health returns a health check response.
-func (h *TaskHandler) Health(w http.ResponseWriter, r *http.Request) {
-	h.respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+// dependencies returns the dependencies of a task and whether it is blocked.
+func (h *TaskHandler) dependencies(ctx context.Context, r *Request[NoBody]) (*model.DependencyStatus, error) {
+	status, err := h.tasks.Dependencies(ctx, r.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("task.blocked", status.Blocked))
+	return status, nil
 }
 
-func (h *TaskHandler) respondJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if data != nil {
-		json.NewEncoder(w).Encode(data)
+// history returns the latest revisions of a task.
+func (h *TaskHandler) history(ctx context.Context, r *Request[NoBody]) ([]model.Revision, error) {
+	revisions, err := h.tasks.History(ctx, r.Param("id"))
+	if err != nil {
+		return nil, err
 	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("task.revisions", len(revisions)))
+	return revisions, nil
 }
 
-func (h *TaskHandler) respondError(w http.ResponseWriter, status int, message string) {
-	h.respondJSON(w, status, map[string]string{"error": message})
+// setArchived returns the endpoint archiving a task, hiding it from default
+// list queries, or restoring an archived task.
+func (h *TaskHandler) setArchived(archived bool) func(context.Context, *Request[NoBody]) (*model.Task, error) {
+	return func(ctx context.Context, r *Request[NoBody]) (*model.Task, error) {
+		return h.tasks.SetArchived(ctx, r.Param("id"), archived)
+	}
 }
 
-func (h *TaskHandler) recordMetrics(ctx context.Context, method, route string, status int, start time.Time) {
-	duration := time.Since(start).Seconds()
+// assign assigns a task to the assignee of the request body; an empty
+// assignee unassigns it.
+func (h *TaskHandler) assign(ctx context.Context, r *Request[model.AssignTaskRequest]) (*model.Task, error) {
+	return h.tasks.Assign(ctx, r.Param("id"), r.Body.Assignee)
+}
 
-	attrs := metric.WithAttributes(
-		attribute.String("http.method", method),
-		attribute.String("http.route", route),
-		attribute.Int("http.status_code", status),
-	)
+// claim leases a task to the caller for the TTL of the optional request
+// body, or renews the caller's lease.
+func (h *TaskHandler) claim(ctx context.Context, r *Request[model.ClaimTaskRequest]) (*model.Task, error) {
+	return h.tasks.Claim(ctx, r.Param("id"), &r.Body)
+}
 
-	h.metrics.RequestCounter.Add(ctx, 1, attrs)
-	h.metrics.RequestDuration.Record(ctx, duration, attrs)
+// release ends the caller's lease on a task.
+func (h *TaskHandler) release(ctx context.Context, r *Request[NoBody]) (*model.Task, error) {
+	return h.tasks.Release(ctx, r.Param("id"))
 }
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/audit"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/feature"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/idgen"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/pagination"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/quota"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/saga"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// newTestTaskHandler returns the task routes backed by an in-memory
+// repository, and the service behind them.
+func newTestTaskHandler(t *testing.T) (http.Handler, *service.TaskService) {
+	t.Helper()
+	meter := noop.NewMeterProvider().Meter("")
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newID, err := idgen.New(idgen.UUIDv4)
+	must(err)
+	repo := repository.NewTaskRepository(newID, false)
+	logger := slog.New(slog.DiscardHandler)
+
+	quotas, err := quota.NewManager(repo, meter, 0, 0)
+	must(err)
+	flags, err := feature.New("", meter)
+	must(err)
+	sagas, err := saga.NewCoordinator(meter)
+	must(err)
+	tasks := service.NewTaskService(repo, logger, audit.NewRecorder(logger, nil), nil, nil, quotas, flags, sagas)
+
+	decoder, err := NewRequestDecoder(meter, 1<<20, false)
+	must(err)
+	endpoints, err := NewEndpoints(decoder, meter)
+	must(err)
+	cursors, err := pagination.NewCodec("test", meter)
+	must(err)
+	projector, err := NewProjector(meter)
+	must(err)
+	return NewTaskHandler(tasks, endpoints, cursors, projector, nil).Routes(), tasks
+}
+
+// TestSystemActorHeaderDoesNotBypassLease checks that a client sending the
+// reserved system actor can't change a task leased to someone else.
+func TestSystemActorHeaderDoesNotBypassLease(t *testing.T) {
+	h, tasks := newTestTaskHandler(t)
+
+	ctx := repository.WithActor(context.Background(), "alice")
+	task, err := tasks.Create(ctx, &model.CreateTaskRequest{Title: "leased"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tasks.Claim(ctx, task.ID, &model.ClaimTaskRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, actor := range []string{repository.SystemActor, "SYSTEM"} {
+		req := httptest.NewRequest(http.MethodPut, "/"+task.ID, strings.NewReader(`{"title":"taken over"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-ID", actor)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusConflict && rec.Code != http.StatusLocked {
+			t.Errorf("PUT as %q: got status %d, want %d or %d: %s", actor, rec.Code, http.StatusConflict, http.StatusLocked, rec.Body)
+		}
+	}
+
+	got, err := tasks.Get(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "leased" {
+		t.Errorf("title = %q, want the leased task unchanged", got.Title)
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/pagination"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultPageSizeV2 is the page size of the v2 task list without a limit
+// query parameter.
+const defaultPageSizeV2 = 50
+
+// TaskV2Handler handles HTTP requests of the v2 task API. Tasks carry a
+// lifecycle status instead of the done flag and archived_at of v1, and the
+// list is always paginated.
+type TaskV2Handler struct {
+	tasks   *service.TaskService
+	decoder *RequestDecoder
+	cursors *pagination.Codec
+}
+
+// NewTaskV2Handler creates a new TaskV2Handler encoding list cursors with
+// cursors.
+func NewTaskV2Handler(tasks *service.TaskService, decoder *RequestDecoder, cursors *pagination.Codec) *TaskV2Handler {
+	return &TaskV2Handler{
+		tasks:   tasks,
+		decoder: decoder,
+		cursors: cursors,
+	}
+}
+
+// Routes returns the chi router with v2 task routes.
+func (h *TaskV2Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(withActor)
+	r.Use(withConsistency)
+
+	r.Get("/", h.List)
+	r.With(withDryRun).Post("/", h.Create)
+	r.Get("/{id}", h.GetByID)
+	r.Put("/{id}/status", h.SetStatus)
+
+	return r
+}
+
+// List returns a page of tasks, with the cursor of the next page in the
+// response body. It takes the include_archived, assignee, limit (default
+// 50) and cursor query parameters of the v1 list.
+func (h *TaskV2Handler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "TaskV2Handler.List")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	opts, err := listOptions(r)
+	if err != nil {
+		logger.WarnContext(ctx, "invalid include_archived", slog.String("include_archived", r.URL.Query().Get("include_archived")))
+		response.Error(w, r, http.StatusBadRequest, "invalid include_archived")
+		return
+	}
+	opts.Limit = defaultPageSizeV2
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			logger.WarnContext(ctx, "invalid limit", slog.String("limit", v))
+			response.Error(w, r, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+			return
+		}
+		opts.Limit = limit
+	}
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		after, err := h.cursors.Decode(ctx, v)
+		if err != nil {
+			writeError(ctx, w, r, err, "failed to decode cursor")
+			return
+		}
+		opts.After = &after
+	}
+
+	// Fetch one task more than the page to know whether there is a next one.
+	page := opts.Limit
+	opts.Limit++
+	tasks, err := h.tasks.List(ctx, opts)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to list tasks")
+		return
+	}
+
+	var resp model.TaskPageV2
+	if len(tasks) > page {
+		tasks = tasks[:page]
+		resp.NextCursor = h.cursors.Encode(repository.CursorOf(tasks[page-1]))
+	}
+	resp.Items = make([]*model.TaskV2, len(tasks))
+	for i, task := range tasks {
+		resp.Items[i] = model.NewTaskV2(task)
+	}
+
+	span.SetAttributes(
+		attribute.Int("task.count", len(tasks)),
+		attribute.Bool("pagination.has_next", resp.NextCursor != ""),
+	)
+	response.JSON(w, r, http.StatusOK, resp)
+}
+
+// Create adds a new task. With dry_run=true it responds with the task that
+// would be created, without creating it.
+func (h *TaskV2Handler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "TaskV2Handler.Create")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	var req model.CreateTaskRequest
+	if err := h.decoder.Decode(ctx, w, r, &req); err != nil {
+		logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
+		response.Error(w, r, err.Status, err.Detail())
+		return
+	}
+
+	req.Owner = actorFromRequest(r)
+	req.Tenant = tenantFromRequest(r)
+
+	task, err := h.tasks.Create(ctx, &req)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to create task")
+		return
+	}
+
+	span.SetAttributes(attribute.String("task.id", task.ID))
+
+	if repository.IsDryRun(ctx) {
+		response.JSON(w, r, http.StatusOK, model.NewTaskV2(task))
+		return
+	}
+	response.JSON(w, r, http.StatusCreated, model.NewTaskV2(task))
+}
+
+// GetByID returns a task by ID.
+func (h *TaskV2Handler) GetByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	ctx, span := tracer.Start(ctx, "TaskV2Handler.GetByID",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	task, err := h.tasks.Get(ctx, id)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to get task")
+		return
+	}
+
+	response.JSON(w, r, http.StatusOK, model.NewTaskV2(task))
+}
+
+// SetStatus moves a task to the status of the request body: open, done or
+// archived.
+func (h *TaskV2Handler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	ctx, span := tracer.Start(ctx, "TaskV2Handler.SetStatus",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	var req model.SetStatusRequest
+	if err := h.decoder.Decode(ctx, w, r, &req); err != nil {
+		logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
+		response.Error(w, r, err.Status, err.Detail())
+		return
+	}
+
+	logger.InfoContext(ctx, "setting task status", slog.String("id", id), slog.String("status", req.Status))
+
+	task, err := h.tasks.SetStatus(ctx, id, &req)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to set task status")
+		return
+	}
+
+	response.JSON(w, r, http.StatusOK, model.NewTaskV2(task))
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// flushTimeout bounds a manual flush of all telemetry providers.
+const flushTimeout = 10 * time.Second
+
+// Flusher is a telemetry provider that can export buffered telemetry on
+// demand, e.g. the SDK tracer, meter and logger providers.
+type Flusher interface {
+	ForceFlush(ctx context.Context) error
+}
+
+// FlushResult reports the outcome of flushing each telemetry signal.
+type FlushResult struct {
+	Flushed []string          `json:"flushed"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// TelemetryHandler handles admin HTTP requests for the telemetry pipeline.
+type TelemetryHandler struct {
+	providers map[string]Flusher
+}
+
+// NewTelemetryHandler creates a new TelemetryHandler for the providers
+// keyed by signal name.
+func NewTelemetryHandler(providers map[string]Flusher) *TelemetryHandler {
+	return &TelemetryHandler{
+		providers: providers,
+	}
+}
+
+// Routes returns the chi router with telemetry routes.
+func (h *TelemetryHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/flush", h.Flush)
+
+	return r
+}
+
+// Flush exports all buffered telemetry instead of waiting for the periodic
+// exports. The span of the flush request itself is only exported later,
+// since it ends after the flush.
+func (h *TelemetryHandler) Flush(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "TelemetryHandler.Flush")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+
+	result := FlushResult{Flushed: make([]string, 0, len(h.providers))}
+	for _, signal := range slices.Sorted(maps.Keys(h.providers)) {
+		start := time.Now()
+		err := h.providers[signal].ForceFlush(ctx)
+		span.AddEvent("telemetry.flushed", trace.WithAttributes(
+			attribute.String("telemetry.signal", signal),
+			attribute.Int64("telemetry.flush.duration_ms", time.Since(start).Milliseconds()),
+			attribute.Bool("telemetry.flush.success", err == nil),
+		))
+		if err != nil {
+			if result.Errors == nil {
+				result.Errors = make(map[string]string)
+			}
+			result.Errors[signal] = err.Error()
+			logger.WarnContext(ctx, "failed to flush telemetry", slog.String("signal", signal), slog.Any("error", err))
+			continue
+		}
+		result.Flushed = append(result.Flushed, signal)
+	}
+
+	if len(result.Errors) > 0 {
+		span.SetStatus(codes.Error, "telemetry flush failed")
+		response.JSON(w, r, http.StatusBadGateway, result)
+		return
+	}
+
+	logger.InfoContext(ctx, "telemetry flushed", slog.Any("signals", result.Flushed))
+	response.JSON(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TemplateHandler handles HTTP requests for task templates.
+type TemplateHandler struct {
+	templates *service.TemplateService
+	decoder   *RequestDecoder
+}
+
+// NewTemplateHandler creates a new TemplateHandler.
+func NewTemplateHandler(templates *service.TemplateService, decoder *RequestDecoder) *TemplateHandler {
+	return &TemplateHandler{
+		templates: templates,
+		decoder:   decoder,
+	}
+}
+
+// Routes returns the chi router with template routes.
+func (h *TemplateHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(withActor)
+
+	r.Get("/", h.List)
+	r.With(withDryRun).Post("/", h.Create)
+	r.Get("/{id}", h.GetByID)
+	r.With(withDryRun).Delete("/{id}", h.Delete)
+	r.With(withDryRun).Post("/{id}/instantiate", h.Instantiate)
+
+	return r
+}
+
+// List returns all templates ordered by name.
+func (h *TemplateHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "TemplateHandler.List")
+	defer span.End()
+
+	templates, err := h.templates.List(ctx)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to list task templates")
+		return
+	}
+
+	span.SetAttributes(attribute.Int("task.template.count", len(templates)))
+	response.JSON(w, r, http.StatusOK, templates)
+}
+
+// Create adds a new template. With dry_run=true it responds with the
+// template that would be created, without creating it.
+func (h *TemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "TemplateHandler.Create")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	var req model.CreateTemplateRequest
+	if err := h.decoder.Decode(ctx, w, r, &req); err != nil {
+		logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
+		response.Error(w, r, err.Status, err.Detail())
+		return
+	}
+
+	req.Owner = actorFromRequest(r)
+	req.Tenant = tenantFromRequest(r)
+
+	tmpl, err := h.templates.Create(ctx, &req)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to create task template")
+		return
+	}
+
+	span.SetAttributes(attribute.String("task.template.id", tmpl.ID))
+
+	if repository.IsDryRun(ctx) {
+		response.JSON(w, r, http.StatusOK, tmpl)
+		return
+	}
+	response.JSON(w, r, http.StatusCreated, tmpl)
+}
+
+// GetByID returns a template by ID.
+func (h *TemplateHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	ctx, span := tracer.Start(ctx, "TemplateHandler.GetByID",
+		trace.WithAttributes(attribute.String("task.template.id", id)),
+	)
+	defer span.End()
+
+	tmpl, err := h.templates.Get(ctx, id)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to get task template")
+		return
+	}
+
+	response.JSON(w, r, http.StatusOK, tmpl)
+}
+
+// Delete removes a template. Tasks created from it are kept.
+func (h *TemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	ctx, span := tracer.Start(ctx, "TemplateHandler.Delete",
+		trace.WithAttributes(attribute.String("task.template.id", id)),
+	)
+	defer span.End()
+
+	if err := h.templates.Delete(ctx, id); err != nil {
+		writeError(ctx, w, r, err, "failed to delete task template")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Instantiate creates a task from a template, substituting the variables of
+// the request body into its title and description. With dry_run=true it
+// responds with the task that would be created, without creating it.
+func (h *TemplateHandler) Instantiate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	ctx, span := tracer.Start(ctx, "TemplateHandler.Instantiate",
+		trace.WithAttributes(attribute.String("task.template.id", id)),
+	)
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	var req model.InstantiateTemplateRequest
+	if err := h.decoder.Decode(ctx, w, r, &req); err != nil {
+		logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
+		response.Error(w, r, err.Status, err.Detail())
+		return
+	}
+
+	logger.InfoContext(ctx, "instantiating task template", slog.String("template_id", id))
+
+	task, err := h.templates.Instantiate(ctx, id, req.Variables, actorFromRequest(r), tenantFromRequest(r))
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to instantiate task template")
+		return
+	}
+
+	span.SetAttributes(attribute.String("task.id", task.ID))
+
+	if repository.IsDryRun(ctx) {
+		response.JSON(w, r, http.StatusOK, task)
+		return
+	}
+	response.JSON(w, r, http.StatusCreated, task)
+}
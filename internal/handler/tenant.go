@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/tenant"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TenantHandler handles admin HTTP requests for tenant policies.
+type TenantHandler struct {
+	policies *tenant.Policies
+	decoder  *RequestDecoder
+}
+
+// NewTenantHandler creates a new TenantHandler.
+func NewTenantHandler(policies *tenant.Policies, decoder *RequestDecoder) *TenantHandler {
+	return &TenantHandler{
+		policies: policies,
+		decoder:  decoder,
+	}
+}
+
+// Routes returns the chi router with tenant policy routes.
+func (h *TenantHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.List)
+	r.Put("/{tenant}", h.Set)
+	r.Delete("/{tenant}", h.Delete)
+
+	return r
+}
+
+// List returns the default policy and the per-tenant overrides.
+func (h *TenantHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	_, span := tracer.Start(ctx, "TenantHandler.List")
+	defer span.End()
+
+	response.JSON(w, r, http.StatusOK, h.policies.Snapshot())
+}
+
+// Set changes the policy of a tenant. The "default" tenant changes the
+// default policy.
+func (h *TenantHandler) Set(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := chi.URLParam(r, "tenant")
+
+	ctx, span := tracer.Start(ctx, "TenantHandler.Set",
+		trace.WithAttributes(telemetry.UserString("tenant.id", name)),
+	)
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	var policy tenant.Policy
+	if err := h.decoder.Decode(ctx, w, r, &policy); err != nil {
+		logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
+		response.Error(w, r, err.Status, err.Detail())
+		return
+	}
+
+	if err := h.policies.Set(name, policy); err != nil {
+		logger.WarnContext(ctx, "invalid tenant policy", slog.Any("error", err))
+		response.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attrs := []any{
+		slog.String("tenant", name),
+		slog.Float64("rate_limit", policy.RateLimit),
+		slog.Int("burst", policy.Burst),
+		slog.String("actor", actorFromRequest(r)),
+	}
+	if policy.SampleRatio != nil {
+		attrs = append(attrs, slog.Float64("sample_ratio", *policy.SampleRatio))
+	}
+	logger.InfoContext(ctx, "tenant policy updated", attrs...)
+
+	response.JSON(w, r, http.StatusOK, h.policies.Snapshot())
+}
+
+// Delete removes the policy of a tenant, which falls back to the default
+// policy.
+func (h *TenantHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := chi.URLParam(r, "tenant")
+
+	ctx, span := tracer.Start(ctx, "TenantHandler.Delete",
+		trace.WithAttributes(telemetry.UserString("tenant.id", name)),
+	)
+	defer span.End()
+
+	if !h.policies.Delete(name) {
+		response.Error(w, r, http.StatusNotFound, "tenant policy not found")
+		return
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "tenant policy removed",
+		slog.String("tenant", name),
+		slog.String("actor", actorFromRequest(r)),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/i18n"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TransitionHandler handles bulk status transitions of tasks.
+type TransitionHandler struct {
+	tasks   *service.TaskService
+	decoder *RequestDecoder
+
+	outcomes metric.Int64Counter
+}
+
+// NewTransitionHandler creates a new TransitionHandler that moves tasks
+// through the task service, so every transition is audited and published
+// like a single status change.
+func NewTransitionHandler(tasks *service.TaskService, decoder *RequestDecoder, meter metric.Meter) (*TransitionHandler, error) {
+	outcomes, err := meter.Int64Counter(
+		"task_transitions_total",
+		metric.WithDescription("Total number of tasks processed by bulk status transitions"),
+		metric.WithUnit("{task}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transition outcome counter: %w", err)
+	}
+
+	return &TransitionHandler{
+		tasks:    tasks,
+		decoder:  decoder,
+		outcomes: outcomes,
+	}, nil
+}
+
+// Routes returns the chi router with transition routes, to be mounted
+// below /tasks/transition.
+func (h *TransitionHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", h.Transition)
+
+	return r
+}
+
+// Transition moves the tasks matching the filter of the request body to its
+// status and responds with a report of the outcome per task. An
+// all-or-nothing transition first checks every task with a dry run and
+// responds with 409 Conflict, changing nothing, if any check fails; the
+// transitions are then applied in one transaction. Nothing changing on a
+// failure only holds on backends that have transactions: a concurrent write
+// between the check and the transaction can still fail a transition, and
+// without transactions the ones applied before it are kept. A best-effort
+// transition applies each transition on its own.
+func (h *TransitionHandler) Transition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctx, span := tracer.Start(ctx, "TransitionHandler.Transition")
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	var req model.TransitionTasksRequest
+	if err := h.decoder.Decode(ctx, w, r, &req); err != nil {
+		logger.WarnContext(ctx, "invalid request body", slog.Any("error", err))
+		response.Error(w, r, err.Status, err.Detail())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(ctx, w, r, err, "invalid transition")
+		return
+	}
+	span.SetAttributes(
+		attribute.String("task.status", req.Status),
+		attribute.String("transition.mode", req.Mode),
+	)
+
+	report := &model.TransitionReport{
+		Status: req.Status,
+		Mode:   req.Mode,
+		Tasks:  make([]model.TaskTransition, 0),
+	}
+	pending, err := h.match(ctx, &req, report)
+	if err != nil {
+		writeError(ctx, w, r, err, "failed to select tasks")
+		return
+	}
+
+	atomic := req.Mode == model.TransitionAllOrNothing
+	if !atomic || (report.Failed == 0 && h.check(ctx, pending, req.Status, report)) {
+		report.Applied = h.apply(ctx, pending, req.Status, report, atomic) == nil
+	}
+	h.skip(pending, report)
+	h.record(ctx, report)
+
+	span.SetAttributes(
+		attribute.Bool("transition.applied", report.Applied),
+		attribute.Int("transition.transitioned", report.Transitioned),
+		attribute.Int("transition.unchanged", report.Unchanged),
+		attribute.Int("transition.failed", report.Failed),
+		attribute.Int("transition.skipped", report.Skipped),
+	)
+	logger.InfoContext(ctx, "tasks transitioned",
+		slog.String("status", req.Status),
+		slog.String("mode", req.Mode),
+		slog.Bool("applied", report.Applied),
+		slog.Int("transitioned", report.Transitioned),
+		slog.Int("failed", report.Failed),
+	)
+
+	status := http.StatusOK
+	if !report.Applied {
+		status = http.StatusConflict
+	}
+	response.JSON(w, r, status, report)
+}
+
+// match returns the tasks of the filter of req that are not in its status
+// yet. Tasks already in the status are reported as unchanged, unknown IDs
+// as failed.
+func (h *TransitionHandler) match(ctx context.Context, req *model.TransitionTasksRequest, report *model.TransitionReport) ([]*model.Task, error) {
+	var matched []*model.Task
+	if len(req.Filter.IDs) > 0 {
+		seen := make(map[string]bool, len(req.Filter.IDs))
+		for _, id := range req.Filter.IDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			task, err := h.tasks.Get(ctx, id)
+			if errors.Is(err, model.ErrTaskNotFound) {
+				h.fail(ctx, report, model.TaskTransition{ID: id}, err)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if req.Filter.Status == "" || task.State() == req.Filter.Status {
+				matched = append(matched, task)
+			}
+		}
+	} else {
+		opts := repository.ListOptions{IncludeArchived: req.Filter.Status == model.StateArchived}
+		for task, err := range h.tasks.ListIter(ctx, opts) {
+			if err != nil {
+				return nil, err
+			}
+			if task.State() != req.Filter.Status {
+				continue
+			}
+			if len(matched) == model.MaxTransitionTasks {
+				return nil, model.ErrTooManyTransitionTasks
+			}
+			matched = append(matched, task)
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("transition.matched", len(matched)+report.Failed))
+
+	pending := matched[:0]
+	for _, task := range matched {
+		if task.State() == req.Status {
+			report.Unchanged++
+			report.Tasks = append(report.Tasks, model.TaskTransition{ID: task.ID, From: task.State(), Outcome: model.TransitionUnchanged})
+			continue
+		}
+		pending = append(pending, task)
+	}
+	return pending, nil
+}
+
+// check runs the transitions of pending as a dry run and reports whether
+// all of them would succeed, reporting those that wouldn't as failed.
+func (h *TransitionHandler) check(ctx context.Context, pending []*model.Task, status string, report *model.TransitionReport) bool {
+	ctx, span := tracer.Start(ctx, "TransitionHandler.Check",
+		trace.WithAttributes(attribute.Int("transition.pending", len(pending))),
+	)
+	defer span.End()
+
+	dryRun := repository.WithDryRun(ctx)
+	for _, task := range pending {
+		if _, err := h.tasks.SetStatus(dryRun, task.ID, &model.SetStatusRequest{Status: status}); err != nil {
+			h.fail(ctx, report, model.TaskTransition{ID: task.ID, From: task.State()}, err)
+		}
+	}
+	return report.Failed == 0
+}
+
+// apply transitions the tasks of pending, in one transaction if atomic. An
+// atomic apply stops at the first failure and returns it, which rolls the
+// transaction back on backends that have transactions.
+func (h *TransitionHandler) apply(ctx context.Context, pending []*model.Task, status string, report *model.TransitionReport, atomic bool) error {
+	run := func(tasks *service.TaskService) error {
+		for _, task := range pending {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			result := model.TaskTransition{ID: task.ID, From: task.State()}
+			if _, err := tasks.SetStatus(ctx, task.ID, &model.SetStatusRequest{Status: status}); err != nil {
+				h.fail(ctx, report, result, err)
+				if atomic {
+					return err
+				}
+				continue
+			}
+			result.Outcome = model.TransitionTransitioned
+			report.Transitioned++
+			report.Tasks = append(report.Tasks, result)
+		}
+		return nil
+	}
+	if atomic {
+		return h.tasks.WithTx(ctx, run)
+	}
+	return run(h.tasks)
+}
+
+// skip reports the tasks of pending without an outcome as skipped.
+func (h *TransitionHandler) skip(pending []*model.Task, report *model.TransitionReport) {
+	done := make(map[string]bool, len(report.Tasks))
+	for _, t := range report.Tasks {
+		done[t.ID] = true
+	}
+	for _, task := range pending {
+		if done[task.ID] {
+			continue
+		}
+		report.Skipped++
+		report.Tasks = append(report.Tasks, model.TaskTransition{ID: task.ID, From: task.State(), Outcome: model.TransitionSkipped})
+	}
+}
+
+// fail records a failed transition on the active span and in the report.
+func (h *TransitionHandler) fail(ctx context.Context, report *model.TransitionReport, result model.TaskTransition, err error) {
+	result.Outcome = model.TransitionFailed
+	result.Error = i18n.T(ctx, err.Error())
+	report.Failed++
+	report.Tasks = append(report.Tasks, result)
+
+	trace.SpanFromContext(ctx).AddEvent("transition.task.failed", trace.WithAttributes(
+		attribute.String("task.id", result.ID),
+		attribute.String("error.message", err.Error()),
+	))
+}
+
+// record counts the tasks of report per outcome.
+func (h *TransitionHandler) record(ctx context.Context, report *model.TransitionReport) {
+	for outcome, n := range map[string]int{
+		model.TransitionTransitioned: report.Transitioned,
+		model.TransitionUnchanged:    report.Unchanged,
+		model.TransitionFailed:       report.Failed,
+		model.TransitionSkipped:      report.Skipped,
+	} {
+		if n == 0 {
+			continue
+		}
+		h.outcomes.Add(ctx, int64(n), metric.WithAttributes(
+			attribute.String("outcome", outcome),
+			attribute.String("task.status", report.Status),
+			attribute.String("transition.mode", report.Mode),
+		))
+	}
+}
@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/buildinfo"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+)
+
+// Version returns the build information of the server.
+func Version(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, r, http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/webhook"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Outcomes of a webhook delivery, as recorded in webhooks_received_total.
+const (
+	webhookCreated          = "created"
+	webhookIgnored          = "ignored"
+	webhookInvalidSignature = "invalid_signature"
+	webhookInvalidPayload   = "invalid_payload"
+	webhookFailed           = "failed"
+)
+
+// WebhookResponse is the response to a webhook delivery.
+type WebhookResponse struct {
+	Source     string `json:"source"`
+	Event      string `json:"event"`
+	DeliveryID string `json:"delivery_id,omitempty"`
+	// TaskID is the task created by the delivery, empty if the event
+	// creates none.
+	TaskID string `json:"task_id,omitempty"`
+}
+
+// WebhookHandler handles webhook deliveries of other services, creating
+// tasks from their events.
+type WebhookHandler struct {
+	tasks    *service.TaskService
+	receiver *webhook.Receiver
+	maxBytes int64
+
+	received metric.Int64Counter
+}
+
+// NewWebhookHandler creates a new WebhookHandler accepting the deliveries
+// of the sources of receiver. Bodies larger than maxBytes are rejected; a
+// maxBytes of 0 disables the limit.
+func NewWebhookHandler(tasks *service.TaskService, receiver *webhook.Receiver, meter metric.Meter, maxBytes int64) (*WebhookHandler, error) {
+	received, err := meter.Int64Counter(
+		"webhooks_received_total",
+		metric.WithDescription("Total number of webhook deliveries by source, event and outcome"),
+		metric.WithUnit("{delivery}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook counter: %w", err)
+	}
+	return &WebhookHandler{
+		tasks:    tasks,
+		receiver: receiver,
+		maxBytes: maxBytes,
+		received: received,
+	}, nil
+}
+
+// Routes returns the chi router with webhook routes.
+func (h *WebhookHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/{source}", h.Receive)
+
+	return r
+}
+
+// Receive verifies and validates a delivery of the source in the URL and
+// creates the task of its event, if any. The span records the source, event
+// and delivery ID; the signature verification, the payload validation and
+// the task creation are child spans.
+func (h *WebhookHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	source := chi.URLParam(r, "source")
+
+	ctx, span := tracer.Start(ctx, "WebhookHandler.Receive",
+		trace.WithAttributes(attribute.String("webhook.source", source)),
+	)
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+
+	if !h.receiver.Accepts(source) {
+		response.Error(w, r, http.StatusNotFound, webhook.ErrUnknownSource.Error())
+		return
+	}
+
+	if h.maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			response.Error(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		response.Error(w, r, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	d, err := h.receiver.Receive(ctx, source, r.Header, body)
+	span.SetAttributes(
+		attribute.String("webhook.event", d.Event),
+		attribute.String("webhook.delivery_id", d.ID),
+	)
+	outcome := webhookIgnored
+	defer func() {
+		span.SetAttributes(attribute.String("webhook.outcome", outcome))
+		h.received.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("webhook.source", source),
+			attribute.String("webhook.event", eventLabel(d, err)),
+			attribute.String("outcome", outcome),
+		))
+	}()
+
+	switch {
+	case errors.Is(err, webhook.ErrInvalidSignature):
+		outcome = webhookInvalidSignature
+		logger.WarnContext(ctx, "webhook signature rejected", slog.String("source", source))
+		response.Error(w, r, http.StatusUnauthorized, err.Error())
+		return
+	case err != nil:
+		outcome = webhookInvalidPayload
+		logger.WarnContext(ctx, "invalid webhook payload",
+			slog.String("source", source),
+			slog.String("event", d.Event),
+			slog.Any("error", err),
+		)
+		response.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := WebhookResponse{Source: d.Source, Event: d.Event, DeliveryID: d.ID}
+	if d.Task == nil {
+		logger.InfoContext(ctx, "webhook ignored", slog.String("source", source), slog.String("event", d.Event))
+		response.JSON(w, r, http.StatusOK, resp)
+		return
+	}
+
+	task, err := h.tasks.Create(repository.WithActor(ctx, "webhook:"+source), d.Task)
+	if err != nil {
+		outcome = webhookFailed
+		writeError(ctx, w, r, err, "failed to create task")
+		return
+	}
+	outcome = webhookCreated
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	logger.InfoContext(ctx, "task created from webhook",
+		slog.String("source", source),
+		slog.String("event", d.Event),
+		slog.String("delivery_id", d.ID),
+		slog.String("id", task.ID),
+	)
+
+	resp.TaskID = task.ID
+	w.Header().Set("Location", taskLocation(r, task.ID))
+	response.JSON(w, r, http.StatusCreated, resp)
+}
+
+// eventLabel returns the event of d as recorded in webhooks_received_total.
+// Events of deliveries with an invalid signature are sent by anyone, so they
+// are recorded as "unverified".
+func eventLabel(d webhook.Delivery, err error) string {
+	if errors.Is(err, webhook.ErrInvalidSignature) {
+		return "unverified"
+	}
+	return d.Event
+}
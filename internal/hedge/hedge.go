@@ -0,0 +1,172 @@
+package hedge
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/async"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attempts of a hedged call, as recorded in the hedge.attempt and
+// hedge.winner span attributes.
+const (
+	AttemptPrimary = "primary"
+	AttemptHedge   = "hedge"
+)
+
+const (
+	// windowSize is the number of recent latencies the hedge delay is
+	// computed from.
+	windowSize = 100
+	// minSamples is the number of latencies needed before calls are hedged.
+	minSamples = 20
+	// percentile of the recent latencies after which a hedge is sent.
+	percentile = 0.95
+)
+
+// Hedger sends a second attempt of a call that is still running after the
+// p95 latency of recent calls, and returns whichever attempt succeeds first.
+// The other attempt is cancelled. Calls are not hedged until enough
+// latencies were observed, and never before minDelay. Only idempotent calls
+// should be hedged, since both attempts may reach the server.
+type Hedger struct {
+	name     string
+	minDelay time.Duration
+	attrs    []attribute.KeyValue
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+
+	issued    metric.Int64Counter
+	cancelled metric.Int64Counter
+}
+
+// NewHedger creates a new Hedger whose attempts run in spans named name.
+// attrs are added to the hedge counters, e.g. the peer.service.
+func NewHedger(name string, meter metric.Meter, minDelay time.Duration, attrs ...attribute.KeyValue) (*Hedger, error) {
+	h := &Hedger{
+		name:      name,
+		minDelay:  minDelay,
+		attrs:     attrs,
+		latencies: make([]time.Duration, 0, windowSize),
+	}
+
+	var err error
+
+	h.issued, err = meter.Int64Counter(
+		"hedged_requests_issued_total",
+		metric.WithDescription("Total number of hedged attempts sent because a call exceeded its p95 latency"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hedged requests issued counter: %w", err)
+	}
+
+	h.cancelled, err = meter.Int64Counter(
+		"hedged_requests_cancelled_total",
+		metric.WithDescription("Total number of attempts of hedged calls cancelled because the other attempt won"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hedged requests cancelled counter: %w", err)
+	}
+
+	return h, nil
+}
+
+type result struct {
+	attempt string
+	elapsed time.Duration
+	err     error
+}
+
+// Do calls fn and, if it is still running after the hedge delay, calls it a
+// second time. It returns once an attempt succeeds, or with the error of the
+// last attempt once all have failed. A primary attempt failing before the
+// hedge delay is not hedged; that is left to retries. The attempt that won
+// is recorded on the span in ctx.
+func (h *Hedger) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	span := trace.SpanFromContext(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, 2)
+	launch := func(attempt string) {
+		start := time.Now()
+		async.Go(ctx, h.name, func(ctx context.Context) {
+			err := fn(ctx)
+			results <- result{attempt: attempt, elapsed: time.Since(start), err: err}
+		}, async.WithSpanOptions(trace.WithAttributes(attribute.String("hedge.attempt", attempt))))
+	}
+
+	launch(AttemptPrimary)
+	inflight := 1
+
+	var hedgeTimer <-chan time.Time
+	if delay, ok := h.delay(); ok {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedgeTimer = timer.C
+		span.SetAttributes(attribute.Int64("hedge.delay_ms", delay.Milliseconds()))
+	}
+
+	for {
+		select {
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			launch(AttemptHedge)
+			inflight++
+			h.issued.Add(ctx, 1, metric.WithAttributes(h.attrs...))
+			span.SetAttributes(attribute.Bool("hedge.issued", true))
+		case r := <-results:
+			inflight--
+			if r.err == nil {
+				h.observe(r.elapsed)
+				span.SetAttributes(attribute.String("hedge.winner", r.attempt))
+				if inflight > 0 {
+					h.cancelled.Add(ctx, 1, metric.WithAttributes(h.attrs...))
+				}
+				return nil
+			}
+			if inflight == 0 {
+				return r.err
+			}
+		}
+	}
+}
+
+// delay returns the p95 of the recent latencies, at least minDelay, and
+// false if there are too few latencies yet.
+func (h *Hedger) delay() (time.Duration, bool) {
+	h.mu.Lock()
+	if len(h.latencies) < minSamples {
+		h.mu.Unlock()
+		return 0, false
+	}
+	sorted := slices.Clone(h.latencies)
+	h.mu.Unlock()
+
+	slices.Sort(sorted)
+	p := sorted[int(float64(len(sorted)-1)*percentile)]
+	return max(p, h.minDelay), true
+}
+
+// observe adds the latency of a successful attempt to the window.
+func (h *Hedger) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < windowSize {
+		h.latencies = append(h.latencies, d)
+		return
+	}
+	h.latencies[h.next] = d
+	h.next = (h.next + 1) % windowSize
+}
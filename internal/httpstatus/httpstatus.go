@@ -0,0 +1,28 @@
+package httpstatus
+
+import (
+	"net/http"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+)
+
+// FromError returns the HTTP status code for err by its kind. A user over
+// quota (ResourceExhausted) may retry later, while PermissionDenied needs
+// someone else to act. Unclassified errors are 500.
+func FromError(err error) int {
+	switch apperr.KindOf(err) {
+	case apperr.NotFound:
+		return http.StatusNotFound
+	case apperr.Conflict:
+		return http.StatusConflict
+	case apperr.Validation:
+		return http.StatusBadRequest
+	case apperr.PermissionDenied:
+		return http.StatusForbidden
+	case apperr.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case apperr.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
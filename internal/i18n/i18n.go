@@ -0,0 +1,146 @@
+// Package i18n translates client-facing messages, such as the details of
+// error responses, into the locale negotiated from the Accept-Language
+// header. The message catalogs are embedded JSON files in locales/, one per
+// locale, keyed by the English message; English is the source locale, so
+// messages missing from a catalog are returned as they are.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jsoncodec"
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is the locale of the messages in the code, used when no
+// supported locale matches the request.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+// Bundle holds the message catalogs of the supported locales.
+type Bundle struct {
+	matcher  language.Matcher
+	locales  []string
+	catalogs map[string]map[string]string
+}
+
+// Load loads the embedded message catalogs.
+func Load() (*Bundle, error) {
+	files, err := catalogFiles.ReadDir("locales")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message catalogs: %w", err)
+	}
+
+	// The default locale goes first, since the matcher falls back to the
+	// first tag.
+	b := &Bundle{
+		locales:  []string{DefaultLocale},
+		catalogs: map[string]map[string]string{DefaultLocale: {}},
+	}
+	for _, file := range files {
+		locale := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+		data, err := catalogFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message catalog %s: %w", locale, err)
+		}
+		catalog := make(map[string]string)
+		if err := jsoncodec.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("failed to parse message catalog %s: %w", locale, err)
+		}
+		if _, err := language.Parse(locale); err != nil {
+			return nil, fmt.Errorf("invalid locale of message catalog %s: %w", file.Name(), err)
+		}
+		if locale != DefaultLocale {
+			b.locales = append(b.locales, locale)
+		}
+		b.catalogs[locale] = catalog
+	}
+
+	tags := make([]language.Tag, len(b.locales))
+	for i, locale := range b.locales {
+		tags[i] = language.Make(locale)
+	}
+	b.matcher = language.NewMatcher(tags)
+	return b, nil
+}
+
+// Locales returns the supported locales, the default locale first.
+func (b *Bundle) Locales() []string {
+	return b.locales
+}
+
+// Match returns the supported locale that best matches an Accept-Language
+// header, or DefaultLocale if none does or the header is invalid.
+func (b *Bundle) Match(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+	_, i, confidence := b.matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return b.locales[i]
+}
+
+// Localizer returns the Localizer of a supported locale.
+func (b *Bundle) Localizer(locale string) *Localizer {
+	return &Localizer{locale: locale, messages: b.catalogs[locale]}
+}
+
+// Localizer translates messages into one locale.
+type Localizer struct {
+	locale   string
+	messages map[string]string
+}
+
+// Locale returns the locale of l, or DefaultLocale for a nil Localizer.
+func (l *Localizer) Locale() string {
+	if l == nil {
+		return DefaultLocale
+	}
+	return l.locale
+}
+
+// Translate returns the translation of msg. A message wrapping another as
+// "prefix: details" is translated by its prefix if it has no translation of
+// its own, keeping the details. Messages without translation, and all
+// messages of a nil Localizer, are returned unchanged.
+func (l *Localizer) Translate(msg string) string {
+	if l == nil {
+		return msg
+	}
+	if t, ok := l.messages[msg]; ok {
+		return t
+	}
+	if prefix, details, ok := strings.Cut(msg, ": "); ok {
+		if t, ok := l.messages[prefix]; ok {
+			return t + ": " + details
+		}
+	}
+	return msg
+}
+
+type localizerKey struct{}
+
+// WithLocalizer returns a context carrying l.
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+// FromContext returns the Localizer of ctx, or nil if there is none.
+func FromContext(ctx context.Context) *Localizer {
+	l, _ := ctx.Value(localizerKey{}).(*Localizer)
+	return l
+}
+
+// T translates msg with the Localizer of ctx.
+func T(ctx context.Context, msg string) string {
+	return FromContext(ctx).Translate(msg)
+}
@@ -0,0 +1,103 @@
+// Package idgen generates task IDs with a configurable strategy. All
+// strategies but uuidv4 are time-sortable: IDs generated later sort after
+// earlier ones (to the millisecond, or the second for KSUIDs), which keeps
+// inserts at the end of B-tree indexes in SQL backends.
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Strategies of New.
+const (
+	UUIDv4 = "uuidv4"
+	UUIDv7 = "uuidv7"
+	ULID   = "ulid"
+	KSUID  = "ksuid"
+)
+
+// Generator returns a new unique ID on every call.
+type Generator func() string
+
+// New returns the generator of the given strategy.
+func New(strategy string) (Generator, error) {
+	switch strategy {
+	case UUIDv4:
+		return newUUIDv4, nil
+	case UUIDv7:
+		return newUUIDv7, nil
+	case ULID:
+		return newULID, nil
+	case KSUID:
+		return newKSUID, nil
+	default:
+		return nil, fmt.Errorf("unknown id strategy %q, must be one of uuidv4, uuidv7, ulid, ksuid", strategy)
+	}
+}
+
+func newUUIDv4() string {
+	return uuid.New().String()
+}
+
+// newUUIDv7 returns a UUIDv7, which starts with the Unix time in
+// milliseconds and is monotonic within a millisecond.
+func newUUIDv7() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// crockford is the alphabet of ULIDs, Crockford's base32.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID: a 48-bit Unix time in milliseconds followed by 80
+// random bits, as 26 characters of Crockford's base32.
+func newULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	rand.Read(b[6:])
+
+	// 26 characters are 130 bits: the first 2 are zero padding.
+	var out [26]byte
+	for i := range out {
+		v := 0
+		for j := range 5 {
+			v <<= 1
+			if p := i*5 + j - 2; p >= 0 {
+				v |= int(b[p/8]>>(7-p%8)) & 1
+			}
+		}
+		out[i] = crockford[v]
+	}
+	return string(out[:])
+}
+
+// ksuidEpoch is the epoch of KSUID timestamps, 2014-05-13T16:53:20Z.
+const ksuidEpoch = 1400000000
+
+// base62 is the alphabet of KSUIDs, ordered so that the encoding sorts like
+// the bytes.
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// newKSUID returns a KSUID: a 32-bit timestamp in seconds since ksuidEpoch
+// followed by 128 random bits, as 27 base62 characters.
+func newKSUID() string {
+	var b [20]byte
+	binary.BigEndian.PutUint32(b[0:4], uint32(time.Now().Unix()-ksuidEpoch))
+	rand.Read(b[4:])
+
+	n := new(big.Int).SetBytes(b[:])
+	base, rem := big.NewInt(int64(len(base62))), new(big.Int)
+	out := make([]byte, 27)
+	for i := len(out) - 1; i >= 0; i-- {
+		n.DivMod(n, base, rem)
+		out[i] = base62[rem.Int64()]
+	}
+	return string(out)
+}
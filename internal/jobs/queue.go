@@ -0,0 +1,463 @@
+package jobs
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/jobs")
+
+// ErrJobNotFound is returned by Cancel for jobs that aren't pending, e.g.
+// because they are already running.
+var ErrJobNotFound = apperr.New(apperr.NotFound, "job not found")
+
+// idleWait is how long an idle worker sleeps when no job is delayed;
+// enqueueing a job wakes it earlier.
+const idleWait = time.Minute
+
+// Priority is the priority of a job. Ready jobs run in priority order, and
+// in enqueue order within a priority.
+type Priority int
+
+// Priorities of jobs.
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// priorities lists the priorities, for the queue depth gauge.
+var priorities = []Priority{PriorityLow, PriorityNormal, PriorityHigh}
+
+// ParsePriority parses low, normal or high.
+func ParsePriority(s string) (Priority, error) {
+	for _, p := range priorities {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, apperr.New(apperr.Validation, fmt.Sprintf("invalid priority %q", s))
+}
+
+// String returns low, normal or high.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	}
+	return "normal"
+}
+
+// MarshalText encodes p as its name.
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// States of pending jobs.
+const (
+	StateReady   = "ready"
+	StateDelayed = "delayed"
+)
+
+// Func is the work of a job.
+type Func func(ctx context.Context) error
+
+// Info describes a pending job.
+type Info struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Priority   Priority  `json:"priority"`
+	State      string    `json:"state"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	RunAt      time.Time `json:"run_at"`
+}
+
+// Option configures a job.
+type Option func(*Info)
+
+// WithPriority sets the priority of the job; the default is PriorityNormal.
+func WithPriority(p Priority) Option {
+	return func(i *Info) {
+		i.Priority = p
+	}
+}
+
+// WithDelay delays the job by d.
+func WithDelay(d time.Duration) Option {
+	return func(i *Info) {
+		i.RunAt = i.EnqueuedAt.Add(d)
+	}
+}
+
+// item is a job in one of the queue's heaps.
+type item struct {
+	Info
+	seq   uint64
+	fn    Func
+	link  trace.SpanContext
+	index int
+}
+
+// byPriority orders ready jobs: higher priority first, then FIFO.
+func byPriority(a, b *item) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.seq < b.seq
+}
+
+// byRunAt orders delayed jobs by the time they become ready.
+func byRunAt(a, b *item) bool {
+	if !a.RunAt.Equal(b.RunAt) {
+		return a.RunAt.Before(b.RunAt)
+	}
+	return byPriority(a, b)
+}
+
+// jobHeap is a heap.Interface of items ordered by less.
+type jobHeap struct {
+	items []*item
+	less  func(a, b *item) bool
+}
+
+func (h *jobHeap) Len() int           { return len(h.items) }
+func (h *jobHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *jobHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *jobHeap) Push(x any) {
+	it := x.(*item)
+	it.index = len(h.items)
+	h.items = append(h.items, it)
+}
+
+func (h *jobHeap) Pop() any {
+	n := len(h.items) - 1
+	it := h.items[n]
+	h.items[n] = nil
+	h.items = h.items[:n]
+	it.index = -1
+	return it
+}
+
+// Queue runs background jobs by priority on a fixed number of workers.
+// Delayed jobs wait in a heap ordered by their run time and move to the
+// ready heap once due. Pending jobs are kept in memory only, so they are
+// lost when the process stops.
+type Queue struct {
+	logger  *slog.Logger
+	workers int
+	wake    chan struct{}
+
+	mu      sync.Mutex
+	seq     uint64
+	ready   jobHeap
+	delayed jobHeap
+	byID    map[string]*item
+
+	latency   metric.Float64Histogram
+	processed metric.Int64Counter
+}
+
+// NewQueue creates a new Queue run by workers workers. The pending jobs are
+// exposed as the job_queue_depth gauge.
+func NewQueue(logger *slog.Logger, meter metric.Meter, workers int) (*Queue, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &Queue{
+		logger:  logger,
+		workers: workers,
+		wake:    make(chan struct{}, workers),
+		ready:   jobHeap{less: byPriority},
+		delayed: jobHeap{less: byRunAt},
+		byID:    make(map[string]*item),
+	}
+
+	var err error
+
+	q.latency, err = meter.Float64Histogram(
+		"job_scheduling_latency_seconds",
+		metric.WithDescription("Time from a job becoming ready until a worker starts it"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job scheduling latency histogram: %w", err)
+	}
+
+	q.processed, err = meter.Int64Counter(
+		"jobs_processed_total",
+		metric.WithDescription("Total number of background jobs run or cancelled"),
+		metric.WithUnit("{job}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create processed jobs counter: %w", err)
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"job_queue_depth",
+		metric.WithDescription("Number of pending background jobs"),
+		metric.WithUnit("{job}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			depth := make(map[Priority][2]int64)
+			q.mu.Lock()
+			for _, it := range q.byID {
+				d := depth[it.Priority]
+				if it.State == StateReady {
+					d[0]++
+				} else {
+					d[1]++
+				}
+				depth[it.Priority] = d
+			}
+			q.mu.Unlock()
+			for _, p := range priorities {
+				priority := attribute.String("job.priority", p.String())
+				o.Observe(depth[p][0], metric.WithAttributes(priority, attribute.String("job.state", StateReady)))
+				o.Observe(depth[p][1], metric.WithAttributes(priority, attribute.String("job.state", StateDelayed)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job queue depth gauge: %w", err)
+	}
+
+	return q, nil
+}
+
+// Enqueue adds a job named name running fn. The job runs in a new trace
+// linked to the span in ctx; the rest of ctx isn't passed on.
+func (q *Queue) Enqueue(ctx context.Context, name string, fn Func, opts ...Option) Info {
+	now := time.Now()
+	it := &item{
+		Info: Info{
+			ID:         uuid.New().String(),
+			Name:       name,
+			Priority:   PriorityNormal,
+			EnqueuedAt: now,
+			RunAt:      now,
+		},
+		fn:   fn,
+		link: trace.SpanContextFromContext(ctx),
+	}
+	for _, opt := range opts {
+		opt(&it.Info)
+	}
+
+	q.mu.Lock()
+	q.seq++
+	it.seq = q.seq
+	q.byID[it.ID] = it
+	if it.RunAt.After(now) {
+		it.State = StateDelayed
+		heap.Push(&q.delayed, it)
+	} else {
+		it.State = StateReady
+		heap.Push(&q.ready, it)
+	}
+	info := it.Info
+	q.mu.Unlock()
+
+	// Wake an idle worker to run the job, or to wait for it if delayed
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return info
+}
+
+// Cancel removes a pending job. It returns ErrJobNotFound if the job isn't
+// pending.
+func (q *Queue) Cancel(ctx context.Context, id string) (Info, error) {
+	q.mu.Lock()
+	it, ok := q.byID[id]
+	if ok {
+		delete(q.byID, id)
+		if it.State == StateReady {
+			heap.Remove(&q.ready, it.index)
+		} else {
+			heap.Remove(&q.delayed, it.index)
+		}
+	}
+	q.mu.Unlock()
+
+	if !ok {
+		return Info{}, ErrJobNotFound
+	}
+	q.processed.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("job.name", it.Name),
+		attribute.String("job.priority", it.Priority.String()),
+		attribute.String("outcome", "cancelled"),
+	))
+	return it.Info, nil
+}
+
+// Pending returns the pending jobs: ready jobs in the order they will run,
+// then delayed jobs by run time.
+func (q *Queue) Pending() []Info {
+	q.mu.Lock()
+	ready := slices.Clone(q.ready.items)
+	delayed := slices.Clone(q.delayed.items)
+	q.mu.Unlock()
+
+	slices.SortFunc(ready, compare(byPriority))
+	slices.SortFunc(delayed, compare(byRunAt))
+
+	infos := make([]Info, 0, len(ready)+len(delayed))
+	for _, it := range append(ready, delayed...) {
+		infos = append(infos, it.Info)
+	}
+	return infos
+}
+
+// compare turns a less function into a comparison for slices.SortFunc.
+func compare(less func(a, b *item) bool) func(a, b *item) int {
+	return func(a, b *item) int {
+		switch {
+		case less(a, b):
+			return -1
+		case less(b, a):
+			return 1
+		}
+		return 0
+	}
+}
+
+// Run runs jobs on the workers until ctx is cancelled, which also cancels
+// the running jobs. Jobs still pending then are dropped.
+func (q *Queue) Run(ctx context.Context) {
+	q.logger.InfoContext(ctx, "job queue started", slog.Int("workers", q.workers))
+
+	var wg sync.WaitGroup
+	for range q.workers {
+		wg.Add(1)
+		go func() { // async:ok: jobs start their own traces, a worker span would stay open until shutdown
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	q.mu.Lock()
+	dropped := len(q.byID)
+	q.mu.Unlock()
+	q.logger.Info("job queue stopped", slog.Int("dropped_jobs", dropped))
+}
+
+// work runs jobs until ctx is cancelled.
+func (q *Queue) work(ctx context.Context) {
+	for {
+		it, wait := q.next(time.Now())
+		if it != nil {
+			q.run(ctx, it)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// next moves the delayed jobs due at now to the ready heap and takes the
+// first ready job. If there is none, it returns how long to wait for the
+// next delayed job.
+func (q *Queue) next(now time.Time) (*item, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.delayed.Len() > 0 && !q.delayed.items[0].RunAt.After(now) {
+		it := heap.Pop(&q.delayed).(*item)
+		it.State = StateReady
+		heap.Push(&q.ready, it)
+	}
+	if q.ready.Len() > 0 {
+		it := heap.Pop(&q.ready).(*item)
+		delete(q.byID, it.ID)
+		return it, 0
+	}
+	if q.delayed.Len() > 0 {
+		return nil, q.delayed.items[0].RunAt.Sub(now)
+	}
+	return nil, idleWait
+}
+
+// run runs a job in a Queue.Job span in a new trace linked to the span that
+// enqueued it, with the queue's logger in its context. A panic is recovered
+// and recorded as a failure of the job.
+func (q *Queue) run(ctx context.Context, it *item) {
+	ctx = logging.NewContext(ctx, q.logger)
+	start := time.Now()
+	attrs := []attribute.KeyValue{
+		attribute.String("job.name", it.Name),
+		attribute.String("job.priority", it.Priority.String()),
+	}
+	q.latency.Record(ctx, start.Sub(it.RunAt).Seconds(), metric.WithAttributes(attrs...))
+
+	ctx, span := tracer.Start(ctx, "Queue.Job",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.Link{SpanContext: it.link}),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			attribute.String("job.id", it.ID),
+			attribute.Float64("job.scheduling_latency_ms", float64(start.Sub(it.RunAt))/float64(time.Millisecond)),
+		),
+	)
+	defer span.End()
+
+	outcome := "success"
+	defer func() {
+		q.processed.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("outcome", outcome))...))
+	}()
+
+	err := func() (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("panic: %v", rec)
+			}
+		}()
+		return it.fn(ctx)
+	}()
+	if err != nil {
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "job failed")
+		q.logger.ErrorContext(ctx, "job failed",
+			slog.String("job_id", it.ID),
+			slog.String("job_name", it.Name),
+			slog.Any("error", err),
+		)
+		return
+	}
+	q.logger.InfoContext(ctx, "job finished",
+		slog.String("job_id", it.ID),
+		slog.String("job_name", it.Name),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
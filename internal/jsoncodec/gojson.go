@@ -0,0 +1,27 @@
+//go:build gojson
+
+package jsoncodec
+
+import (
+	"io"
+
+	"github.com/goccy/go-json"
+)
+
+// Codec is the name of the compiled-in JSON implementation, selected with
+// the gojson build tag.
+const Codec = "go-json"
+
+// Marshal returns the JSON encoding of v.
+func Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal parses the JSON-encoded data and stores the result in v.
+func Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func newDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
@@ -0,0 +1,93 @@
+package jsoncodec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Operations recorded by Record.
+const (
+	OperationEncode = "encode"
+	OperationDecode = "decode"
+)
+
+// Decoder decodes JSON values from a stream, like encoding/json.Decoder.
+type Decoder interface {
+	Decode(v any) error
+	DisallowUnknownFields()
+}
+
+// Metrics records the duration and payload size of JSON encoding and
+// decoding by route, with the compiled-in implementation as json.codec so
+// implementations can be compared across deployments.
+type Metrics struct {
+	duration metric.Float64Histogram
+	size     metric.Int64Histogram
+}
+
+// NewMetrics creates a new Metrics.
+func NewMetrics(meter metric.Meter) (*Metrics, error) {
+	duration, err := meter.Float64Histogram(
+		"json_codec_duration_seconds",
+		metric.WithDescription("Duration of encoding HTTP responses and decoding HTTP request bodies as JSON"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.000005, 0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.005, 0.01, 0.05),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create json codec duration histogram: %w", err)
+	}
+
+	size, err := meter.Int64Histogram(
+		"json_payload_size_bytes",
+		metric.WithDescription("Size of JSON HTTP responses and request bodies"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(64, 256, 1<<10, 4<<10, 16<<10, 64<<10, 256<<10, 1<<20),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create json payload size histogram: %w", err)
+	}
+
+	return &Metrics{duration: duration, size: size}, nil
+}
+
+type recorderKey struct{}
+
+// recorder is the Metrics of a request and the function returning its route.
+type recorder struct {
+	metrics *Metrics
+	route   func() string
+}
+
+// NewContext returns a context in which Record records to m. route returns
+// the route of the request; it is called on every Record, since the route
+// is only known once the request has been routed.
+func NewContext(ctx context.Context, m *Metrics, route func() string) context.Context {
+	return context.WithValue(ctx, recorderKey{}, recorder{metrics: m, route: route})
+}
+
+// Record records an encode or decode operation of size bytes that started
+// at start, if ctx carries Metrics from NewContext.
+func Record(ctx context.Context, operation string, start time.Time, size int) {
+	rec, ok := ctx.Value(recorderKey{}).(recorder)
+	if !ok {
+		return
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("json.operation", operation),
+		attribute.String("json.codec", Codec),
+		attribute.String("http.route", rec.route()),
+	)
+	rec.metrics.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	rec.metrics.size.Record(ctx, int64(size), attrs)
+}
+
+// NewDecoder returns a Decoder reading from r with the compiled-in
+// implementation.
+func NewDecoder(r io.Reader) Decoder {
+	return newDecoder(r)
+}
@@ -0,0 +1,26 @@
+//go:build !gojson
+
+package jsoncodec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Codec is the name of the compiled-in JSON implementation. Build with
+// -tags gojson to use github.com/goccy/go-json instead.
+const Codec = "encoding/json"
+
+// Marshal returns the JSON encoding of v.
+func Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal parses the JSON-encoded data and stores the result in v.
+func Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func newDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
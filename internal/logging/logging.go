@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+type loggerKey struct{}
+
+// NewContext returns a context carrying logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx by NewContext, or the default
+// logger if there is none. Request handlers get a logger enriched with the
+// request attributes from the ContextLogger middleware.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Levels beyond the four slog levels, after their syslog counterparts.
+// NOTICE is for normal but significant events, such as configuration
+// changes, that should stay visible when INFO is filtered out.
+const (
+	LevelTrace    = slog.Level(-8)
+	LevelNotice   = slog.Level(2)
+	LevelCritical = slog.Level(12)
+)
+
+// levelNames holds the names of the custom levels.
+var levelNames = map[slog.Level]string{
+	LevelTrace:    "TRACE",
+	LevelNotice:   "NOTICE",
+	LevelCritical: "CRITICAL",
+}
+
+// LevelName returns the name of level: the custom level names, or the slog
+// name such as INFO or WARN+1 for other levels.
+func LevelName(level slog.Level) string {
+	if name, ok := levelNames[level]; ok {
+		return name
+	}
+	return level.String()
+}
+
+// ParseLevel parses a level name case-insensitively, including the custom
+// level names.
+func ParseLevel(s string) (slog.Level, error) {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unsupported log level %q", s)
+	}
+	return level, nil
+}
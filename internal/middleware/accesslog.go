@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/log"
+)
+
+// accessLogScope is the instrumentation scope of access log records, which
+// tells them apart from application logs in the backend.
+const accessLogScope = "github.com/hiroki-koketsu/go-otel-sample/internal/middleware/accesslog"
+
+// AccessLog emits one OpenTelemetry log record per request, except for the
+// given skipped paths, with the HTTP semantic-convention attributes of the
+// request and response. The records are emitted directly to provider under
+// their own instrumentation scope rather than through slog, so they are not
+// filtered by the application log level. 5xx responses are logged as
+// errors, others as info.
+func AccessLog(provider log.LoggerProvider, skipPaths ...string) func(http.Handler) http.Handler {
+	logger := provider.Logger(accessLogScope)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range skipPaths {
+				if r.URL.Path == p {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			start := time.Now()
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				route := routePattern(r)
+				elapsed := time.Since(start)
+
+				var rec log.Record
+				rec.SetTimestamp(start)
+				rec.SetObservedTimestamp(time.Now())
+				rec.SetSeverity(log.SeverityInfo)
+				rec.SetSeverityText("INFO")
+				if status >= http.StatusInternalServerError {
+					rec.SetSeverity(log.SeverityError)
+					rec.SetSeverityText("ERROR")
+				}
+				rec.SetBody(log.StringValue(fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status)))
+				rec.AddAttributes(
+					log.String("http.request.method", r.Method),
+					log.String("http.route", route),
+					log.Int("http.response.status_code", status),
+					log.String("url.path", r.URL.Path),
+					log.String("url.scheme", scheme(r)),
+					log.String("server.address", r.Host),
+					log.String("network.protocol.version", strconv.Itoa(r.ProtoMajor)+"."+strconv.Itoa(r.ProtoMinor)),
+					log.String("client.address", clientAddress(r)),
+					log.String("user_agent.original", r.UserAgent()),
+					log.Int64("http.response.body.size", int64(ww.BytesWritten())),
+					log.Float64("http.server.request.duration", elapsed.Seconds()),
+					log.String("request_id", chimiddleware.GetReqID(r.Context())),
+				)
+				if r.ContentLength >= 0 {
+					rec.AddAttributes(log.Int64("http.request.body.size", r.ContentLength))
+				}
+				logger.Emit(r.Context(), rec)
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// clientAddress returns the client IP from RemoteAddr, which RealIP may
+// have replaced with an address without port.
+func clientAddress(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+)
+
+// AdminAuth only lets requests through that carry token as a bearer token.
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				response.Error(w, r, http.StatusUnauthorized, "admin token required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Baggage copies the allowed members of the incoming W3C baggage, such as
+// the session ID of a browser frontend, to the server span. otelhttp has
+// already extracted the baggage into the request context.
+func Baggage(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if attrs := telemetry.BaggageAttributes(ctx, keys); len(attrs) > 0 {
+				trace.SpanFromContext(ctx).SetAttributes(attrs...)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
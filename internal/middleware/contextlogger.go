@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContextLogger stores a request-scoped logger derived from logger in the
+// request context, for use with logging.FromContext. Its records carry the
+// trace ID, request ID and user of the request, and the matched route once
+// chi has routed it.
+func ContextLogger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			attrs := []any{slog.String("request_id", chimiddleware.GetReqID(ctx))}
+			if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+				attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+			}
+			if user := r.Header.Get("X-User-ID"); user != "" {
+				attrs = append(attrs, slog.String("enduser.id", user))
+			}
+
+			// The route is only known after routing, so it is added when a
+			// record is handled rather than with the other attributes.
+			requestLogger := slog.New(&routeHandler{next: logger.Handler(), r: r}).With(attrs...)
+			next.ServeHTTP(w, r.WithContext(logging.NewContext(ctx, requestLogger)))
+		})
+	}
+}
+
+// routeHandler adds the http.route of r to every record it handles.
+type routeHandler struct {
+	next slog.Handler
+	r    *http.Request
+}
+
+func (h *routeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *routeHandler) Handle(ctx context.Context, record slog.Record) error {
+	if route := routePattern(h.r); route != "" {
+		record = record.Clone()
+		record.AddAttrs(slog.String("http.route", route))
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *routeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &routeHandler{next: h.next.WithAttrs(attrs), r: h.r}
+}
+
+func (h *routeHandler) WithGroup(name string) slog.Handler {
+	return &routeHandler{next: h.next.WithGroup(name), r: h.r}
+}
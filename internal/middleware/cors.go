@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Defaults used for unset CORS settings. The allowed headers include the
+// identity headers of the API and the W3C trace context headers, so browser
+// frontends can propagate their traces.
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "X-User-ID", "X-Tenant-ID", "traceparent", "tracestate", "baggage"}
+)
+
+// defaultCORSMaxAge is how long browsers may cache a preflight response.
+const defaultCORSMaxAge = 10 * time.Minute
+
+// CORSConfig configures cross-origin requests. An origin of "*" allows any
+// origin. Empty methods, headers and max age use the defaults.
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           Duration `json:"max_age"`
+}
+
+// Duration is a time.Duration that unmarshals from a JSON string such as "10m".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadCORSConfig reads a CORS configuration from a JSON file and applies the
+// non-empty settings of env on top of it.
+func LoadCORSConfig(file string, env CORSConfig) (CORSConfig, error) {
+	var c CORSConfig
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return c, fmt.Errorf("failed to read cors config file: %w", err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("failed to parse cors config file: %w", err)
+	}
+
+	if len(env.AllowedOrigins) > 0 {
+		c.AllowedOrigins = env.AllowedOrigins
+	}
+	if len(env.AllowedMethods) > 0 {
+		c.AllowedMethods = env.AllowedMethods
+	}
+	if len(env.AllowedHeaders) > 0 {
+		c.AllowedHeaders = env.AllowedHeaders
+	}
+	if len(env.ExposedHeaders) > 0 {
+		c.ExposedHeaders = env.ExposedHeaders
+	}
+	if env.AllowCredentials {
+		c.AllowCredentials = true
+	}
+	if env.MaxAge > 0 {
+		c.MaxAge = env.MaxAge
+	}
+	return c, nil
+}
+
+// CORS answers preflight requests and adds CORS headers to responses for
+// allowed origins. Requests from other origins are passed on without CORS
+// headers (so browsers block them) and counted; their preflights get a 403.
+// Allowing credentials from any origin is rejected, since it would let any
+// site make credentialed requests.
+func CORS(c CORSConfig, meter metric.Meter) (func(http.Handler) http.Handler, error) {
+	anyOrigin := slices.Contains(c.AllowedOrigins, "*")
+	if anyOrigin && c.AllowCredentials {
+		return nil, errors.New("cors credentials can't be allowed for any origin")
+	}
+
+	denied, err := meter.Int64Counter(
+		"cors_denied_requests_total",
+		metric.WithDescription("Total number of cross-origin requests from origins that are not allowed"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cors denied counter: %w", err)
+	}
+
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = defaultCORSMethods
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = defaultCORSHeaders
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = Duration(defaultCORSMaxAge)
+	}
+
+	methods := strings.Join(c.AllowedMethods, ", ")
+	headers := strings.Join(c.AllowedHeaders, ", ")
+	exposed := strings.Join(c.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(time.Duration(c.MaxAge).Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			span := trace.SpanFromContext(ctx)
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			if !anyOrigin && !slices.Contains(c.AllowedOrigins, origin) {
+				span.AddEvent("cors.denied", trace.WithAttributes(telemetry.UserString("cors.origin", origin)))
+				denied.Add(ctx, 1, metric.WithAttributes(attribute.Bool("cors.preflight", preflight)))
+				if preflight {
+					response.Error(w, r, http.StatusForbidden, "origin not allowed")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposed != "" {
+					h.Set("Access-Control-Expose-Headers", exposed)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			h.Set("Access-Control-Max-Age", maxAge)
+			span.SetAttributes(attribute.Bool("cors.preflight", true))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}, nil
+}
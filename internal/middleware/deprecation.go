@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Deprecation marks the responses of a deprecated API version with the
+// Deprecation header (RFC 9745) dated deprecatedAt, the Sunset header
+// (RFC 8594) if sunset is set, and a Link to the successor version. Requests
+// get api.deprecated=true on their server span.
+func Deprecation(deprecatedAt, sunset time.Time, successor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", fmt.Sprintf("@%d", deprecatedAt.Unix()))
+			if !sunset.IsZero() {
+				h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			if successor != "" {
+				h.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			}
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.Bool("api.deprecated", true))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
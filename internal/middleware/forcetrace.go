@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ForceTraceHeader requests that a request is traced regardless of the
+// sampling ratio.
+const ForceTraceHeader = "X-Force-Trace"
+
+// ForceTraceConfig restricts who may force a request to be traced: callers
+// sending Token as the value of X-Force-Trace, or calling from an address in
+// AllowedNetworks (with any value). Forcing is disabled if neither is set.
+type ForceTraceConfig struct {
+	Token           string
+	AllowedNetworks []netip.Prefix
+}
+
+// ParseNetworks parses CIDR prefixes, e.g. 10.0.0.0/8; single addresses
+// are taken as a prefix of their full length.
+func ParseNetworks(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(cidr)
+			if aerr != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", cidr, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ForceTrace samples requests carrying an authorized X-Force-Trace header,
+// whatever the configured sampler or the sampling decision of the caller;
+// their server span records sampling.forced. Unauthorized headers are
+// ignored, so the request is sampled as usual. Both outcomes are counted in
+// forced_traces_total. It wraps otelhttp.NewHandler, since the sampling
+// decision is made when the server span is started; the client address is
+// therefore the address of the connection, not one forwarded by a proxy.
+func ForceTrace(c ForceTraceConfig, meter metric.Meter) (func(http.Handler) http.Handler, error) {
+	requests, err := meter.Int64Counter(
+		"forced_traces_total",
+		metric.WithDescription("Total number of requests asking to be traced by X-Force-Trace"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create forced trace counter: %w", err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v := r.Header.Get(ForceTraceHeader)
+			if v == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			result := "denied"
+			if c.allowed(v, r.RemoteAddr) {
+				result = "forced"
+				r = r.WithContext(telemetry.WithForcedSampling(ctx))
+			}
+			requests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// allowed reports whether a request from remoteAddr sending value in
+// X-Force-Trace may force tracing.
+func (c ForceTraceConfig) allowed(value, remoteAddr string) bool {
+	if c.Token != "" && subtle.ConstantTimeCompare([]byte(value), []byte(c.Token)) == 1 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range c.AllowedNetworks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jsoncodec"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// JSONCodec makes response.JSON and the request decoder record how long
+// encoding and decoding take and the payload sizes, by route.
+func JSONCodec(meter metric.Meter) (func(http.Handler) http.Handler, error) {
+	metrics, err := jsoncodec.NewMetrics(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The route is matched after this middleware runs, so it is
+			// looked up when recording
+			ctx := jsoncodec.NewContext(r.Context(), metrics, func() string {
+				return routePattern(r)
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}, nil
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/i18n"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Locale negotiates the locale of the response from the Accept-Language
+// header among the locales of bundle, and makes its Localizer available to
+// handlers via i18n.FromContext, so error responses are translated. The
+// locale is recorded as i18n.locale on the server span and returned in the
+// Content-Language header.
+func Locale(bundle *i18n.Bundle) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			locale := bundle.Match(r.Header.Get("Accept-Language"))
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("i18n.locale", locale))
+
+			h := w.Header()
+			h.Set("Content-Language", locale)
+			h.Add("Vary", "Accept-Language")
+
+			ctx := i18n.WithLocalizer(r.Context(), bundle.Localizer(locale))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Logger is a drop-in replacement for chi's Logger that writes access logs
+// through logger with the request context, so they carry the trace and span
+// ID of the request instead of going to stdout uncorrelated.
+func Logger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return chimiddleware.RequestLogger(&logFormatter{logger: logger})
+}
+
+type logFormatter struct {
+	logger *slog.Logger
+}
+
+func (f *logFormatter) NewLogEntry(r *http.Request) chimiddleware.LogEntry {
+	return &logEntry{logger: f.logger, r: r}
+}
+
+type logEntry struct {
+	logger *slog.Logger
+	r      *http.Request
+}
+
+func (e *logEntry) Write(status, bytes int, _ http.Header, elapsed time.Duration, _ any) {
+	e.logger.InfoContext(e.r.Context(), "http request",
+		slog.String("http.request.method", e.r.Method),
+		slog.String("url.path", e.r.URL.Path),
+		slog.String("network.protocol.version", e.r.Proto),
+		slog.String("client.address", e.r.RemoteAddr),
+		slog.String("request_id", chimiddleware.GetReqID(e.r.Context())),
+		slog.Int("http.response.status_code", status),
+		slog.Int("http.response.body.size", bytes),
+		slog.Duration("duration", elapsed),
+	)
+}
+
+// Panic is a no-op; panics are logged by Recoverer.
+func (e *logEntry) Panic(any, []byte) {}
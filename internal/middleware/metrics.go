@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Metrics records HTTP server metrics for every request except the given
+// skipped paths (e.g. health checks). The route attribute is taken from the
+// matched chi route pattern so that path parameters don't explode cardinality.
+// API requests are also recorded with their api.version (v1, v2), so the
+// migration between versions can be charted.
+func Metrics(metrics *telemetry.Metrics, skipPaths ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range skipPaths {
+				if r.URL.Path == p {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			ctx := r.Context()
+			start := time.Now()
+			metrics.RequestStarted(ctx, r.Method)
+
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				route := routePattern(r)
+				var extra []attribute.KeyValue
+				if version := apiVersion(route); version != "" {
+					extra = append(extra, attribute.String("api.version", version))
+				}
+				metrics.RequestFinished(ctx, r.Method, route, status, time.Since(start), int64(ww.BytesWritten()), extra...)
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// routePattern returns the matched chi route pattern without the trailing
+// slash that mounted sub-routers add, or "" if no route matched.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	pattern := rctx.RoutePattern()
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return pattern
+}
+
+// apiVersion returns the version segment of an /api/<version>/... route
+// pattern, or "" for other routes.
+func apiVersion(route string) string {
+	rest, ok := strings.CutPrefix(route, "/api/")
+	if !ok {
+		return ""
+	}
+	version, _, _ := strings.Cut(rest, "/")
+	return version
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/overload"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Shed sheds load by the priority classify assigns to every request except
+// the given skipped paths: requests of a priority shedder sheds at the
+// current load level are rejected with 503 Service Unavailable and a
+// Retry-After header. The priority and load level are recorded on the server
+// span as request.priority and overload.level, with overload.shed set on
+// rejected requests. Shed requests aren't logged one by one, as they come in
+// bursts; they are counted in requests_shed_total instead.
+func Shed(shedder *overload.Shedder, classify func(*http.Request) overload.Priority, skipPaths ...string) func(http.Handler) http.Handler {
+	retryAfter := strconv.Itoa(int(math.Ceil(shedder.RetryAfter().Seconds())))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range skipPaths {
+				if r.URL.Path == p {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			ctx := r.Context()
+			priority := classify(r)
+			level, ok := shedder.Admit(ctx, priority)
+
+			span := trace.SpanFromContext(ctx)
+			span.SetAttributes(
+				attribute.String("request.priority", priority.String()),
+				attribute.String("overload.level", level.String()),
+			)
+			if !ok {
+				span.SetAttributes(attribute.Bool("overload.shed", true))
+				w.Header().Set("Retry-After", retryAfter)
+				response.Error(w, r, http.StatusServiceUnavailable, "server overloaded")
+				return
+			}
+
+			start := time.Now()
+			defer func() {
+				shedder.Done(time.Since(start))
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
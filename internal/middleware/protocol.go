@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Protocol records the negotiated HTTP protocol (HTTP/1.1, HTTP/2.0 or
+// HTTP/3.0) on the active span and counts requests per protocol.
+func Protocol(meter metric.Meter) (func(http.Handler) http.Handler, error) {
+	counter, err := meter.Int64Counter(
+		"http_requests_by_protocol_total",
+		metric.WithDescription("Total number of HTTP requests by negotiated protocol"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create protocol request counter: %w", err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			version := fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor)
+
+			trace.SpanFromContext(ctx).SetAttributes(
+				attribute.String("network.protocol.name", "http"),
+				attribute.String("network.protocol.version", version),
+				attribute.Bool("http.tls", r.TLS != nil),
+			)
+			counter.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("network.protocol.version", version),
+			))
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
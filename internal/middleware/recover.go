@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Recoverer recovers from panics in downstream handlers. Unlike chi's
+// Recoverer, it records the panic and stack trace on the active span, marks
+// the span as failed, logs through the given (OTel-bridged) logger, counts
+// the panic in panics_total and responds with a problem+json 500.
+func Recoverer(logger *slog.Logger, meter metric.Meter) (func(http.Handler) http.Handler, error) {
+	panics, err := meter.Int64Counter(
+		"panics_total",
+		metric.WithDescription("Total number of recovered panics in HTTP handlers"),
+		metric.WithUnit("{panic}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create panic counter: %w", err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					// Let net/http abort the response as intended.
+					panic(rec)
+				}
+
+				ctx := r.Context()
+				stack := string(debug.Stack())
+				err := fmt.Errorf("panic: %v", rec)
+
+				span := trace.SpanFromContext(ctx)
+				span.RecordError(err, trace.WithAttributes(
+					attribute.String("exception.stacktrace", stack),
+				))
+				span.SetStatus(codes.Error, err.Error())
+
+				logger.ErrorContext(ctx, "panic recovered",
+					slog.Any("error", err),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("stacktrace", stack),
+				)
+
+				panics.Add(ctx, 1, metric.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", routePattern(r)),
+				))
+
+				response.Error(w, r, http.StatusInternalServerError, "internal server error")
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
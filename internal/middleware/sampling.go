@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+)
+
+// AdaptiveSampling reports the duration and outcome of every request except
+// the given skipped paths to sampler, counting 5xx responses as failed.
+func AdaptiveSampling(sampler *telemetry.AdaptiveSampler, skipPaths ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range skipPaths {
+				if r.URL.Path == p {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			start := time.Now()
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				sampler.Observe(time.Since(start), ww.Status() >= http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
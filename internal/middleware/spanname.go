@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanName renames the server span created by otelhttp to
+// "{method} {route}" once chi has matched the route, and sets http.route.
+// Unmatched requests are named after the method only, so raw paths never
+// end up in span names.
+func SpanName(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		span := trace.SpanFromContext(r.Context())
+		if route := routePattern(r); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+			return
+		}
+		span.SetName(r.Method)
+	})
+}
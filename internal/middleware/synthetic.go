@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// SyntheticBaggage drops the synthetic baggage member (see
+// telemetry.SyntheticKey) of requests from callers that may not force
+// tracing (see ForceTraceConfig), so only trusted callers can mark their
+// traffic as synthetic and keep it out of dashboards. Requests carrying the
+// member are counted in synthetic_requests_total by result (accepted,
+// dropped). Like ForceTrace, it wraps otelhttp.NewHandler, which extracts
+// the baggage when the server span is started.
+func SyntheticBaggage(c ForceTraceConfig, meter metric.Meter) (func(http.Handler) http.Handler, error) {
+	requests, err := meter.Int64Counter(
+		"synthetic_requests_total",
+		metric.WithDescription("Total number of requests marked as synthetic by their baggage"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create synthetic request counter: %w", err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			values := r.Header.Values("baggage")
+			marked := false
+			for _, v := range values {
+				if bag, err := baggage.Parse(v); err == nil && bag.Member(telemetry.SyntheticKey).Key() != "" {
+					marked = true
+				}
+			}
+			if !marked {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result := "accepted"
+			if !c.allowed(r.Header.Get(ForceTraceHeader), r.RemoteAddr) {
+				result = "dropped"
+				r = r.Clone(r.Context())
+				r.Header.Del("baggage")
+				for _, v := range values {
+					bag, err := baggage.Parse(v)
+					if err != nil {
+						continue
+					}
+					if bag = bag.DeleteMember(telemetry.SyntheticKey); bag.Len() > 0 {
+						r.Header.Add("baggage", bag.String())
+					}
+				}
+			}
+			requests.Add(r.Context(), 1, metric.WithAttributes(attribute.String("result", result)))
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/tenant"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tenant puts the tenant named by the X-Tenant-ID header into the request
+// context. It wraps otelhttp.NewHandler, so the tenant sampler sees the
+// tenant when the server span is started.
+func Tenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := r.Header.Get(tenant.Header); name != "" {
+			r = r.WithContext(tenant.WithTenant(r.Context(), name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TenantPolicy records the policy applied to the tenant of every request
+// except the given skipped paths on the server span, as tenant.id,
+// tenant.policy (override or default) and tenant.rate_limit, and enforces
+// its rate limit: requests over the limit are rejected with 429 Too Many
+// Requests and a Retry-After header, and counted in
+// tenant_rate_limited_total. Requests limited per process because the shared
+// limiter failed are counted in tenant_rate_limiter_fallbacks_total and
+// record tenant.rate_limiter_fallback.
+func TenantPolicy(policies *tenant.Policies, meter metric.Meter, skipPaths ...string) (func(http.Handler) http.Handler, error) {
+	limited, err := meter.Int64Counter(
+		"tenant_rate_limited_total",
+		metric.WithDescription("Total number of requests rejected by the rate limit of their tenant"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant rate limit counter: %w", err)
+	}
+	fallbacks, err := meter.Int64Counter(
+		"tenant_rate_limiter_fallbacks_total",
+		metric.WithDescription("Total number of requests rate limited per process because the shared rate limiter failed"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant rate limiter fallback counter: %w", err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range skipPaths {
+				if r.URL.Path == p {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			ctx := r.Context()
+			name, _ := tenant.FromContext(ctx)
+			d := policies.Lookup(name)
+
+			span := trace.SpanFromContext(ctx)
+			span.SetAttributes(
+				telemetry.UserString("tenant.id", name),
+				attribute.String("tenant.policy", d.PolicyName()),
+				attribute.Float64("tenant.rate_limit", d.Policy.RateLimit),
+			)
+
+			ok, retryAfter, err := policies.Allow(ctx, d)
+			if err != nil {
+				fallbacks.Add(ctx, 1)
+				span.SetAttributes(attribute.Bool("tenant.rate_limiter_fallback", true))
+				// Only log the failure, not every request while the shared
+				// limiter isn't asked
+				if !errors.Is(err, tenant.ErrLimiterUnavailable) {
+					logging.FromContext(ctx).WarnContext(ctx, "shared rate limiter failed, limiting per process",
+						slog.Any("error", err),
+					)
+				}
+			}
+			if !ok {
+				// Only tenants with an override are labelled, as any name
+				// can be sent
+				label := "other"
+				if d.Override {
+					label = name
+				}
+				limited.Add(ctx, 1, metric.WithAttributes(
+					attribute.String("tenant", label),
+					attribute.String("tenant.policy", d.PolicyName()),
+				))
+				span.SetAttributes(attribute.Bool("tenant.rate_limited", true))
+				logging.FromContext(ctx).WarnContext(ctx, "tenant rate limit exceeded",
+					slog.String("tenant", name),
+					slog.Float64("rate_limit", d.Policy.RateLimit),
+				)
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				response.Error(w, r, http.StatusTooManyRequests, "tenant rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/deadline"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TimeoutBudget derives the deadline of a request from the timeout budget
+// its caller sent in the X-Request-Timeout or grpc-timeout header, capped
+// at limit, so outbound calls made through deadline.Transport forward what is
+// left of it. The budget is recorded on the server span as
+// timeout.budget_ms, and the time the request consumed of it as
+// timeout.consumed_ms, with timeout.exceeded set if it ran out. Requests
+// with a malformed budget are rejected with 400 Bad Request; requests
+// without one keep the server's own timeout.
+func TimeoutBudget(limit time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			budget, ok, err := deadline.Parse(r.Header)
+			if err != nil {
+				logging.FromContext(r.Context()).WarnContext(r.Context(), "invalid timeout budget", slog.Any("error", err))
+				response.Error(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			span := trace.SpanFromContext(r.Context())
+			span.SetAttributes(attribute.Int64("timeout.budget_ms", budget.Milliseconds()))
+			if budget > limit {
+				budget = limit
+			}
+
+			ctx, cancel := deadline.WithBudget(r.Context(), budget)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			consumed, _ := deadline.Consumed(ctx)
+			span.SetAttributes(
+				attribute.Int64("timeout.consumed_ms", consumed.Milliseconds()),
+				attribute.Bool("timeout.exceeded", errors.Is(ctx.Err(), context.DeadlineExceeded)),
+			)
+		})
+	}
+}
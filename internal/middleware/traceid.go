@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDHeader is the response header carrying the trace ID of the request.
+const TraceIDHeader = "X-Trace-Id"
+
+// TraceID sets the X-Trace-Id response header to the trace ID of the server
+// span, so clients can look up the trace of a request.
+func TraceID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			w.Header().Set(TraceIDHeader, sc.TraceID().String())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
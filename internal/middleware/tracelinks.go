@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+)
+
+// TraceLinks adds a trace_url member to error responses linking to their
+// trace in a trace UI, with {trace_id} in template replaced by the trace ID,
+// so anyone hitting an error during a demo can click through to its trace.
+func TraceLinks(template string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(response.WithTraceURLTemplate(r.Context(), template)))
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// TaskChange is an entry of the task change feed. Cursors increase by one
+// with every recorded revision.
+type TaskChange struct {
+	Cursor    int64     `json:"cursor"`
+	TaskID    string    `json:"task_id"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+)
+
+// Lease TTLs of a claim.
+const (
+	DefaultLeaseTTL = 5 * time.Minute
+	MinLeaseTTL     = time.Second
+	MaxLeaseTTL     = time.Hour
+)
+
+// Lease errors.
+var (
+	ErrInvalidLeaseTTL = apperr.New(apperr.Validation, "ttl must be a duration between 1s and 1h")
+	ErrTaskNotOpen     = apperr.New(apperr.Conflict, "only open tasks can be claimed")
+	ErrTaskLeased      = apperr.New(apperr.Conflict, "task is claimed by another worker")
+	ErrLeaseNotHeld    = apperr.New(apperr.Conflict, "task is not claimed by the caller")
+	ErrLeaseExpired    = apperr.New(apperr.Conflict, "lease expired")
+)
+
+// Lease is a time-limited claim of a task by a worker. While it is active,
+// only the holder can claim, change or delete the task; once it expires,
+// any worker can claim the task.
+type Lease struct {
+	Holder    string    `json:"holder"`
+	ClaimedAt time.Time `json:"claimed_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Active reports whether l is set and not expired at now.
+func (l *Lease) Active(now time.Time) bool {
+	return l != nil && now.Before(l.ExpiresAt)
+}
+
+// ClaimTaskRequest represents the request body for claiming a task. TTL is
+// a duration such as "30s"; empty means DefaultLeaseTTL. Claiming a task
+// again before the lease expires renews it.
+type ClaimTaskRequest struct {
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Validate checks if the ClaimTaskRequest is valid.
+func (r *ClaimTaskRequest) Validate() error {
+	if r.TTL == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(r.TTL)
+	if err != nil || ttl < MinLeaseTTL || ttl > MaxLeaseTTL {
+		return ErrInvalidLeaseTTL
+	}
+	return nil
+}
+
+// Duration returns the requested lease TTL.
+func (r *ClaimTaskRequest) Duration() time.Duration {
+	if r.TTL == "" {
+		return DefaultLeaseTTL
+	}
+	ttl, _ := time.ParseDuration(r.TTL)
+	return ttl
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Revision actions.
+const (
+	RevisionCreated    = "created"
+	RevisionUpdated    = "updated"
+	RevisionArchived   = "archived"
+	RevisionUnarchived = "unarchived"
+	RevisionDeleted    = "deleted"
+	RevisionAssigned   = "assigned"
+	RevisionClaimed    = "claimed"
+	RevisionReleased   = "released"
+	RevisionAttached   = "attached"
+)
+
+// Change describes a single field change between two task states.
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Revision is a recorded change to a task.
+type Revision struct {
+	Version   int               `json:"version"`
+	Action    string            `json:"action"`
+	Actor     string            `json:"actor"`
+	Timestamp time.Time         `json:"timestamp"`
+	Changes   map[string]Change `json:"changes,omitempty"`
+}
+
+// Diff returns the fields that differ between two task states.
+func Diff(before, after *Task) map[string]Change {
+	changes := make(map[string]Change)
+	if before.Title != after.Title {
+		changes["title"] = Change{From: before.Title, To: after.Title}
+	}
+	if before.Description != after.Description {
+		changes["description"] = Change{From: before.Description, To: after.Description}
+	}
+	if before.Done != after.Done {
+		changes["done"] = Change{From: before.Done, To: after.Done}
+	}
+	if (before.ArchivedAt == nil) != (after.ArchivedAt == nil) {
+		changes["archived"] = Change{From: before.ArchivedAt != nil, To: after.ArchivedAt != nil}
+	}
+	if before.Assignee != after.Assignee {
+		changes["assignee"] = Change{From: before.Assignee, To: after.Assignee}
+	}
+	if before, after := leaseHolder(before.Lease), leaseHolder(after.Lease); before != after {
+		changes["lease_holder"] = Change{From: before, To: after}
+	}
+	if !equalTimes(before.RemindAt, after.RemindAt) {
+		changes["remind_at"] = Change{From: before.RemindAt, To: after.RemindAt}
+	}
+	if !slices.Equal(before.DependsOn, after.DependsOn) {
+		changes["depends_on"] = Change{From: before.DependsOn, To: after.DependsOn}
+	}
+	if before, after := attachmentIDs(before.Attachments), attachmentIDs(after.Attachments); !slices.Equal(before, after) {
+		changes["attachments"] = Change{From: before, To: after}
+	}
+	return changes
+}
+
+// leaseHolder returns the holder of l, or "" if l is nil.
+func leaseHolder(l *Lease) string {
+	if l == nil {
+		return ""
+	}
+	return l.Holder
+}
+
+// attachmentIDs returns the IDs of attachments.
+func attachmentIDs(attachments []Attachment) []string {
+	ids := make([]string, len(attachments))
+	for i, att := range attachments {
+		ids[i] = att.ID
+	}
+	return ids
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// TaskStats summarizes the stored tasks.
+type TaskStats struct {
+	Total   int64            `json:"total"`
+	ByState map[string]int64 `json:"by_state"`
+
+	// CompletionRate is the share of tasks that are done, archived or not.
+	CompletionRate float64 `json:"completion_rate"`
+
+	// AverageAgeSeconds is the average time since the tasks were created.
+	AverageAgeSeconds float64 `json:"average_age_seconds"`
+
+	// CreatedPerDay counts the tasks created on each of the last days (UTC),
+	// oldest first. Deleted tasks are not counted.
+	CreatedPerDay []DailyCount `json:"created_per_day"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// DailyCount is the number of tasks on a day (YYYY-MM-DD).
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
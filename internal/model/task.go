@@ -1,7 +1,11 @@
 package model
 
 import (
+	"slices"
 	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Task represents a todo item in the system.
@@ -12,19 +16,159 @@ type Task struct {
 	Done        bool      `json:"done"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// ArchivedAt is set while the task is archived. Archived tasks are
+	// hidden from list queries by default.
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+
+	// Owner and Tenant identify who created the task.
+	Owner  string `json:"owner,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+
+	// Assignee is the user the task is assigned to, if any.
+	Assignee string `json:"assignee,omitempty"`
+
+	// Lease is the latest claim of the task by a worker, if any. It stays
+	// set after it expires until the task is claimed again or released.
+	Lease *Lease `json:"lease,omitempty"`
+
+	// Recurrence is set for recurring tasks that materialize occurrences.
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+
+	// Attachments holds metadata of files uploaded to the task.
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// DependsOn lists the IDs of tasks that must be done before this one.
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// ParentID is the recurring task an occurrence was materialized from.
+	ParentID string `json:"parent_id,omitempty"`
+
+	// TemplateID is the task template the task was instantiated from.
+	TemplateID string `json:"template_id,omitempty"`
+
+	// RemindAt is when a reminder for the task is due. RemindedAt is set
+	// once the reminder was delivered or gave up retrying.
+	RemindAt   *time.Time `json:"remind_at,omitempty"`
+	RemindedAt *time.Time `json:"reminded_at,omitempty"`
+
+	// OriginSpan is the span context of the request that created the task,
+	// used to link background work back to it.
+	OriginSpan trace.SpanContext `json:"-"`
+}
+
+// Clone returns a deep copy of t, which shares no memory with t. The
+// repository hands out clones, so callers can't change stored tasks or race
+// with updates while encoding them.
+func (t *Task) Clone() *Task {
+	c := *t
+	c.ArchivedAt = clonePtr(t.ArchivedAt)
+	c.Lease = clonePtr(t.Lease)
+	c.Recurrence = clonePtr(t.Recurrence)
+	c.Attachments = slices.Clone(t.Attachments)
+	c.DependsOn = slices.Clone(t.DependsOn)
+	c.RemindAt = clonePtr(t.RemindAt)
+	c.RemindedAt = clonePtr(t.RemindedAt)
+	return &c
+}
+
+// clonePtr returns a pointer to a copy of *p, or nil if p is nil.
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// Lifecycle states of a task.
+const (
+	StateOpen     = "open"
+	StateDone     = "done"
+	StateArchived = "archived"
+)
+
+// State returns the lifecycle state of the task.
+func (t *Task) State() string {
+	switch {
+	case t.ArchivedAt != nil:
+		return StateArchived
+	case t.Done:
+		return StateDone
+	default:
+		return StateOpen
+	}
+}
+
+// Attachment describes a file attached to a task. The content lives in an
+// object store under StorageKey.
+type Attachment struct {
+	ID          string    `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
+// Recurrence describes the schedule of a recurring task.
+type Recurrence struct {
+	Interval  string    `json:"interval"`
+	NextRunAt time.Time `json:"next_run_at"`
+}
+
+// Every returns the parsed recurrence interval.
+func (r *Recurrence) Every() time.Duration {
+	d, _ := time.ParseDuration(r.Interval)
+	return d
+}
+
+// RecurrenceRule is the recurrence requested when creating a task.
+type RecurrenceRule struct {
+	Interval string `json:"interval"`
+}
+
+// MinRecurrenceInterval is the shortest allowed recurrence interval.
+const MinRecurrenceInterval = time.Minute
+
 // CreateTaskRequest represents the request body for creating a task.
 type CreateTaskRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Recurrence  *RecurrenceRule `json:"recurrence,omitempty"`
+	DependsOn   []string        `json:"depends_on,omitempty"`
+	RemindAt    *time.Time      `json:"remind_at,omitempty"`
+
+	// Owner and Tenant are taken from the request identity, not the body.
+	Owner  string `json:"-"`
+	Tenant string `json:"-"`
+
+	// TemplateID is set when instantiating a task template.
+	TemplateID string `json:"-"`
 }
 
 // UpdateTaskRequest represents the request body for updating a task.
+// A nil DependsOn leaves dependencies unchanged; an empty list clears them.
+// Setting RemindAt reschedules the reminder, even if one was already sent.
 type UpdateTaskRequest struct {
-	Title       string `json:"title,omitempty"`
-	Description string `json:"description,omitempty"`
-	Done        *bool  `json:"done,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Done        *bool      `json:"done,omitempty"`
+	DependsOn   []string   `json:"depends_on,omitempty"`
+	RemindAt    *time.Time `json:"remind_at,omitempty"`
+}
+
+// AssignTaskRequest represents the request body for assigning a task. An
+// empty Assignee unassigns the task.
+type AssignTaskRequest struct {
+	Assignee string `json:"assignee"`
+}
+
+// DependencyStatus describes a task's dependencies and whether it is blocked.
+type DependencyStatus struct {
+	TaskID       string  `json:"task_id"`
+	Blocked      bool    `json:"blocked"`
+	Dependencies []*Task `json:"dependencies"`
 }
 
 // Validate checks if the CreateTaskRequest is valid.
@@ -32,19 +176,50 @@ func (r *CreateTaskRequest) Validate() error {
 	if r.Title == "" {
 		return ErrTitleRequired
 	}
+	if r.Recurrence != nil {
+		d, err := time.ParseDuration(r.Recurrence.Interval)
+		if err != nil || d < MinRecurrenceInterval {
+			return ErrInvalidRecurrence
+		}
+	}
 	return nil
 }
 
-// TaskError represents a domain error for tasks.
-type TaskError struct {
-	Message string
+// Task domain errors. Their kind decides the HTTP status and how they are
+// recorded on spans; see package apperr. A user over quota may retry after
+// deleting tasks, while a tenant over quota needs an admin.
+var (
+	ErrTaskNotFound  = apperr.New(apperr.NotFound, "task not found")
+	ErrTitleRequired = apperr.New(apperr.Validation, "title is required")
+
+	ErrInvalidRecurrence = apperr.New(apperr.Validation, "recurrence interval must be a duration of at least 1m")
+	ErrRemindAtRequired  = apperr.New(apperr.Validation, "remind_at is required")
+
+	ErrUserQuotaExceeded   = apperr.New(apperr.ResourceExhausted, "task quota exceeded for user")
+	ErrTenantQuotaExceeded = apperr.New(apperr.PermissionDenied, "task quota exceeded for tenant")
+
+	ErrAttachmentNotFound = apperr.New(apperr.NotFound, "attachment not found")
+
+	ErrArchivingDisabled = apperr.New(apperr.NotFound, "task archiving is disabled")
+
+	ErrDependencyNotFound     = apperr.New(apperr.Validation, "dependency not found")
+	ErrDependencyCycle        = apperr.New(apperr.Conflict, "dependencies would create a cycle")
+	ErrDependenciesIncomplete = apperr.New(apperr.Conflict, "task has incomplete dependencies")
+
+	ErrDuplicateTitle = apperr.New(apperr.Conflict, "task with this title already exists")
+)
+
+// DuplicateTitleError is returned when unique titles are enforced and the
+// owner already has a task with the title. It matches ErrDuplicateTitle.
+type DuplicateTitleError struct {
+	// TaskID is the ID of the existing task.
+	TaskID string
 }
 
-func (e TaskError) Error() string {
-	return e.Message
+func (e *DuplicateTitleError) Error() string {
+	return ErrDuplicateTitle.Error() + ": " + e.TaskID
 }
 
-var (
-	ErrTaskNotFound  = TaskError{Message: "task not found"}
-	ErrTitleRequired = TaskError{Message: "title is required"}
-)
+func (e *DuplicateTitleError) Unwrap() error {
+	return ErrDuplicateTitle
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func BenchmarkClone(b *testing.B) {
+	now := time.Now()
+	task := &Task{
+		ID:          "0198c4e2-7a10-7c3e-9f3a-2b6d1e0f4a5c",
+		Title:       "Write the quarterly report",
+		Description: "Collect the numbers and write the summary",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Owner:       "alice",
+		Tenant:      "acme",
+		Assignee:    "bob",
+		Lease:       &Lease{},
+		Recurrence:  &Recurrence{},
+		Attachments: []Attachment{{}, {}},
+		DependsOn:   []string{"0198c4e2-7a10-7c3e-9f3a-2b6d1e0f4a5d", "0198c4e2-7a10-7c3e-9f3a-2b6d1e0f4a5e"},
+		RemindAt:    &now,
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		_ = task.Clone()
+	}
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+)
+
+// ErrInvalidStatus is returned for statuses other than StateOpen, StateDone
+// and StateArchived.
+var ErrInvalidStatus = apperr.New(apperr.Validation, "status must be open, done or archived")
+
+// TaskV2 is the representation of a task in the v2 API, which replaces the
+// done flag and archived_at of v1 with a single lifecycle status.
+type TaskV2 struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Owner    string `json:"owner,omitempty"`
+	Tenant   string `json:"tenant,omitempty"`
+	Assignee string `json:"assignee,omitempty"`
+	Lease    *Lease `json:"lease,omitempty"`
+
+	Recurrence  *Recurrence  `json:"recurrence,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	DependsOn   []string     `json:"depends_on,omitempty"`
+	ParentID    string       `json:"parent_id,omitempty"`
+	TemplateID  string       `json:"template_id,omitempty"`
+
+	RemindAt   *time.Time `json:"remind_at,omitempty"`
+	RemindedAt *time.Time `json:"reminded_at,omitempty"`
+}
+
+// NewTaskV2 returns the v2 representation of t.
+func NewTaskV2(t *Task) *TaskV2 {
+	return &TaskV2{
+		ID:          t.ID,
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      t.State(),
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+		Owner:       t.Owner,
+		Tenant:      t.Tenant,
+		Assignee:    t.Assignee,
+		Lease:       t.Lease,
+		Recurrence:  t.Recurrence,
+		Attachments: t.Attachments,
+		DependsOn:   t.DependsOn,
+		ParentID:    t.ParentID,
+		TemplateID:  t.TemplateID,
+		RemindAt:    t.RemindAt,
+		RemindedAt:  t.RemindedAt,
+	}
+}
+
+// TaskPageV2 is a page of the v2 task list. NextCursor is empty on the last
+// page.
+type TaskPageV2 struct {
+	Items      []*TaskV2 `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// SetStatusRequest represents the request body for changing the status of
+// a task in the v2 API.
+type SetStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// Validate checks if the SetStatusRequest is valid.
+func (r *SetStatusRequest) Validate() error {
+	switch r.Status {
+	case StateOpen, StateDone, StateArchived:
+		return nil
+	default:
+		return ErrInvalidStatus
+	}
+}
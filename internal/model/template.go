@@ -0,0 +1,106 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+)
+
+// TaskTemplate is a reusable blueprint for tasks. Its title and description
+// may reference variables as {{name}}, which are substituted when a task is
+// instantiated from the template.
+type TaskTemplate struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Variables lists the variables referenced by Title and Description,
+	// in order of first use.
+	Variables []string `json:"variables"`
+
+	// Owner and Tenant identify who created the template.
+	Owner  string `json:"owner,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// templateVariable matches a variable reference such as {{name}} or
+// {{ name }}.
+var templateVariable = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// TemplateVariables returns the variables referenced in texts, in order of
+// first use.
+func TemplateVariables(texts ...string) []string {
+	vars := []string{}
+	for _, text := range texts {
+		for _, m := range templateVariable.FindAllStringSubmatch(text, -1) {
+			if !slices.Contains(vars, m[1]) {
+				vars = append(vars, m[1])
+			}
+		}
+	}
+	return vars
+}
+
+// Render returns the title and description of the template with its
+// variables substituted from vars. Unknown entries of vars are ignored;
+// a variable missing from vars is a validation error.
+func (t *TaskTemplate) Render(vars map[string]string) (title, description string, err error) {
+	var missing []string
+	for _, name := range t.Variables {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", "", fmt.Errorf("%w: %s", ErrTemplateVariableMissing, strings.Join(missing, ", "))
+	}
+
+	substitute := func(text string) string {
+		return templateVariable.ReplaceAllStringFunc(text, func(ref string) string {
+			return vars[templateVariable.FindStringSubmatch(ref)[1]]
+		})
+	}
+	return substitute(t.Title), substitute(t.Description), nil
+}
+
+// CreateTemplateRequest represents the request body for creating a task
+// template.
+type CreateTemplateRequest struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+
+	// Owner and Tenant are taken from the request identity, not the body.
+	Owner  string `json:"-"`
+	Tenant string `json:"-"`
+}
+
+// Validate checks if the CreateTemplateRequest is valid.
+func (r *CreateTemplateRequest) Validate() error {
+	if r.Name == "" {
+		return ErrTemplateNameRequired
+	}
+	if r.Title == "" {
+		return ErrTitleRequired
+	}
+	return nil
+}
+
+// InstantiateTemplateRequest represents the request body for creating a
+// task from a template.
+type InstantiateTemplateRequest struct {
+	Variables map[string]string `json:"variables"`
+}
+
+// Task template domain errors; see package apperr.
+var (
+	ErrTemplateNotFound        = apperr.New(apperr.NotFound, "task template not found")
+	ErrTemplateNameRequired    = apperr.New(apperr.Validation, "name is required")
+	ErrTemplateVariableMissing = apperr.New(apperr.Validation, "missing template variables")
+)
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+)
+
+// Modes of a bulk status transition.
+const (
+	// TransitionAllOrNothing checks every task first and changes none of
+	// them if any can't be transitioned.
+	TransitionAllOrNothing = "all_or_nothing"
+	// TransitionBestEffort transitions every task it can and reports the
+	// others as failed.
+	TransitionBestEffort = "best_effort"
+)
+
+// Outcomes of a task in a bulk status transition.
+const (
+	TransitionTransitioned = "transitioned"
+	TransitionUnchanged    = "unchanged"
+	TransitionFailed       = "failed"
+	// TransitionSkipped marks tasks left alone because another task of an
+	// all-or-nothing transition failed.
+	TransitionSkipped = "skipped"
+)
+
+// MaxTransitionTasks caps the number of tasks a bulk status transition may
+// match.
+const MaxTransitionTasks = 1000
+
+// Bulk transition errors.
+var (
+	ErrInvalidTransitionMode  = apperr.New(apperr.Validation, "mode must be all_or_nothing or best_effort")
+	ErrEmptyTransitionFilter  = apperr.New(apperr.Validation, "filter must have ids or a status")
+	ErrTooManyTransitionTasks = apperr.New(apperr.Validation, "filter matches more than 1000 tasks")
+)
+
+// TransitionFilter selects the tasks of a bulk status transition: the tasks
+// with the given IDs, the tasks in the given status, or the tasks with the
+// given IDs that are in the given status.
+type TransitionFilter struct {
+	IDs    []string `json:"ids,omitempty"`
+	Status string   `json:"status,omitempty"`
+}
+
+// TransitionTasksRequest represents the request body for moving the tasks
+// matching Filter to Status. Mode defaults to TransitionAllOrNothing.
+type TransitionTasksRequest struct {
+	Filter TransitionFilter `json:"filter"`
+	Status string           `json:"status"`
+	Mode   string           `json:"mode,omitempty"`
+}
+
+// Validate checks if the TransitionTasksRequest is valid and defaults its
+// mode.
+func (r *TransitionTasksRequest) Validate() error {
+	if err := (&SetStatusRequest{Status: r.Status}).Validate(); err != nil {
+		return err
+	}
+	if len(r.Filter.IDs) == 0 && r.Filter.Status == "" {
+		return ErrEmptyTransitionFilter
+	}
+	if r.Filter.Status != "" {
+		if err := (&SetStatusRequest{Status: r.Filter.Status}).Validate(); err != nil {
+			return err
+		}
+	}
+	if len(r.Filter.IDs) > MaxTransitionTasks {
+		return ErrTooManyTransitionTasks
+	}
+	switch r.Mode {
+	case "":
+		r.Mode = TransitionAllOrNothing
+	case TransitionAllOrNothing, TransitionBestEffort:
+	default:
+		return ErrInvalidTransitionMode
+	}
+	return nil
+}
+
+// TaskTransition is the outcome of a task in a bulk status transition. From
+// is the status of the task before the transition.
+type TaskTransition struct {
+	ID      string `json:"id"`
+	From    string `json:"from,omitempty"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// TransitionReport summarizes a bulk status transition. Applied is false if
+// an all-or-nothing transition was abandoned.
+type TransitionReport struct {
+	Status       string           `json:"status"`
+	Mode         string           `json:"mode"`
+	Applied      bool             `json:"applied"`
+	Transitioned int              `json:"transitioned"`
+	Unchanged    int              `json:"unchanged"`
+	Failed       int              `json:"failed"`
+	Skipped      int              `json:"skipped"`
+	Tasks        []TaskTransition `json:"tasks"`
+}
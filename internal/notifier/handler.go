@@ -0,0 +1,125 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Idempotency keys are remembered for dedupWindow, and at most maxDedupKeys
+// of them.
+const (
+	dedupWindow  = 10 * time.Minute
+	maxDedupKeys = 10000
+)
+
+// Handler serves the notifier HTTP API.
+type Handler struct {
+	logger     *slog.Logger
+	delivered  metric.Int64Counter
+	duplicates metric.Int64Counter
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	keys []string // in order of arrival, for expiry
+}
+
+// NewHandler creates a new notifier Handler.
+func NewHandler(logger *slog.Logger, meter metric.Meter) (*Handler, error) {
+	delivered, err := meter.Int64Counter(
+		"notifications_delivered_total",
+		metric.WithDescription("Total number of notifications delivered"),
+		metric.WithUnit("{notification}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create delivered counter: %w", err)
+	}
+
+	duplicates, err := meter.Int64Counter(
+		"notifications_duplicate_total",
+		metric.WithDescription("Total number of notifications not delivered again because their idempotency key was seen"),
+		metric.WithUnit("{notification}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create duplicate counter: %w", err)
+	}
+
+	return &Handler{
+		logger:     logger,
+		delivered:  delivered,
+		duplicates: duplicates,
+		seen:       make(map[string]time.Time),
+	}, nil
+}
+
+// Notify accepts a notification and "delivers" it by logging. A
+// notification whose Idempotency-Key was seen within the dedup window is
+// accepted without delivering it again.
+func (h *Handler) Notify(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "NotifierHandler.Notify")
+	defer span.End()
+
+	var n Notification
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		h.logger.WarnContext(ctx, "invalid notification", slog.Any("error", err))
+		response.Error(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("notification.event", n.Event),
+		attribute.String("task.id", n.TaskID),
+	)
+
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" && !h.first(key) {
+		span.SetAttributes(attribute.Bool("notification.duplicate", true))
+		h.logger.InfoContext(ctx, "duplicate notification ignored",
+			slog.String("event", n.Event),
+			slog.String("task_id", n.TaskID),
+		)
+		h.duplicates.Add(ctx, 1, metric.WithAttributes(attribute.String("notification.event", n.Event)))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	h.logger.InfoContext(ctx, "notification delivered",
+		slog.String("event", n.Event),
+		slog.String("task_id", n.TaskID),
+		slog.String("title", n.Title),
+	)
+	h.delivered.Add(ctx, 1, metric.WithAttributes(attribute.String("notification.event", n.Event)))
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// first records key and reports whether it was not seen within the dedup
+// window, forgetting expired keys and the oldest ones over maxDedupKeys.
+func (h *Handler) first(key string) bool {
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for len(h.keys) > 0 {
+		oldest := h.keys[0]
+		if now.Sub(h.seen[oldest]) < dedupWindow && len(h.keys) < maxDedupKeys {
+			break
+		}
+		delete(h.seen, oldest)
+		h.keys = h.keys[1:]
+	}
+
+	if _, ok := h.seen[key]; ok {
+		return false
+	}
+	h.seen[key] = now
+	h.keys = append(h.keys, key)
+	return true
+}
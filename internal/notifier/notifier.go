@@ -0,0 +1,169 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/deadline"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/hedge"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/retry"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/notifier")
+
+// PeerService is the peer.service attribute value used for calls to the notifier.
+const PeerService = "notifier"
+
+// EventTaskCompleted is sent when a task transitions to done.
+const EventTaskCompleted = "task.completed"
+
+// IdempotencyKeyHeader carries a key per notification. Retries and hedged
+// attempts of a notification send the same key, so the notifier delivers it
+// once.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Notification is the payload accepted by the notifier service.
+type Notification struct {
+	Event  string `json:"event"`
+	TaskID string `json:"task_id"`
+	Title  string `json:"title"`
+}
+
+// Client calls the notifier service over HTTP, propagating trace context
+// and retrying failed calls. Slow calls are hedged if a Hedger is set; the
+// attempts of a call share an idempotency key, so hedging doesn't deliver
+// duplicates.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retry      retry.Policy
+	hedger     *hedge.Hedger
+
+	callDuration metric.Float64Histogram
+	callErrors   metric.Int64Counter
+}
+
+// NewClient creates a new Client for the notifier at baseURL, retrying calls
+// according to policy and hedging them with hedger; a nil hedger disables
+// hedging.
+func NewClient(baseURL string, meter metric.Meter, policy retry.Policy, hedger *hedge.Hedger) (*Client, error) {
+	c := &Client{
+		baseURL: baseURL,
+		retry:   policy,
+		hedger:  hedger,
+		httpClient: &http.Client{
+			Transport: otelhttp.NewTransport(deadline.NewTransport(http.DefaultTransport)),
+			Timeout:   5 * time.Second,
+		},
+	}
+
+	var err error
+
+	c.callDuration, err = meter.Float64Histogram(
+		"service_call_duration_seconds",
+		metric.WithDescription("Duration of outbound calls to downstream services"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service call duration histogram: %w", err)
+	}
+
+	c.callErrors, err = meter.Int64Counter(
+		"service_call_errors_total",
+		metric.WithDescription("Total number of failed outbound calls to downstream services"),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service call error counter: %w", err)
+	}
+
+	return c, nil
+}
+
+// NotifyCompleted tells the notifier that a task was completed.
+func (c *Client) NotifyCompleted(ctx context.Context, task *model.Task) error {
+	return c.send(ctx, Notification{
+		Event:  EventTaskCompleted,
+		TaskID: task.ID,
+		Title:  task.Title,
+	})
+}
+
+func (c *Client) send(ctx context.Context, n Notification) error {
+	ctx, span := tracer.Start(ctx, "NotifierClient.Send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("peer.service", PeerService),
+			attribute.String("notification.event", n.Event),
+			attribute.String("task.id", n.TaskID),
+		),
+	)
+	defer span.End()
+
+	key := uuid.New().String()
+	start := time.Now()
+	err := retry.Do(ctx, "NotifierClient.Attempt", c.retry, func(ctx context.Context) error {
+		if c.hedger != nil {
+			return c.hedger.Do(ctx, func(ctx context.Context) error {
+				return c.post(ctx, n, key)
+			})
+		}
+		return c.post(ctx, n, key)
+	})
+
+	attrs := []attribute.KeyValue{
+		attribute.String("peer.service", PeerService),
+		attribute.String("operation", n.Event),
+	}
+	c.callDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	if err != nil {
+		c.callErrors.Add(ctx, 1, metric.WithAttributes(attrs...))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "notification failed")
+		return err
+	}
+
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, n Notification, key string) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("failed to encode notification: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/notify", bytes.NewReader(body))
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, key)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call notifier: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("notifier returned status %d", resp.StatusCode)
+		if !retry.RetryableStatus(resp.StatusCode) {
+			return retry.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
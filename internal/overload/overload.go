@@ -0,0 +1,269 @@
+// Package overload protects the server from overload by shedding requests
+// by priority. The load level follows the number of requests in flight and
+// the p99 latency of the served requests: with one of them over its
+// threshold, low-priority requests (lists, exports, bulk operations) are
+// shed; with both, normal-priority requests (writes) are shed as well, so
+// that high-priority reads are still served.
+package overload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Latency window bounds: windows with fewer samples than minSamples count
+// as normal load, and at most maxSamples of a window are kept.
+const (
+	minSamples = 20
+	maxSamples = 4096
+)
+
+// Priority is the priority of a request. Under overload, requests are shed
+// from the lowest priority up.
+type Priority int
+
+// Request priorities.
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// String returns the name of p, as recorded in metrics and spans.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	default:
+		return "high"
+	}
+}
+
+// Level is the load level of the server.
+type Level int
+
+// Load levels.
+const (
+	// LevelNormal sheds nothing.
+	LevelNormal Level = iota
+	// LevelElevated sheds low-priority requests; one threshold is exceeded.
+	LevelElevated
+	// LevelCritical also sheds normal-priority requests; both thresholds are
+	// exceeded.
+	LevelCritical
+)
+
+// String returns the name of l, as recorded in metrics, spans and logs.
+func (l Level) String() string {
+	switch l {
+	case LevelNormal:
+		return "normal"
+	case LevelElevated:
+		return "elevated"
+	default:
+		return "critical"
+	}
+}
+
+// Sheds reports whether requests of priority p are shed at level l.
+func (l Level) Sheds(p Priority) bool {
+	return int(p) < int(l)
+}
+
+// Config configures a Shedder. A zero threshold disables its check.
+type Config struct {
+	// MaxInFlight is the number of concurrent requests from which the
+	// server is overloaded.
+	MaxInFlight int64
+	// LatencyThreshold is the p99 latency above which the server is
+	// overloaded.
+	LatencyThreshold time.Duration
+	// Interval is the window over which the p99 latency is measured.
+	Interval time.Duration
+}
+
+// Shedder tracks the requests in flight and the p99 latency of the served
+// requests, and decides which requests to shed.
+type Shedder struct {
+	cfg Config
+
+	inFlight atomic.Int64
+	p99      atomic.Int64 // time.Duration of the last window
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	observed  int
+
+	// level is the level of the last evaluation, for logging changes. It
+	// is only used by Run.
+	level Level
+
+	shed metric.Int64Counter
+}
+
+// NewShedder creates a new Shedder and registers its instruments: the
+// requests_shed_total counter and the overload_level and
+// overload_latency_p99_seconds gauges.
+func NewShedder(c Config, meter metric.Meter) (*Shedder, error) {
+	if c.MaxInFlight <= 0 && c.LatencyThreshold <= 0 {
+		return nil, errors.New("load shedding needs a maximum of in-flight requests or a latency threshold")
+	}
+	if c.Interval <= 0 {
+		return nil, fmt.Errorf("invalid load shedding interval %s", c.Interval)
+	}
+	s := &Shedder{cfg: c, latencies: make([]time.Duration, 0, maxSamples)}
+
+	var err error
+
+	s.shed, err = meter.Int64Counter(
+		"requests_shed_total",
+		metric.WithDescription("Total number of requests rejected to shed load, by priority and load level"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shed requests counter: %w", err)
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"overload_level",
+		metric.WithDescription("Current load level: 0 normal, 1 elevated (low priority shed), 2 critical (low and normal priority shed)"),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(s.Level()))
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create load level gauge: %w", err)
+	}
+
+	_, err = meter.Float64ObservableGauge(
+		"overload_latency_p99_seconds",
+		metric.WithDescription("p99 latency of the requests served in the last load shedding window"),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(time.Duration(s.p99.Load()).Seconds())
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create p99 latency gauge: %w", err)
+	}
+
+	return s, nil
+}
+
+// Level returns the current load level.
+func (s *Shedder) Level() Level {
+	level := LevelNormal
+	if s.cfg.MaxInFlight > 0 && s.inFlight.Load() >= s.cfg.MaxInFlight {
+		level++
+	}
+	if s.cfg.LatencyThreshold > 0 && time.Duration(s.p99.Load()) > s.cfg.LatencyThreshold {
+		level++
+	}
+	return level
+}
+
+// InFlight returns the number of admitted requests in flight.
+func (s *Shedder) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// RetryAfter is how long shed clients should wait before retrying: the
+// load level is re-evaluated within a window.
+func (s *Shedder) RetryAfter() time.Duration {
+	return s.cfg.Interval
+}
+
+// Admit decides whether a request of priority p is served at the current
+// load level, counting shed requests. An admitted request must call Done
+// when it has been served.
+func (s *Shedder) Admit(ctx context.Context, p Priority) (Level, bool) {
+	level := s.Level()
+	if level.Sheds(p) {
+		s.shed.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("request.priority", p.String()),
+			attribute.String("overload.level", level.String()),
+		))
+		return level, false
+	}
+	s.inFlight.Add(1)
+	return level, true
+}
+
+// Done records that an admitted request was served in duration.
+func (s *Shedder) Done(duration time.Duration) {
+	s.inFlight.Add(-1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.latencies) < maxSamples {
+		s.latencies = append(s.latencies, duration)
+	} else {
+		s.latencies[s.observed%maxSamples] = duration
+	}
+	s.observed++
+}
+
+// Run evaluates the p99 latency every interval until ctx is done.
+func (s *Shedder) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.evaluate(ctx)
+		}
+	}
+}
+
+// evaluate sets the p99 latency of the requests served since the last
+// evaluation and logs changes of the load level.
+func (s *Shedder) evaluate(ctx context.Context) {
+	s.mu.Lock()
+	latencies, observed := s.latencies, s.observed
+	s.latencies, s.observed = make([]time.Duration, 0, maxSamples), 0
+	s.mu.Unlock()
+
+	var p99 time.Duration
+	if observed >= minSamples {
+		slices.Sort(latencies)
+		p99 = latencies[(len(latencies)*99-1)/100]
+	}
+	s.p99.Store(int64(p99))
+
+	level := s.Level()
+	if level == s.level {
+		return
+	}
+	from := s.level
+	s.level = level
+
+	log := logging.FromContext(ctx).InfoContext
+	if level > from {
+		log = logging.FromContext(ctx).WarnContext
+	}
+	log(ctx, "load level changed",
+		slog.String("from", from.String()),
+		slog.String("to", level.String()),
+		slog.Int64("in_flight", s.InFlight()),
+		slog.Duration("latency_p99", p99),
+		slog.Int("requests", observed),
+	)
+}
@@ -0,0 +1,115 @@
+package pagination
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Reasons for rejecting a cursor.
+const (
+	ReasonMalformed = "malformed"
+	ReasonSignature = "signature"
+)
+
+// ErrInvalidCursor is returned by Decode for cursors that were not issued
+// by the codec.
+var ErrInvalidCursor = apperr.New(apperr.Validation, "invalid cursor")
+
+// signatureSize is the number of bytes of the HMAC kept in a cursor.
+const signatureSize = 16
+
+// Codec turns repository cursors into opaque, signed strings, so clients
+// can page through the task list without server-side session state but
+// cannot forge positions.
+type Codec struct {
+	key []byte
+
+	failures metric.Int64Counter
+}
+
+// NewCodec creates a new Codec signing cursors with secret. An empty secret
+// uses a random key, which invalidates all cursors on restart and differs
+// between replicas.
+func NewCodec(secret string, meter metric.Meter) (*Codec, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+
+	failures, err := meter.Int64Counter(
+		"pagination_cursor_decode_failures_total",
+		metric.WithDescription("Total number of pagination cursors rejected as malformed or wrongly signed"),
+		metric.WithUnit("{cursor}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cursor decode failures counter: %w", err)
+	}
+
+	return &Codec{key: key, failures: failures}, nil
+}
+
+// Encode returns the opaque form of c: the base64url encoding of its
+// creation time and ID, followed by an HMAC of both.
+func (k *Codec) Encode(c repository.Cursor) string {
+	payload := []byte(strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + c.ID)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(k.sign(payload))
+}
+
+// Decode parses a cursor returned by Encode. Rejected cursors are counted by
+// reason and reported as ErrInvalidCursor.
+func (k *Codec) Decode(ctx context.Context, s string) (repository.Cursor, error) {
+	c, reason := k.decode(s)
+	if reason != "" {
+		k.failures.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+		return repository.Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (k *Codec) decode(s string) (repository.Cursor, string) {
+	encPayload, encSig, ok := strings.Cut(s, ".")
+	if !ok {
+		return repository.Cursor{}, ReasonMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return repository.Cursor{}, ReasonMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return repository.Cursor{}, ReasonMalformed
+	}
+	if !hmac.Equal(sig, k.sign(payload)) {
+		return repository.Cursor{}, ReasonSignature
+	}
+
+	nanos, id, ok := bytes.Cut(payload, []byte(":"))
+	if !ok {
+		return repository.Cursor{}, ReasonMalformed
+	}
+	n, err := strconv.ParseInt(string(nanos), 10, 64)
+	if err != nil {
+		return repository.Cursor{}, ReasonMalformed
+	}
+	return repository.Cursor{CreatedAt: time.Unix(0, n), ID: string(id)}, ""
+}
+
+func (k *Codec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, k.key)
+	mac.Write(payload)
+	return mac.Sum(nil)[:signatureSize]
+}
@@ -0,0 +1,237 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/quota")
+
+// Scope is the kind of subject a quota applies to.
+type Scope string
+
+const (
+	ScopeUser   Scope = "user"
+	ScopeTenant Scope = "tenant"
+)
+
+// DefaultSubject is the subject name used to change the default limit of a
+// scope through SetLimit.
+const DefaultSubject = "default"
+
+// Counter reports how many tasks there are in total and how many a user or
+// tenant currently owns.
+type Counter interface {
+	Count() int64
+	CountByOwner(owner string) int64
+	CountByTenant(tenant string) int64
+}
+
+// Limits is a snapshot of the configured quotas. A limit of 0 means
+// unlimited.
+type Limits struct {
+	DefaultUser   int64            `json:"default_user"`
+	DefaultTenant int64            `json:"default_tenant"`
+	Users         map[string]int64 `json:"users"`
+	Tenants       map[string]int64 `json:"tenants"`
+}
+
+// Manager enforces the maximum number of tasks per user and per tenant.
+// Limits can be changed at runtime.
+type Manager struct {
+	counter Counter
+
+	mu     sync.RWMutex
+	limits Limits
+
+	exceeded metric.Int64Counter
+}
+
+// NewManager creates a new Manager with the given default limits.
+// A limit of 0 disables the quota for that scope.
+func NewManager(counter Counter, meter metric.Meter, maxPerUser, maxPerTenant int64) (*Manager, error) {
+	m := &Manager{
+		counter: counter,
+		limits: Limits{
+			DefaultUser:   maxPerUser,
+			DefaultTenant: maxPerTenant,
+			Users:         make(map[string]int64),
+			Tenants:       make(map[string]int64),
+		},
+	}
+
+	var err error
+
+	m.exceeded, err = meter.Int64Counter(
+		"quota_exceeded_total",
+		metric.WithDescription("Total number of task creations rejected because a quota was exceeded"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quota exceeded counter: %w", err)
+	}
+
+	usage, err := meter.Int64ObservableGauge(
+		"quota_usage",
+		metric.WithDescription("Current number of tasks per quota subject"),
+		metric.WithUnit("{task}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quota usage gauge: %w", err)
+	}
+
+	limit, err := meter.Int64ObservableGauge(
+		"quota_limit",
+		metric.WithDescription("Configured task limit per quota subject (0 means unlimited)"),
+		metric.WithUnit("{task}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quota limit gauge: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		m.observe(o, usage, limit)
+		return nil
+	}, usage, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register quota callback: %w", err)
+	}
+
+	return m, nil
+}
+
+// Check returns model.ErrUserQuotaExceeded or model.ErrTenantQuotaExceeded
+// if creating one more task would exceed a limit. An empty tenant skips the
+// tenant quota. The check is not atomic with the subsequent create, so
+// concurrent requests may overshoot a limit slightly.
+func (m *Manager) Check(ctx context.Context, user, tenant string) error {
+	_, span := tracer.Start(ctx, "QuotaManager.Check",
+		trace.WithAttributes(
+			telemetry.UserString("quota.user", user),
+			telemetry.UserString("quota.tenant", tenant),
+		),
+	)
+	defer span.End()
+
+	m.mu.RLock()
+	userLimit := m.limitLocked(ScopeUser, user)
+	tenantLimit := m.limitLocked(ScopeTenant, tenant)
+	m.mu.RUnlock()
+
+	if tenant != "" && tenantLimit > 0 && m.counter.CountByTenant(tenant) >= tenantLimit {
+		m.reject(ctx, span, ScopeTenant)
+		return model.ErrTenantQuotaExceeded
+	}
+	if userLimit > 0 && m.counter.CountByOwner(user) >= userLimit {
+		m.reject(ctx, span, ScopeUser)
+		return model.ErrUserQuotaExceeded
+	}
+	return nil
+}
+
+// SetLimit changes the limit of a subject. Using DefaultSubject changes the
+// default limit of the scope. A negative limit removes a subject override.
+func (m *Manager) SetLimit(scope Scope, subject string, limit int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var overrides map[string]int64
+	switch scope {
+	case ScopeUser:
+		if subject == DefaultSubject {
+			m.limits.DefaultUser = max(limit, 0)
+			return nil
+		}
+		overrides = m.limits.Users
+	case ScopeTenant:
+		if subject == DefaultSubject {
+			m.limits.DefaultTenant = max(limit, 0)
+			return nil
+		}
+		overrides = m.limits.Tenants
+	default:
+		return fmt.Errorf("unknown quota scope %q", scope)
+	}
+
+	if limit < 0 {
+		delete(overrides, subject)
+		return nil
+	}
+	overrides[subject] = limit
+	return nil
+}
+
+// Limits returns a copy of the configured limits.
+func (m *Manager) Limits() Limits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l := m.limits
+	l.Users = maps.Clone(m.limits.Users)
+	l.Tenants = maps.Clone(m.limits.Tenants)
+	return l
+}
+
+func (m *Manager) limitLocked(scope Scope, subject string) int64 {
+	if scope == ScopeTenant {
+		if l, ok := m.limits.Tenants[subject]; ok {
+			return l
+		}
+		return m.limits.DefaultTenant
+	}
+	if l, ok := m.limits.Users[subject]; ok {
+		return l
+	}
+	return m.limits.DefaultUser
+}
+
+func (m *Manager) reject(ctx context.Context, span trace.Span, scope Scope) {
+	m.exceeded.Add(ctx, 1, metric.WithAttributes(attribute.String("quota.scope", string(scope))))
+	span.AddEvent("quota.exceeded", trace.WithAttributes(attribute.String("quota.scope", string(scope))))
+}
+
+// observe reports usage and limits of the subjects with an override. The
+// other subjects are reported together under DefaultSubject, so that callers
+// can't add a series per header value: its usage is the number of tasks they
+// own and its limit the default limit.
+func (m *Manager) observe(o metric.Observer, usage, limit metric.Int64ObservableGauge) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	total := m.counter.Count()
+
+	others := total
+	for user, l := range m.limits.Users {
+		n := m.counter.CountByOwner(user)
+		others -= n
+		observeSubject(o, usage, limit, ScopeUser, user, n, l)
+	}
+	observeSubject(o, usage, limit, ScopeUser, DefaultSubject, others, m.limits.DefaultUser)
+
+	// Tasks without a tenant are not subject to a tenant quota
+	others = total - m.counter.CountByTenant("")
+	for tenant, l := range m.limits.Tenants {
+		n := m.counter.CountByTenant(tenant)
+		others -= n
+		observeSubject(o, usage, limit, ScopeTenant, tenant, n, l)
+	}
+	observeSubject(o, usage, limit, ScopeTenant, DefaultSubject, others, m.limits.DefaultTenant)
+}
+
+func observeSubject(o metric.Observer, usage, limit metric.Int64ObservableGauge, scope Scope, subject string, n, l int64) {
+	attrs := metric.WithAttributes(
+		attribute.String("quota.scope", string(scope)),
+		attribute.String("quota.subject", subject),
+	)
+	o.ObserveInt64(usage, n, attrs)
+	o.ObserveInt64(limit, l, attrs)
+}
@@ -0,0 +1,192 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/deadline"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/retry"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/reminder")
+
+// Delivery channels.
+const (
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// Sender delivers task reminders over a channel.
+type Sender interface {
+	Channel() string
+	Send(ctx context.Context, task *model.Task) error
+}
+
+// Config selects and configures the Sender returned by NewSender.
+type Config struct {
+	Channel    string
+	WebhookURL string
+	EmailFrom  string
+	EmailTo    string
+}
+
+// NewSender creates the Sender for c.Channel.
+func NewSender(c Config, logger *slog.Logger) (Sender, error) {
+	switch c.Channel {
+	case ChannelLog:
+		return NewLogSender(logger), nil
+	case ChannelWebhook:
+		if c.WebhookURL == "" {
+			return nil, fmt.Errorf("reminder channel %q requires a webhook url", c.Channel)
+		}
+		return NewWebhookSender(c.WebhookURL), nil
+	case ChannelEmail:
+		if c.EmailFrom == "" || c.EmailTo == "" {
+			return nil, fmt.Errorf("reminder channel %q requires sender and recipient addresses", c.Channel)
+		}
+		return NewSMTPSender(c.EmailFrom, c.EmailTo, logger), nil
+	}
+	return nil, fmt.Errorf("unsupported reminder channel %q", c.Channel)
+}
+
+// LogSender writes reminders to the log.
+type LogSender struct {
+	logger *slog.Logger
+}
+
+// NewLogSender creates a new LogSender.
+func NewLogSender(logger *slog.Logger) *LogSender {
+	return &LogSender{logger: logger}
+}
+
+// Channel returns ChannelLog.
+func (s *LogSender) Channel() string { return ChannelLog }
+
+// Send logs the reminder.
+func (s *LogSender) Send(ctx context.Context, task *model.Task) error {
+	s.logger.InfoContext(ctx, "task reminder",
+		slog.String("id", task.ID),
+		slog.String("title", task.Title),
+		slog.String("owner", task.Owner),
+	)
+	return nil
+}
+
+// Payload is the body posted by WebhookSender.
+type Payload struct {
+	TaskID   string    `json:"task_id"`
+	Title    string    `json:"title"`
+	Owner    string    `json:"owner,omitempty"`
+	RemindAt time.Time `json:"remind_at"`
+}
+
+// WebhookSender posts reminders as JSON to a URL, propagating trace context.
+type WebhookSender struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookSender creates a new WebhookSender posting to url.
+func NewWebhookSender(url string) *WebhookSender {
+	return &WebhookSender{
+		url: url,
+		httpClient: &http.Client{
+			Transport: otelhttp.NewTransport(deadline.NewTransport(http.DefaultTransport)),
+			Timeout:   5 * time.Second,
+		},
+	}
+}
+
+// Channel returns ChannelWebhook.
+func (s *WebhookSender) Channel() string { return ChannelWebhook }
+
+// Send posts the reminder to the webhook. Client errors other than timeouts
+// and rate limiting are marked as permanent, since retrying them cannot
+// succeed.
+func (s *WebhookSender) Send(ctx context.Context, task *model.Task) error {
+	payload := Payload{TaskID: task.ID, Title: task.Title, Owner: task.Owner}
+	if task.RemindAt != nil {
+		payload.RemindAt = *task.RemindAt
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("failed to encode reminder: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call reminder webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("reminder webhook returned status %d", resp.StatusCode)
+		if !retry.RetryableStatus(resp.StatusCode) {
+			return retry.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// SMTPSender is a stub email sender: it renders the reminder email and logs
+// it in an SMTP client span instead of connecting to a mail server.
+type SMTPSender struct {
+	from   string
+	to     string
+	logger *slog.Logger
+}
+
+// NewSMTPSender creates a new SMTPSender sending from from to to.
+func NewSMTPSender(from, to string, logger *slog.Logger) *SMTPSender {
+	return &SMTPSender{from: from, to: to, logger: logger}
+}
+
+// Channel returns ChannelEmail.
+func (s *SMTPSender) Channel() string { return ChannelEmail }
+
+// Send renders the reminder email and logs it.
+func (s *SMTPSender) Send(ctx context.Context, task *model.Task) error {
+	ctx, span := tracer.Start(ctx, "SMTPSender.Send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("peer.service", "smtp"),
+			attribute.String("task.id", task.ID),
+		),
+	)
+	defer span.End()
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", s.to)
+	// The title is encoded, so line breaks in it can't start new headers
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Reminder: "+task.Title))
+	fmt.Fprintf(&msg, "\r\nTask %s is due.\r\n", task.ID)
+
+	span.SetAttributes(attribute.Int("email.size", msg.Len()))
+	s.logger.InfoContext(ctx, "reminder email rendered (smtp stub)",
+		slog.String("id", task.ID),
+		slog.String("to", s.to),
+		slog.Int("size", msg.Len()),
+	)
+	return nil
+}
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FullPolicy decides what a BoundedRepository does when a task is created
+// while it holds its maximum number of tasks.
+type FullPolicy string
+
+const (
+	// FullPolicyLRU evicts the least recently used task to make room.
+	FullPolicyLRU FullPolicy = "lru"
+	// FullPolicyReject rejects the new task with ErrRepositoryFull.
+	FullPolicyReject FullPolicy = "reject"
+)
+
+var (
+	// ErrRepositoryFull is returned when creating a task in a full
+	// BoundedRepository with FullPolicyReject.
+	ErrRepositoryFull = apperr.New(apperr.ResourceExhausted, "task repository is full")
+	// ErrInvalidFullPolicy is returned by ParseFullPolicy for unknown
+	// policies.
+	ErrInvalidFullPolicy = apperr.New(apperr.Validation, "full policy must be lru or reject")
+)
+
+// ParseFullPolicy returns the policy named s.
+func ParseFullPolicy(s string) (FullPolicy, error) {
+	switch p := FullPolicy(s); p {
+	case FullPolicyLRU, FullPolicyReject:
+		return p, nil
+	default:
+		return "", ErrInvalidFullPolicy
+	}
+}
+
+type evictionKey struct{}
+
+// withEviction returns a context in which Delete also forgets the history of
+// the task, so evicted tasks don't keep using memory.
+func withEviction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, evictionKey{}, true)
+}
+
+// isEviction reports whether ctx was returned by withEviction.
+func isEviction(ctx context.Context) bool {
+	evicted, _ := ctx.Value(evictionKey{}).(bool)
+	return evicted
+}
+
+// lruIndex orders task IDs from most to least recently used.
+type lruIndex struct {
+	mu    sync.Mutex
+	order *list.List
+	elems map[string]*list.Element
+}
+
+// touch marks id as the most recently used task, adding it if needed.
+func (l *lruIndex) touch(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.elems[id]; ok {
+		l.order.MoveToFront(e)
+		return
+	}
+	l.elems[id] = l.order.PushFront(id)
+}
+
+// remove forgets id.
+func (l *lruIndex) remove(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.elems[id]; ok {
+		l.order.Remove(e)
+		delete(l.elems, id)
+	}
+}
+
+// oldest returns the least recently used task ID.
+func (l *lruIndex) oldest() (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// len returns the number of tracked tasks.
+func (l *lruIndex) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// BoundedRepository caps the number of tasks of the wrapped repository.
+// Creating a task while it is full evicts the least recently used task or
+// fails, depending on its FullPolicy. Reads of a single task and writes
+// count as uses; List does not. Evicted tasks are deleted together with
+// their history, and appear as deleted in the change feed.
+type BoundedRepository struct {
+	Repository
+
+	maxTasks int
+	policy   FullPolicy
+	logger   *slog.Logger
+
+	// createMu serializes the operations that add tasks, so the check for
+	// room and the insert are not interleaved.
+	createMu *sync.Mutex
+	lru      *lruIndex
+
+	evictions metric.Int64Counter
+}
+
+// NewBoundedRepository creates a new BoundedRepository around repo, which
+// must be empty, holding at most maxTasks tasks.
+func NewBoundedRepository(repo Repository, logger *slog.Logger, meter metric.Meter, maxTasks int, policy FullPolicy) (*BoundedRepository, error) {
+	if maxTasks <= 0 {
+		return nil, fmt.Errorf("invalid maximum task count %d", maxTasks)
+	}
+	if _, err := ParseFullPolicy(string(policy)); err != nil {
+		return nil, err
+	}
+
+	b := &BoundedRepository{
+		Repository: repo,
+		maxTasks:   maxTasks,
+		policy:     policy,
+		logger:     logger,
+		createMu:   &sync.Mutex{},
+		lru: &lruIndex{
+			order: list.New(),
+			elems: make(map[string]*list.Element),
+		},
+	}
+
+	var err error
+
+	b.evictions, err = meter.Int64Counter(
+		"repository_evictions_total",
+		metric.WithDescription("Total number of tasks evicted from the full task repository"),
+		metric.WithUnit("{task}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create eviction counter: %w", err)
+	}
+
+	_, err = meter.Float64ObservableGauge(
+		"repository_fullness_ratio",
+		metric.WithDescription("Number of stored tasks relative to the maximum task count"),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(float64(b.lru.len())/float64(b.maxTasks), metric.WithAttributes(
+				attribute.String("repository.full_policy", string(b.policy)),
+			))
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository fullness gauge: %w", err)
+	}
+
+	return b, nil
+}
+
+// makeRoom ensures a task can be added, evicting least recently used tasks
+// or failing with ErrRepositoryFull. Dry runs are checked but evict nothing.
+// b.createMu must be held.
+func (b *BoundedRepository) makeRoom(ctx context.Context) error {
+	for b.lru.len() >= b.maxTasks {
+		if b.policy == FullPolicyReject {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("repository.full", true))
+			return ErrRepositoryFull
+		}
+		if IsDryRun(ctx) {
+			return nil
+		}
+		id, ok := b.lru.oldest()
+		if !ok {
+			return nil
+		}
+		if err := b.evict(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evict deletes the task with the given ID in a BoundedRepository.Evict
+// span. A task that is already gone is only forgotten.
+func (b *BoundedRepository) evict(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "BoundedRepository.Evict",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.Int("repository.max_tasks", b.maxTasks),
+		),
+	)
+	defer span.End()
+
+	err := b.Repository.Delete(withEviction(ctx), id)
+	if err != nil && !errors.Is(err, model.ErrTaskNotFound) {
+		span.RecordError(err)
+		return fmt.Errorf("failed to evict task: %w", err)
+	}
+	b.lru.remove(id)
+	if err != nil {
+		return nil
+	}
+
+	b.evictions.Add(ctx, 1)
+	b.logger.DebugContext(ctx, "task evicted", slog.String("id", id))
+	return nil
+}
+
+// Create adds a new task, making room for it first; see makeRoom.
+func (b *BoundedRepository) Create(ctx context.Context, req *model.CreateTaskRequest) (*model.Task, error) {
+	b.createMu.Lock()
+	defer b.createMu.Unlock()
+
+	if err := b.makeRoom(ctx); err != nil {
+		return nil, err
+	}
+	task, err := b.Repository.Create(ctx, req)
+	if err == nil && !IsDryRun(ctx) {
+		b.lru.touch(task.ID)
+	}
+	return task, err
+}
+
+// Materialize creates the next occurrence of the recurring task, making
+// room for it first; see makeRoom.
+func (b *BoundedRepository) Materialize(ctx context.Context, id string, now time.Time) (*model.Task, int, error) {
+	b.createMu.Lock()
+	defer b.createMu.Unlock()
+
+	if err := b.makeRoom(ctx); err != nil {
+		return nil, 0, err
+	}
+	occurrence, missed, err := b.Repository.Materialize(ctx, id, now)
+	if err == nil {
+		b.lru.touch(id)
+		b.lru.touch(occurrence.ID)
+	}
+	return occurrence, missed, err
+}
+
+// GetByID returns a task by ID and marks it as used.
+func (b *BoundedRepository) GetByID(ctx context.Context, id string) (*model.Task, error) {
+	task, err := b.Repository.GetByID(ctx, id)
+	if err == nil {
+		b.lru.touch(id)
+	}
+	return task, err
+}
+
+// Delete removes a task.
+func (b *BoundedRepository) Delete(ctx context.Context, id string) error {
+	err := b.Repository.Delete(ctx, id)
+	if err == nil && !IsDryRun(ctx) {
+		b.lru.remove(id)
+	}
+	return err
+}
+
+// The writes below delegate to the wrapped repository and mark the task as
+// used.
+
+func (b *BoundedRepository) Update(ctx context.Context, id string, req *model.UpdateTaskRequest) (*model.Task, error) {
+	task, err := b.Repository.Update(ctx, id, req)
+	b.used(ctx, id, err)
+	return task, err
+}
+
+func (b *BoundedRepository) SetArchived(ctx context.Context, id string, archived bool) (*model.Task, error) {
+	task, err := b.Repository.SetArchived(ctx, id, archived)
+	b.used(ctx, id, err)
+	return task, err
+}
+
+func (b *BoundedRepository) Assign(ctx context.Context, id, assignee string) (*model.Task, error) {
+	task, err := b.Repository.Assign(ctx, id, assignee)
+	b.used(ctx, id, err)
+	return task, err
+}
+
+func (b *BoundedRepository) Claim(ctx context.Context, id, holder string, ttl time.Duration) (*model.Task, *model.Lease, error) {
+	task, expired, err := b.Repository.Claim(ctx, id, holder, ttl)
+	b.used(ctx, id, err)
+	return task, expired, err
+}
+
+func (b *BoundedRepository) Release(ctx context.Context, id, holder string) (*model.Task, error) {
+	task, err := b.Repository.Release(ctx, id, holder)
+	b.used(ctx, id, err)
+	return task, err
+}
+
+func (b *BoundedRepository) MarkReminded(ctx context.Context, id string, at time.Time) error {
+	err := b.Repository.MarkReminded(ctx, id, at)
+	b.used(ctx, id, err)
+	return err
+}
+
+func (b *BoundedRepository) AddAttachment(ctx context.Context, taskID string, att model.Attachment) error {
+	err := b.Repository.AddAttachment(ctx, taskID, att)
+	b.used(ctx, taskID, err)
+	return err
+}
+
+// used marks the task with the given ID as used after a successful write.
+func (b *BoundedRepository) used(ctx context.Context, id string, err error) {
+	if err == nil && !IsDryRun(ctx) {
+		b.lru.touch(id)
+	}
+}
+
+// WithTx runs fn in a transaction of the wrapped repository, bounding the
+// writes fn makes through tx like any other.
+func (b *BoundedRepository) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return b.Repository.WithTx(ctx, func(tx Repository) error {
+		txb := *b
+		txb.Repository = tx
+		return fn(&txb)
+	})
+}
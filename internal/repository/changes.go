@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxChanges is the number of change feed entries kept in memory.
+const maxChanges = 1000
+
+// Changes returns the changes recorded after the since cursor, the current
+// cursor, whether older changes were dropped from the feed, and a channel
+// that is closed when the next change is recorded. A since cursor ahead of
+// the feed (e.g. from before a restart) returns the whole feed.
+func (r *TaskRepository) Changes(ctx context.Context, since int64) ([]model.TaskChange, int64, bool, <-chan struct{}) {
+	_, span := tracer.Start(ctx, "TaskRepository.Changes",
+		trace.WithAttributes(attribute.Int64("changes.since", since)),
+	)
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if since > r.cursor {
+		since = 0
+	}
+
+	changes := make([]model.TaskChange, 0)
+	for _, c := range r.changes {
+		if c.Cursor > since {
+			changes = append(changes, c)
+		}
+	}
+	truncated := len(r.changes) > 0 && r.changes[0].Cursor > since+1
+
+	span.SetAttributes(
+		attribute.Int64("changes.cursor", r.cursor),
+		attribute.Int("changes.count", len(changes)),
+	)
+	return changes, r.cursor, truncated, r.changed
+}
+
+// recordChange appends a revision to the change feed and wakes up waiting
+// pollers. r.mu must be held for writing.
+func (r *TaskRepository) recordChange(rev model.Revision, id string) {
+	r.cursor++
+	r.changes = append(r.changes, model.TaskChange{
+		Cursor:    r.cursor,
+		TaskID:    id,
+		Action:    rev.Action,
+		Timestamp: rev.Timestamp,
+	})
+	if len(r.changes) > maxChanges {
+		r.changes = r.changes[len(r.changes)-maxChanges:]
+	}
+
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// CoalescingRepository collapses concurrent identical GetByID and List calls
+// into one call to the wrapped repository, and optionally remembers task IDs
+// that were not found for a short time. How reads see preceding writes
+// depends on its Consistency. Other methods are passed through.
+type CoalescingRepository struct {
+	Repository
+
+	group       singleflight.Group
+	generation  atomic.Uint64
+	negativeTTL time.Duration
+
+	defaultConsistency Consistency
+	window             time.Duration
+
+	mu       sync.Mutex
+	notFound map[string]time.Time
+	writers  map[string]time.Time
+
+	coalesced    metric.Int64Counter
+	negativeHits metric.Int64Counter
+}
+
+// NewCoalescingRepository creates a new CoalescingRepository around repo.
+// Not-found results of GetByID are cached for negativeTTL; a negativeTTL of
+// 0 disables the negative cache. With ConsistencyReadYourWrites, the reads
+// of a writer bypass coalescing and the negative cache for window after
+// its last write.
+func NewCoalescingRepository(repo Repository, meter metric.Meter, negativeTTL time.Duration, consistency Consistency, window time.Duration) (*CoalescingRepository, error) {
+	if _, err := ParseConsistency(string(consistency)); err != nil {
+		return nil, err
+	}
+
+	c := &CoalescingRepository{
+		Repository:         repo,
+		negativeTTL:        negativeTTL,
+		defaultConsistency: consistency,
+		window:             window,
+		notFound:           make(map[string]time.Time),
+		writers:            make(map[string]time.Time),
+	}
+
+	var err error
+
+	c.coalesced, err = meter.Int64Counter(
+		"repository_coalesced_reads_total",
+		metric.WithDescription("Total number of repository reads served by a concurrent identical read"),
+		metric.WithUnit("{read}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create coalesced reads counter: %w", err)
+	}
+
+	c.negativeHits, err = meter.Int64Counter(
+		"repository_negative_cache_hits_total",
+		metric.WithDescription("Total number of task lookups answered from the not-found cache"),
+		metric.WithUnit("{read}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create negative cache hits counter: %w", err)
+	}
+
+	return c, nil
+}
+
+// GetByID returns a task by ID, sharing the result with concurrent lookups
+// of the same ID. A shared task is copied for every caller.
+func (c *CoalescingRepository) GetByID(ctx context.Context, id string) (*model.Task, error) {
+	if c.bypass(ctx) {
+		return c.Repository.GetByID(ctx, id)
+	}
+	if c.cachedNotFound(id) {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.negative_hit", true))
+		c.negativeHits.Add(ctx, 1)
+		return nil, model.ErrTaskNotFound
+	}
+
+	v, shared, err := c.do(ctx, "get_by_id", c.key("get:%s", id), func(ctx context.Context) (any, error) {
+		return c.Repository.GetByID(ctx, id)
+	})
+	if errors.Is(err, model.ErrTaskNotFound) {
+		c.rememberNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		return v.(*model.Task).Clone(), nil
+	}
+	return v.(*model.Task), nil
+}
+
+// List returns the tasks matching opts, sharing the result with concurrent
+// calls with the same options. Shared tasks are copied for every caller.
+func (c *CoalescingRepository) List(ctx context.Context, opts ListOptions) ([]*model.Task, error) {
+	if c.bypass(ctx) {
+		return c.Repository.List(ctx, opts)
+	}
+	key := c.key("list:%t:%s:%d", opts.IncludeArchived, opts.Assignee, opts.Limit)
+	if opts.After != nil {
+		key += fmt.Sprintf(":%d:%s", opts.After.CreatedAt.UnixNano(), opts.After.ID)
+	}
+	v, shared, err := c.do(ctx, "list", key, func(ctx context.Context) (any, error) {
+		return c.Repository.List(ctx, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	tasks := v.([]*model.Task)
+	if shared {
+		tasks = cloneTasks(tasks)
+	}
+	return tasks, nil
+}
+
+// Create creates the task and forgets a cached not-found result for its ID,
+// so a task created under a looked-up ID is visible right away.
+func (c *CoalescingRepository) Create(ctx context.Context, req *model.CreateTaskRequest) (*model.Task, error) {
+	task, err := c.Repository.Create(ctx, req)
+	if err == nil {
+		c.mu.Lock()
+		delete(c.notFound, task.ID)
+		c.mu.Unlock()
+		c.wrote(ctx, task.ID)
+	}
+	return task, err
+}
+
+// do runs fn once for concurrent calls with the same key. The shared call
+// runs in the context of the first caller without its cancellation, so a
+// cancelled leader doesn't fail the others. Callers that waited for the
+// leader's result are marked on their span and counted. do reports whether
+// the result was given to more than one caller, so none may modify it.
+func (c *CoalescingRepository) do(ctx context.Context, operation, key string, fn func(context.Context) (any, error)) (any, bool, error) {
+	leader := false
+	v, err, shared := c.group.Do(key, func() (any, error) {
+		leader = true
+		return fn(context.WithoutCancel(ctx))
+	})
+
+	coalesced := !leader
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("repository.coalesced", coalesced))
+	if coalesced {
+		c.coalesced.Add(ctx, 1, metric.WithAttributes(attribute.String("repository.operation", operation)))
+	}
+	return v, shared, err
+}
+
+// cloneTasks returns copies of tasks.
+func cloneTasks(tasks []*model.Task) []*model.Task {
+	clones := make([]*model.Task, len(tasks))
+	for i, task := range tasks {
+		clones[i] = task.Clone()
+	}
+	return clones
+}
+
+func (c *CoalescingRepository) cachedNotFound(id string) bool {
+	if c.negativeTTL <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.notFound[id]
+	if ok && time.Now().After(expires) {
+		delete(c.notFound, id)
+		return false
+	}
+	return ok
+}
+
+func (c *CoalescingRepository) rememberNotFound(id string) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired entries so lookups of random IDs don't grow the map.
+	now := time.Now()
+	for key, expires := range c.notFound {
+		if now.After(expires) {
+			delete(c.notFound, key)
+		}
+	}
+	c.notFound[id] = now.Add(c.negativeTTL)
+}
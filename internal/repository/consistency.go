@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Consistency is the read consistency of a CoalescingRepository.
+type Consistency string
+
+const (
+	// ConsistencyEventual lets reads join in-flight reads and use the
+	// not-found cache even right after a write, so a read may briefly
+	// return data from before the write.
+	ConsistencyEventual Consistency = "eventual"
+	// ConsistencyReadYourWrites invalidates in-flight reads and refreshes
+	// the not-found cache synchronously on every write, and makes the
+	// writer's reads bypass both for a short window after its write.
+	ConsistencyReadYourWrites Consistency = "read_your_writes"
+)
+
+// ErrInvalidConsistency is returned by ParseConsistency for unknown modes.
+var ErrInvalidConsistency = apperr.New(apperr.Validation, "consistency must be eventual or read_your_writes")
+
+// ParseConsistency returns the consistency named s.
+func ParseConsistency(s string) (Consistency, error) {
+	switch c := Consistency(s); c {
+	case ConsistencyEventual, ConsistencyReadYourWrites:
+		return c, nil
+	default:
+		return "", ErrInvalidConsistency
+	}
+}
+
+type consistencyKey struct{}
+
+// WithConsistency returns a context whose repository calls use c instead
+// of the consistency the CoalescingRepository was created with.
+func WithConsistency(ctx context.Context, c Consistency) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, c)
+}
+
+// consistency returns the consistency of calls made with ctx.
+func (c *CoalescingRepository) consistency(ctx context.Context) Consistency {
+	if mode, ok := ctx.Value(consistencyKey{}).(Consistency); ok {
+		return mode
+	}
+	return c.defaultConsistency
+}
+
+// bypass reports whether a read made with ctx must not be coalesced or
+// answered from the not-found cache: with read-your-writes consistency,
+// that is while the actor of ctx wrote within the window. The consistency
+// and the decision are recorded on the current span.
+func (c *CoalescingRepository) bypass(ctx context.Context) bool {
+	mode := c.consistency(ctx)
+	bypass := false
+	if mode == ConsistencyReadYourWrites {
+		c.mu.Lock()
+		wroteAt, ok := c.writers[ActorFromContext(ctx)]
+		c.mu.Unlock()
+		bypass = ok && time.Since(wroteAt) < c.window
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("cache.consistency", string(mode)),
+		attribute.Bool("cache.bypassed", bypass),
+	)
+	return bypass
+}
+
+// wrote invalidates the reads affected by a successful write of the task
+// with the given ID (empty if unknown, which clears the not-found cache)
+// and remembers the actor of ctx as a recent writer. It only acts with
+// read-your-writes consistency and outside dry runs.
+func (c *CoalescingRepository) wrote(ctx context.Context, id string) {
+	if c.consistency(ctx) != ConsistencyReadYourWrites || IsDryRun(ctx) {
+		return
+	}
+
+	// Reads started from now on get new keys, so they don't join reads
+	// that may have seen the task before the write
+	c.generation.Add(1)
+
+	if id != "" {
+		c.refreshNotFound(ctx, id)
+	} else {
+		c.mu.Lock()
+		clear(c.notFound)
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for actor, wroteAt := range c.writers {
+		if now.Sub(wroteAt) >= c.window {
+			delete(c.writers, actor)
+		}
+	}
+	c.writers[ActorFromContext(ctx)] = now
+}
+
+// refreshNotFound repopulates the not-found cache entry of id from the
+// wrapped repository, so a deleted task is known to be missing and a
+// recreated one isn't.
+func (c *CoalescingRepository) refreshNotFound(ctx context.Context, id string) {
+	c.mu.Lock()
+	delete(c.notFound, id)
+	c.mu.Unlock()
+
+	if c.negativeTTL <= 0 {
+		return
+	}
+	if _, err := c.Repository.GetByID(ctx, id); errors.Is(err, model.ErrTaskNotFound) {
+		c.rememberNotFound(id)
+	}
+}
+
+// key returns the singleflight key of a read in the current generation.
+func (c *CoalescingRepository) key(format string, args ...any) string {
+	return fmt.Sprintf("%d:", c.generation.Load()) + fmt.Sprintf(format, args...)
+}
+
+// Update updates the task; see wrote.
+func (c *CoalescingRepository) Update(ctx context.Context, id string, req *model.UpdateTaskRequest) (*model.Task, error) {
+	task, err := c.Repository.Update(ctx, id, req)
+	if err == nil {
+		c.wrote(ctx, id)
+	}
+	return task, err
+}
+
+// Delete deletes the task; see wrote.
+func (c *CoalescingRepository) Delete(ctx context.Context, id string) error {
+	err := c.Repository.Delete(ctx, id)
+	if err == nil {
+		c.wrote(ctx, id)
+	}
+	return err
+}
+
+// SetArchived archives or unarchives the task; see wrote.
+func (c *CoalescingRepository) SetArchived(ctx context.Context, id string, archived bool) (*model.Task, error) {
+	task, err := c.Repository.SetArchived(ctx, id, archived)
+	if err == nil {
+		c.wrote(ctx, id)
+	}
+	return task, err
+}
+
+// Assign assigns the task; see wrote.
+func (c *CoalescingRepository) Assign(ctx context.Context, id, assignee string) (*model.Task, error) {
+	task, err := c.Repository.Assign(ctx, id, assignee)
+	if err == nil {
+		c.wrote(ctx, id)
+	}
+	return task, err
+}
+
+// Claim claims the task; see wrote.
+func (c *CoalescingRepository) Claim(ctx context.Context, id, holder string, ttl time.Duration) (*model.Task, *model.Lease, error) {
+	task, expired, err := c.Repository.Claim(ctx, id, holder, ttl)
+	if err == nil {
+		c.wrote(ctx, id)
+	}
+	return task, expired, err
+}
+
+// Release releases the task; see wrote. An expired lease is cleared too.
+func (c *CoalescingRepository) Release(ctx context.Context, id, holder string) (*model.Task, error) {
+	task, err := c.Repository.Release(ctx, id, holder)
+	if err == nil || errors.Is(err, model.ErrLeaseExpired) {
+		c.wrote(ctx, id)
+	}
+	return task, err
+}
+
+// Materialize materializes the next occurrence of the recurring task; see
+// wrote.
+func (c *CoalescingRepository) Materialize(ctx context.Context, id string, now time.Time) (*model.Task, int, error) {
+	task, n, err := c.Repository.Materialize(ctx, id, now)
+	if err == nil {
+		c.wrote(ctx, id)
+	}
+	return task, n, err
+}
+
+// MarkReminded marks the reminder of the task as delivered; see wrote.
+func (c *CoalescingRepository) MarkReminded(ctx context.Context, id string, at time.Time) error {
+	err := c.Repository.MarkReminded(ctx, id, at)
+	if err == nil {
+		c.wrote(ctx, id)
+	}
+	return err
+}
+
+// AddAttachment adds the attachment to the task; see wrote.
+func (c *CoalescingRepository) AddAttachment(ctx context.Context, taskID string, att model.Attachment) error {
+	err := c.Repository.AddAttachment(ctx, taskID, att)
+	if err == nil {
+		c.wrote(ctx, taskID)
+	}
+	return err
+}
+
+// WithTx runs fn in a transaction of the wrapped repository. The written
+// tasks aren't known, so a commit clears the whole not-found cache; see
+// wrote.
+func (c *CoalescingRepository) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	err := c.Repository.WithTx(ctx, fn)
+	if err == nil {
+		c.wrote(ctx, "")
+	}
+	return err
+}
@@ -0,0 +1,346 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Results of comparing a mirrored write with the primary.
+const (
+	shadowMatch          = "match"
+	shadowMismatch       = "mismatch"
+	shadowSecondaryError = "secondary_error"
+)
+
+// DualWriteRepository writes to a primary and a secondary repository and
+// compares the results, logging and counting mismatches. It is meant for
+// observing a migration between storage backends: the primary stays the
+// source of truth for writes, while reads are served by either backend.
+type DualWriteRepository struct {
+	primary   Repository
+	secondary Repository
+	reads     Repository
+	logger    *slog.Logger
+
+	comparisons metric.Int64Counter
+}
+
+// NewDualWriteRepository creates a new DualWriteRepository. Reads are served
+// by the secondary if readSecondary is set, by the primary otherwise.
+func NewDualWriteRepository(primary, secondary Repository, logger *slog.Logger, meter metric.Meter, readSecondary bool) (*DualWriteRepository, error) {
+	comparisons, err := meter.Int64Counter(
+		"dual_write_comparisons_total",
+		metric.WithDescription("Total number of mirrored repository writes by comparison result"),
+		metric.WithUnit("{write}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dual write comparisons counter: %w", err)
+	}
+
+	reads := primary
+	if readSecondary {
+		reads = secondary
+	}
+
+	return &DualWriteRepository{
+		primary:     primary,
+		secondary:   secondary,
+		reads:       reads,
+		logger:      logger,
+		comparisons: comparisons,
+	}, nil
+}
+
+// Create creates the task in both repositories. The secondary reuses the
+// ID assigned by the primary.
+func (d *DualWriteRepository) Create(ctx context.Context, req *model.CreateTaskRequest) (*model.Task, error) {
+	task, err := d.primary.Create(ctx, req)
+	d.shadowTask(ctx, "create", task, err, func(ctx context.Context) (*model.Task, error) {
+		if task != nil {
+			ctx = withTaskID(ctx, task.ID)
+		}
+		return d.secondary.Create(ctx, req)
+	})
+	return task, err
+}
+
+// Update updates the task in both repositories.
+func (d *DualWriteRepository) Update(ctx context.Context, id string, req *model.UpdateTaskRequest) (*model.Task, error) {
+	task, err := d.primary.Update(ctx, id, req)
+	d.shadowTask(ctx, "update", task, err, func(ctx context.Context) (*model.Task, error) {
+		return d.secondary.Update(ctx, id, req)
+	})
+	return task, err
+}
+
+// Delete deletes the task from both repositories.
+func (d *DualWriteRepository) Delete(ctx context.Context, id string) error {
+	err := d.primary.Delete(ctx, id)
+	d.shadowTask(ctx, "delete", nil, err, func(ctx context.Context) (*model.Task, error) {
+		return nil, d.secondary.Delete(ctx, id)
+	})
+	return err
+}
+
+// SetArchived archives or unarchives the task in both repositories.
+func (d *DualWriteRepository) SetArchived(ctx context.Context, id string, archived bool) (*model.Task, error) {
+	task, err := d.primary.SetArchived(ctx, id, archived)
+	d.shadowTask(ctx, "set_archived", task, err, func(ctx context.Context) (*model.Task, error) {
+		return d.secondary.SetArchived(ctx, id, archived)
+	})
+	return task, err
+}
+
+// Assign assigns the task in both repositories.
+func (d *DualWriteRepository) Assign(ctx context.Context, id, assignee string) (*model.Task, error) {
+	task, err := d.primary.Assign(ctx, id, assignee)
+	d.shadowTask(ctx, "assign", task, err, func(ctx context.Context) (*model.Task, error) {
+		return d.secondary.Assign(ctx, id, assignee)
+	})
+	return task, err
+}
+
+// Claim claims the task in both repositories.
+func (d *DualWriteRepository) Claim(ctx context.Context, id, holder string, ttl time.Duration) (*model.Task, *model.Lease, error) {
+	task, expired, err := d.primary.Claim(ctx, id, holder, ttl)
+	d.shadowTask(ctx, "claim", task, err, func(ctx context.Context) (*model.Task, error) {
+		task, _, err := d.secondary.Claim(ctx, id, holder, ttl)
+		return task, err
+	})
+	return task, expired, err
+}
+
+// Release releases the task in both repositories.
+func (d *DualWriteRepository) Release(ctx context.Context, id, holder string) (*model.Task, error) {
+	task, err := d.primary.Release(ctx, id, holder)
+	d.shadowTask(ctx, "release", task, err, func(ctx context.Context) (*model.Task, error) {
+		return d.secondary.Release(ctx, id, holder)
+	})
+	return task, err
+}
+
+// Materialize materializes the occurrence in both repositories. The
+// secondary reuses the occurrence ID assigned by the primary.
+func (d *DualWriteRepository) Materialize(ctx context.Context, id string, now time.Time) (*model.Task, int, error) {
+	occurrence, missed, err := d.primary.Materialize(ctx, id, now)
+	d.shadowTask(ctx, "materialize", occurrence, err, func(ctx context.Context) (*model.Task, error) {
+		if occurrence != nil {
+			ctx = withTaskID(ctx, occurrence.ID)
+		}
+		task, _, err := d.secondary.Materialize(ctx, id, now)
+		return task, err
+	})
+	return occurrence, missed, err
+}
+
+// MarkReminded marks the reminder as handled in both repositories.
+func (d *DualWriteRepository) MarkReminded(ctx context.Context, id string, at time.Time) error {
+	err := d.primary.MarkReminded(ctx, id, at)
+	d.shadowTask(ctx, "mark_reminded", nil, err, func(ctx context.Context) (*model.Task, error) {
+		return nil, d.secondary.MarkReminded(ctx, id, at)
+	})
+	return err
+}
+
+// AddAttachment records the attachment in both repositories.
+func (d *DualWriteRepository) AddAttachment(ctx context.Context, taskID string, att model.Attachment) error {
+	err := d.primary.AddAttachment(ctx, taskID, att)
+	d.shadowTask(ctx, "add_attachment", nil, err, func(ctx context.Context) (*model.Task, error) {
+		return nil, d.secondary.AddAttachment(ctx, taskID, att)
+	})
+	return err
+}
+
+// GetByID reads the task from the read repository.
+func (d *DualWriteRepository) GetByID(ctx context.Context, id string) (*model.Task, error) {
+	return d.reads.GetByID(ctx, id)
+}
+
+// List reads the tasks from the read repository.
+func (d *DualWriteRepository) List(ctx context.Context, opts ListOptions) ([]*model.Task, error) {
+	return d.reads.List(ctx, opts)
+}
+
+// ListIter iterates over the tasks of the read repository.
+func (d *DualWriteRepository) ListIter(ctx context.Context, opts ListOptions) iter.Seq2[*model.Task, error] {
+	return d.reads.ListIter(ctx, opts)
+}
+
+// History reads the task history from the read repository.
+func (d *DualWriteRepository) History(ctx context.Context, id string) ([]model.Revision, error) {
+	return d.reads.History(ctx, id)
+}
+
+// Dependencies reads the task dependencies from the read repository.
+func (d *DualWriteRepository) Dependencies(ctx context.Context, id string) (*model.DependencyStatus, error) {
+	return d.reads.Dependencies(ctx, id)
+}
+
+// Changes reads the change feed of the read repository.
+func (d *DualWriteRepository) Changes(ctx context.Context, since int64) ([]model.TaskChange, int64, bool, <-chan struct{}) {
+	return d.reads.Changes(ctx, since)
+}
+
+// Stats aggregates the tasks of the read repository.
+func (d *DualWriteRepository) Stats(ctx context.Context, now time.Time) (*model.TaskStats, error) {
+	return d.reads.Stats(ctx, now)
+}
+
+// CreatedPerDay counts the created tasks per day in the read repository.
+func (d *DualWriteRepository) CreatedPerDay(ctx context.Context, now time.Time, days int) ([]model.DailyCount, error) {
+	return d.reads.CreatedPerDay(ctx, now, days)
+}
+
+// DueRecurring reads the due recurring tasks from the primary, which drives
+// materialization.
+func (d *DualWriteRepository) DueRecurring(ctx context.Context, now time.Time) ([]*model.Task, error) {
+	return d.primary.DueRecurring(ctx, now)
+}
+
+// DueReminders reads the due reminders from the primary, which drives
+// delivery.
+func (d *DualWriteRepository) DueReminders(ctx context.Context, now time.Time) ([]*model.Task, error) {
+	return d.primary.DueReminders(ctx, now)
+}
+
+// GetAttachment reads the attachment metadata from the read repository.
+func (d *DualWriteRepository) GetAttachment(ctx context.Context, taskID, attachmentID string) (*model.Attachment, error) {
+	return d.reads.GetAttachment(ctx, taskID, attachmentID)
+}
+
+// Count returns the task count of the read repository.
+func (d *DualWriteRepository) Count() int64 {
+	return d.reads.Count()
+}
+
+// CountByState returns the task counts per state of the read repository.
+func (d *DualWriteRepository) CountByState() map[string]int64 {
+	return d.reads.CountByState()
+}
+
+// CountByOwner returns the task count of owner in the read repository.
+func (d *DualWriteRepository) CountByOwner(owner string) int64 {
+	return d.reads.CountByOwner(owner)
+}
+
+// CountByTenant returns the task count of tenant in the read repository.
+func (d *DualWriteRepository) CountByTenant(tenant string) int64 {
+	return d.reads.CountByTenant(tenant)
+}
+
+// OpenByAssignee returns the open tasks per assignee of the read repository.
+func (d *DualWriteRepository) OpenByAssignee() map[string]int64 {
+	return d.reads.OpenByAssignee()
+}
+
+// Transactional reports whether the primary has transactions.
+func (d *DualWriteRepository) Transactional() bool {
+	return d.primary.Transactional()
+}
+
+// WithTx runs fn in a transaction of the primary. Writes are still mirrored
+// to the secondary as they happen, so a rolled back transaction shows up as
+// a mismatch between the backends.
+func (d *DualWriteRepository) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return d.primary.WithTx(ctx, func(tx Repository) error {
+		txd := *d
+		txd.primary = tx
+		if d.reads == d.primary {
+			txd.reads = tx
+		}
+		return fn(&txd)
+	})
+}
+
+// shadowTask mirrors a write to the secondary in a child span and compares
+// its outcome with the primary's. Secondary failures are recorded but never
+// returned to the caller.
+func (d *DualWriteRepository) shadowTask(ctx context.Context, op string, want *model.Task, wantErr error, write func(ctx context.Context) (*model.Task, error)) {
+	ctx, span := tracer.Start(ctx, "DualWriteRepository.Shadow",
+		trace.WithAttributes(attribute.String("repository.operation", op)),
+	)
+	defer span.End()
+
+	got, err := write(ctx)
+
+	result, fields := shadowMatch, []string(nil)
+	switch {
+	case wantErr == nil && err != nil:
+		result = shadowSecondaryError
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "secondary write failed")
+	case wantErr != nil:
+		if err == nil || !errors.Is(err, wantErr) {
+			result, fields = shadowMismatch, []string{"error"}
+		}
+	case want != nil && got != nil:
+		fields = compareTasks(want, got)
+		if len(fields) > 0 {
+			result = shadowMismatch
+		}
+	}
+
+	d.comparisons.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("repository.operation", op),
+		attribute.String("result", result),
+	))
+	span.SetAttributes(attribute.String("dual_write.result", result))
+
+	switch result {
+	case shadowSecondaryError:
+		d.logger.ErrorContext(ctx, "secondary repository write failed",
+			slog.String("operation", op),
+			slog.Any("error", err),
+		)
+	case shadowMismatch:
+		span.SetAttributes(attribute.StringSlice("dual_write.mismatched_fields", fields))
+		d.logger.WarnContext(ctx, "dual write mismatch",
+			slog.String("operation", op),
+			slog.Any("fields", fields),
+			slog.Any("primary_error", wantErr),
+			slog.Any("secondary_error", err),
+		)
+	}
+}
+
+// compareTasks returns the names of the fields that differ between the
+// primary and secondary copy of a task. Timestamps are not compared since
+// each backend sets its own.
+func compareTasks(want, got *model.Task) []string {
+	var fields []string
+	if want.ID != got.ID {
+		fields = append(fields, "id")
+	}
+	for field := range model.Diff(want, got) {
+		fields = append(fields, field)
+	}
+	if want.Owner != got.Owner {
+		fields = append(fields, "owner")
+	}
+	if want.Tenant != got.Tenant {
+		fields = append(fields, "tenant")
+	}
+	if want.ParentID != got.ParentID {
+		fields = append(fields, "parent_id")
+	}
+	if (want.Recurrence == nil) != (got.Recurrence == nil) ||
+		want.Recurrence != nil && want.Recurrence.Interval != got.Recurrence.Interval {
+		fields = append(fields, "recurrence")
+	}
+	if len(want.Attachments) != len(got.Attachments) {
+		fields = append(fields, "attachments")
+	}
+	slices.Sort(fields)
+	return fields
+}
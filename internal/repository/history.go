@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRevisions is the number of latest revisions kept per task. Older
+// revisions are dropped; versions keep counting.
+const maxRevisions = 100
+
+// SystemActor is the actor of repository mutations without a WithActor
+// context, e.g. by the schedulers.
+const SystemActor = "system"
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes repository mutations to actor
+// in the task history.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, or SystemActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+type internalKey struct{}
+
+// AsInternal returns a context marking repository mutations as made by the
+// service itself, e.g. by the schedulers, which are not subject to task
+// leases. It must never be used for changes requested by clients; the actor
+// of a context says who made a change, not whether it may bypass a lease.
+func AsInternal(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalKey{}, true)
+}
+
+// isInternal reports whether ctx was returned by AsInternal.
+func isInternal(ctx context.Context) bool {
+	internal, _ := ctx.Value(internalKey{}).(bool)
+	return internal
+}
+
+// History returns the latest revisions of a task, up to maxRevisions,
+// oldest first. The history of a deleted task is deleted with it.
+func (r *TaskRepository) History(ctx context.Context, id string) ([]model.Revision, error) {
+	_, span := tracer.Start(ctx, "TaskRepository.History",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revisions, ok := r.revisions[id]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return nil, model.ErrTaskNotFound
+	}
+
+	span.SetAttributes(attribute.Int("task.revisions", len(revisions)))
+	return append([]model.Revision(nil), revisions...), nil
+}
+
+// appendRevision records a change to a task in its history and the change
+// feed. An update without field changes is not recorded. r.mu must be held
+// for writing.
+func (r *TaskRepository) appendRevision(ctx context.Context, id, action string, before, after *model.Task) {
+	changes := model.Diff(before, after)
+	if action == model.RevisionUpdated && len(changes) == 0 {
+		return
+	}
+
+	_, span := tracer.Start(ctx, "TaskRepository.AppendRevision",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.String("task.revision.action", action),
+		),
+	)
+	defer span.End()
+
+	revisions := r.revisions[id]
+	rev := model.Revision{
+		Version:   1,
+		Action:    action,
+		Actor:     ActorFromContext(ctx),
+		Timestamp: time.Now(),
+		Changes:   changes,
+	}
+	if len(revisions) > 0 {
+		rev.Version = revisions[len(revisions)-1].Version + 1
+	}
+	if len(revisions) == maxRevisions {
+		revisions = slices.Delete(revisions, 0, 1)
+	}
+	r.revisions[id] = append(revisions, rev)
+	r.recordChange(rev, id)
+
+	span.SetAttributes(attribute.Int("task.revision.version", rev.Version))
+}
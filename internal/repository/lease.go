@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Claim leases the open task id to holder for ttl, or renews the lease if
+// holder already holds it. It fails with model.ErrTaskLeased while another
+// holder's lease is active. An expired lease of any holder is replaced and
+// returned, so callers can tell that its work may be incomplete.
+func (r *TaskRepository) Claim(ctx context.Context, id, holder string, ttl time.Duration) (*model.Task, *model.Lease, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.Claim",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.String("lease.holder", holder),
+			attribute.String("lease.ttl", ttl.String()),
+		),
+	)
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[id]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return nil, nil, model.ErrTaskNotFound
+	}
+	span.SetAttributes(attribute.Bool("task.found", true))
+	if task.State() != model.StateOpen {
+		return nil, nil, model.ErrTaskNotOpen
+	}
+
+	now := time.Now()
+	current := task.Lease
+	if current.Active(now) && current.Holder != holder {
+		span.AddEvent("lease.contended", leaseEventAttributes(current))
+		return nil, nil, model.ErrTaskLeased
+	}
+
+	var expired *model.Lease
+	if current != nil && !current.Active(now) {
+		expired = current
+		span.AddEvent("lease.expired", leaseEventAttributes(current))
+	}
+
+	before := *task
+	snapshot(ctx, task)
+	lease := &model.Lease{Holder: holder, ClaimedAt: now, ExpiresAt: now.Add(ttl)}
+	renewed := current.Active(now)
+	if renewed {
+		lease.ClaimedAt = current.ClaimedAt
+	}
+	task.Lease = lease
+	task.UpdatedAt = now
+
+	if renewed {
+		span.AddEvent("lease.renewed", leaseEventAttributes(lease))
+	} else {
+		span.AddEvent("lease.claimed", leaseEventAttributes(lease))
+		r.appendRevision(ctx, id, model.RevisionClaimed, &before, task)
+	}
+	return task.Clone(), expired, nil
+}
+
+// Release ends the lease of holder on task id. It fails with
+// model.ErrLeaseNotHeld if holder doesn't hold the latest lease. A lease
+// that already expired is cleared as well, but reported with
+// model.ErrLeaseExpired since another worker may have claimed the task in
+// the meantime.
+func (r *TaskRepository) Release(ctx context.Context, id, holder string) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.Release",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.String("lease.holder", holder),
+		),
+	)
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[id]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return nil, model.ErrTaskNotFound
+	}
+	span.SetAttributes(attribute.Bool("task.found", true))
+
+	lease := task.Lease
+	if lease == nil || lease.Holder != holder {
+		return nil, model.ErrLeaseNotHeld
+	}
+
+	now := time.Now()
+	before := *task
+	snapshot(ctx, task)
+	task.Lease = nil
+	task.UpdatedAt = now
+	r.appendRevision(ctx, id, model.RevisionReleased, &before, task)
+
+	if !lease.Active(now) {
+		span.AddEvent("lease.expired", leaseEventAttributes(lease))
+		return nil, model.ErrLeaseExpired
+	}
+	span.AddEvent("lease.released", leaseEventAttributes(lease))
+	return task.Clone(), nil
+}
+
+// checkLease returns model.ErrTaskLeased if task is leased to another
+// actor than the one of ctx, recording the contention on the span of ctx.
+// Internal changes (see AsInternal), e.g. by the schedulers, and evictions
+// are not subject to leases.
+func checkLease(ctx context.Context, task *model.Task) error {
+	if isInternal(ctx) || isEviction(ctx) {
+		return nil
+	}
+	actor := ActorFromContext(ctx)
+	if !task.Lease.Active(time.Now()) || task.Lease.Holder == actor {
+		return nil
+	}
+	trace.SpanFromContext(ctx).AddEvent("lease.contended", leaseEventAttributes(task.Lease))
+	return model.ErrTaskLeased
+}
+
+// leaseEventAttributes returns the span event attributes of lease.
+func leaseEventAttributes(lease *model.Lease) trace.EventOption {
+	return trace.WithAttributes(
+		attribute.String("lease.holder", lease.Holder),
+		attribute.String("lease.expires_at", lease.ExpiresAt.Format(time.RFC3339Nano)),
+	)
+}
@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BackendMemory is the db.system value of the in-memory repository.
+const BackendMemory = "memory"
+
+// MetricsRepository records the duration and errors of every storage
+// operation of the wrapped repository, so storage SLIs exist independently
+// of the HTTP metrics. The count methods used by gauges are passed through.
+type MetricsRepository struct {
+	Repository
+
+	attrs []attribute.KeyValue
+
+	duration   metric.Float64Histogram
+	errors     metric.Int64Counter
+	rollbacks  metric.Int64Counter
+	duplicates metric.Int64Counter
+	contention metric.Int64Counter
+	expiries   metric.Int64Counter
+}
+
+// NewMetricsRepository creates a new MetricsRepository around repo. backend
+// is recorded as db.system and role (e.g. primary or secondary) as
+// repository.role.
+func NewMetricsRepository(repo Repository, meter metric.Meter, backend, role string) (*MetricsRepository, error) {
+	m := &MetricsRepository{
+		Repository: repo,
+		attrs: []attribute.KeyValue{
+			attribute.String("db.system", backend),
+			attribute.String("repository.role", role),
+		},
+	}
+
+	var err error
+
+	m.duration, err = meter.Float64Histogram(
+		"db_client_operation_duration_seconds",
+		metric.WithDescription("Duration of task repository operations"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository duration histogram: %w", err)
+	}
+
+	m.errors, err = meter.Int64Counter(
+		"db_client_operation_errors_total",
+		metric.WithDescription("Total number of failed task repository operations"),
+		metric.WithUnit("{operation}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository error counter: %w", err)
+	}
+
+	m.rollbacks, err = meter.Int64Counter(
+		"db_client_transaction_rollbacks_total",
+		metric.WithDescription("Total number of rolled back task repository transactions"),
+		metric.WithUnit("{transaction}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository rollback counter: %w", err)
+	}
+
+	m.duplicates, err = meter.Int64Counter(
+		"task_duplicates_total",
+		metric.WithDescription("Total number of task writes rejected for a title the owner already uses"),
+		metric.WithUnit("{task}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create duplicate task counter: %w", err)
+	}
+
+	m.contention, err = meter.Int64Counter(
+		"task_lease_contention_total",
+		metric.WithDescription("Total number of task claims and writes rejected because another worker holds the lease"),
+		metric.WithUnit("{operation}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lease contention counter: %w", err)
+	}
+
+	m.expiries, err = meter.Int64Counter(
+		"task_lease_expirations_total",
+		metric.WithDescription("Total number of task leases found expired when the task was claimed again or released"),
+		metric.WithUnit("{lease}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lease expiration counter: %w", err)
+	}
+
+	return m, nil
+}
+
+// record records an operation that started at start. Failed operations are
+// counted with their error kind (see apperr.Kind) as error.type, and
+// duplicate titles and lease contention are also counted on their own.
+func (m *MetricsRepository) record(ctx context.Context, operation string, start time.Time, err error) {
+	attrs := append([]attribute.KeyValue{attribute.String("db.operation.name", operation)}, m.attrs...)
+	m.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	if err == nil {
+		return
+	}
+
+	m.errors.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("error.type", apperr.KindOf(err).String()))...))
+	if errors.Is(err, model.ErrDuplicateTitle) {
+		m.duplicates.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+	if errors.Is(err, model.ErrTaskLeased) {
+		m.contention.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+}
+
+// expired counts a lease that operation found expired.
+func (m *MetricsRepository) expired(ctx context.Context, operation string) {
+	m.expiries.Add(ctx, 1, metric.WithAttributes(append([]attribute.KeyValue{attribute.String("db.operation.name", operation)}, m.attrs...)...))
+}
+
+// WithTx runs fn in a transaction of the wrapped repository, traced as a
+// Repository.Tx span with a commit or rollback event. Rollbacks are
+// counted; the operations of fn are recorded like any other.
+func (m *MetricsRepository) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	ctx, span := tracer.Start(ctx, "Repository.Tx", trace.WithAttributes(m.attrs...))
+	defer span.End()
+
+	err := m.Repository.WithTx(ctx, func(tx Repository) error {
+		txm := *m
+		txm.Repository = tx
+		return fn(&txm)
+	})
+	if err != nil {
+		span.RecordError(err)
+		// Without transactions, the writes of fn are kept
+		if !m.Transactional() {
+			span.SetStatus(codes.Error, "unit of work failed")
+			return err
+		}
+		span.AddEvent("rollback", trace.WithAttributes(attribute.String("error.message", err.Error())))
+		span.SetStatus(codes.Error, "transaction rolled back")
+		m.rollbacks.Add(ctx, 1, metric.WithAttributes(m.attrs...))
+		return err
+	}
+	if m.Transactional() {
+		span.AddEvent("commit")
+	}
+	return nil
+}
+
+// The operations below delegate to the wrapped repository and record the
+// outcome under their db.operation.name.
+
+func (m *MetricsRepository) Create(ctx context.Context, req *model.CreateTaskRequest) (*model.Task, error) {
+	start := time.Now()
+	task, err := m.Repository.Create(ctx, req)
+	m.record(ctx, "create", start, err)
+	return task, err
+}
+
+func (m *MetricsRepository) GetByID(ctx context.Context, id string) (*model.Task, error) {
+	start := time.Now()
+	task, err := m.Repository.GetByID(ctx, id)
+	m.record(ctx, "get_by_id", start, err)
+	return task, err
+}
+
+func (m *MetricsRepository) List(ctx context.Context, opts ListOptions) ([]*model.Task, error) {
+	start := time.Now()
+	tasks, err := m.Repository.List(ctx, opts)
+	m.record(ctx, "list", start, err)
+	return tasks, err
+}
+
+// ListIter records the iteration once it is done, so the duration covers
+// the time the caller took to consume the tasks.
+func (m *MetricsRepository) ListIter(ctx context.Context, opts ListOptions) iter.Seq2[*model.Task, error] {
+	return func(yield func(*model.Task, error) bool) {
+		start := time.Now()
+		var err error
+		defer func() { m.record(ctx, "list_iter", start, err) }()
+
+		for task, iterErr := range m.Repository.ListIter(ctx, opts) {
+			err = iterErr
+			if !yield(task, iterErr) {
+				return
+			}
+		}
+	}
+}
+
+func (m *MetricsRepository) Update(ctx context.Context, id string, req *model.UpdateTaskRequest) (*model.Task, error) {
+	start := time.Now()
+	task, err := m.Repository.Update(ctx, id, req)
+	m.record(ctx, "update", start, err)
+	return task, err
+}
+
+func (m *MetricsRepository) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := m.Repository.Delete(ctx, id)
+	m.record(ctx, "delete", start, err)
+	return err
+}
+
+func (m *MetricsRepository) SetArchived(ctx context.Context, id string, archived bool) (*model.Task, error) {
+	start := time.Now()
+	task, err := m.Repository.SetArchived(ctx, id, archived)
+	m.record(ctx, "set_archived", start, err)
+	return task, err
+}
+
+func (m *MetricsRepository) Assign(ctx context.Context, id, assignee string) (*model.Task, error) {
+	start := time.Now()
+	task, err := m.Repository.Assign(ctx, id, assignee)
+	m.record(ctx, "assign", start, err)
+	return task, err
+}
+
+// Claim also counts the expired lease it replaced, if any.
+func (m *MetricsRepository) Claim(ctx context.Context, id, holder string, ttl time.Duration) (*model.Task, *model.Lease, error) {
+	start := time.Now()
+	task, expired, err := m.Repository.Claim(ctx, id, holder, ttl)
+	m.record(ctx, "claim", start, err)
+	if expired != nil {
+		m.expired(ctx, "claim")
+	}
+	return task, expired, err
+}
+
+// Release also counts the lease if it had expired.
+func (m *MetricsRepository) Release(ctx context.Context, id, holder string) (*model.Task, error) {
+	start := time.Now()
+	task, err := m.Repository.Release(ctx, id, holder)
+	m.record(ctx, "release", start, err)
+	if errors.Is(err, model.ErrLeaseExpired) {
+		m.expired(ctx, "release")
+	}
+	return task, err
+}
+
+func (m *MetricsRepository) History(ctx context.Context, id string) ([]model.Revision, error) {
+	start := time.Now()
+	revisions, err := m.Repository.History(ctx, id)
+	m.record(ctx, "history", start, err)
+	return revisions, err
+}
+
+func (m *MetricsRepository) Dependencies(ctx context.Context, id string) (*model.DependencyStatus, error) {
+	start := time.Now()
+	status, err := m.Repository.Dependencies(ctx, id)
+	m.record(ctx, "dependencies", start, err)
+	return status, err
+}
+
+func (m *MetricsRepository) Changes(ctx context.Context, since int64) ([]model.TaskChange, int64, bool, <-chan struct{}) {
+	start := time.Now()
+	changes, cursor, reset, changed := m.Repository.Changes(ctx, since)
+	m.record(ctx, "changes", start, nil)
+	return changes, cursor, reset, changed
+}
+
+func (m *MetricsRepository) Stats(ctx context.Context, now time.Time) (*model.TaskStats, error) {
+	start := time.Now()
+	stats, err := m.Repository.Stats(ctx, now)
+	m.record(ctx, "stats", start, err)
+	return stats, err
+}
+
+func (m *MetricsRepository) CreatedPerDay(ctx context.Context, now time.Time, days int) ([]model.DailyCount, error) {
+	start := time.Now()
+	counts, err := m.Repository.CreatedPerDay(ctx, now, days)
+	m.record(ctx, "created_per_day", start, err)
+	return counts, err
+}
+
+func (m *MetricsRepository) DueRecurring(ctx context.Context, now time.Time) ([]*model.Task, error) {
+	start := time.Now()
+	tasks, err := m.Repository.DueRecurring(ctx, now)
+	m.record(ctx, "due_recurring", start, err)
+	return tasks, err
+}
+
+func (m *MetricsRepository) Materialize(ctx context.Context, id string, now time.Time) (*model.Task, int, error) {
+	start := time.Now()
+	occurrence, missed, err := m.Repository.Materialize(ctx, id, now)
+	m.record(ctx, "materialize", start, err)
+	return occurrence, missed, err
+}
+
+func (m *MetricsRepository) DueReminders(ctx context.Context, now time.Time) ([]*model.Task, error) {
+	start := time.Now()
+	tasks, err := m.Repository.DueReminders(ctx, now)
+	m.record(ctx, "due_reminders", start, err)
+	return tasks, err
+}
+
+func (m *MetricsRepository) MarkReminded(ctx context.Context, id string, at time.Time) error {
+	start := time.Now()
+	err := m.Repository.MarkReminded(ctx, id, at)
+	m.record(ctx, "mark_reminded", start, err)
+	return err
+}
+
+func (m *MetricsRepository) AddAttachment(ctx context.Context, taskID string, att model.Attachment) error {
+	start := time.Now()
+	err := m.Repository.AddAttachment(ctx, taskID, att)
+	m.record(ctx, "add_attachment", start, err)
+	return err
+}
+
+func (m *MetricsRepository) GetAttachment(ctx context.Context, taskID, attachmentID string) (*model.Attachment, error) {
+	start := time.Now()
+	att, err := m.Repository.GetAttachment(ctx, taskID, attachmentID)
+	m.record(ctx, "get_attachment", start, err)
+	return att, err
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"iter"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+)
+
+// Repository is the task storage used by the handlers and the scheduler.
+type Repository interface {
+	Create(ctx context.Context, req *model.CreateTaskRequest) (*model.Task, error)
+	GetByID(ctx context.Context, id string) (*model.Task, error)
+	List(ctx context.Context, opts ListOptions) ([]*model.Task, error)
+	// ListIter is List for large result sets: it yields the tasks one at a
+	// time instead of materializing them, stopping after the first error.
+	ListIter(ctx context.Context, opts ListOptions) iter.Seq2[*model.Task, error]
+	Update(ctx context.Context, id string, req *model.UpdateTaskRequest) (*model.Task, error)
+	Delete(ctx context.Context, id string) error
+	SetArchived(ctx context.Context, id string, archived bool) (*model.Task, error)
+	Assign(ctx context.Context, id, assignee string) (*model.Task, error)
+	Claim(ctx context.Context, id, holder string, ttl time.Duration) (*model.Task, *model.Lease, error)
+	Release(ctx context.Context, id, holder string) (*model.Task, error)
+	History(ctx context.Context, id string) ([]model.Revision, error)
+	Dependencies(ctx context.Context, id string) (*model.DependencyStatus, error)
+	Changes(ctx context.Context, since int64) ([]model.TaskChange, int64, bool, <-chan struct{})
+	Stats(ctx context.Context, now time.Time) (*model.TaskStats, error)
+	CreatedPerDay(ctx context.Context, now time.Time, days int) ([]model.DailyCount, error)
+
+	DueRecurring(ctx context.Context, now time.Time) ([]*model.Task, error)
+	Materialize(ctx context.Context, id string, now time.Time) (*model.Task, int, error)
+	DueReminders(ctx context.Context, now time.Time) ([]*model.Task, error)
+	MarkReminded(ctx context.Context, id string, at time.Time) error
+
+	AddAttachment(ctx context.Context, taskID string, att model.Attachment) error
+	GetAttachment(ctx context.Context, taskID, attachmentID string) (*model.Attachment, error)
+
+	// WithTx runs fn as a unit of work: the writes fn makes through tx are
+	// committed if it returns nil and rolled back otherwise. Backends
+	// without transactions run fn on themselves.
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
+	// Transactional reports whether WithTx rolls back the writes of a
+	// failed fn.
+	Transactional() bool
+
+	Count() int64
+	CountByState() map[string]int64
+	CountByOwner(owner string) int64
+	CountByTenant(tenant string) int64
+	OpenByAssignee() map[string]int64
+}
+
+var (
+	_ Repository = (*TaskRepository)(nil)
+	_ Repository = (*DualWriteRepository)(nil)
+	_ Repository = (*CoalescingRepository)(nil)
+	_ Repository = (*MetricsRepository)(nil)
+	_ Repository = (*BoundedRepository)(nil)
+)
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context in which Create, Update, Delete and
+// SetArchived run all their checks and return their result without
+// applying the change.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx was returned by WithDryRun.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+type snapshotKey struct{}
+
+// Snapshot receives the state of a task before a write, see WithSnapshot.
+type Snapshot struct {
+	// Before is the task before the write. It stays nil if the task was not
+	// found.
+	Before *model.Task
+}
+
+// WithSnapshot returns a context in which Update, Delete, SetArchived,
+// Assign, Claim, Release and AddAttachment copy the task they change into s
+// before changing it. The copy is taken under the repository lock, so unlike
+// a GetByID before the write it can't miss a concurrent change. In dual-write
+// mode, s receives the task of the primary.
+func WithSnapshot(ctx context.Context, s *Snapshot) context.Context {
+	return context.WithValue(ctx, snapshotKey{}, s)
+}
+
+// snapshot copies task into the Snapshot of ctx, unless there is none or it
+// already holds a task.
+func snapshot(ctx context.Context, task *model.Task) {
+	if s, ok := ctx.Value(snapshotKey{}).(*Snapshot); ok && s.Before == nil {
+		s.Before = task.Clone()
+	}
+}
+
+type taskIDKey struct{}
+
+// withTaskID returns a context that makes the next created task use id,
+// so mirrored writes keep the IDs of the primary.
+func withTaskID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, taskIDKey{}, id)
+}
+
+// taskIDFromContext returns the ID set by withTaskID, if any.
+func taskIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(taskIDKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dateLayout formats the days of TaskStats.CreatedPerDay.
+const dateLayout = "2006-01-02"
+
+// Stats aggregates the tasks as of now. CreatedPerDay is left empty. A SQL
+// backend would compute the same values with one aggregate query.
+func (r *TaskRepository) Stats(ctx context.Context, now time.Time) (*model.TaskStats, error) {
+	_, span := tracer.Start(ctx, "TaskRepository.Stats")
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := &model.TaskStats{
+		Total: int64(len(r.tasks)),
+		ByState: map[string]int64{
+			model.StateOpen:     0,
+			model.StateDone:     0,
+			model.StateArchived: 0,
+		},
+		GeneratedAt: now,
+	}
+
+	var done int64
+	var age time.Duration
+	for _, task := range r.tasks {
+		stats.ByState[task.State()]++
+		if task.Done {
+			done++
+		}
+		age += now.Sub(task.CreatedAt)
+	}
+	if stats.Total > 0 {
+		stats.CompletionRate = float64(done) / float64(stats.Total)
+		stats.AverageAgeSeconds = age.Seconds() / float64(stats.Total)
+	}
+
+	span.SetAttributes(attribute.Int64("stats.total", stats.Total))
+	return stats, nil
+}
+
+// CreatedPerDay counts the tasks created on each of the given number of
+// days up to and including today (UTC), oldest first.
+func (r *TaskRepository) CreatedPerDay(ctx context.Context, now time.Time, days int) ([]model.DailyCount, error) {
+	_, span := tracer.Start(ctx, "TaskRepository.CreatedPerDay",
+		trace.WithAttributes(attribute.Int("stats.days", days)),
+	)
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	first := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+	end := first.AddDate(0, 0, days)
+
+	counts := make([]model.DailyCount, days)
+	for i := range counts {
+		counts[i].Date = first.AddDate(0, 0, i).Format(dateLayout)
+	}
+	for _, task := range r.tasks {
+		created := task.CreatedAt.UTC()
+		if !created.Before(first) && created.Before(end) {
+			counts[int(created.Sub(first)/(24*time.Hour))].Count++
+		}
+	}
+	return counts, nil
+}
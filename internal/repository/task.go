@@ -2,11 +2,16 @@ package repository
 
 import (
 	"context"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/idgen"
 	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -14,43 +19,133 @@ import (
 
 var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/repository")
 
+// ListOptions narrows down the tasks returned by TaskRepository.List.
+type ListOptions struct {
+	// IncludeArchived also returns archived tasks.
+	IncludeArchived bool
+	// Assignee only returns the tasks assigned to this user if set.
+	Assignee string
+	// After only returns the tasks sorting after this cursor if set.
+	After *Cursor
+	// Limit caps the number of returned tasks if positive. ListIter
+	// ignores After and Limit.
+	Limit int
+}
+
+// Cursor is the position of a task in the list order: by creation time,
+// then by ID. Neither changes after a task is created, so paging by cursor
+// neither skips nor repeats tasks when tasks are created or deleted
+// between pages.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// CursorOf returns the cursor of task.
+func CursorOf(task *model.Task) Cursor {
+	return Cursor{CreatedAt: task.CreatedAt, ID: task.ID}
+}
+
+// compare orders c relative to other in the list order.
+func (c Cursor) compare(other Cursor) int {
+	if n := c.CreatedAt.Compare(other.CreatedAt); n != 0 {
+		return n
+	}
+	return strings.Compare(c.ID, other.ID)
+}
+
 // TaskRepository provides an in-memory storage for tasks.
 type TaskRepository struct {
-	mu    sync.RWMutex
-	tasks map[string]*model.Task
+	newID idgen.Generator
+
+	mu        sync.RWMutex
+	tasks     map[string]*model.Task
+	revisions map[string][]model.Revision
+
+	// titles maps the titles of every owner to their task, nil unless
+	// unique titles are enforced.
+	titles map[titleKey]string
+
+	// Change feed; changed is closed and replaced on every change.
+	changes []model.TaskChange
+	cursor  int64
+	changed chan struct{}
 }
 
-// NewTaskRepository creates a new TaskRepository.
-func NewTaskRepository() *TaskRepository {
-	return &TaskRepository{
-		tasks: make(map[string]*model.Task),
+// NewTaskRepository creates a new TaskRepository generating task IDs with
+// newID. With uniqueTitles, an owner can't have two tasks with the same
+// title; see model.DuplicateTitleError.
+func NewTaskRepository(newID idgen.Generator, uniqueTitles bool) *TaskRepository {
+	r := &TaskRepository{
+		newID:     newID,
+		tasks:     make(map[string]*model.Task),
+		revisions: make(map[string][]model.Revision),
+		changed:   make(chan struct{}),
+	}
+	if uniqueTitles {
+		r.titles = make(map[titleKey]string)
 	}
+	return r
 }
 
 // Create adds a new task to the repository.
 func (r *TaskRepository) Create(ctx context.Context, req *model.CreateTaskRequest) (*model.Task, error) {
 	ctx, span := tracer.Start(ctx, "TaskRepository.Create",
-		trace.WithAttributes(attribute.String("task.title", req.Title)),
+		trace.WithAttributes(telemetry.UserString("task.title", req.Title)),
 	)
 	defer span.End()
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if err := r.validateDependencies(ctx, "", req.DependsOn); err != nil {
+		return nil, err
+	}
+	if err := r.checkTitle(ctx, req.Owner, req.Title, ""); err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	task := &model.Task{
-		ID:          uuid.New().String(),
+		ID:          r.newTaskID(ctx),
 		Title:       req.Title,
 		Description: req.Description,
 		Done:        false,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+		Owner:       req.Owner,
+		Tenant:      req.Tenant,
+		DependsOn:   slices.Clone(req.DependsOn),
+		RemindAt:    req.RemindAt,
+		TemplateID:  req.TemplateID,
+		OriginSpan:  trace.SpanContextFromContext(ctx),
+	}
+	if req.Recurrence != nil {
+		d, _ := time.ParseDuration(req.Recurrence.Interval)
+		task.Recurrence = &model.Recurrence{
+			Interval:  req.Recurrence.Interval,
+			NextRunAt: now.Add(d),
+		}
+		span.SetAttributes(attribute.String("task.recurrence.interval", req.Recurrence.Interval))
+	}
+
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	if IsDryRun(ctx) {
+		return task, nil
 	}
 
 	r.tasks[task.ID] = task
+	r.indexTitle(task)
+	r.appendRevision(ctx, task.ID, model.RevisionCreated, &model.Task{}, task)
+	return task.Clone(), nil
+}
 
-	span.SetAttributes(attribute.String("task.id", task.ID))
-	return task, nil
+// newTaskID returns the ID set by withTaskID or a new one.
+func (r *TaskRepository) newTaskID(ctx context.Context) string {
+	if id, ok := taskIDFromContext(ctx); ok {
+		return id
+	}
+	return r.newID()
 }
 
 // GetByID retrieves a task by its ID.
@@ -70,26 +165,112 @@ func (r *TaskRepository) GetByID(ctx context.Context, id string) (*model.Task, e
 	}
 
 	span.SetAttributes(attribute.Bool("task.found", true))
-	return task, nil
+	return task.Clone(), nil
 }
 
-// List returns all tasks in the repository.
-func (r *TaskRepository) List(ctx context.Context) ([]*model.Task, error) {
-	ctx, span := tracer.Start(ctx, "TaskRepository.List")
+// List returns the tasks in the repository in list order, a page at a
+// time if opts.Limit is set. Archived tasks are excluded unless
+// opts.IncludeArchived is set.
+func (r *TaskRepository) List(ctx context.Context, opts ListOptions) ([]*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.List",
+		trace.WithAttributes(attribute.Bool("task.include_archived", opts.IncludeArchived)),
+	)
 	defer span.End()
 
+	if opts.Assignee != "" {
+		span.SetAttributes(telemetry.UserString("task.assignee", opts.Assignee))
+	}
+	if opts.Limit > 0 {
+		span.SetAttributes(attribute.Int("task.limit", opts.Limit))
+	}
+	span.SetAttributes(attribute.Bool("task.paginated", opts.After != nil))
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	tasks := make([]*model.Task, 0, len(r.tasks))
 	for _, task := range r.tasks {
+		if task.ArchivedAt != nil && !opts.IncludeArchived {
+			continue
+		}
+		if opts.Assignee != "" && task.Assignee != opts.Assignee {
+			continue
+		}
+		if opts.After != nil && CursorOf(task).compare(*opts.After) <= 0 {
+			continue
+		}
 		tasks = append(tasks, task)
 	}
+	slices.SortFunc(tasks, func(a, b *model.Task) int {
+		return CursorOf(a).compare(CursorOf(b))
+	})
+	if opts.Limit > 0 && len(tasks) > opts.Limit {
+		tasks = tasks[:opts.Limit]
+	}
+	for i, task := range tasks {
+		tasks[i] = task.Clone()
+	}
 
 	span.SetAttributes(attribute.Int("task.count", len(tasks)))
 	return tasks, nil
 }
 
+// listIterChunk is the number of tasks ListIter reads per lock acquisition.
+const listIterChunk = 100
+
+// ListIter returns the tasks matching opts one at a time, for result sets
+// too large to hold in a slice. The repository is only locked while a chunk
+// of tasks is read, not while they are consumed, so tasks changed during the
+// iteration may or may not be seen. The span covers the whole iteration.
+func (r *TaskRepository) ListIter(ctx context.Context, opts ListOptions) iter.Seq2[*model.Task, error] {
+	return func(yield func(*model.Task, error) bool) {
+		_, span := tracer.Start(ctx, "TaskRepository.ListIter",
+			trace.WithAttributes(attribute.Bool("task.include_archived", opts.IncludeArchived)),
+		)
+		defer span.End()
+
+		if opts.Assignee != "" {
+			span.SetAttributes(telemetry.UserString("task.assignee", opts.Assignee))
+		}
+
+		r.mu.RLock()
+		ids := slices.Collect(maps.Keys(r.tasks))
+		r.mu.RUnlock()
+
+		count := 0
+		defer func() { span.SetAttributes(attribute.Int("task.count", count)) }()
+
+		chunk := make([]*model.Task, 0, listIterChunk)
+		for batch := range slices.Chunk(ids, listIterChunk) {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			chunk = chunk[:0]
+			r.mu.RLock()
+			for _, id := range batch {
+				task, ok := r.tasks[id]
+				if !ok || task.ArchivedAt != nil && !opts.IncludeArchived {
+					continue
+				}
+				if opts.Assignee != "" && task.Assignee != opts.Assignee {
+					continue
+				}
+				chunk = append(chunk, task.Clone())
+			}
+			r.mu.RUnlock()
+
+			for _, task := range chunk {
+				count++
+				if !yield(task, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
 // Update modifies an existing task.
 func (r *TaskRepository) Update(ctx context.Context, id string, req *model.UpdateTaskRequest) (*model.Task, error) {
 	ctx, span := tracer.Start(ctx, "TaskRepository.Update",
@@ -100,11 +281,40 @@ func (r *TaskRepository) Update(ctx context.Context, id string, req *model.Updat
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	task, ok := r.tasks[id]
+	stored, ok := r.tasks[id]
 	if !ok {
 		span.SetAttributes(attribute.Bool("task.found", false))
 		return nil, model.ErrTaskNotFound
 	}
+	if err := checkLease(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	before := *stored
+	snapshot(ctx, stored)
+
+	// A dry run applies the changes to a copy.
+	task := stored
+	if IsDryRun(ctx) {
+		preview := before
+		task = &preview
+	}
+
+	dependsOn := task.DependsOn
+	if req.DependsOn != nil {
+		if err := r.validateDependencies(ctx, id, req.DependsOn); err != nil {
+			return nil, err
+		}
+		dependsOn = req.DependsOn
+	}
+	if req.Done != nil && *req.Done && r.hasIncomplete(dependsOn) {
+		return nil, model.ErrDependenciesIncomplete
+	}
+	if req.Title != "" && task.ParentID == "" {
+		if err := r.checkTitle(ctx, task.Owner, req.Title, id); err != nil {
+			return nil, err
+		}
+	}
 
 	if req.Title != "" {
 		task.Title = req.Title
@@ -115,13 +325,23 @@ func (r *TaskRepository) Update(ctx context.Context, id string, req *model.Updat
 	if req.Done != nil {
 		task.Done = *req.Done
 	}
+	if req.RemindAt != nil {
+		task.RemindAt = req.RemindAt
+		task.RemindedAt = nil
+	}
+	task.DependsOn = dependsOn
 	task.UpdatedAt = time.Now()
+	if !IsDryRun(ctx) {
+		r.unindexTitle(&before)
+		r.indexTitle(task)
+		r.appendRevision(ctx, id, model.RevisionUpdated, &before, task)
+	}
 
 	span.SetAttributes(attribute.Bool("task.found", true))
-	return task, nil
+	return task.Clone(), nil
 }
 
-// Delete removes a task from the repository.
+// Delete removes a task and its history from the repository.
 func (r *TaskRepository) Delete(ctx context.Context, id string) error {
 	ctx, span := tracer.Start(ctx, "TaskRepository.Delete",
 		trace.WithAttributes(attribute.String("task.id", id)),
@@ -131,19 +351,494 @@ func (r *TaskRepository) Delete(ctx context.Context, id string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, ok := r.tasks[id]; !ok {
+	deleted, ok := r.tasks[id]
+	if !ok {
 		span.SetAttributes(attribute.Bool("task.found", false))
 		return model.ErrTaskNotFound
 	}
+	if err := checkLease(ctx, deleted); err != nil {
+		return err
+	}
+	snapshot(ctx, deleted)
 
-	delete(r.tasks, id)
 	span.SetAttributes(attribute.Bool("task.found", true))
+	if IsDryRun(ctx) {
+		return nil
+	}
+
+	delete(r.tasks, id)
+	r.unindexTitle(deleted)
+	// The deletion still reaches the change feed
+	r.appendRevision(ctx, id, model.RevisionDeleted, deleted, deleted)
+	delete(r.revisions, id)
+
+	// Drop the deleted task from the dependencies of other tasks.
+	for _, task := range r.tasks {
+		for i, dep := range task.DependsOn {
+			if dep == id {
+				before := *task
+				task.DependsOn = append(task.DependsOn[:i:i], task.DependsOn[i+1:]...)
+				r.appendRevision(ctx, task.ID, model.RevisionUpdated, &before, task)
+				break
+			}
+		}
+	}
 	return nil
 }
 
+// SetArchived archives or unarchives a task. Archiving an archived task
+// keeps its original ArchivedAt.
+func (r *TaskRepository) SetArchived(ctx context.Context, id string, archived bool) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.SetArchived",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.Bool("task.archived", archived),
+		),
+	)
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.tasks[id]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return nil, model.ErrTaskNotFound
+	}
+	if err := checkLease(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	before := *stored
+	snapshot(ctx, stored)
+
+	// A dry run applies the changes to a copy.
+	task := stored
+	if IsDryRun(ctx) {
+		preview := before
+		task = &preview
+	}
+
+	action := ""
+	switch {
+	case archived && task.ArchivedAt == nil:
+		now := time.Now()
+		task.ArchivedAt = &now
+		task.UpdatedAt = now
+		action = model.RevisionArchived
+	case !archived && task.ArchivedAt != nil:
+		task.ArchivedAt = nil
+		task.UpdatedAt = time.Now()
+		action = model.RevisionUnarchived
+	}
+	if action != "" && !IsDryRun(ctx) {
+		r.appendRevision(ctx, id, action, &before, task)
+	}
+
+	span.SetAttributes(attribute.Bool("task.found", true))
+	return task.Clone(), nil
+}
+
+// Assign assigns a task to assignee, or unassigns it if assignee is empty.
+func (r *TaskRepository) Assign(ctx context.Context, id, assignee string) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.Assign",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			telemetry.UserString("task.assignee", assignee),
+		),
+	)
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.tasks[id]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return nil, model.ErrTaskNotFound
+	}
+	if err := checkLease(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	before := *stored
+	snapshot(ctx, stored)
+
+	// A dry run applies the changes to a copy.
+	task := stored
+	if IsDryRun(ctx) {
+		preview := before
+		task = &preview
+	}
+
+	if task.Assignee != assignee {
+		task.Assignee = assignee
+		task.UpdatedAt = time.Now()
+		if !IsDryRun(ctx) {
+			r.appendRevision(ctx, id, model.RevisionAssigned, &before, task)
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("task.found", true))
+	return task.Clone(), nil
+}
+
+// CountByState returns the number of tasks in each lifecycle state.
+func (r *TaskRepository) CountByState() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[string]int64{
+		model.StateOpen:     0,
+		model.StateDone:     0,
+		model.StateArchived: 0,
+	}
+	for _, task := range r.tasks {
+		counts[task.State()]++
+	}
+	return counts
+}
+
+// CountByOwner returns the number of tasks created by owner.
+func (r *TaskRepository) CountByOwner(owner string) int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var n int64
+	for _, task := range r.tasks {
+		if task.Owner == owner {
+			n++
+		}
+	}
+	return n
+}
+
+// CountByTenant returns the number of tasks belonging to tenant.
+func (r *TaskRepository) CountByTenant(tenant string) int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var n int64
+	for _, task := range r.tasks {
+		if task.Tenant == tenant {
+			n++
+		}
+	}
+	return n
+}
+
+// OpenByAssignee returns the number of open tasks per assignee. Unassigned
+// tasks are not counted.
+func (r *TaskRepository) OpenByAssignee() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, task := range r.tasks {
+		if task.Assignee != "" && task.State() == model.StateOpen {
+			counts[task.Assignee]++
+		}
+	}
+	return counts
+}
+
 // Count returns the current number of tasks.
 func (r *TaskRepository) Count() int64 {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	return int64(len(r.tasks))
 }
+
+// DueRecurring returns the recurring tasks whose next run is at or before now.
+func (r *TaskRepository) DueRecurring(ctx context.Context, now time.Time) ([]*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.DueRecurring")
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tasks := make([]*model.Task, 0)
+	for _, task := range r.tasks {
+		if task.Recurrence != nil && task.ArchivedAt == nil && !task.Recurrence.NextRunAt.After(now) {
+			tasks = append(tasks, task.Clone())
+		}
+	}
+
+	span.SetAttributes(attribute.Int("task.count", len(tasks)))
+	return tasks, nil
+}
+
+// Materialize creates the next occurrence of a recurring task and advances
+// its schedule past now. It returns the occurrence and the number of runs
+// that were missed because the schedule fell behind by more than one interval.
+func (r *TaskRepository) Materialize(ctx context.Context, id string, now time.Time) (*model.Task, int, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.Materialize",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	parent, ok := r.tasks[id]
+	if !ok || parent.Recurrence == nil {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return nil, 0, model.ErrTaskNotFound
+	}
+
+	occurrence := &model.Task{
+		ID:          r.newTaskID(ctx),
+		Title:       parent.Title,
+		Description: parent.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Owner:       parent.Owner,
+		Tenant:      parent.Tenant,
+		ParentID:    parent.ID,
+		OriginSpan:  parent.OriginSpan,
+	}
+	r.tasks[occurrence.ID] = occurrence
+	r.appendRevision(ctx, occurrence.ID, model.RevisionCreated, &model.Task{}, occurrence)
+
+	// Advance the schedule to the first run after now, counting skipped runs.
+	every := parent.Recurrence.Every()
+	next := parent.Recurrence.NextRunAt.Add(every)
+	missed := 0
+	for !next.After(now) {
+		next = next.Add(every)
+		missed++
+	}
+	parent.Recurrence.NextRunAt = next
+
+	span.SetAttributes(
+		attribute.String("task.occurrence.id", occurrence.ID),
+		attribute.Int("task.recurrence.missed", missed),
+	)
+	return occurrence.Clone(), missed, nil
+}
+
+// DueReminders returns the open tasks whose reminder is due at or before now
+// and was not sent yet.
+func (r *TaskRepository) DueReminders(ctx context.Context, now time.Time) ([]*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.DueReminders")
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tasks := make([]*model.Task, 0)
+	for _, task := range r.tasks {
+		if task.RemindAt != nil && task.RemindedAt == nil && task.State() == model.StateOpen && !task.RemindAt.After(now) {
+			tasks = append(tasks, task.Clone())
+		}
+	}
+
+	span.SetAttributes(attribute.Int("task.count", len(tasks)))
+	return tasks, nil
+}
+
+// MarkReminded records that the reminder of a task was handled at at.
+func (r *TaskRepository) MarkReminded(ctx context.Context, id string, at time.Time) error {
+	_, span := tracer.Start(ctx, "TaskRepository.MarkReminded",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[id]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return model.ErrTaskNotFound
+	}
+	task.RemindedAt = &at
+
+	span.SetAttributes(attribute.Bool("task.found", true))
+	return nil
+}
+
+// Dependencies returns the dependencies of a task and whether it is blocked
+// by any of them being incomplete.
+func (r *TaskRepository) Dependencies(ctx context.Context, id string) (*model.DependencyStatus, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.Dependencies",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	task, ok := r.tasks[id]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return nil, model.ErrTaskNotFound
+	}
+
+	status := &model.DependencyStatus{
+		TaskID:       id,
+		Blocked:      r.hasIncomplete(task.DependsOn),
+		Dependencies: make([]*model.Task, 0, len(task.DependsOn)),
+	}
+	for _, dep := range task.DependsOn {
+		if t, ok := r.tasks[dep]; ok {
+			status.Dependencies = append(status.Dependencies, t.Clone())
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Bool("task.found", true),
+		attribute.Bool("task.blocked", status.Blocked),
+		attribute.Int("task.dependency_count", len(status.Dependencies)),
+	)
+	return status, nil
+}
+
+// validateDependencies checks that all dependencies exist and that adding
+// them to the task with the given ID does not introduce a cycle.
+// An empty id validates dependencies for a task that does not exist yet.
+// The caller must hold the lock.
+func (r *TaskRepository) validateDependencies(ctx context.Context, id string, deps []string) error {
+	_, span := tracer.Start(ctx, "TaskRepository.ValidateDependencies",
+		trace.WithAttributes(attribute.Int("task.dependency_count", len(deps))),
+	)
+	defer span.End()
+
+	edges := 0
+	for _, task := range r.tasks {
+		edges += len(task.DependsOn)
+	}
+	span.SetAttributes(
+		attribute.Int("graph.nodes", len(r.tasks)),
+		attribute.Int("graph.edges", edges),
+	)
+
+	for _, dep := range deps {
+		if _, ok := r.tasks[dep]; !ok {
+			span.SetAttributes(attribute.String("graph.missing_dependency", dep))
+			return model.ErrDependencyNotFound
+		}
+	}
+
+	if id == "" {
+		return nil
+	}
+
+	// A cycle exists if the task is reachable from any of its new dependencies.
+	visited := make(map[string]bool)
+	var reaches func(from string) bool
+	reaches = func(from string) bool {
+		if from == id {
+			return true
+		}
+		if visited[from] {
+			return false
+		}
+		visited[from] = true
+		task, ok := r.tasks[from]
+		if !ok {
+			return false
+		}
+		for _, next := range task.DependsOn {
+			if reaches(next) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, dep := range deps {
+		if reaches(dep) {
+			span.SetAttributes(attribute.Bool("graph.cycle", true))
+			return model.ErrDependencyCycle
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("graph.cycle", false))
+	return nil
+}
+
+// hasIncomplete reports whether any of the given tasks is not done.
+// The caller must hold the lock.
+func (r *TaskRepository) hasIncomplete(ids []string) bool {
+	for _, id := range ids {
+		if t, ok := r.tasks[id]; ok && !t.Done {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAttachment records attachment metadata on a task.
+func (r *TaskRepository) AddAttachment(ctx context.Context, taskID string, att model.Attachment) error {
+	ctx, span := tracer.Start(ctx, "TaskRepository.AddAttachment",
+		trace.WithAttributes(
+			attribute.String("task.id", taskID),
+			attribute.String("attachment.id", att.ID),
+		),
+	)
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[taskID]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return model.ErrTaskNotFound
+	}
+	if err := checkLease(ctx, task); err != nil {
+		return err
+	}
+
+	before := *task
+	snapshot(ctx, task)
+
+	task.Attachments = append(task.Attachments, att)
+	task.UpdatedAt = time.Now()
+	r.appendRevision(ctx, taskID, model.RevisionAttached, &before, task)
+
+	span.SetAttributes(attribute.Bool("task.found", true))
+	return nil
+}
+
+// GetAttachment returns the metadata of an attachment of a task.
+func (r *TaskRepository) GetAttachment(ctx context.Context, taskID, attachmentID string) (*model.Attachment, error) {
+	ctx, span := tracer.Start(ctx, "TaskRepository.GetAttachment",
+		trace.WithAttributes(
+			attribute.String("task.id", taskID),
+			attribute.String("attachment.id", attachmentID),
+		),
+	)
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	task, ok := r.tasks[taskID]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.found", false))
+		return nil, model.ErrTaskNotFound
+	}
+
+	for _, att := range task.Attachments {
+		if att.ID == attachmentID {
+			span.SetAttributes(attribute.Bool("attachment.found", true))
+			return &att, nil
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("attachment.found", false))
+	return nil, model.ErrAttachmentNotFound
+}
+
+// WithTx runs fn on r. The in-memory repository has no transactions, so
+// the writes of a failing fn are not rolled back.
+func (r *TaskRepository) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return fn(r)
+}
+
+// Transactional returns false: the in-memory repository has no
+// transactions.
+func (r *TaskRepository) Transactional() bool {
+	return false
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/idgen"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+)
+
+// newTestRepository returns a repository with a task and a second task
+// depending on it, both with a reminder.
+func newTestRepository(t *testing.T) (*TaskRepository, *model.Task, *model.Task) {
+	t.Helper()
+	ctx := context.Background()
+	newID, err := idgen.New(idgen.UUIDv4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewTaskRepository(newID, false)
+
+	remindAt := time.Now().Add(time.Hour)
+	dep, err := repo.Create(ctx, &model.CreateTaskRequest{Title: "dependency", RemindAt: &remindAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := repo.Create(ctx, &model.CreateTaskRequest{Title: "task", DependsOn: []string{dep.ID}, RemindAt: &remindAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, task, dep
+}
+
+// mutate changes every field of task that is shared by reference.
+func mutate(task *model.Task) {
+	task.Title = "mutated"
+	if task.RemindAt != nil {
+		*task.RemindAt = time.Time{}
+	}
+	if len(task.DependsOn) > 0 {
+		task.DependsOn[0] = "mutated"
+	}
+	task.DependsOn = append(task.DependsOn, "appended")
+}
+
+// TestReturnedTasksAreCopies mutates the tasks returned by every getter
+// while the stored tasks are updated and read concurrently. Run with -race,
+// a getter returning a stored task is reported as a data race; without it,
+// the checks of the stored tasks below catch the mutations.
+func TestReturnedTasksAreCopies(t *testing.T) {
+	ctx := context.Background()
+	repo, task, dep := newTestRepository(t)
+
+	getters := map[string]func() []*model.Task{
+		"Create": func() []*model.Task {
+			created, err := repo.Create(ctx, &model.CreateTaskRequest{Title: "created", DependsOn: []string{dep.ID}})
+			if err != nil {
+				t.Error(err)
+			}
+			return []*model.Task{created}
+		},
+		"GetByID": func() []*model.Task {
+			got, err := repo.GetByID(ctx, task.ID)
+			if err != nil {
+				t.Error(err)
+			}
+			return []*model.Task{got}
+		},
+		"List": func() []*model.Task {
+			tasks, err := repo.List(ctx, ListOptions{})
+			if err != nil {
+				t.Error(err)
+			}
+			return tasks
+		},
+		"ListIter": func() []*model.Task {
+			var tasks []*model.Task
+			for got, err := range repo.ListIter(ctx, ListOptions{}) {
+				if err != nil {
+					t.Error(err)
+					break
+				}
+				tasks = append(tasks, got)
+			}
+			return tasks
+		},
+		"Dependencies": func() []*model.Task {
+			status, err := repo.Dependencies(ctx, task.ID)
+			if err != nil {
+				t.Error(err)
+			}
+			return status.Dependencies
+		},
+		"Update": func() []*model.Task {
+			updated, err := repo.Update(ctx, dep.ID, &model.UpdateTaskRequest{Description: "updated"})
+			if err != nil {
+				t.Error(err)
+			}
+			return []*model.Task{updated}
+		},
+	}
+
+	for name, get := range getters {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for range 8 {
+				wg.Go(func() {
+					for range 20 {
+						for _, got := range get() {
+							mutate(got)
+						}
+					}
+				})
+			}
+			// Read the stored tasks while the copies are mutated
+			wg.Go(func() {
+				for range 20 {
+					if _, err := repo.Update(ctx, task.ID, &model.UpdateTaskRequest{Description: "concurrent"}); err != nil {
+						t.Error(err)
+					}
+					if _, err := repo.List(ctx, ListOptions{}); err != nil {
+						t.Error(err)
+					}
+				}
+			})
+			wg.Wait()
+
+			for _, id := range []string{task.ID, dep.ID} {
+				stored, err := repo.GetByID(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stored.Title == "mutated" {
+					t.Errorf("title of stored task %s was changed through a returned task", id)
+				}
+				if stored.RemindAt == nil || stored.RemindAt.IsZero() {
+					t.Errorf("reminder of stored task %s was changed through a returned task", id)
+				}
+			}
+			stored, err := repo.GetByID(ctx, task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored.DependsOn) != 1 || stored.DependsOn[0] != dep.ID {
+				t.Errorf("dependencies of stored task = %v, want [%s]", stored.DependsOn, dep.ID)
+			}
+		})
+	}
+}
+
+// TestListOrderByID checks that with time-sortable ID strategies the list
+// order, by creation time and then ID, is also the order of the IDs.
+func TestListOrderByID(t *testing.T) {
+	tests := []struct {
+		strategy string
+		// resolution is the time resolution of the strategy's IDs; IDs
+		// generated within it are in random order.
+		resolution time.Duration
+	}{
+		{idgen.UUIDv7, time.Millisecond},
+		{idgen.ULID, time.Millisecond},
+		{idgen.KSUID, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			newID, err := idgen.New(tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo := NewTaskRepository(newID, false)
+
+			const n = 4
+			for i := range n {
+				if i > 0 {
+					time.Sleep(tt.resolution + time.Millisecond)
+				}
+				if _, err := repo.Create(ctx, &model.CreateTaskRequest{Title: "task"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tasks, err := repo.List(ctx, ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != n {
+				t.Fatalf("got %d tasks, want %d", len(tasks), n)
+			}
+			if !slices.IsSortedFunc(tasks, func(a, b *model.Task) int { return strings.Compare(a.ID, b.ID) }) {
+				ids := make([]string, len(tasks))
+				for i, task := range tasks {
+					ids[i] = task.ID
+				}
+				t.Errorf("list order is not the order of the IDs: %v", ids)
+			}
+
+			// Paging by cursor must see the tasks in the same order
+			var paged []string
+			opts := ListOptions{Limit: 1}
+			for {
+				page, err := repo.List(ctx, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page) == 0 {
+					break
+				}
+				last := page[len(page)-1]
+				paged = append(paged, last.ID)
+				opts.After = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+			}
+			if !slices.IsSorted(paged) || len(paged) != n {
+				t.Errorf("paged IDs = %v, want %d IDs in order", paged, n)
+			}
+		})
+	}
+}
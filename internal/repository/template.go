@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TemplateRepository provides an in-memory storage for task templates.
+type TemplateRepository struct {
+	mu        sync.RWMutex
+	templates map[string]*model.TaskTemplate
+}
+
+// NewTemplateRepository creates a new TemplateRepository.
+func NewTemplateRepository() *TemplateRepository {
+	return &TemplateRepository{
+		templates: make(map[string]*model.TaskTemplate),
+	}
+}
+
+// Create adds a new template to the repository.
+func (r *TemplateRepository) Create(ctx context.Context, req *model.CreateTemplateRequest) (*model.TaskTemplate, error) {
+	_, span := tracer.Start(ctx, "TemplateRepository.Create")
+	defer span.End()
+
+	tmpl := &model.TaskTemplate{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		Title:       req.Title,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		Variables:   model.TemplateVariables(req.Title, req.Description),
+		Owner:       req.Owner,
+		Tenant:      req.Tenant,
+	}
+	span.SetAttributes(attribute.String("task.template.id", tmpl.ID))
+	if IsDryRun(ctx) {
+		return tmpl, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[tmpl.ID] = tmpl
+	return tmpl, nil
+}
+
+// GetByID retrieves a template by its ID.
+func (r *TemplateRepository) GetByID(ctx context.Context, id string) (*model.TaskTemplate, error) {
+	_, span := tracer.Start(ctx, "TemplateRepository.GetByID",
+		trace.WithAttributes(attribute.String("task.template.id", id)),
+	)
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tmpl, ok := r.templates[id]
+	if !ok {
+		span.SetAttributes(attribute.Bool("task.template.found", false))
+		return nil, model.ErrTemplateNotFound
+	}
+
+	span.SetAttributes(attribute.Bool("task.template.found", true))
+	return tmpl, nil
+}
+
+// List returns all templates ordered by name.
+func (r *TemplateRepository) List(ctx context.Context) ([]*model.TaskTemplate, error) {
+	_, span := tracer.Start(ctx, "TemplateRepository.List")
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	templates := make([]*model.TaskTemplate, 0, len(r.templates))
+	for _, tmpl := range r.templates {
+		templates = append(templates, tmpl)
+	}
+	slices.SortFunc(templates, func(a, b *model.TaskTemplate) int {
+		if n := strings.Compare(a.Name, b.Name); n != 0 {
+			return n
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	span.SetAttributes(attribute.Int("task.template.count", len(templates)))
+	return templates, nil
+}
+
+// Delete removes a template. Tasks instantiated from it are kept.
+func (r *TemplateRepository) Delete(ctx context.Context, id string) error {
+	_, span := tracer.Start(ctx, "TemplateRepository.Delete",
+		trace.WithAttributes(attribute.String("task.template.id", id)),
+	)
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.templates[id]; !ok {
+		return model.ErrTemplateNotFound
+	}
+	if IsDryRun(ctx) {
+		return nil
+	}
+	delete(r.templates, id)
+	return nil
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// titleKey is a title of an owner in the unique title index. Titles are
+// compared case-insensitively, ignoring surrounding space.
+type titleKey struct {
+	owner string
+	title string
+}
+
+func newTitleKey(owner, title string) titleKey {
+	return titleKey{owner: owner, title: strings.ToLower(strings.TrimSpace(title))}
+}
+
+// checkTitle returns a model.DuplicateTitleError if unique titles are
+// enforced and a task other than id already has title for owner. r.mu must
+// be held.
+func (r *TaskRepository) checkTitle(ctx context.Context, owner, title, id string) error {
+	if r.titles == nil {
+		return nil
+	}
+	existing, ok := r.titles[newTitleKey(owner, title)]
+	if !ok || existing == id {
+		return nil
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("task.duplicate_of", existing))
+	return &model.DuplicateTitleError{TaskID: existing}
+}
+
+// indexTitle adds task to the unique title index. Occurrences of recurring
+// tasks share the title of their parent and aren't indexed. r.mu must be
+// held.
+func (r *TaskRepository) indexTitle(task *model.Task) {
+	if r.titles != nil && task.ParentID == "" {
+		r.titles[newTitleKey(task.Owner, task.Title)] = task.ID
+	}
+}
+
+// unindexTitle removes task from the unique title index. r.mu must be held.
+func (r *TaskRepository) unindexTitle(task *model.Task) {
+	key := newTitleKey(task.Owner, task.Title)
+	if r.titles != nil && r.titles[key] == task.ID {
+		delete(r.titles, key)
+	}
+}
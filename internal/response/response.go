@@ -0,0 +1,96 @@
+package response
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/i18n"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jsoncodec"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. TraceID is an extension
+// member that lets clients correlate an error response with its trace, and
+// TraceURL one linking to the trace in a trace UI (see
+// WithTraceURLTemplate).
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+	TraceURL string `json:"trace_url,omitempty"`
+}
+
+type traceURLTemplateKey struct{}
+
+// WithTraceURLTemplate returns a context in which problems link to their
+// trace with template, in which {trace_id} is replaced by the trace ID.
+func WithTraceURLTemplate(ctx context.Context, template string) context.Context {
+	return context.WithValue(ctx, traceURLTemplateKey{}, template)
+}
+
+// JSON writes data as a JSON response with the given status. The encoding
+// duration and size are recorded with the jsoncodec metrics of the request,
+// if any. If data can't be encoded, a 500 problem is written instead.
+func JSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	if data == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		return
+	}
+
+	start := time.Now()
+	body, err := jsoncodec.Marshal(data)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+	jsoncodec.Record(r.Context(), jsoncodec.OperationEncode, start, len(body))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// Error writes a problem+json response for the given status and detail.
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	WriteProblem(w, NewProblem(r, status, detail))
+}
+
+// NewProblem builds a Problem for the request, filling in the instance
+// from the request path and the trace ID from the active span, with a link
+// to the trace if it is sampled and the request context has a trace URL
+// template. The title and detail are translated into the locale of the
+// request (see i18n.FromContext).
+func NewProblem(r *http.Request, status int, detail string) Problem {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    i18n.T(r.Context(), http.StatusText(status)),
+		Status:   status,
+		Detail:   i18n.T(r.Context(), detail),
+		Instance: r.URL.Path,
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		p.TraceID = sc.TraceID().String()
+		// Unsampled traces aren't exported, so there is nothing to link to
+		if template, ok := r.Context().Value(traceURLTemplateKey{}).(string); ok && sc.IsSampled() {
+			p.TraceURL = strings.ReplaceAll(template, "{trace_id}", p.TraceID)
+		}
+	}
+	return p
+}
+
+// WriteProblem writes p as a problem+json response.
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	body, _ := jsoncodec.Marshal(p)
+	w.Write(append(body, '\n'))
+}
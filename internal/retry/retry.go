@@ -0,0 +1,134 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/retry")
+
+// Policy configures Do.
+type Policy struct {
+	// MaxAttempts is the number of attempts including the first one. Values
+	// below 1 make a single attempt.
+	MaxAttempts int
+	// Backoff is the wait before the first retry. It is multiplied by
+	// Multiplier (2 if unset) after every retry, up to MaxBackoff if set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Multiplier float64
+	// Jitter randomizes each wait by up to this fraction in either
+	// direction, e.g. 0.2 for ±20%, so callers failing together do not
+	// retry in lockstep.
+	Jitter float64
+	// Retryable reports whether err is worth another attempt. If nil, every
+	// error not marked with Permanent is retried.
+	Retryable func(err error) bool
+}
+
+// permanentError marks an error as not retryable.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not retryable, so Do returns it without further
+// attempts. It returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// RetryableStatus reports whether an HTTP response with status code is worth
+// retrying: timeouts, rate limiting and server errors are, other client
+// errors are not.
+func RetryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, ctx is done
+// or p.MaxAttempts attempts were made, and returns the last error. Each
+// attempt runs in a child span named name with the attempt number and, if
+// another attempt follows, the time slept before it.
+func Do(ctx context.Context, name string, p Policy, fn func(ctx context.Context) error) error {
+	attempts := max(p.MaxAttempts, 1)
+	wait := p.Backoff
+
+	for attempt := 1; ; attempt++ {
+		ctx, span := tracer.Start(ctx, name, trace.WithAttributes(
+			attribute.Int("retry.attempt", attempt),
+			attribute.Int("retry.max_attempts", attempts),
+		))
+
+		err := fn(ctx)
+		if err == nil {
+			span.End()
+			return nil
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		retryable := p.retryable(err)
+		span.SetAttributes(attribute.Bool("retry.retryable", retryable))
+		if !retryable || attempt == attempts || ctx.Err() != nil {
+			span.End()
+			return err
+		}
+
+		sleep := p.jitter(wait)
+		span.SetAttributes(attribute.Int64("retry.sleep_ms", sleep.Milliseconds()))
+		span.End()
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		wait = p.next(wait)
+	}
+}
+
+func (p Policy) retryable(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// next returns the wait before the retry following one after wait.
+func (p Policy) next(wait time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	wait = time.Duration(float64(wait) * multiplier)
+	if p.MaxBackoff > 0 {
+		wait = min(wait, p.MaxBackoff)
+	}
+	return wait
+}
+
+// jitter randomizes wait by up to p.Jitter in either direction.
+func (p Policy) jitter(wait time.Duration) time.Duration {
+	if p.Jitter <= 0 || wait <= 0 {
+		return wait
+	}
+	factor := 1 + p.Jitter*(2*rand.Float64()-1)
+	return max(time.Duration(float64(wait)*factor), 0)
+}
@@ -0,0 +1,118 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/saga")
+
+// Outcomes of a saga run.
+const (
+	OutcomeCompleted          = "completed"
+	OutcomeCompensated        = "compensated"
+	OutcomeCompensationFailed = "compensation_failed"
+)
+
+// Step is a step of a saga. Compensate undoes Do when a later step fails;
+// it is nil for steps that need no undo.
+type Step struct {
+	Name       string
+	Do         func(ctx context.Context) error
+	Compensate func(ctx context.Context) error
+}
+
+// Coordinator runs sagas: it runs their steps in order and, when a step
+// fails, compensates the completed steps in reverse order. Every run, step
+// and compensation gets a span, and runs are counted by outcome.
+type Coordinator struct {
+	executions metric.Int64Counter
+}
+
+// NewCoordinator creates a new Coordinator.
+func NewCoordinator(meter metric.Meter) (*Coordinator, error) {
+	executions, err := meter.Int64Counter(
+		"saga_executions_total",
+		metric.WithDescription("Total number of saga runs by outcome"),
+		metric.WithUnit("{saga}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create saga executions counter: %w", err)
+	}
+	return &Coordinator{executions: executions}, nil
+}
+
+// Run runs the saga name. It returns the error of the failed step, joined
+// with any compensation errors. Compensations run even if ctx is cancelled.
+func (c *Coordinator) Run(ctx context.Context, name string, steps ...Step) error {
+	ctx, span := tracer.Start(ctx, "Saga.Run",
+		trace.WithAttributes(attribute.String("saga.name", name)),
+	)
+	defer span.End()
+
+	outcome, err := c.run(ctx, steps)
+
+	span.SetAttributes(attribute.String("saga.outcome", outcome))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "saga "+outcome)
+	}
+	c.executions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("saga.name", name),
+		attribute.String("saga.outcome", outcome),
+	))
+	return err
+}
+
+func (c *Coordinator) run(ctx context.Context, steps []Step) (string, error) {
+	for i, step := range steps {
+		err := runStep(ctx, "Saga.Step", step.Name, step.Do)
+		if err == nil {
+			continue
+		}
+
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("saga.failed_step", step.Name))
+		if compErr := compensate(context.WithoutCancel(ctx), steps[:i]); compErr != nil {
+			return OutcomeCompensationFailed, errors.Join(err, compErr)
+		}
+		return OutcomeCompensated, err
+	}
+	return OutcomeCompleted, nil
+}
+
+// compensate undoes the completed steps in reverse order. A failing
+// compensation doesn't stop the others.
+func compensate(ctx context.Context, completed []Step) error {
+	var errs []error
+	for i := len(completed) - 1; i >= 0; i-- {
+		step := completed[i]
+		if step.Compensate == nil {
+			continue
+		}
+		if err := runStep(ctx, "Saga.Compensate", step.Name, step.Compensate); err != nil {
+			errs = append(errs, fmt.Errorf("failed to compensate %s: %w", step.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func runStep(ctx context.Context, spanName, step string, fn func(context.Context) error) error {
+	ctx, span := tracer.Start(ctx, spanName,
+		trace.WithAttributes(attribute.String("saga.step", step)),
+	)
+	defer span.End()
+
+	if err := fn(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/scheduler")
+
+// RecurringScheduler periodically materializes occurrences of recurring tasks.
+type RecurringScheduler struct {
+	repo     repository.Repository
+	logger   *slog.Logger
+	interval time.Duration
+
+	scheduledRuns metric.Int64Counter
+	missedRuns    metric.Int64Counter
+}
+
+// NewRecurringScheduler creates a new RecurringScheduler that checks for due
+// tasks every interval.
+func NewRecurringScheduler(repo repository.Repository, logger *slog.Logger, meter metric.Meter, interval time.Duration) (*RecurringScheduler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid recurring scheduler interval %s", interval)
+	}
+	s := &RecurringScheduler{
+		repo:     repo,
+		logger:   logger,
+		interval: interval,
+	}
+
+	var err error
+
+	s.scheduledRuns, err = meter.Int64Counter(
+		"recurring_runs_scheduled_total",
+		metric.WithDescription("Total number of recurring task occurrences materialized"),
+		metric.WithUnit("{run}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheduled runs counter: %w", err)
+	}
+
+	s.missedRuns, err = meter.Int64Counter(
+		"recurring_runs_missed_total",
+		metric.WithDescription("Total number of recurring task runs skipped because the scheduler fell behind"),
+		metric.WithUnit("{run}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create missed runs counter: %w", err)
+	}
+
+	return s, nil
+}
+
+// Run ticks until ctx is cancelled.
+func (s *RecurringScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.logger.InfoContext(ctx, "recurring scheduler started", slog.Duration("interval", s.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("recurring scheduler stopped")
+			return
+		case now := <-ticker.C:
+			s.tick(ctx, now)
+		}
+	}
+}
+
+func (s *RecurringScheduler) tick(ctx context.Context, now time.Time) {
+	ctx, span := tracer.Start(ctx, "RecurringScheduler.Tick",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+	)
+	defer span.End()
+
+	ctx = repository.AsInternal(repository.WithActor(ctx, "scheduler"))
+
+	due, err := s.repo.DueRecurring(ctx, now)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to list due tasks")
+		s.logger.ErrorContext(ctx, "failed to list due recurring tasks", slog.Any("error", err))
+		return
+	}
+	span.SetAttributes(attribute.Int("scheduler.due_count", len(due)))
+
+	for _, task := range due {
+		s.materialize(ctx, task.ID, task.OriginSpan, now)
+	}
+}
+
+func (s *RecurringScheduler) materialize(ctx context.Context, id string, origin trace.SpanContext, now time.Time) {
+	opts := []trace.SpanStartOption{
+		trace.WithAttributes(attribute.String("task.id", id)),
+	}
+	if origin.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{
+			SpanContext: origin,
+			Attributes:  []attribute.KeyValue{attribute.String("link.type", "origin")},
+		}))
+	}
+
+	ctx, span := tracer.Start(ctx, "RecurringScheduler.Materialize", opts...)
+	defer span.End()
+
+	occurrence, missed, err := s.repo.Materialize(ctx, id, now)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to materialize occurrence")
+		s.logger.ErrorContext(ctx, "failed to materialize recurring task", slog.String("id", id), slog.Any("error", err))
+		return
+	}
+
+	s.scheduledRuns.Add(ctx, 1)
+	if missed > 0 {
+		s.missedRuns.Add(ctx, int64(missed))
+		s.logger.WarnContext(ctx, "recurring task runs missed", slog.String("id", id), slog.Int("missed", missed))
+	}
+
+	span.SetAttributes(
+		attribute.String("task.occurrence.id", occurrence.ID),
+		attribute.Int("scheduler.missed_runs", missed),
+	)
+	s.logger.InfoContext(ctx, "recurring task materialized",
+		slog.String("id", id),
+		slog.String("occurrence_id", occurrence.ID),
+	)
+}
@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/reminder"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/retry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReminderScheduler periodically delivers due task reminders, retrying
+// failed deliveries with exponential backoff.
+type ReminderScheduler struct {
+	repo     repository.Repository
+	sender   reminder.Sender
+	logger   *slog.Logger
+	interval time.Duration
+	retry    retry.Policy
+
+	deliveries metric.Int64Counter
+	retries    metric.Int64Counter
+}
+
+// NewReminderScheduler creates a new ReminderScheduler that checks for due
+// reminders every interval and retries each delivery according to policy.
+func NewReminderScheduler(repo repository.Repository, sender reminder.Sender, logger *slog.Logger, meter metric.Meter, interval time.Duration, policy retry.Policy) (*ReminderScheduler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid reminder scheduler interval %s", interval)
+	}
+	s := &ReminderScheduler{
+		repo:     repo,
+		sender:   sender,
+		logger:   logger,
+		interval: interval,
+		retry:    policy,
+	}
+
+	var err error
+
+	s.deliveries, err = meter.Int64Counter(
+		"reminder_deliveries_total",
+		metric.WithDescription("Total number of task reminders delivered or given up on"),
+		metric.WithUnit("{reminder}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reminder deliveries counter: %w", err)
+	}
+
+	s.retries, err = meter.Int64Counter(
+		"reminder_delivery_retries_total",
+		metric.WithDescription("Total number of retried task reminder deliveries"),
+		metric.WithUnit("{retry}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reminder retries counter: %w", err)
+	}
+
+	return s, nil
+}
+
+// Run ticks until ctx is cancelled.
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.logger.InfoContext(ctx, "reminder scheduler started",
+		slog.Duration("interval", s.interval),
+		slog.String("channel", s.sender.Channel()),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("reminder scheduler stopped")
+			return
+		case now := <-ticker.C:
+			s.tick(ctx, now)
+		}
+	}
+}
+
+func (s *ReminderScheduler) tick(ctx context.Context, now time.Time) {
+	ctx, span := tracer.Start(ctx, "ReminderScheduler.Tick",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+	)
+	defer span.End()
+
+	ctx = repository.AsInternal(repository.WithActor(ctx, "scheduler"))
+
+	due, err := s.repo.DueReminders(ctx, now)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to list due reminders")
+		s.logger.ErrorContext(ctx, "failed to list due reminders", slog.Any("error", err))
+		return
+	}
+	span.SetAttributes(attribute.Int("scheduler.due_count", len(due)))
+
+	for _, task := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.deliver(ctx, task)
+	}
+}
+
+// deliver sends the reminder of task in a span linked to the request that
+// created it. The reminder is marked as handled even if all attempts fail,
+// so it is not delivered again on every tick.
+func (s *ReminderScheduler) deliver(ctx context.Context, task *model.Task) {
+	opts := []trace.SpanStartOption{
+		trace.WithAttributes(
+			attribute.String("task.id", task.ID),
+			attribute.String("reminder.channel", s.sender.Channel()),
+		),
+	}
+	if task.OriginSpan.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{
+			SpanContext: task.OriginSpan,
+			Attributes:  []attribute.KeyValue{attribute.String("link.type", "origin")},
+		}))
+	}
+
+	ctx, span := tracer.Start(ctx, "ReminderScheduler.Deliver", opts...)
+	defer span.End()
+
+	attempts, err := s.send(ctx, task)
+	span.SetAttributes(attribute.Int("reminder.attempts", attempts))
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "reminder delivery failed")
+		s.logger.ErrorContext(ctx, "failed to deliver task reminder",
+			slog.String("id", task.ID),
+			slog.Int("attempts", attempts),
+			slog.Any("error", err),
+		)
+	} else {
+		s.logger.InfoContext(ctx, "task reminder delivered", slog.String("id", task.ID), slog.Int("attempts", attempts))
+	}
+	s.deliveries.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("reminder.channel", s.sender.Channel()),
+		attribute.String("result", result),
+	))
+
+	if ctx.Err() != nil {
+		// Shutting down; leave the reminder due for the next run.
+		return
+	}
+	if err := s.repo.MarkReminded(ctx, task.ID, time.Now()); err != nil {
+		s.logger.ErrorContext(ctx, "failed to mark task reminded", slog.String("id", task.ID), slog.Any("error", err))
+	}
+}
+
+// send tries to deliver the reminder according to the retry policy, with a
+// span per attempt, and returns the number of attempts made and the last
+// error.
+func (s *ReminderScheduler) send(ctx context.Context, task *model.Task) (int, error) {
+	attempts := 0
+	err := retry.Do(ctx, "ReminderScheduler.Send", s.retry, func(ctx context.Context) error {
+		attempts++
+		if attempts > 1 {
+			s.retries.Add(ctx, 1, metric.WithAttributes(attribute.String("reminder.channel", s.sender.Channel())))
+		}
+		return s.sender.Send(ctx, task)
+	})
+	return attempts, err
+}
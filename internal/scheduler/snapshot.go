@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/snapshot"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SnapshotScheduler takes task snapshots on a cron schedule.
+type SnapshotScheduler struct {
+	snapshots *snapshot.Snapshotter
+	schedule  *CronSchedule
+	logger    *slog.Logger
+}
+
+// NewSnapshotScheduler creates a new SnapshotScheduler taking snapshots
+// whenever schedule matches.
+func NewSnapshotScheduler(snapshots *snapshot.Snapshotter, schedule *CronSchedule, logger *slog.Logger) *SnapshotScheduler {
+	return &SnapshotScheduler{
+		snapshots: snapshots,
+		schedule:  schedule,
+		logger:    logger,
+	}
+}
+
+// Run takes snapshots until ctx is cancelled.
+func (s *SnapshotScheduler) Run(ctx context.Context) {
+	s.logger.InfoContext(ctx, "snapshot scheduler started", slog.String("schedule", s.schedule.String()))
+
+	for {
+		next := s.schedule.Next(time.Now())
+		if next.IsZero() {
+			s.logger.WarnContext(ctx, "snapshot schedule never matches", slog.String("schedule", s.schedule.String()))
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.logger.Info("snapshot scheduler stopped")
+			return
+		case <-timer.C:
+			s.take(ctx)
+		}
+	}
+}
+
+// take takes a snapshot in a new trace, since it isn't part of any request.
+func (s *SnapshotScheduler) take(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "SnapshotScheduler.Tick", trace.WithNewRoot())
+	defer span.End()
+
+	result, err := s.snapshots.Take(ctx, snapshot.TriggerSchedule)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to take task snapshot", slog.Any("error", err))
+		return
+	}
+	s.logger.InfoContext(ctx, "task snapshot taken",
+		slog.String("key", result.Key),
+		slog.Int("tasks", result.Tasks),
+		slog.Int64("bytes", result.Bytes),
+	)
+}
@@ -0,0 +1,253 @@
+// Package seed loads demo tasks from a JSON or YAML fixture into an empty
+// repository on startup, so demos start with meaningful data. Tasks are
+// created through the task service, so they get history, audit entries and
+// events like tasks created through the API.
+package seed
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jsoncodec"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/service"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/seed")
+
+// Actor is the user the seeded tasks are created as, and their owner if
+// the fixture doesn't name one.
+const Actor = "seed"
+
+// EmbeddedSource is the source of the embedded demo fixture.
+const EmbeddedSource = "embedded"
+
+//go:embed fixtures/demo.yaml
+var demoFixture []byte
+
+// Fixture is a list of tasks to seed.
+type Fixture struct {
+	// Source is the file the fixture was loaded from, or EmbeddedSource.
+	Source string `json:"-" yaml:"-"`
+
+	Tasks []Item `json:"tasks" yaml:"tasks"`
+}
+
+// Item is a task of a fixture. Key names the task for the DependsOn of
+// later items. Status is a lifecycle state (see model.Task.State);
+// Recurrence is a recurrence interval such as "24h".
+type Item struct {
+	Key         string   `json:"key,omitempty" yaml:"key,omitempty"`
+	Title       string   `json:"title" yaml:"title"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Status      string   `json:"status,omitempty" yaml:"status,omitempty"`
+	Owner       string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Tenant      string   `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	Assignee    string   `json:"assignee,omitempty" yaml:"assignee,omitempty"`
+	DependsOn   []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	Recurrence  string   `json:"recurrence,omitempty" yaml:"recurrence,omitempty"`
+}
+
+// Load reads the fixture in file, as JSON if its extension is .json and as
+// YAML otherwise, or the embedded demo fixture if file is empty. Unknown
+// fields, duplicate keys and references to unknown or later keys are
+// rejected.
+func Load(file string) (*Fixture, error) {
+	data, source := demoFixture, EmbeddedSource
+	if file != "" {
+		var err error
+		if data, err = os.ReadFile(file); err != nil {
+			return nil, fmt.Errorf("failed to read seed file: %w", err)
+		}
+		source = file
+	}
+
+	f := &Fixture{Source: source}
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		dec := jsoncodec.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(f); err != nil {
+			return nil, fmt.Errorf("failed to parse seed file %s: %w", source, err)
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(f); err != nil {
+			return nil, fmt.Errorf("failed to parse seed file %s: %w", source, err)
+		}
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("invalid seed file %s: %w", source, err)
+	}
+	return f, nil
+}
+
+// validate checks the keys, statuses and dependencies of the items.
+func (f *Fixture) validate() error {
+	keys := make(map[string]bool, len(f.Tasks))
+	for i, item := range f.Tasks {
+		if item.Status != "" {
+			if err := (&model.SetStatusRequest{Status: item.Status}).Validate(); err != nil {
+				return fmt.Errorf("task %d: %w", i, err)
+			}
+		}
+		for _, key := range item.DependsOn {
+			if !keys[key] {
+				return fmt.Errorf("task %d: depends on unknown or later key %q", i, key)
+			}
+		}
+		if item.Key == "" {
+			continue
+		}
+		if keys[item.Key] {
+			return fmt.Errorf("task %d: duplicate key %q", i, item.Key)
+		}
+		keys[item.Key] = true
+	}
+	return nil
+}
+
+// Result counts the items of a seeding and those that were created or
+// failed. Skipped is set if the repository wasn't empty.
+type Result struct {
+	Items   int
+	Created int
+	Failed  int
+	Skipped bool
+}
+
+// Run seeds the tasks of f through tasks if count reports an empty
+// repository. It runs in a root span of its own, linked to the span of ctx,
+// so the seeding shows up as one trace. Items that fail, and the items
+// depending on them, are counted and logged but don't stop the others.
+func Run(ctx context.Context, tasks *service.TaskService, count func() int64, f *Fixture) Result {
+	logger := logging.FromContext(ctx)
+
+	ctx, span := tracer.Start(repository.WithActor(ctx, Actor), "Seed.Run",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(
+			attribute.String("seed.source", f.Source),
+			attribute.Int("seed.items", len(f.Tasks)),
+		),
+	)
+	defer span.End()
+
+	result := Result{Items: len(f.Tasks)}
+	if n := count(); n > 0 {
+		result.Skipped = true
+		span.SetAttributes(attribute.Bool("seed.skipped", true))
+		logger.InfoContext(ctx, "repository not empty, skipping seeding", slog.Int64("tasks", n))
+		return result
+	}
+
+	start := time.Now()
+	ids := make(map[string]string, len(f.Tasks))
+	for i, item := range f.Tasks {
+		id, err := seedItem(ctx, tasks, item, ids)
+		if err != nil {
+			result.Failed++
+			span.AddEvent("seed.item.failed", trace.WithAttributes(
+				attribute.Int("seed.item", i),
+				attribute.String("error.message", err.Error()),
+			))
+			logger.WarnContext(ctx, "failed to seed task",
+				slog.Int("item", i),
+				slog.String("title", item.Title),
+				slog.Any("error", err),
+			)
+			continue
+		}
+		result.Created++
+		if item.Key != "" {
+			ids[item.Key] = id
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("seed.created", result.Created),
+		attribute.Int("seed.failed", result.Failed),
+	)
+	if result.Failed > 0 {
+		span.SetStatus(codes.Error, "failed to seed some tasks")
+	}
+	logger.InfoContext(ctx, "seeding finished",
+		slog.String("source", f.Source),
+		slog.Int("created", result.Created),
+		slog.Int("failed", result.Failed),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return result
+}
+
+// errDependencyNotSeeded is returned for items depending on an item that
+// failed.
+var errDependencyNotSeeded = errors.New("dependency was not seeded")
+
+// seedItem creates the task of item, then sets its status and assignee,
+// and returns its ID. ids maps the keys of the created items to their IDs.
+func seedItem(ctx context.Context, tasks *service.TaskService, item Item, ids map[string]string) (string, error) {
+	ctx, span := tracer.Start(ctx, "Seed.Item", trace.WithAttributes(attribute.String("seed.item.key", item.Key)))
+	defer span.End()
+
+	req := &model.CreateTaskRequest{
+		Title:       item.Title,
+		Description: item.Description,
+		Owner:       item.Owner,
+		Tenant:      item.Tenant,
+	}
+	if req.Owner == "" {
+		req.Owner = Actor
+	}
+	if item.Recurrence != "" {
+		req.Recurrence = &model.RecurrenceRule{Interval: item.Recurrence}
+	}
+	for _, key := range item.DependsOn {
+		id, ok := ids[key]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", errDependencyNotSeeded, key)
+		}
+		req.DependsOn = append(req.DependsOn, id)
+	}
+
+	task, err := tasks.Create(ctx, req)
+	if err != nil {
+		return "", fail(span, err)
+	}
+	span.SetAttributes(attribute.String("task.id", task.ID))
+
+	if item.Status != "" && item.Status != model.StateOpen {
+		if _, err := tasks.SetStatus(ctx, task.ID, &model.SetStatusRequest{Status: item.Status}); err != nil {
+			return "", fail(span, err)
+		}
+	}
+	if item.Assignee != "" {
+		if _, err := tasks.Assign(ctx, task.ID, item.Assignee); err != nil {
+			return "", fail(span, err)
+		}
+	}
+	return task.ID, nil
+}
+
+// fail records err on span and returns it.
+func fail(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
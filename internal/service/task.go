@@ -0,0 +1,614 @@
+package service
+
+import (
+	"context"
+	"iter"
+	"log/slog"
+	"slices"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/audit"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/eventbus"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/feature"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/notifier"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/quota"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/saga"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"github.com/hiroki-koketsu/go-otel-sample/pkg/events"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/service")
+
+// TaskService holds the business rules for tasks: validation, quotas,
+// feature gates and status transitions. It records audit entries, publishes
+// task events and notifies completions after successful mutations. The
+// acting user is taken from repository.ActorFromContext. In a dry run
+// (repository.WithDryRun) mutations are checked but neither applied nor
+// recorded.
+type TaskService struct {
+	repo    repository.Repository
+	logger  *slog.Logger
+	auditor *audit.Recorder
+	notify  *notifier.Client
+	events  eventbus.Publisher
+	quotas  *quota.Manager
+	flags   *feature.Flags
+	sagas   *saga.Coordinator
+}
+
+// NewTaskService creates a new TaskService. The notifier client, event
+// publisher and quota manager may be nil, in which case task completions are
+// not sent downstream, no task events are published and no quotas are
+// enforced. Feature flags gate archiving and quota enforcement. sagas runs
+// multi-step operations such as CreateWithReminder.
+func NewTaskService(repo repository.Repository, logger *slog.Logger, auditor *audit.Recorder, notify *notifier.Client, publisher eventbus.Publisher, quotas *quota.Manager, flags *feature.Flags, sagas *saga.Coordinator) *TaskService {
+	return &TaskService{
+		repo:    repo,
+		logger:  logger,
+		auditor: auditor,
+		notify:  notify,
+		events:  publisher,
+		quotas:  quotas,
+		flags:   flags,
+		sagas:   sagas,
+	}
+}
+
+// List returns the tasks matching opts.
+func (s *TaskService) List(ctx context.Context, opts repository.ListOptions) ([]*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.List")
+	defer span.End()
+
+	tasks, err := s.repo.List(ctx, opts)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	return tasks, nil
+}
+
+// ListIter returns the tasks matching opts one at a time, for callers that
+// stream large result sets.
+func (s *TaskService) ListIter(ctx context.Context, opts repository.ListOptions) iter.Seq2[*model.Task, error] {
+	return func(yield func(*model.Task, error) bool) {
+		ctx, span := tracer.Start(ctx, "TaskService.ListIter")
+		defer span.End()
+
+		for task, err := range s.repo.ListIter(ctx, opts) {
+			if err != nil {
+				yield(nil, fail(span, err))
+				return
+			}
+			if !yield(task, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Get returns a task by ID.
+func (s *TaskService) Get(ctx context.Context, id string) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Get",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	task, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	return task, nil
+}
+
+// Create validates the request, checks the quotas of req.Owner and
+// req.Tenant and creates the task.
+func (s *TaskService) Create(ctx context.Context, req *model.CreateTaskRequest) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Create",
+		trace.WithAttributes(telemetry.UserString("task.title", req.Title)),
+	)
+	defer span.End()
+	dryRun := markDryRun(ctx, span)
+
+	if err := req.Validate(); err != nil {
+		s.logger.WarnContext(ctx, "validation failed", slog.Any("error", err))
+		return nil, fail(span, err)
+	}
+	if err := s.checkQuota(ctx, req.Owner, req.Tenant); err != nil {
+		return nil, fail(span, err)
+	}
+
+	task, err := s.repo.Create(ctx, req)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	if dryRun {
+		return task, nil
+	}
+
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	s.logger.InfoContext(ctx, "task created", slog.String("id", task.ID))
+
+	after := *task
+	s.auditor.Record(ctx, audit.Entry{
+		Actor:  repository.ActorFromContext(ctx),
+		Action: audit.ActionCreate,
+		TaskID: task.ID,
+		After:  &after,
+	})
+	s.publish(ctx, events.New(task.ID, events.TaskCreatedV1{Task: taskV1(&after)}))
+
+	return task, nil
+}
+
+// CreateWithReminder creates a task with a reminder as a saga of three
+// steps: create the task, schedule its reminder and publish the created
+// event. Unlike Create, a failed publish fails the operation; the task is
+// then deleted again, which also cancels its reminder.
+func (s *TaskService) CreateWithReminder(ctx context.Context, req *model.CreateTaskRequest) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.CreateWithReminder",
+		trace.WithAttributes(telemetry.UserString("task.title", req.Title)),
+	)
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		s.logger.WarnContext(ctx, "validation failed", slog.Any("error", err))
+		return nil, fail(span, err)
+	}
+	if req.RemindAt == nil {
+		return nil, fail(span, model.ErrRemindAtRequired)
+	}
+	if err := s.checkQuota(ctx, req.Owner, req.Tenant); err != nil {
+		return nil, fail(span, err)
+	}
+
+	create := *req
+	create.RemindAt = nil
+
+	var task *model.Task
+	err := s.sagas.Run(ctx, "create_task_with_reminder",
+		saga.Step{
+			Name: "create_task",
+			Do: func(ctx context.Context) error {
+				var err error
+				task, err = s.repo.Create(ctx, &create)
+				return err
+			},
+			Compensate: func(ctx context.Context) error {
+				return s.repo.Delete(ctx, task.ID)
+			},
+		},
+		saga.Step{
+			Name: "schedule_reminder",
+			Do: func(ctx context.Context) error {
+				var err error
+				task, err = s.repo.Update(ctx, task.ID, &model.UpdateTaskRequest{RemindAt: req.RemindAt})
+				return err
+			},
+		},
+		saga.Step{
+			Name: "publish_event",
+			Do: func(ctx context.Context) error {
+				if s.events == nil {
+					return nil
+				}
+				after := *task
+				return s.events.Publish(ctx, events.New(task.ID, events.TaskCreatedV1{Task: taskV1(&after)}))
+			},
+		},
+	)
+	if err != nil {
+		s.logger.WarnContext(ctx, "create with reminder failed", slog.Any("error", err))
+		return nil, fail(span, err)
+	}
+
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	s.logger.InfoContext(ctx, "task created with reminder", slog.String("id", task.ID))
+
+	after := *task
+	s.auditor.Record(ctx, audit.Entry{
+		Actor:  repository.ActorFromContext(ctx),
+		Action: audit.ActionCreate,
+		TaskID: task.ID,
+		After:  &after,
+	})
+
+	return task, nil
+}
+
+// Update modifies a task and notifies the downstream service if the update
+// completed it.
+func (s *TaskService) Update(ctx context.Context, id string, req *model.UpdateTaskRequest) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Update",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+	dryRun := markDryRun(ctx, span)
+
+	var snap repository.Snapshot
+	task, err := s.repo.Update(repository.WithSnapshot(ctx, &snap), id, req)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	if dryRun {
+		return task, nil
+	}
+
+	completed := !snap.Before.Done && task.Done
+	span.SetAttributes(attribute.Bool("task.completed", completed))
+	s.logger.InfoContext(ctx, "task updated", slog.String("id", id))
+
+	after := *task
+	s.auditor.Record(ctx, audit.Entry{
+		Actor:  repository.ActorFromContext(ctx),
+		Action: audit.ActionUpdate,
+		TaskID: id,
+		Before: snap.Before,
+		After:  &after,
+	})
+	s.publish(ctx, events.New(id, events.TaskUpdatedV1{Task: taskV1(&after)}))
+
+	if completed && s.notify != nil {
+		if err := s.notify.NotifyCompleted(ctx, task); err != nil {
+			s.logger.WarnContext(ctx, "failed to notify task completion", slog.String("id", id), slog.Any("error", err))
+		}
+	}
+
+	return task, nil
+}
+
+// Delete removes a task.
+func (s *TaskService) Delete(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "TaskService.Delete",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+	dryRun := markDryRun(ctx, span)
+
+	var snap repository.Snapshot
+	if err := s.repo.Delete(repository.WithSnapshot(ctx, &snap), id); err != nil {
+		return fail(span, err)
+	}
+	if dryRun {
+		return nil
+	}
+
+	s.logger.InfoContext(ctx, "task deleted", slog.String("id", id))
+
+	s.auditor.Record(ctx, audit.Entry{
+		Actor:  repository.ActorFromContext(ctx),
+		Action: audit.ActionDelete,
+		TaskID: id,
+		Before: snap.Before,
+	})
+	s.publish(ctx, events.New(id, events.TaskDeletedV1{}))
+
+	return nil
+}
+
+// SetArchived archives or unarchives a task. It returns
+// model.ErrArchivingDisabled if the task-archiving flag is off for the actor.
+func (s *TaskService) SetArchived(ctx context.Context, id string, archived bool) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.SetArchived",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.Bool("task.archived", archived),
+		),
+	)
+	defer span.End()
+	dryRun := markDryRun(ctx, span)
+
+	if !s.flags.Enabled(ctx, feature.FlagTaskArchiving, repository.ActorFromContext(ctx)) {
+		return nil, fail(span, model.ErrArchivingDisabled)
+	}
+
+	var snap repository.Snapshot
+	task, err := s.repo.SetArchived(repository.WithSnapshot(ctx, &snap), id, archived)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	if dryRun {
+		return task, nil
+	}
+
+	s.logger.InfoContext(ctx, "task archive state changed", slog.String("id", id), slog.Bool("archived", archived))
+
+	after := *task
+	action, payload := audit.ActionArchive, events.Payload(events.TaskArchivedV1{Task: taskV1(&after)})
+	if !archived {
+		action, payload = audit.ActionUnarchive, events.TaskUnarchivedV1{Task: taskV1(&after)}
+	}
+	s.auditor.Record(ctx, audit.Entry{
+		Actor:  repository.ActorFromContext(ctx),
+		Action: action,
+		TaskID: id,
+		Before: snap.Before,
+		After:  &after,
+	})
+	s.publish(ctx, events.New(id, payload))
+
+	return task, nil
+}
+
+// SetStatus moves a task to the status of req (see model.Task.State) in one
+// transaction, through SetArchived and Update so the changes are audited
+// and published as usual. Archiving keeps the done flag; leaving the
+// archived status unarchives the task first.
+func (s *TaskService) SetStatus(ctx context.Context, id string, req *model.SetStatusRequest) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.SetStatus",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.String("task.status", req.Status),
+		),
+	)
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		s.logger.WarnContext(ctx, "validation failed", slog.Any("error", err))
+		return nil, fail(span, err)
+	}
+
+	var task *model.Task
+	err := s.WithTx(ctx, func(tx *TaskService) error {
+		var err error
+		if task, err = tx.Get(ctx, id); err != nil {
+			return err
+		}
+		span.SetAttributes(attribute.String("task.status.previous", task.State()))
+		if task.State() == req.Status {
+			return nil
+		}
+
+		if req.Status == model.StateArchived {
+			task, err = tx.SetArchived(ctx, id, true)
+			return err
+		}
+		if task.ArchivedAt != nil {
+			if task, err = tx.SetArchived(ctx, id, false); err != nil {
+				return err
+			}
+		}
+		if done := req.Status == model.StateDone; task.Done != done {
+			task, err = tx.Update(ctx, id, &model.UpdateTaskRequest{Done: &done})
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	return task, nil
+}
+
+// Assign assigns a task to assignee, or unassigns it if assignee is empty.
+// Only actual changes of the assignee are audited and published.
+func (s *TaskService) Assign(ctx context.Context, id, assignee string) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Assign",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			telemetry.UserString("task.assignee", assignee),
+		),
+	)
+	defer span.End()
+	dryRun := markDryRun(ctx, span)
+
+	// The previous assignee is taken by the repository, atomically with the
+	// assign
+	var snap repository.Snapshot
+	task, err := s.repo.Assign(repository.WithSnapshot(ctx, &snap), id, assignee)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	if dryRun || snap.Before.Assignee == task.Assignee {
+		return task, nil
+	}
+
+	s.logger.InfoContext(ctx, "task assigned",
+		slog.String("id", id),
+		slog.String("from", snap.Before.Assignee),
+		slog.String("to", assignee),
+	)
+
+	after := *task
+	s.auditor.Record(ctx, audit.Entry{
+		Actor:  repository.ActorFromContext(ctx),
+		Action: audit.ActionAssign,
+		TaskID: id,
+		Before: snap.Before,
+		After:  &after,
+	})
+	s.publish(ctx, events.New(id, events.TaskAssignedV1{Task: taskV1(&after)}))
+
+	return task, nil
+}
+
+// Claim leases a task to the acting user for the TTL of req, or renews
+// their lease. New claims are audited and published; renewals are not.
+func (s *TaskService) Claim(ctx context.Context, id string, req *model.ClaimTaskRequest) (*model.Task, error) {
+	holder := repository.ActorFromContext(ctx)
+	ctx, span := tracer.Start(ctx, "TaskService.Claim",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.String("lease.holder", holder),
+		),
+	)
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		return nil, fail(span, err)
+	}
+
+	var snap repository.Snapshot
+	task, expired, err := s.repo.Claim(repository.WithSnapshot(ctx, &snap), id, holder, req.Duration())
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	if expired != nil {
+		s.logger.WarnContext(ctx, "task lease expired",
+			slog.String("id", id),
+			slog.String("holder", expired.Holder),
+			slog.Time("expired_at", expired.ExpiresAt),
+		)
+	}
+	if renewed := snap.Before.Lease != nil && snap.Before.Lease.ClaimedAt.Equal(task.Lease.ClaimedAt); renewed {
+		span.SetAttributes(attribute.Bool("lease.renewed", true))
+		return task, nil
+	}
+
+	s.logger.InfoContext(ctx, "task claimed",
+		slog.String("id", id),
+		slog.String("holder", holder),
+		slog.Time("expires_at", task.Lease.ExpiresAt),
+	)
+
+	after := *task
+	s.auditor.Record(ctx, audit.Entry{
+		Actor:  holder,
+		Action: audit.ActionClaim,
+		TaskID: id,
+		Before: snap.Before,
+		After:  &after,
+	})
+	s.publish(ctx, events.New(id, events.TaskClaimedV1{Task: taskV1(&after)}))
+
+	return task, nil
+}
+
+// Release ends the lease of the acting user on a task, so other workers can
+// claim it right away.
+func (s *TaskService) Release(ctx context.Context, id string) (*model.Task, error) {
+	holder := repository.ActorFromContext(ctx)
+	ctx, span := tracer.Start(ctx, "TaskService.Release",
+		trace.WithAttributes(
+			attribute.String("task.id", id),
+			attribute.String("lease.holder", holder),
+		),
+	)
+	defer span.End()
+
+	var snap repository.Snapshot
+	task, err := s.repo.Release(repository.WithSnapshot(ctx, &snap), id, holder)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+
+	s.logger.InfoContext(ctx, "task released", slog.String("id", id), slog.String("holder", holder))
+
+	after := *task
+	s.auditor.Record(ctx, audit.Entry{
+		Actor:  holder,
+		Action: audit.ActionRelease,
+		TaskID: id,
+		Before: snap.Before,
+		After:  &after,
+	})
+	s.publish(ctx, events.New(id, events.TaskReleasedV1{Task: taskV1(&after)}))
+
+	return task, nil
+}
+
+// Dependencies returns the dependencies of a task and whether it is blocked.
+func (s *TaskService) Dependencies(ctx context.Context, id string) (*model.DependencyStatus, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Dependencies",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	status, err := s.repo.Dependencies(ctx, id)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	return status, nil
+}
+
+// History returns the revisions of a task, including deleted ones.
+func (s *TaskService) History(ctx context.Context, id string) ([]model.Revision, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.History",
+		trace.WithAttributes(attribute.String("task.id", id)),
+	)
+	defer span.End()
+
+	revisions, err := s.repo.History(ctx, id)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	return revisions, nil
+}
+
+// WithTx runs fn as a unit of work on a service whose writes go through a
+// repository transaction (see repository.Repository.WithTx). Audit entries
+// and events of the writes are recorded as they happen, not on commit.
+func (s *TaskService) WithTx(ctx context.Context, fn func(tx *TaskService) error) error {
+	return s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		tx := *s
+		tx.repo = repo
+		return fn(&tx)
+	})
+}
+
+// checkQuota returns an error if owner or tenant can't create another task.
+// It is a no-op without a quota manager or with quota enforcement disabled.
+func (s *TaskService) checkQuota(ctx context.Context, owner, tenant string) error {
+	if s.quotas == nil || !s.flags.Enabled(ctx, feature.FlagQuotaEnforcement, owner) {
+		return nil
+	}
+	if err := s.quotas.Check(ctx, owner, tenant); err != nil {
+		s.logger.WarnContext(ctx, "quota exceeded",
+			slog.String("owner", owner),
+			slog.String("tenant", tenant),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	return nil
+}
+
+// publish sends a task event if a publisher is configured. Publishing failures
+// are logged but don't fail the operation.
+func (s *TaskService) publish(ctx context.Context, e events.Event) {
+	if s.events == nil {
+		return
+	}
+	if err := s.events.Publish(ctx, e); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish task event",
+			slog.String("type", string(e.Type)),
+			slog.String("task_id", e.TaskID),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// taskV1 returns the task of version 1 events for t.
+func taskV1(t *model.Task) events.TaskV1 {
+	return events.TaskV1{
+		ID:          t.ID,
+		Title:       t.Title,
+		Description: t.Description,
+		Done:        t.Done,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+		ArchivedAt:  t.ArchivedAt,
+		Owner:       t.Owner,
+		Tenant:      t.Tenant,
+		Assignee:    t.Assignee,
+		DependsOn:   slices.Clone(t.DependsOn),
+	}
+}
+
+// markDryRun reports whether ctx is a dry run and marks the span if so.
+func markDryRun(ctx context.Context, span trace.Span) bool {
+	if !repository.IsDryRun(ctx) {
+		return false
+	}
+	span.SetAttributes(attribute.Bool("request.dry_run", true))
+	return true
+}
+
+// fail records err on the span by its kind and returns it; see
+// apperr.Record.
+func fail(span trace.Span, err error) error {
+	apperr.Record(span, err)
+	return err
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TemplateService manages task templates and creates tasks from them. Tasks
+// are created through the task service, so they go through the same
+// validation, quotas, auditing and events as any other task.
+type TemplateService struct {
+	templates *repository.TemplateRepository
+	tasks     *TaskService
+	logger    *slog.Logger
+
+	instantiations metric.Int64Counter
+}
+
+// NewTemplateService creates a new TemplateService.
+func NewTemplateService(templates *repository.TemplateRepository, tasks *TaskService, logger *slog.Logger, meter metric.Meter) (*TemplateService, error) {
+	instantiations, err := meter.Int64Counter(
+		"task_template_instantiations_total",
+		metric.WithDescription("Tasks instantiated from a task template"),
+		metric.WithUnit("{task}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template instantiation counter: %w", err)
+	}
+
+	return &TemplateService{
+		templates:      templates,
+		tasks:          tasks,
+		logger:         logger,
+		instantiations: instantiations,
+	}, nil
+}
+
+// List returns all templates.
+func (s *TemplateService) List(ctx context.Context) ([]*model.TaskTemplate, error) {
+	ctx, span := tracer.Start(ctx, "TemplateService.List")
+	defer span.End()
+
+	templates, err := s.templates.List(ctx)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	return templates, nil
+}
+
+// Get returns a template by ID.
+func (s *TemplateService) Get(ctx context.Context, id string) (*model.TaskTemplate, error) {
+	ctx, span := tracer.Start(ctx, "TemplateService.Get",
+		trace.WithAttributes(attribute.String("task.template.id", id)),
+	)
+	defer span.End()
+
+	tmpl, err := s.templates.GetByID(ctx, id)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	return tmpl, nil
+}
+
+// Create validates the request and creates the template.
+func (s *TemplateService) Create(ctx context.Context, req *model.CreateTemplateRequest) (*model.TaskTemplate, error) {
+	ctx, span := tracer.Start(ctx, "TemplateService.Create",
+		trace.WithAttributes(telemetry.UserString("task.template.name", req.Name)),
+	)
+	defer span.End()
+	dryRun := markDryRun(ctx, span)
+
+	if err := req.Validate(); err != nil {
+		s.logger.WarnContext(ctx, "validation failed", slog.Any("error", err))
+		return nil, fail(span, err)
+	}
+
+	tmpl, err := s.templates.Create(ctx, req)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+	span.SetAttributes(
+		attribute.String("task.template.id", tmpl.ID),
+		attribute.Int("task.template.variables", len(tmpl.Variables)),
+	)
+	if !dryRun {
+		s.logger.InfoContext(ctx, "task template created", slog.String("id", tmpl.ID))
+	}
+	return tmpl, nil
+}
+
+// Delete removes a template.
+func (s *TemplateService) Delete(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "TemplateService.Delete",
+		trace.WithAttributes(attribute.String("task.template.id", id)),
+	)
+	defer span.End()
+	dryRun := markDryRun(ctx, span)
+
+	if err := s.templates.Delete(ctx, id); err != nil {
+		return fail(span, err)
+	}
+	if !dryRun {
+		s.logger.InfoContext(ctx, "task template deleted", slog.String("id", id))
+	}
+	return nil
+}
+
+// Instantiate creates a task from the template with the given ID, owned by
+// owner and tenant, substituting vars into its title and description. Every
+// attempt on an existing template is counted by template and result, dry
+// runs excepted.
+func (s *TemplateService) Instantiate(ctx context.Context, id string, vars map[string]string, owner, tenant string) (*model.Task, error) {
+	ctx, span := tracer.Start(ctx, "TemplateService.Instantiate",
+		trace.WithAttributes(attribute.String("task.template.id", id)),
+	)
+	defer span.End()
+	dryRun := markDryRun(ctx, span)
+
+	tmpl, err := s.templates.GetByID(ctx, id)
+	if err != nil {
+		return nil, fail(span, err)
+	}
+
+	task, err := s.instantiate(ctx, tmpl, vars, owner, tenant)
+	if !dryRun {
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		s.instantiations.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("task.template.id", id),
+			attribute.String("result", result),
+		))
+	}
+	if err != nil {
+		return nil, fail(span, err)
+	}
+
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	return task, nil
+}
+
+func (s *TemplateService) instantiate(ctx context.Context, tmpl *model.TaskTemplate, vars map[string]string, owner, tenant string) (*model.Task, error) {
+	title, description, err := tmpl.Render(vars)
+	if err != nil {
+		s.logger.WarnContext(ctx, "template rendering failed", slog.String("template_id", tmpl.ID), slog.Any("error", err))
+		return nil, err
+	}
+	return s.tasks.Create(ctx, &model.CreateTaskRequest{
+		Title:       title,
+		Description: description,
+		Owner:       owner,
+		Tenant:      tenant,
+		TemplateID:  tmpl.ID,
+	})
+}
@@ -0,0 +1,159 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/async"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/repository"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/snapshot")
+
+// Triggers of a snapshot.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// ContentType is the content type of snapshot objects: one JSON task per line.
+const ContentType = "application/x-ndjson"
+
+// ErrInProgress is returned by Take while another snapshot is being taken.
+var ErrInProgress = apperr.New(apperr.Conflict, "snapshot already in progress")
+
+// Result describes a snapshot written to the object store.
+type Result struct {
+	Key       string    `json:"key"`
+	Tasks     int       `json:"tasks"`
+	Bytes     int64     `json:"bytes"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+}
+
+// Snapshotter writes all tasks, archived ones included, to an object store
+// as NDJSON. Tasks are streamed from the repository into the upload, so a
+// snapshot never holds all tasks in memory.
+type Snapshotter struct {
+	repo   repository.Repository
+	store  storage.ObjectStore
+	prefix string
+
+	running sync.Mutex
+
+	duration metric.Float64Histogram
+	size     metric.Int64Histogram
+}
+
+// NewSnapshotter creates a new Snapshotter writing to store below prefix.
+func NewSnapshotter(repo repository.Repository, store storage.ObjectStore, meter metric.Meter, prefix string) (*Snapshotter, error) {
+	s := &Snapshotter{
+		repo:   repo,
+		store:  store,
+		prefix: prefix,
+	}
+
+	var err error
+
+	s.duration, err = meter.Float64Histogram(
+		"task_snapshot_duration_seconds",
+		metric.WithDescription("Duration of task snapshots written to object storage"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot duration histogram: %w", err)
+	}
+
+	s.size, err = meter.Int64Histogram(
+		"task_snapshot_size_bytes",
+		metric.WithDescription("Size of task snapshots written to object storage"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(1<<10, 1<<14, 1<<17, 1<<20, 1<<23, 1<<26, 1<<30),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot size histogram: %w", err)
+	}
+
+	return s, nil
+}
+
+// Take writes a snapshot of all tasks and returns where it was written. Only
+// one snapshot is taken at a time; Take returns ErrInProgress otherwise.
+func (s *Snapshotter) Take(ctx context.Context, trigger string) (*Result, error) {
+	if !s.running.TryLock() {
+		return nil, ErrInProgress
+	}
+	defer s.running.Unlock()
+
+	start := time.Now()
+	key := s.prefix + start.UTC().Format("20060102T150405Z") + ".ndjson"
+
+	ctx, span := tracer.Start(ctx, "Snapshotter.Take", trace.WithAttributes(
+		attribute.String("snapshot.trigger", trigger),
+		attribute.String("storage.key", key),
+	))
+	defer span.End()
+
+	// Tasks are encoded into the pipe while the store uploads from it. If
+	// the upload fails, closing the reader stops the encoder.
+	pr, pw := io.Pipe()
+	tasks := 0
+	encoded := async.Go(ctx, "Snapshotter.Encode", func(ctx context.Context) {
+		enc := json.NewEncoder(pw)
+		for task, err := range s.repo.ListIter(ctx, repository.ListOptions{IncludeArchived: true}) {
+			if err == nil {
+				err = enc.Encode(task)
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			tasks++
+		}
+		pw.Close()
+	})
+
+	size, err := s.store.Put(ctx, key, pr, ContentType)
+	pr.CloseWithError(err)
+	<-encoded
+
+	elapsed := time.Since(start)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	s.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(
+		attribute.String("snapshot.trigger", trigger),
+		attribute.String("result", result),
+	))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "snapshot failed")
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	s.size.Record(ctx, size, metric.WithAttributes(attribute.String("snapshot.trigger", trigger)))
+
+	span.SetAttributes(
+		attribute.Int("snapshot.tasks", tasks),
+		attribute.Int64("snapshot.bytes", size),
+	)
+	return &Result{
+		Key:       key,
+		Tasks:     tasks,
+		Bytes:     size,
+		StartedAt: start,
+		Duration:  elapsed.String(),
+	}, nil
+}
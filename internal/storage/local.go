@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LocalStore stores objects as files below a base directory.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a LocalStore rooted at dir, creating it if needed.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// Put writes the object to disk.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "LocalStore.Put",
+		trace.WithAttributes(attribute.String("storage.key", key)),
+	)
+	defer span.End()
+
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create directory")
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create file")
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, NewContextReader(ctx, r))
+	if err != nil {
+		os.Remove(p)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to write object")
+		return n, fmt.Errorf("failed to write object: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int64("storage.size", n))
+	return n, nil
+}
+
+// Get opens the object file.
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	_, span := tracer.Start(ctx, "LocalStore.Get",
+		trace.WithAttributes(attribute.String("storage.key", key)),
+	)
+	defer span.End()
+
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to open object")
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	return f, nil
+}
+
+// Delete removes the object file.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	_, span := tracer.Start(ctx, "LocalStore.Delete",
+		trace.WithAttributes(attribute.String("storage.key", key)),
+	)
+	defer span.End()
+
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to delete object")
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+)
+
+// S3Config holds the settings for an S3-compatible object store.
+// Set Endpoint and UsePathStyle to target MinIO.
+type S3Config struct {
+	Bucket       string
+	Region       string
+	Endpoint     string
+	UsePathStyle bool
+}
+
+// S3Store stores objects in an S3 bucket. SDK calls are traced with otelaws.
+type S3Store struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	bucket   string
+}
+
+// NewS3Store creates an S3Store using the default AWS credential chain.
+func NewS3Store(ctx context.Context, cfg S3Config) (*S3Store, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	// Instrument all AWS SDK calls with OpenTelemetry spans
+	otelaws.AppendMiddlewares(&awsCfg.APIOptions)
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	return &S3Store{
+		client:   client,
+		uploader: manager.NewUploader(client),
+		bucket:   cfg.Bucket,
+	}, nil
+}
+
+// Put streams the object to S3 using multipart uploads, so the size does not
+// need to be known up front.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	counter := &countingReader{r: r}
+	_, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        counter,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return counter.n, fmt.Errorf("failed to upload object: %w", err)
+	}
+	return counter.n, nil
+}
+
+// Get opens the object for streaming.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to get object: %w", apperr.Wrap(apperr.Unavailable, err))
+	}
+	return out.Body, nil
+}
+
+// Delete removes the object.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", apperr.Wrap(apperr.Unavailable, err))
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/apperr"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/storage")
+
+// ErrObjectNotFound is returned when an object does not exist in the store.
+var ErrObjectNotFound = apperr.New(apperr.NotFound, "object not found")
+
+// ObjectStore stores opaque binary objects such as task attachments.
+type ObjectStore interface {
+	// Put streams r into the object at key and returns the number of bytes written.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error)
+	// Get opens the object at key for streaming. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object at key.
+	Delete(ctx context.Context, key string) error
+}
+
+// contextReader stops reading once ctx is cancelled, so copies of large
+// objects are abandoned when the client goes away.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// NewContextReader wraps r so that reads fail once ctx is cancelled.
+func NewContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return contextReader{ctx: ctx, r: r}
+}
@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// MaxUserValueLength is the maximum length in bytes of user-controlled
+// values, such as task titles, put on spans by UserString.
+const MaxUserValueLength = 128
+
+// UserString returns a string attribute for a user-controlled value,
+// truncated to MaxUserValueLength on a rune boundary. Unlike the span
+// attribute value limit, it lets short system values through untouched while
+// keeping arbitrary user input from bloating telemetry payloads.
+func UserString(key, value string) attribute.KeyValue {
+	if len(value) <= MaxUserValueLength {
+		return attribute.String(key, value)
+	}
+	cut := MaxUserValueLength
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return attribute.String(key, value[:cut]+"…")
+}
+
+// BaggageAttributes returns the members of the baggage in ctx named in keys
+// as string attributes of the same name. Baggage comes from clients, so
+// only allowed keys are used and values are truncated like UserString.
+func BaggageAttributes(ctx context.Context, keys []string) []attribute.KeyValue {
+	b := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for _, key := range keys {
+		if m := b.Member(key); m.Key() != "" {
+			attrs = append(attrs, UserString(key, m.Value()))
+		}
+	}
+	return attrs
+}
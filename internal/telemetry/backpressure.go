@@ -0,0 +1,349 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Reasons for dropping telemetry.
+const (
+	DropReasonQueueFull    = "queue_full"
+	DropReasonBlockTimeout = "block_timeout"
+	DropReasonShutdown     = "shutdown"
+)
+
+// Defaults of the export queues, matching the SDK batch processors.
+const (
+	defaultSpanBatchTimeout = 5 * time.Second
+	defaultLogBatchTimeout  = time.Second
+	defaultMaxExportBatch   = 512
+	defaultMaxQueueSize     = 2048
+	queueExportTimeout      = 30 * time.Second
+)
+
+// DropReporter counts spans and log records dropped because their export
+// queue was full, and warns about them on stderr at most once per interval
+// and signal, since the OTLP log pipeline may be the one that is full.
+type DropReporter struct {
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu         sync.Mutex
+	dropped    map[dropKey]int64
+	unreported map[string]int64
+	lastWarn   map[string]time.Time
+}
+
+type dropKey struct {
+	signal, reason string
+}
+
+// NewDropReporter creates a new DropReporter warning at most once per
+// interval and signal.
+func NewDropReporter(interval time.Duration) *DropReporter {
+	return &DropReporter{
+		interval:   interval,
+		logger:     slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		dropped:    make(map[dropKey]int64),
+		unreported: make(map[string]int64),
+		lastWarn:   make(map[string]time.Time),
+	}
+}
+
+// drop records n dropped items of signal. It is safe to call on a nil
+// DropReporter.
+func (d *DropReporter) drop(signal, reason string, n int) {
+	if d == nil || n == 0 {
+		return
+	}
+	d.mu.Lock()
+	d.dropped[dropKey{signal, reason}] += int64(n)
+	d.unreported[signal] += int64(n)
+	now := time.Now()
+	if now.Sub(d.lastWarn[signal]) < d.interval {
+		d.mu.Unlock()
+		return
+	}
+	unreported := d.unreported[signal]
+	d.unreported[signal] = 0
+	d.lastWarn[signal] = now
+	d.mu.Unlock()
+
+	d.logger.Warn("telemetry dropped before export",
+		slog.String("signal", signal),
+		slog.String("reason", reason),
+		slog.Int64("dropped", unreported),
+	)
+}
+
+// Dropped returns the number of dropped items by signal and reason.
+func (d *DropReporter) Dropped() map[string]map[string]int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dropped := make(map[string]map[string]int64)
+	for k, n := range d.dropped {
+		if dropped[k.signal] == nil {
+			dropped[k.signal] = make(map[string]int64)
+		}
+		dropped[k.signal][k.reason] = n
+	}
+	return dropped
+}
+
+// RegisterDroppedCounter registers an observable counter reporting the
+// telemetry dropped by d.
+func RegisterDroppedCounter(meter metric.Meter, d *DropReporter) error {
+	_, err := meter.Int64ObservableCounter(
+		"telemetry_dropped_total",
+		metric.WithDescription("Total number of spans and log records dropped before export"),
+		metric.WithUnit("{item}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for signal, reasons := range d.Dropped() {
+				for reason, n := range reasons {
+					o.Observe(n, metric.WithAttributes(
+						attribute.String("signal", signal),
+						attribute.String("reason", reason),
+					))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create dropped telemetry counter: %w", err)
+	}
+	return nil
+}
+
+// exportQueue buffers items and exports them in batches from a single
+// goroutine, like the SDK batch processors. When the queue is full, enqueue
+// drops the item, or with blocking waits up to blockTimeout (0 waits until
+// there is space) for the exporter to catch up.
+type exportQueue[T any] struct {
+	signal       string
+	batchSize    int
+	batchTimeout time.Duration
+	blocking     bool
+	blockTimeout time.Duration
+	export       func(context.Context, []T) error
+	drops        *DropReporter
+
+	queue   chan T
+	flushes chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+
+	stopOnce sync.Once
+}
+
+func newExportQueue[T any](signal string, c BatchConfig, defaultTimeout time.Duration, drops *DropReporter, export func(context.Context, []T) error) *exportQueue[T] {
+	q := &exportQueue[T]{
+		signal:       signal,
+		batchSize:    defaultMaxExportBatch,
+		batchTimeout: defaultTimeout,
+		blocking:     c.Blocking,
+		blockTimeout: c.BlockTimeout,
+		export:       export,
+		drops:        drops,
+		flushes:      make(chan chan struct{}),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if c.Timeout > 0 {
+		q.batchTimeout = c.Timeout
+	}
+	if c.MaxExportBatchSize > 0 {
+		q.batchSize = c.MaxExportBatchSize
+	}
+	size := defaultMaxQueueSize
+	if c.MaxQueueSize > 0 {
+		size = c.MaxQueueSize
+	}
+	q.batchSize = min(q.batchSize, size)
+	q.queue = make(chan T, size)
+
+	go q.run() // async:ok: telemetry must not trace itself
+	return q
+}
+
+// enqueue queues item for export or drops it.
+func (q *exportQueue[T]) enqueue(item T) {
+	select {
+	case <-q.stop:
+		q.drops.drop(q.signal, DropReasonShutdown, 1)
+		return
+	default:
+	}
+
+	select {
+	case q.queue <- item:
+		return
+	default:
+	}
+	if !q.blocking {
+		q.drops.drop(q.signal, DropReasonQueueFull, 1)
+		return
+	}
+
+	var timeout <-chan time.Time
+	if q.blockTimeout > 0 {
+		timer := time.NewTimer(q.blockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case q.queue <- item:
+	case <-timeout:
+		q.drops.drop(q.signal, DropReasonBlockTimeout, 1)
+	case <-q.stop:
+		q.drops.drop(q.signal, DropReasonShutdown, 1)
+	}
+}
+
+func (q *exportQueue[T]) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.batchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, q.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), queueExportTimeout)
+		// Export errors are reported by the exporters themselves.
+		_ = q.export(ctx, batch)
+		cancel()
+		batch = make([]T, 0, q.batchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case item := <-q.queue:
+				batch = append(batch, item)
+				if len(batch) == q.batchSize {
+					flush()
+				}
+			default:
+				flush()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case item := <-q.queue:
+			batch = append(batch, item)
+			if len(batch) == q.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case done := <-q.flushes:
+			drain()
+			close(done)
+		case <-q.stop:
+			drain()
+			return
+		}
+	}
+}
+
+// forceFlush exports all queued items.
+func (q *exportQueue[T]) forceFlush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case q.flushes <- done:
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown exports all queued items and stops the queue. Items enqueued
+// afterwards are dropped.
+func (q *exportQueue[T]) shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// spanQueueProcessor is a batch span processor on an exportQueue.
+type spanQueueProcessor struct {
+	exporter sdktrace.SpanExporter
+	queue    *exportQueue[sdktrace.ReadOnlySpan]
+}
+
+func newSpanQueueProcessor(exporter sdktrace.SpanExporter, c BatchConfig, drops *DropReporter) *spanQueueProcessor {
+	return &spanQueueProcessor{
+		exporter: exporter,
+		queue:    newExportQueue("traces", c, defaultSpanBatchTimeout, drops, exporter.ExportSpans),
+	}
+}
+
+func (p *spanQueueProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *spanQueueProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	p.queue.enqueue(s)
+}
+
+func (p *spanQueueProcessor) ForceFlush(ctx context.Context) error {
+	return p.queue.forceFlush(ctx)
+}
+
+func (p *spanQueueProcessor) Shutdown(ctx context.Context) error {
+	return errors.Join(p.queue.shutdown(ctx), p.exporter.Shutdown(ctx))
+}
+
+// logQueueProcessor is a batch log processor on an exportQueue.
+type logQueueProcessor struct {
+	exporter sdklog.Exporter
+	queue    *exportQueue[sdklog.Record]
+}
+
+func newLogQueueProcessor(exporter sdklog.Exporter, c BatchConfig, drops *DropReporter) *logQueueProcessor {
+	return &logQueueProcessor{
+		exporter: exporter,
+		queue:    newExportQueue("logs", c, defaultLogBatchTimeout, drops, exporter.Export),
+	}
+}
+
+// OnEmit queues a copy of record, which is only valid during the call.
+func (p *logQueueProcessor) OnEmit(_ context.Context, record *sdklog.Record) error {
+	p.queue.enqueue(record.Clone())
+	return nil
+}
+
+func (p *logQueueProcessor) ForceFlush(ctx context.Context) error {
+	return errors.Join(p.queue.forceFlush(ctx), p.exporter.ForceFlush(ctx))
+}
+
+func (p *logQueueProcessor) Shutdown(ctx context.Context) error {
+	return errors.Join(p.queue.shutdown(ctx), p.exporter.Shutdown(ctx))
+}
@@ -0,0 +1,220 @@
+package telemetry
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// CardinalityReport configures a CardinalityReporter.
+type CardinalityReport struct {
+	// Interval is how often the report is made.
+	Interval time.Duration
+	// TopN is the number of instruments with the most series that are
+	// logged with every report.
+	TopN int
+	// WarnThreshold is the number of series of an instrument from which it
+	// is logged as a warning with the distinct values of every attribute
+	// key; 0 disables the warnings.
+	WarnThreshold int
+}
+
+// InstrumentCardinality is the number of series of an instrument and the
+// number of distinct values of each of its attribute keys.
+type InstrumentCardinality struct {
+	Name   string
+	Series int
+	Keys   map[string]int
+}
+
+// CardinalityReporter periodically collects the metrics produced by the
+// SDK through a manual reader and reports the number of series per
+// instrument and attribute key, so cardinality explosions show up in the
+// logs (and the metric_series gauge) before they show up on the bill.
+type CardinalityReporter struct {
+	cfg    CardinalityReport
+	reader *sdkmetric.ManualReader
+
+	mu     sync.Mutex
+	latest []InstrumentCardinality
+}
+
+// NewCardinalityReporter creates a new CardinalityReporter. Its reader is
+// added to the meter provider with WithCardinalityReporter.
+func NewCardinalityReporter(c CardinalityReport) (*CardinalityReporter, error) {
+	if c.Interval <= 0 {
+		return nil, fmt.Errorf("invalid cardinality report interval %s", c.Interval)
+	}
+	return &CardinalityReporter{cfg: c, reader: sdkmetric.NewManualReader()}, nil
+}
+
+// Collect returns the cardinality of every instrument with data, the
+// instruments with the most series first.
+func (c *CardinalityReporter) Collect(ctx context.Context) ([]InstrumentCardinality, error) {
+	var rm metricdata.ResourceMetrics
+	if err := c.reader.Collect(ctx, &rm); err != nil {
+		return nil, fmt.Errorf("failed to collect metrics: %w", err)
+	}
+
+	var report []InstrumentCardinality
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sets := attributeSets(m.Data)
+			if len(sets) == 0 {
+				continue
+			}
+			values := make(map[string]map[attribute.Value]struct{})
+			for _, set := range sets {
+				for _, kv := range set.ToSlice() {
+					key := string(kv.Key)
+					if values[key] == nil {
+						values[key] = make(map[attribute.Value]struct{})
+					}
+					values[key][kv.Value] = struct{}{}
+				}
+			}
+			keys := make(map[string]int, len(values))
+			for key, v := range values {
+				keys[key] = len(v)
+			}
+			report = append(report, InstrumentCardinality{Name: m.Name, Series: len(sets), Keys: keys})
+		}
+	}
+	slices.SortFunc(report, func(a, b InstrumentCardinality) int {
+		if n := cmp.Compare(b.Series, a.Series); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return report, nil
+}
+
+// attributeSets returns the attribute sets of the data points of data, one
+// per series.
+func attributeSets(data metricdata.Aggregation) []attribute.Set {
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		return setsOf(d.DataPoints, func(p metricdata.DataPoint[int64]) attribute.Set { return p.Attributes })
+	case metricdata.Sum[float64]:
+		return setsOf(d.DataPoints, func(p metricdata.DataPoint[float64]) attribute.Set { return p.Attributes })
+	case metricdata.Gauge[int64]:
+		return setsOf(d.DataPoints, func(p metricdata.DataPoint[int64]) attribute.Set { return p.Attributes })
+	case metricdata.Gauge[float64]:
+		return setsOf(d.DataPoints, func(p metricdata.DataPoint[float64]) attribute.Set { return p.Attributes })
+	case metricdata.Histogram[int64]:
+		return setsOf(d.DataPoints, func(p metricdata.HistogramDataPoint[int64]) attribute.Set { return p.Attributes })
+	case metricdata.Histogram[float64]:
+		return setsOf(d.DataPoints, func(p metricdata.HistogramDataPoint[float64]) attribute.Set { return p.Attributes })
+	case metricdata.ExponentialHistogram[int64]:
+		return setsOf(d.DataPoints, func(p metricdata.ExponentialHistogramDataPoint[int64]) attribute.Set { return p.Attributes })
+	case metricdata.ExponentialHistogram[float64]:
+		return setsOf(d.DataPoints, func(p metricdata.ExponentialHistogramDataPoint[float64]) attribute.Set { return p.Attributes })
+	case metricdata.Summary:
+		return setsOf(d.DataPoints, func(p metricdata.SummaryDataPoint) attribute.Set { return p.Attributes })
+	default:
+		return nil
+	}
+}
+
+func setsOf[P any](points []P, attrs func(P) attribute.Set) []attribute.Set {
+	sets := make([]attribute.Set, len(points))
+	for i, p := range points {
+		sets[i] = attrs(p)
+	}
+	return sets
+}
+
+// Run reports the cardinality every interval until ctx is done.
+func (c *CardinalityReporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.report(ctx)
+		}
+	}
+}
+
+// report collects the cardinality, logs the instruments with the most
+// series and warns about the instruments over the threshold.
+func (c *CardinalityReporter) report(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	report, err := c.Collect(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to report metric cardinality", slog.Any("error", err))
+		return
+	}
+	c.mu.Lock()
+	c.latest = report
+	c.mu.Unlock()
+
+	total := 0
+	for _, ic := range report {
+		total += ic.Series
+	}
+	top := make([]any, 0, c.cfg.TopN)
+	for _, ic := range report[:min(c.cfg.TopN, len(report))] {
+		top = append(top, slog.Int(ic.Name, ic.Series))
+	}
+	logger.InfoContext(ctx, "metric cardinality report",
+		slog.Int("instruments", len(report)),
+		slog.Int("series", total),
+		slog.Group("top", top...),
+	)
+
+	if c.cfg.WarnThreshold <= 0 {
+		return
+	}
+	for _, ic := range report {
+		if ic.Series < c.cfg.WarnThreshold {
+			break
+		}
+		keys := make([]any, 0, len(ic.Keys))
+		for key, n := range ic.Keys {
+			keys = append(keys, slog.Int(key, n))
+		}
+		logger.WarnContext(ctx, "metric cardinality above threshold",
+			slog.String("instrument", ic.Name),
+			slog.Int("series", ic.Series),
+			slog.Int("threshold", c.cfg.WarnThreshold),
+			slog.Group("distinct_values", keys...),
+		)
+	}
+}
+
+// RegisterCardinalityGauge registers an observable gauge reporting the
+// number of series per instrument of the latest report of c.
+func RegisterCardinalityGauge(meter metric.Meter, c *CardinalityReporter) error {
+	_, err := meter.Int64ObservableGauge(
+		"metric_series",
+		metric.WithDescription("Number of series per metric instrument in the latest cardinality report"),
+		metric.WithUnit("{series}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for _, ic := range c.latest {
+				o.Observe(int64(ic.Series), metric.WithAttributes(attribute.String("metric.name", ic.Name)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create metric series gauge: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	channelzsvc "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ExporterConns tracks the gRPC client connections used by the OTLP exporters
+// so their connectivity state can be reported by readiness checks and metrics.
+// In shared mode, exporters of different signals connecting to the same
+// endpoint with the same transport settings reuse one connection, which
+// multiplexes their concurrent exports as separate streams.
+type ExporterConns struct {
+	shared bool
+
+	mu     sync.RWMutex
+	conns  map[string]*grpc.ClientConn
+	byDial map[string]*grpc.ClientConn
+}
+
+// NewExporterConns creates an empty ExporterConns registry. With shared set,
+// dialed connections are reused across signals.
+func NewExporterConns(shared bool) *ExporterConns {
+	return &ExporterConns{
+		shared: shared,
+		conns:  make(map[string]*grpc.ClientConn),
+		byDial: make(map[string]*grpc.ClientConn),
+	}
+}
+
+// Add registers the connection used by the exporter of the given signal.
+// It is safe to call on a nil registry.
+func (c *ExporterConns) Add(signal string, conn *grpc.ClientConn) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns[signal] = conn
+}
+
+// dial connects the OTLP exporter of signal like dialOTLP and registers the
+// connection. In shared mode, a connection dialed earlier for the same
+// endpoint, compression and security is reused; headers are sent per
+// export, so they may differ between the signals sharing it. The payloads
+// sent on the connection are measured by stats, if set. It is safe to call
+// on a nil registry.
+func (c *ExporterConns) dial(signal, endpoint string, collector *FileCollector, o OTLPConfig, stats *ExportStats) (*grpc.ClientConn, map[string]string, error) {
+	if c == nil || !c.shared {
+		conn, headers, err := dialOTLP(endpoint, collector, o, stats.dialOptions(signal, o.Compression)...)
+		if err == nil {
+			c.Add(signal, conn)
+		}
+		return conn, headers, err
+	}
+
+	key := fmt.Sprintf("%s|%t|%s", endpoint, o.Insecure, o.Compression)
+	if collector != nil {
+		key = fmt.Sprintf("file-collector|%s", o.Compression)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, ok := c.byDial[key]; ok {
+		headers, err := o.headers()
+		if err != nil {
+			return nil, nil, err
+		}
+		c.conns[signal] = conn
+		return conn, headers, nil
+	}
+	conn, headers, err := dialOTLP(endpoint, collector, o, stats.dialOptions(signal, o.Compression)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.byDial[key] = conn
+	c.conns[signal] = conn
+	return conn, headers, nil
+}
+
+// connections returns the distinct connections of the registry.
+func (c *ExporterConns) connections() []*grpc.ClientConn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var conns []*grpc.ClientConn
+	for _, conn := range c.conns {
+		if !slices.Contains(conns, conn) {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// Close closes the connections. The exporters don't close connections they
+// were given, so it must run after the providers using them are shut down.
+func (c *ExporterConns) Close(context.Context) error {
+	var errs []error
+	for _, conn := range c.connections() {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// States returns the current connectivity state per signal.
+func (c *ExporterConns) States() map[string]connectivity.State {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	states := make(map[string]connectivity.State, len(c.conns))
+	for signal, conn := range c.conns {
+		states[signal] = conn.GetState()
+	}
+	return states
+}
+
+// Healthy reports whether no exporter connection is failing.
+// Idle connections count as healthy because gRPC connects lazily, and
+// mirror connections are ignored since mirror exports may fail.
+func (c *ExporterConns) Healthy() bool {
+	for signal, state := range c.States() {
+		if strings.HasSuffix(signal, mirrorSuffix) {
+			continue
+		}
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterConnStateGauge registers observable gauges reporting 1 for the
+// current connectivity state of each exporter connection by signal, and the
+// number of distinct connections per state, which is lower than the number
+// of signals when connections are shared.
+func RegisterConnStateGauge(meter metric.Meter, conns *ExporterConns) error {
+	_, err := meter.Int64ObservableGauge(
+		"otlp_exporter_connection_state",
+		metric.WithDescription("Connectivity state of the OTLP exporter gRPC connections"),
+		metric.WithUnit("{connection}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for signal, state := range conns.States() {
+				o.Observe(1, metric.WithAttributes(
+					attribute.String("signal", signal),
+					attribute.String("grpc.state", state.String()),
+				))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create connection state gauge: %w", err)
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"otlp_exporter_connections",
+		metric.WithDescription("Number of distinct gRPC connections used by the OTLP exporters"),
+		metric.WithUnit("{connection}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			counts := make(map[connectivity.State]int64)
+			for _, conn := range conns.connections() {
+				counts[conn.GetState()]++
+			}
+			for state, n := range counts {
+				o.Observe(n, metric.WithAttributes(attribute.String("grpc.state", state.String())))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create connection count gauge: %w", err)
+	}
+	return nil
+}
+
+// StartChannelzServer serves the gRPC channelz service on addr so the
+// exporter connections can be inspected with tools like grpcdebug.
+func StartChannelzServer(addr string) (*grpc.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for channelz: %w", err)
+	}
+
+	s := grpc.NewServer()
+	channelzsvc.RegisterChannelzServiceToServer(s)
+
+	go s.Serve(lis) // async:ok: diagnostics server, not request work
+
+	return s, nil
+}
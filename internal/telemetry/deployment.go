@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// DeploymentProcessor is a span processor adding a fixed set of deployment
+// attributes to every span when it starts. It is an example of a custom
+// processor: only OnStart does any work, and since it holds no spans there
+// is nothing to flush or shut down.
+//
+// Unlike resource attributes, which are sent once per export batch, these
+// attributes are on each span, so they can be filtered on in backends that
+// only index span attributes.
+type DeploymentProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+var _ sdktrace.SpanProcessor = (*DeploymentProcessor)(nil)
+
+// NewDeploymentProcessor creates a new DeploymentProcessor adding attrs.
+func NewDeploymentProcessor(attrs ...attribute.KeyValue) *DeploymentProcessor {
+	return &DeploymentProcessor{attrs: attrs}
+}
+
+// OnStart adds the deployment attributes to s. Attributes set later by
+// instrumentation with the same key win.
+func (p *DeploymentProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(p.attrs...)
+}
+
+// OnEnd does nothing; ended spans are read-only.
+func (p *DeploymentProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing.
+func (p *DeploymentProcessor) Shutdown(context.Context) error { return nil }
+
+// ForceFlush does nothing.
+func (p *DeploymentProcessor) ForceFlush(context.Context) error { return nil }
+
+// deploymentAttributes parses spec, a comma-separated list of
+// attribute=ENV_VAR pairs, and reads each variable with getenv. Attributes
+// whose variable is unset or empty are left out.
+func deploymentAttributes(spec string, getenv func(string) string) ([]attribute.KeyValue, error) {
+	var attrs []attribute.KeyValue
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, env, ok := strings.Cut(pair, "=")
+		key, env = strings.TrimSpace(key), strings.TrimSpace(env)
+		if !ok || key == "" || env == "" {
+			return nil, fmt.Errorf("invalid deployment attribute %q", pair)
+		}
+		if value := getenv(env); value != "" {
+			attrs = append(attrs, attribute.String(key, value))
+		}
+	}
+	return attrs, nil
+}
@@ -0,0 +1,218 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+// otlpServices maps the gRPC services of the OTLP collector to the signal
+// they receive.
+var otlpServices = map[string]string{
+	"opentelemetry.proto.collector.trace.v1.TraceService":     "traces",
+	"opentelemetry.proto.collector.metrics.v1.MetricsService": "metrics",
+	"opentelemetry.proto.collector.logs.v1.LogsService":       "logs",
+}
+
+// ExportStats measures the OTLP exports of each signal: the duration of
+// every export, and the size of its serialized payload before and after
+// compression, so the cost of the telemetry of the service can be compared
+// across instrumentation and exporter settings.
+type ExportStats struct {
+	duration       metric.Float64Histogram
+	payloadSize    metric.Int64Histogram
+	compressedSize metric.Int64Histogram
+}
+
+// NewExportStats creates a new ExportStats recording to meter. The exporters
+// measured are created before the meter provider, so meter is usually the
+// global meter, which forwards to the provider once it is set.
+func NewExportStats(meter metric.Meter) (*ExportStats, error) {
+	s := &ExportStats{}
+
+	var err error
+
+	s.duration, err = meter.Float64Histogram(
+		"otlp_export_duration_seconds",
+		metric.WithDescription("Duration of OTLP exports by signal and outcome"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export duration histogram: %w", err)
+	}
+
+	sizeBuckets := metric.WithExplicitBucketBoundaries(1<<8, 1<<10, 1<<12, 1<<14, 1<<16, 1<<18, 1<<20, 1<<22)
+
+	s.payloadSize, err = meter.Int64Histogram(
+		"otlp_export_payload_size_bytes",
+		metric.WithDescription("Size of serialized OTLP export requests before compression"),
+		metric.WithUnit("By"),
+		sizeBuckets,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export payload size histogram: %w", err)
+	}
+
+	s.compressedSize, err = meter.Int64Histogram(
+		"otlp_export_compressed_size_bytes",
+		metric.WithDescription("Size of serialized OTLP export requests as sent, after compression"),
+		metric.WithUnit("By"),
+		sizeBuckets,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export compressed size histogram: %w", err)
+	}
+
+	return s, nil
+}
+
+// dialOptions returns the option measuring the payloads sent on a connection
+// dialed for signal with compression. It is safe to call on a nil
+// ExportStats, which measures nothing.
+func (s *ExportStats) dialOptions(signal, compression string) []grpc.DialOption {
+	if s == nil {
+		return nil
+	}
+	if compression == "" {
+		compression = CompressionNone
+	}
+	return []grpc.DialOption{grpc.WithStatsHandler(&payloadStats{
+		s:           s,
+		mirror:      strings.HasSuffix(signal, mirrorSuffix),
+		compression: compression,
+	})}
+}
+
+// record records an export of signal that started at start.
+func (s *ExportStats) record(ctx context.Context, signal string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	s.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("outcome", outcome),
+	))
+}
+
+// payloadStats is a gRPC stats handler recording the size of the export
+// requests sent on a connection. Connections may be shared between the
+// signals, so the signal of each request is taken from its method.
+type payloadStats struct {
+	s           *ExportStats
+	mirror      bool
+	compression string
+}
+
+type signalKey struct{}
+
+func (p *payloadStats) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	service, _, _ := strings.Cut(strings.TrimPrefix(info.FullMethodName, "/"), "/")
+	signal, ok := otlpServices[service]
+	if !ok {
+		return ctx
+	}
+	if p.mirror {
+		signal += mirrorSuffix
+	}
+	return context.WithValue(ctx, signalKey{}, signal)
+}
+
+func (p *payloadStats) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	out, ok := rs.(*stats.OutPayload)
+	if !ok {
+		return
+	}
+	signal, ok := ctx.Value(signalKey{}).(string)
+	if !ok {
+		return
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("otlp.compression", p.compression),
+	)
+	p.s.payloadSize.Record(ctx, int64(out.Length), attrs)
+	p.s.compressedSize.Record(ctx, int64(out.CompressedLength), attrs)
+}
+
+func (p *payloadStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (p *payloadStats) HandleConn(context.Context, stats.ConnStats) {}
+
+// wrapSpans returns exporter recording the duration of its exports as
+// signal. It is safe to call on a nil ExportStats, which returns exporter.
+func (s *ExportStats) wrapSpans(signal string, exporter sdktrace.SpanExporter) sdktrace.SpanExporter {
+	if s == nil {
+		return exporter
+	}
+	return &measuredSpanExporter{SpanExporter: exporter, s: s, signal: signal}
+}
+
+type measuredSpanExporter struct {
+	sdktrace.SpanExporter
+	s      *ExportStats
+	signal string
+}
+
+func (e *measuredSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.s.record(ctx, e.signal, start, err)
+	return err
+}
+
+// wrapMetrics returns exporter recording the duration of its exports as
+// signal. It is safe to call on a nil ExportStats, which returns exporter.
+func (s *ExportStats) wrapMetrics(signal string, exporter sdkmetric.Exporter) sdkmetric.Exporter {
+	if s == nil {
+		return exporter
+	}
+	return &measuredMetricExporter{Exporter: exporter, s: s, signal: signal}
+}
+
+type measuredMetricExporter struct {
+	sdkmetric.Exporter
+	s      *ExportStats
+	signal string
+}
+
+func (e *measuredMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	e.s.record(ctx, e.signal, start, err)
+	return err
+}
+
+// wrapLogs returns exporter recording the duration of its exports as
+// signal. It is safe to call on a nil ExportStats, which returns exporter.
+func (s *ExportStats) wrapLogs(signal string, exporter sdklog.Exporter) sdklog.Exporter {
+	if s == nil {
+		return exporter
+	}
+	return &measuredLogExporter{Exporter: exporter, s: s, signal: signal}
+}
+
+type measuredLogExporter struct {
+	sdklog.Exporter
+	s      *ExportStats
+	signal string
+}
+
+func (e *measuredLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.s.record(ctx, e.signal, start, err)
+	return err
+}
@@ -0,0 +1,276 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Fallbacks used while the collector is unavailable.
+const (
+	FallbackStdout = "stdout"
+	FallbackDrop   = "drop"
+)
+
+// Degradation switches exporters to a local fallback when exports to the
+// collector fail, and back once a probe export succeeds again. It reports
+// which signals are degraded for readiness checks.
+type Degradation struct {
+	fallback string
+	probe    time.Duration
+	out      io.Writer
+
+	// logger writes state changes to stderr, since the OTLP log pipeline
+	// may be the one that is failing.
+	logger *slog.Logger
+
+	mu       sync.RWMutex
+	degraded map[string]bool
+}
+
+// NewDegradation creates a new Degradation using fallback (stdout or drop)
+// while degraded, retrying the collector every probeInterval.
+func NewDegradation(fallback string, probeInterval time.Duration) (*Degradation, error) {
+	switch fallback {
+	case FallbackStdout, FallbackDrop:
+	default:
+		return nil, fmt.Errorf("unsupported telemetry fallback %q", fallback)
+	}
+	return &Degradation{
+		fallback: fallback,
+		probe:    probeInterval,
+		out:      os.Stdout,
+		logger:   slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		degraded: make(map[string]bool),
+	}, nil
+}
+
+// Fallback returns the configured fallback.
+func (d *Degradation) Fallback() string {
+	return d.fallback
+}
+
+// Degraded reports whether any signal is exported to the fallback.
+// It is safe to call on a nil Degradation.
+func (d *Degradation) Degraded() bool {
+	if d == nil {
+		return false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, degraded := range d.degraded {
+		if degraded {
+			return true
+		}
+	}
+	return false
+}
+
+// Signals returns whether each wrapped signal is degraded.
+func (d *Degradation) Signals() map[string]bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return maps.Clone(d.degraded)
+}
+
+func (d *Degradation) set(signal string, degraded bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.degraded[signal] = degraded
+}
+
+// failover holds the export state of one signal.
+type failover struct {
+	signal string
+	d      *Degradation
+
+	mu        sync.Mutex
+	degraded  bool
+	nextProbe time.Time
+}
+
+func (d *Degradation) failover(signal string) *failover {
+	d.set(signal, false)
+	return &failover{signal: signal, d: d}
+}
+
+// export calls primary unless the signal is degraded and no probe is due,
+// and calls fallback instead of or after a failing primary. Primary errors
+// are not returned, so the SDK doesn't report every failed export.
+func (f *failover) export(ctx context.Context, primary, fallback func(context.Context) error) error {
+	f.mu.Lock()
+	skip := f.degraded && time.Now().Before(f.nextProbe)
+	f.mu.Unlock()
+	if skip {
+		return fallback(ctx)
+	}
+
+	if err := primary(ctx); err != nil {
+		f.failed(err)
+		return fallback(ctx)
+	}
+	f.recovered()
+	return nil
+}
+
+func (f *failover) failed(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextProbe = time.Now().Add(f.d.probe)
+	if f.degraded {
+		return
+	}
+	f.degraded = true
+	f.d.set(f.signal, true)
+	f.d.logger.Warn("telemetry export failed, switching to fallback",
+		slog.String("signal", f.signal),
+		slog.String("fallback", f.d.fallback),
+		slog.Duration("probe_interval", f.d.probe),
+		slog.Any("error", err),
+	)
+}
+
+func (f *failover) recovered() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.degraded {
+		return
+	}
+	f.degraded = false
+	f.d.set(f.signal, false)
+	f.d.logger.Info("telemetry export recovered", slog.String("signal", f.signal))
+}
+
+// WrapSpanExporter returns a span exporter that falls back when exporter fails.
+func (d *Degradation) WrapSpanExporter(exporter sdktrace.SpanExporter) (sdktrace.SpanExporter, error) {
+	var fallback sdktrace.SpanExporter
+	if d.fallback == FallbackStdout {
+		var err error
+		fallback, err = stdouttrace.New(stdouttrace.WithWriter(d.out))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout trace exporter: %w", err)
+		}
+	}
+	return &failoverSpanExporter{primary: exporter, fallback: fallback, f: d.failover("traces")}, nil
+}
+
+type failoverSpanExporter struct {
+	primary  sdktrace.SpanExporter
+	fallback sdktrace.SpanExporter
+	f        *failover
+}
+
+func (e *failoverSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.f.export(ctx,
+		func(ctx context.Context) error { return e.primary.ExportSpans(ctx, spans) },
+		func(ctx context.Context) error {
+			if e.fallback == nil {
+				return nil
+			}
+			return e.fallback.ExportSpans(ctx, spans)
+		},
+	)
+}
+
+func (e *failoverSpanExporter) Shutdown(ctx context.Context) error {
+	if e.fallback != nil {
+		_ = e.fallback.Shutdown(ctx)
+	}
+	return e.primary.Shutdown(ctx)
+}
+
+// WrapMetricExporter returns a metric exporter that falls back when exporter
+// fails. Temporality and aggregation are taken from exporter.
+func (d *Degradation) WrapMetricExporter(exporter sdkmetric.Exporter) (sdkmetric.Exporter, error) {
+	var fallback sdkmetric.Exporter
+	if d.fallback == FallbackStdout {
+		var err error
+		fallback, err = stdoutmetric.New(
+			stdoutmetric.WithWriter(d.out),
+			stdoutmetric.WithTemporalitySelector(exporter.Temporality),
+			stdoutmetric.WithAggregationSelector(exporter.Aggregation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout metric exporter: %w", err)
+		}
+	}
+	return &failoverMetricExporter{Exporter: exporter, fallback: fallback, f: d.failover("metrics")}, nil
+}
+
+type failoverMetricExporter struct {
+	sdkmetric.Exporter
+	fallback sdkmetric.Exporter
+	f        *failover
+}
+
+func (e *failoverMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.f.export(ctx,
+		func(ctx context.Context) error { return e.Exporter.Export(ctx, rm) },
+		func(ctx context.Context) error {
+			if e.fallback == nil {
+				return nil
+			}
+			return e.fallback.Export(ctx, rm)
+		},
+	)
+}
+
+func (e *failoverMetricExporter) Shutdown(ctx context.Context) error {
+	if e.fallback != nil {
+		_ = e.fallback.Shutdown(ctx)
+	}
+	return e.Exporter.Shutdown(ctx)
+}
+
+// WrapLogExporter returns a log exporter that falls back when exporter fails.
+func (d *Degradation) WrapLogExporter(exporter sdklog.Exporter) (sdklog.Exporter, error) {
+	var fallback sdklog.Exporter
+	if d.fallback == FallbackStdout {
+		var err error
+		fallback, err = stdoutlog.New(stdoutlog.WithWriter(d.out))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout log exporter: %w", err)
+		}
+	}
+	return &failoverLogExporter{Exporter: exporter, fallback: fallback, f: d.failover("logs")}, nil
+}
+
+type failoverLogExporter struct {
+	sdklog.Exporter
+	fallback sdklog.Exporter
+	f        *failover
+}
+
+func (e *failoverLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return e.f.export(ctx,
+		func(ctx context.Context) error { return e.Exporter.Export(ctx, records) },
+		func(ctx context.Context) error {
+			if e.fallback == nil {
+				return nil
+			}
+			return e.fallback.Export(ctx, records)
+		},
+	)
+}
+
+func (e *failoverLogExporter) Shutdown(ctx context.Context) error {
+	if e.fallback != nil {
+		_ = e.fallback.Shutdown(ctx)
+	}
+	return e.Exporter.Shutdown(ctx)
+}
@@ -0,0 +1,341 @@
+package telemetry
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// fileCollectorBufferSize is the size of the in-memory connection between
+// the exporters and the file collector.
+const fileCollectorBufferSize = 1 << 20
+
+// FileCollector is an in-process OTLP collector that writes every export
+// request as one OTLP JSON line to a rotating file per signal (traces.jsonl,
+// metrics.jsonl, logs.jsonl), like the collector's file exporter. The files
+// can be replayed with the collector's otlpjsonfile receiver.
+//
+// The OTLP gRPC exporters reach it over an in-memory connection (Dial), so
+// no network collector is needed.
+type FileCollector struct {
+	lis    *bufconn.Listener
+	server *grpc.Server
+	files  []*rotatingFile
+}
+
+// NewFileCollector starts a FileCollector writing to dir. A file is rotated
+// once it would grow beyond maxSize bytes; at most maxBackups rotated files
+// are kept per signal (0 keeps all).
+func NewFileCollector(dir string, maxSize int64, maxBackups int) (*FileCollector, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create telemetry file directory: %w", err)
+	}
+
+	traces, err := openRotatingFile(dir, "traces", maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := openRotatingFile(dir, "metrics", maxSize, maxBackups)
+	if err != nil {
+		_ = traces.Close()
+		return nil, err
+	}
+	logs, err := openRotatingFile(dir, "logs", maxSize, maxBackups)
+	if err != nil {
+		_ = traces.Close()
+		_ = metrics.Close()
+		return nil, err
+	}
+
+	c := &FileCollector{
+		lis:    bufconn.Listen(fileCollectorBufferSize),
+		server: grpc.NewServer(),
+		files:  []*rotatingFile{traces, metrics, logs},
+	}
+	coltracepb.RegisterTraceServiceServer(c.server, &fileTraceService{out: traces})
+	colmetricspb.RegisterMetricsServiceServer(c.server, &fileMetricsService{out: metrics})
+	collogspb.RegisterLogsServiceServer(c.server, &fileLogsService{out: logs})
+
+	go func() { _ = c.server.Serve(c.lis) }() // async:ok: telemetry must not trace itself
+	return c, nil
+}
+
+// Dial returns a client connection to the collector for an OTLP gRPC
+// exporter. opts must include plaintext transport credentials.
+func (c *FileCollector) Dial(opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return c.lis.DialContext(ctx)
+	}))
+	conn, err := grpc.NewClient("passthrough:///file-collector", opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to file collector: %w", err)
+	}
+	return conn, nil
+}
+
+// Shutdown waits for in-flight exports and closes the files. It must run
+// after the providers using the collector are shut down.
+func (c *FileCollector) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() { // async:ok: bounds a blocking call by ctx
+		c.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		c.server.Stop()
+	}
+
+	var errs []error
+	for _, f := range c.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+type fileTraceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	out *rotatingFile
+}
+
+func (s *fileTraceService) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	if err := s.out.writeRequest(req); err != nil {
+		return nil, err
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+type fileMetricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	out *rotatingFile
+}
+
+func (s *fileMetricsService) Export(_ context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	if err := s.out.writeRequest(req); err != nil {
+		return nil, err
+	}
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+type fileLogsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	out *rotatingFile
+}
+
+func (s *fileLogsService) Export(_ context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	if err := s.out.writeRequest(req); err != nil {
+		return nil, err
+	}
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+// otlpJSON is the OTLP JSON encoding: lowerCamelCase field names and enums
+// as numbers. IDs are fixed up by hexIDs.
+var otlpJSON = protojson.MarshalOptions{UseEnumNumbers: true}
+
+// marshalOTLPJSON encodes msg as a single OTLP JSON line.
+func marshalOTLPJSON(msg proto.Message) ([]byte, error) {
+	b, err := otlpJSON.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	hexIDs(v)
+	return json.Marshal(v)
+}
+
+// hexIDs rewrites the trace and span IDs in v from the protobuf JSON
+// encoding of bytes (base64) to the hex strings OTLP JSON requires.
+func hexIDs(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			switch k {
+			case "traceId", "spanId", "parentSpanId":
+				if s, ok := field.(string); ok {
+					if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+						v[k] = hex.EncodeToString(id)
+					}
+				}
+			default:
+				hexIDs(field)
+			}
+		}
+	case []any:
+		for _, e := range v {
+			hexIDs(e)
+		}
+	}
+}
+
+// rotatingFile is an append-only JSON lines file that is renamed to
+// <name>-<timestamp>.jsonl once it reaches its maximum size.
+type rotatingFile struct {
+	dir        string
+	name       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(dir, name string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{dir: dir, name: name, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) path() string {
+	return filepath.Join(r.dir, r.name+".jsonl")
+}
+
+func (r *rotatingFile) open() error {
+	f, size, err := r.openFile()
+	if err != nil {
+		return err
+	}
+	r.f = f
+	r.size = size
+	return nil
+}
+
+// openFile opens the current file for appending and returns its size.
+func (r *rotatingFile) openFile() (*os.File, int64, error) {
+	f, err := os.OpenFile(r.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open telemetry file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, fmt.Errorf("failed to stat telemetry file: %w", err)
+	}
+	return f, info.Size(), nil
+}
+
+func (r *rotatingFile) writeRequest(msg proto.Message) error {
+	line, err := marshalOTLPJSON(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", r.name, err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		// A failed rotation keeps the current file, so the line is still
+		// written and the next write retries.
+		if err := r.rotate(); err != nil {
+			otel.Handle(err)
+		}
+	}
+	n, err := r.f.Write(line)
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", r.name, err)
+	}
+	return nil
+}
+
+// rotate moves the current file aside, opens a new one and removes the
+// oldest backups beyond maxBackups. The current handle is only replaced
+// once the new file is open; if that fails, the file is moved back and
+// r.f stays usable. r.mu must be held.
+func (r *rotatingFile) rotate() error {
+	backup := r.backupPath(time.Now())
+	if err := os.Rename(r.path(), backup); err != nil {
+		return fmt.Errorf("failed to rotate telemetry file: %w", err)
+	}
+	f, size, err := r.openFile()
+	if err != nil {
+		if rerr := os.Rename(backup, r.path()); rerr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to restore telemetry file: %w", rerr))
+		}
+		return err
+	}
+	if err := r.f.Close(); err != nil {
+		otel.Handle(fmt.Errorf("failed to close rotated telemetry file: %w", err))
+	}
+	r.f = f
+	r.size = size
+
+	if r.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(filepath.Join(r.dir, r.name+"-*.jsonl"))
+	if err != nil {
+		return nil
+	}
+	slices.SortFunc(backups, func(a, b string) int {
+		ta, sa := r.backupOrder(a)
+		tb, sb := r.backupOrder(b)
+		return cmp.Or(strings.Compare(ta, tb), cmp.Compare(sa, sb))
+	})
+	for _, old := range backups[:max(len(backups)-r.maxBackups, 0)] {
+		_ = os.Remove(old)
+	}
+	return nil
+}
+
+// backupTimeFormat is the time format of backup names, which sorts
+// chronologically.
+const backupTimeFormat = "20060102T150405.000"
+
+// backupPath returns the path to rotate the current file to at now. The
+// rename would replace an existing file, so backups taken within the same
+// millisecond get a sequence suffix: <name>-<timestamp>-1.jsonl, and so on.
+func (r *rotatingFile) backupPath(now time.Time) string {
+	base := filepath.Join(r.dir, r.name+"-"+now.UTC().Format(backupTimeFormat))
+	backup := base + ".jsonl"
+	for seq := 1; ; seq++ {
+		if _, err := os.Lstat(backup); err != nil {
+			return backup
+		}
+		backup = fmt.Sprintf("%s-%d.jsonl", base, seq)
+	}
+}
+
+// backupOrder returns the timestamp and sequence number of the backup at
+// path, by which backups sort from oldest to newest.
+func (r *rotatingFile) backupOrder(path string) (string, int) {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), r.name+"-"), ".jsonl")
+	timestamp, seq, _ := strings.Cut(name, "-")
+	n, _ := strconv.Atoi(seq)
+	return timestamp, n
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
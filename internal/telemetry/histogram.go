@@ -0,0 +1,52 @@
+package telemetry
+
+import (
+	"fmt"
+	"strings"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Supported histogram aggregations, matching the values of
+// OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION.
+const (
+	HistogramExplicit    = "explicit_bucket_histogram"
+	HistogramExponential = "base2_exponential_bucket_histogram"
+)
+
+// HistogramViews returns the views that apply the histogram aggregation to
+// all histogram instruments.
+//
+// With "explicit_bucket_histogram", the bucket boundaries hinted by each
+// instrument are kept, unless durationBoundaries is set, in which case it
+// replaces the boundaries of every histogram measured in seconds. With
+// "base2_exponential_bucket_histogram", bucket boundaries are chosen by the
+// SDK from the recorded values and durationBoundaries is ignored.
+func HistogramViews(aggregation string, durationBoundaries []float64) ([]sdkmetric.View, error) {
+	switch strings.ToLower(aggregation) {
+	case "", HistogramExplicit:
+		if len(durationBoundaries) == 0 {
+			return nil, nil
+		}
+		return []sdkmetric.View{
+			sdkmetric.NewView(
+				sdkmetric.Instrument{Kind: sdkmetric.InstrumentKindHistogram, Unit: "s"},
+				sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{
+					Boundaries: durationBoundaries,
+				}},
+			),
+		}, nil
+	case HistogramExponential:
+		return []sdkmetric.View{
+			sdkmetric.NewView(
+				sdkmetric.Instrument{Kind: sdkmetric.InstrumentKindHistogram},
+				sdkmetric.Stream{Aggregation: sdkmetric.AggregationBase2ExponentialHistogram{
+					MaxSize:  160,
+					MaxScale: 20,
+				}},
+			),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown histogram aggregation %q", aggregation)
+	}
+}
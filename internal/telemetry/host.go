@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"go.opentelemetry.io/contrib/instrumentation/host"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RegisterHostMetrics starts the contrib host instrumentation on provider,
+// which reports the CPU time of the process and the host, host memory usage
+// and utilization and network I/O, and registers gauges with meter for the
+// memory, open file descriptors and threads of the process. Values a
+// platform can't report, e.g. file descriptors on Windows, are left out.
+func RegisterHostMetrics(provider metric.MeterProvider, meter metric.Meter) error {
+	if err := host.Start(host.WithMeterProvider(provider)); err != nil {
+		return fmt.Errorf("failed to start host metrics: %w", err)
+	}
+
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		return fmt.Errorf("failed to inspect process: %w", err)
+	}
+
+	memory, err := meter.Int64ObservableGauge(
+		"process.memory.usage",
+		metric.WithDescription("Resident memory of the process"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create process memory gauge: %w", err)
+	}
+	virtual, err := meter.Int64ObservableGauge(
+		"process.memory.virtual",
+		metric.WithDescription("Virtual memory of the process"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create process virtual memory gauge: %w", err)
+	}
+	fds, err := meter.Int64ObservableGauge(
+		"process.open_file_descriptor.count",
+		metric.WithDescription("Number of file descriptors open in the process"),
+		metric.WithUnit("{file_descriptor}"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create open file descriptor gauge: %w", err)
+	}
+	threads, err := meter.Int64ObservableGauge(
+		"process.thread.count",
+		metric.WithDescription("Number of OS threads of the process"),
+		metric.WithUnit("{thread}"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create process thread gauge: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		if mem, err := proc.MemoryInfoWithContext(ctx); err == nil {
+			o.ObserveInt64(memory, int64(mem.RSS))
+			o.ObserveInt64(virtual, int64(mem.VMS))
+		}
+		if n, err := proc.NumFDsWithContext(ctx); err == nil {
+			o.ObserveInt64(fds, int64(n))
+		}
+		if n, err := proc.NumThreadsWithContext(ctx); err == nil {
+			o.ObserveInt64(threads, int64(n))
+		}
+		return nil
+	}, memory, virtual, fds, threads)
+	if err != nil {
+		return fmt.Errorf("failed to register process metrics callback: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BufferedLog is a log record kept by a LogBuffer.
+type BufferedLog struct {
+	Timestamp  time.Time      `json:"timestamp"`
+	Severity   string         `json:"severity"`
+	Body       string         `json:"body"`
+	TraceID    string         `json:"trace_id,omitempty"`
+	SpanID     string         `json:"span_id,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// LogBuffer is a log processor keeping the most recent log records in
+// memory, independent of the exporter, so the records of a trace can be
+// collected into a support bundle after the fact.
+type LogBuffer struct {
+	mu      sync.Mutex
+	records []BufferedLog
+	next    int
+	full    bool
+}
+
+// NewLogBuffer creates a new LogBuffer keeping the last size records.
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{records: make([]BufferedLog, size)}
+}
+
+// OnEmit implements sdklog.Processor.
+func (b *LogBuffer) OnEmit(_ context.Context, r *sdklog.Record) error {
+	l := BufferedLog{
+		Timestamp: r.Timestamp(),
+		Severity:  r.SeverityText(),
+		Body:      r.Body().String(),
+	}
+	if l.Severity == "" {
+		l.Severity = r.Severity().String()
+	}
+	if r.TraceID().IsValid() {
+		l.TraceID = r.TraceID().String()
+		l.SpanID = r.SpanID().String()
+	}
+	if r.AttributesLen() > 0 {
+		l.Attributes = make(map[string]any, r.AttributesLen())
+		r.WalkAttributes(func(kv log.KeyValue) bool {
+			l.Attributes[kv.Key] = bufferedValue(kv.Value)
+			return true
+		})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.records[b.next] = l
+	b.next = (b.next + 1) % len(b.records)
+	if b.next == 0 {
+		b.full = true
+	}
+	return nil
+}
+
+// Records returns the buffered records of the trace traceID, oldest first,
+// or all buffered records if traceID is invalid.
+func (b *LogBuffer) Records(traceID trace.TraceID) []BufferedLog {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := b.records[:b.next]
+	if b.full {
+		ordered = append(append([]BufferedLog(nil), b.records[b.next:]...), b.records[:b.next]...)
+	}
+
+	var records []BufferedLog
+	for _, l := range ordered {
+		if !traceID.IsValid() || l.TraceID == traceID.String() {
+			records = append(records, l)
+		}
+	}
+	return records
+}
+
+// Shutdown implements sdklog.Processor.
+func (b *LogBuffer) Shutdown(context.Context) error { return nil }
+
+// ForceFlush implements sdklog.Processor.
+func (b *LogBuffer) ForceFlush(context.Context) error { return nil }
+
+// bufferedValue converts v into a value encoding to the equivalent JSON.
+func bufferedValue(v log.Value) any {
+	switch v.Kind() {
+	case log.KindBool:
+		return v.AsBool()
+	case log.KindFloat64:
+		return v.AsFloat64()
+	case log.KindInt64:
+		return v.AsInt64()
+	case log.KindSlice:
+		values := v.AsSlice()
+		s := make([]any, len(values))
+		for i, e := range values {
+			s[i] = bufferedValue(e)
+		}
+		return s
+	case log.KindMap:
+		kvs := v.AsMap()
+		m := make(map[string]any, len(kvs))
+		for _, kv := range kvs {
+			m[kv.Key] = bufferedValue(kv.Value)
+		}
+		return m
+	default:
+		return v.String()
+	}
+}
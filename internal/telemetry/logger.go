@@ -5,58 +5,177 @@ import (
 	"fmt"
 	"log/slog"
 
-	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/buildinfo"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/log/global"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
+type loggerOptions struct {
+	degradation *Degradation
+	collector   *FileCollector
+	mirror      Mirror
+	otlp        OTLPConfig
+	batch       BatchConfig
+	drops       *DropReporter
+	stats       *ExportStats
+	buffer      *LogBuffer
+	level       string
+}
+
+// LoggerOption configures InitLoggerProvider.
+type LoggerOption func(*loggerOptions)
+
+// WithLogFallback exports log records to the fallback of d while the
+// exporter is failing.
+func WithLogFallback(d *Degradation) LoggerOption {
+	return func(o *loggerOptions) {
+		o.degradation = d
+	}
+}
+
+// WithLogOTLP configures the headers, compression and TLS of the OTLP
+// exporter.
+func WithLogOTLP(c OTLPConfig) LoggerOption {
+	return func(o *loggerOptions) {
+		o.otlp = c
+	}
+}
+
+// WithLogLevel drops log records below level (trace, debug, info, notice,
+// warn, error or critical)
+// before they are exported.
+func WithLogLevel(level string) LoggerOption {
+	return func(o *loggerOptions) {
+		o.level = level
+	}
+}
+
+// WithLogBatchConfig tunes the export queue of log records.
+func WithLogBatchConfig(c BatchConfig) LoggerOption {
+	return func(o *loggerOptions) {
+		o.batch = c
+	}
+}
+
+// WithLogDropReporter counts and reports log records dropped because the
+// export queue is full.
+func WithLogDropReporter(d *DropReporter) LoggerOption {
+	return func(o *loggerOptions) {
+		o.drops = d
+	}
+}
+
+// WithLogExportStats measures the duration and payload size of the OTLP log
+// record exports with s.
+func WithLogExportStats(s *ExportStats) LoggerOption {
+	return func(o *loggerOptions) {
+		o.stats = s
+	}
+}
+
+// WithLogFileCollector sends OTLP log records to c instead of the collector
+// at the OTLP endpoint.
+func WithLogFileCollector(c *FileCollector) LoggerOption {
+	return func(o *loggerOptions) {
+		o.collector = c
+	}
+}
+
+// WithLogBuffer also keeps the most recent log records in b.
+func WithLogBuffer(b *LogBuffer) LoggerOption {
+	return func(o *loggerOptions) {
+		o.buffer = b
+	}
+}
+
+// WithLogMirror also exports log records to m if it mirrors logs.
+func WithLogMirror(m Mirror) LoggerOption {
+	return func(o *loggerOptions) {
+		o.mirror = m
+	}
+}
+
 // InitLoggerProvider initializes the OpenTelemetry logger provider.
 // It configures an OTLP gRPC exporter and returns a slog.Logger that
-// bridges to OpenTelemetry for log-trace correlation.
-func InitLoggerProvider(ctx context.Context, serviceName, otlpEndpoint, environment string) (*sdklog.LoggerProvider, *slog.Logger, error) {
+// bridges to OpenTelemetry for log-trace correlation. The logger is also
+// installed as the slog (and log package) default, so third-party logs are
+// exported and correlated as well.
+// The exporter connection is registered with conns for health reporting.
+func InitLoggerProvider(ctx context.Context, serviceName, otlpEndpoint, environment string, conns *ExporterConns, opts ...LoggerOption) (*sdklog.LoggerProvider, *slog.Logger, error) {
+	o := loggerOptions{otlp: defaultOTLPConfig}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	level := slog.LevelDebug
+	if o.level != "" {
+		var err error
+		if level, err = logging.ParseLevel(o.level); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// Create OTLP gRPC exporter
-	conn, err := grpc.NewClient(otlpEndpoint,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	conn, headers, err := conns.dial("logs", otlpEndpoint, o.collector, o.otlp, o.stats)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+		return nil, nil, err
 	}
 
-	exporter, err := otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn))
+	var exporter sdklog.Exporter
+	exporter, err = otlploggrpc.New(ctx,
+		otlploggrpc.WithGRPCConn(conn),
+		otlploggrpc.WithHeaders(headers),
+	)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create log exporter: %w", err)
 	}
+	exporter = o.stats.wrapLogs("logs", exporter)
+	if o.degradation != nil {
+		exporter, err = o.degradation.WrapLogExporter(exporter)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if o.mirror.enabled("logs") {
+		exporter, err = o.mirror.mirrorLogs(ctx, exporter, conns, o.stats)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// Create resource with service information
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(serviceName),
-			semconv.DeploymentEnvironment(environment),
-		),
+	serviceRes := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(buildinfo.Version),
+		semconv.DeploymentEnvironment(environment),
 	)
+	res, err := resource.Merge(resource.Default(), serviceRes)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Create logger provider with batch processor
-	lp := sdklog.NewLoggerProvider(
-		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
-		sdklog.WithResource(res),
-	)
+	// Create logger provider with a batching export queue, preceded by the
+	// buffer of recent records if any
+	lpOpts := []sdklog.LoggerProviderOption{sdklog.WithResource(res)}
+	if o.buffer != nil {
+		lpOpts = append(lpOpts, sdklog.WithProcessor(o.buffer))
+	}
+	lpOpts = append(lpOpts, sdklog.WithProcessor(newLogQueueProcessor(exporter, o.batch, o.drops)))
+	lp := sdklog.NewLoggerProvider(lpOpts...)
 
 	// Set global logger provider
 	global.SetLoggerProvider(lp)
 
-	// Create slog logger that bridges to OpenTelemetry
-	// This enables automatic log-trace correlation
-	logger := otelslog.NewLogger(serviceName)
+	// Create slog logger that emits OpenTelemetry log records
+	// This enables automatic log-trace correlation; the trace handler also
+	// puts trace and service identifiers into the record attributes
+	logger := slog.New(NewTraceHandler(NewLevelHandler(level, NewLogHandler(serviceName)), serviceRes))
+	slog.SetDefault(logger)
 
 	return lp, logger, nil
 }
@@ -0,0 +1,301 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/jsoncodec"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// maxStackDepth bounds the number of frames of exception.stacktrace.
+const maxStackDepth = 64
+
+// LogHandler is a slog.Handler that emits records as OpenTelemetry log
+// records, keeping more of their structure than the otelslog bridge:
+//
+//   - Levels map to severities after their syslog counterparts, with the
+//     level name (e.g. NOTICE) as severity text.
+//   - Groups, group values and structs become nested map values instead of
+//     flattened or formatted strings.
+//   - The first error attribute outside a group becomes the
+//     exception.type and exception.message attributes.
+//   - Records at ERROR and above get the stack of the logging call as
+//     exception.stacktrace.
+type LogHandler struct {
+	logger log.Logger
+
+	// groups are the open groups, innermost last. attrs[0] holds the
+	// attributes outside any group and attrs[i] those of groups[i-1].
+	groups []string
+	attrs  [][]log.KeyValue
+}
+
+// NewLogHandler creates a LogHandler emitting to the logger named name of
+// the global logger provider.
+func NewLogHandler(name string) *LogHandler {
+	return &LogHandler{
+		logger: global.GetLoggerProvider().Logger(name),
+		attrs:  make([][]log.KeyValue, 1),
+	}
+}
+
+// Enabled reports whether the logger emits records of level.
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	var param log.EnabledParameters
+	param.SetSeverity(severity(level))
+	return h.logger.Enabled(ctx, param)
+}
+
+// Handle converts the record and emits it.
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var record log.Record
+	record.SetTimestamp(r.Time)
+	record.SetObservedTimestamp(r.Time)
+	record.SetBody(log.StringValue(r.Message))
+	record.SetSeverity(severity(r.Level))
+	record.SetSeverityText(logging.LevelName(r.Level))
+
+	attrs := make([]log.KeyValue, 0, r.NumAttrs())
+	var exception error
+	r.Attrs(func(a slog.Attr) bool {
+		a.Value = a.Value.Resolve()
+		if err, ok := a.Value.Any().(error); ok && len(h.groups) == 0 && exception == nil {
+			exception = err
+			return true
+		}
+		attrs = appendAttr(attrs, a)
+		return true
+	})
+
+	// Close the groups from the innermost, leaving out empty ones.
+	for i := len(h.groups); i > 0; i-- {
+		inner := append(h.attrs[i][:len(h.attrs[i]):len(h.attrs[i])], attrs...)
+		attrs = nil
+		if len(inner) > 0 {
+			attrs = []log.KeyValue{log.Map(h.groups[i-1], inner...)}
+		}
+	}
+	record.AddAttributes(h.attrs[0]...)
+	record.AddAttributes(attrs...)
+
+	if exception != nil {
+		record.AddAttributes(
+			log.String(string(semconv.ExceptionTypeKey), errorType(exception)),
+			log.String(string(semconv.ExceptionMessageKey), exception.Error()),
+		)
+	}
+	if r.Level >= slog.LevelError && r.PC != 0 {
+		record.AddAttributes(log.String(string(semconv.ExceptionStacktraceKey), stackFrom(r.PC)))
+	}
+
+	h.logger.Emit(ctx, record)
+	return nil
+}
+
+// WithAttrs returns a LogHandler with attrs added to the innermost group.
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := h.clone()
+	last := len(h2.attrs) - 1
+	kvs := h2.attrs[last][:len(h2.attrs[last]):len(h2.attrs[last])]
+	for _, a := range attrs {
+		kvs = appendAttr(kvs, a)
+	}
+	h2.attrs[last] = kvs
+	return h2
+}
+
+// WithGroup returns a LogHandler that nests further attributes in the
+// group name.
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := h.clone()
+	h2.groups = append(h2.groups, name)
+	h2.attrs = append(h2.attrs, nil)
+	return h2
+}
+
+// clone returns a copy of h that can get attributes and groups without
+// changing h.
+func (h *LogHandler) clone() *LogHandler {
+	return &LogHandler{
+		logger: h.logger,
+		groups: h.groups[:len(h.groups):len(h.groups)],
+		attrs:  slices.Clone(h.attrs),
+	}
+}
+
+// severity maps a slog level to the severity of its syslog counterpart in
+// the OpenTelemetry data model. Levels between them keep their distance to
+// the next lower slog level, e.g. WARN+1 is WARN2.
+func severity(level slog.Level) log.Severity {
+	switch level {
+	case logging.LevelTrace:
+		return log.SeverityTrace
+	case logging.LevelNotice:
+		return log.SeverityInfo2
+	case logging.LevelCritical:
+		return log.SeverityFatal
+	}
+	const offset = slog.Level(log.SeverityDebug) - slog.LevelDebug
+	return log.Severity(min(max(level+offset, slog.Level(log.SeverityTrace1)), slog.Level(log.SeverityFatal4)))
+}
+
+// appendAttr appends a converted to kvs, leaving out empty attributes and
+// inlining groups without a key, as slog handlers do.
+func appendAttr(kvs []log.KeyValue, a slog.Attr) []log.KeyValue {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		if len(group) == 0 {
+			return kvs
+		}
+		if a.Key == "" {
+			for _, ga := range group {
+				kvs = appendAttr(kvs, ga)
+			}
+			return kvs
+		}
+	}
+	return append(kvs, log.KeyValue{Key: a.Key, Value: logValue(a.Value)})
+}
+
+// logValue converts a resolved slog value to a log value.
+func logValue(v slog.Value) log.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return log.StringValue(v.String())
+	case slog.KindInt64:
+		return log.Int64Value(v.Int64())
+	case slog.KindUint64:
+		return log.Int64Value(int64(min(v.Uint64(), 1<<63-1)))
+	case slog.KindFloat64:
+		return log.Float64Value(v.Float64())
+	case slog.KindBool:
+		return log.BoolValue(v.Bool())
+	case slog.KindDuration:
+		return log.StringValue(v.Duration().String())
+	case slog.KindTime:
+		return log.StringValue(v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		var kvs []log.KeyValue
+		for _, a := range v.Group() {
+			kvs = appendAttr(kvs, a)
+		}
+		return log.MapValue(kvs...)
+	default:
+		return anyValue(v.Any())
+	}
+}
+
+// anyValue converts a value of any other type: errors and fmt.Stringers
+// to strings, byte slices to bytes, and other values to their JSON
+// structure, falling back to the %+v format.
+func anyValue(v any) log.Value {
+	switch v := v.(type) {
+	case nil:
+		return log.Value{}
+	case error:
+		return log.StringValue(v.Error())
+	case fmt.Stringer:
+		return log.StringValue(v.String())
+	case []byte:
+		return log.BytesValue(v)
+	}
+	data, err := jsoncodec.Marshal(v)
+	if err != nil {
+		return log.StringValue(fmt.Sprintf("%+v", v))
+	}
+	var decoded any
+	if err := jsoncodec.Unmarshal(data, &decoded); err != nil {
+		return log.StringValue(fmt.Sprintf("%+v", v))
+	}
+	return jsonValue(decoded)
+}
+
+// jsonValue converts a decoded JSON value to a log value.
+func jsonValue(v any) log.Value {
+	switch v := v.(type) {
+	case string:
+		return log.StringValue(v)
+	case float64:
+		if i := int64(v); float64(i) == v {
+			return log.Int64Value(i)
+		}
+		return log.Float64Value(v)
+	case bool:
+		return log.BoolValue(v)
+	case []any:
+		values := make([]log.Value, len(v))
+		for i, e := range v {
+			values[i] = jsonValue(e)
+		}
+		return log.SliceValue(values...)
+	case map[string]any:
+		kvs := make([]log.KeyValue, 0, len(v))
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			kvs = append(kvs, log.KeyValue{Key: key, Value: jsonValue(v[key])})
+		}
+		return log.MapValue(kvs...)
+	default:
+		return log.Value{}
+	}
+}
+
+// errorType returns the type of the innermost error that err wraps, which
+// names the cause better than the fmt wrapper around it.
+func errorType(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return reflect.TypeOf(err).String()
+		}
+		err = next
+	}
+}
+
+// stackFrom formats the stack of the calling goroutine from the frame of
+// pc, the logging call of a record, in the format of runtime/debug.Stack.
+// The frames of slog and the handlers are left out.
+func stackFrom(pc uintptr) string {
+	caller, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	found := false
+	for {
+		frame, more := frames.Next()
+		if !found && frame.Function == caller.Function && frame.Line == caller.Line {
+			found = true
+		}
+		if found {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	if !found {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", caller.Function, caller.File, caller.Line)
+	}
+	return b.String()
+}
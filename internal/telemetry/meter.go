@@ -1,43 +1,178 @@
 package telemetry
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
+	"github.com/hiroki-koketsu/go-otel-sample/internal/buildinfo"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/metric"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 // Metrics holds the custom metrics instruments for the application.
 type Metrics struct {
-	RequestCounter   metric.Int64Counter
-	RequestDuration  metric.Float64Histogram
-	TasksGauge       metric.Int64ObservableGauge
-	taskCountFunc    func() int64
+	RequestCounter  metric.Int64Counter
+	RequestDuration metric.Float64Histogram
+	ActiveRequests  metric.Int64UpDownCounter
+	ResponseSize    metric.Int64Histogram
+	TasksGauge      metric.Int64ObservableGauge
+	TaskStateGauge  metric.Int64ObservableGauge
+	taskCountFunc   func() int64
+	taskStateFunc   func() map[string]int64
+	legacyHTTP      bool
+}
+
+type meterOptions struct {
+	temporality        string
+	histogram          string
+	durationBoundaries []float64
+	exportInterval     time.Duration
+	degradation        *Degradation
+	collector          *FileCollector
+	mirror             Mirror
+	otlp               OTLPConfig
+	cardinality        *CardinalityReporter
+	stats              *ExportStats
+}
+
+// defaultExportInterval is how often metrics are exported by default.
+const defaultExportInterval = 10 * time.Second
+
+// MeterOption configures InitMeterProvider.
+type MeterOption func(*meterOptions)
+
+// WithTemporality selects the temporality preference of the OTLP metric
+// exporter: cumulative (default), delta or lowmemory. Backends such as
+// Datadog and Dynatrace require delta.
+func WithTemporality(preference string) MeterOption {
+	return func(o *meterOptions) {
+		o.temporality = preference
+	}
+}
+
+// WithHistogramAggregation selects how histograms are aggregated:
+// explicit_bucket_histogram (default) or base2_exponential_bucket_histogram.
+// durationBoundaries optionally overrides the explicit buckets of histograms
+// measured in seconds.
+func WithHistogramAggregation(aggregation string, durationBoundaries []float64) MeterOption {
+	return func(o *meterOptions) {
+		o.histogram = aggregation
+		o.durationBoundaries = durationBoundaries
+	}
+}
+
+// WithExportInterval sets how often the periodic reader exports metrics.
+// A zero interval keeps the default of 10 seconds.
+func WithExportInterval(interval time.Duration) MeterOption {
+	return func(o *meterOptions) {
+		if interval > 0 {
+			o.exportInterval = interval
+		}
+	}
+}
+
+// WithMetricFallback exports metrics to the fallback of d while the exporter
+// is failing.
+func WithMetricFallback(d *Degradation) MeterOption {
+	return func(o *meterOptions) {
+		o.degradation = d
+	}
+}
+
+// WithMetricOTLP configures the headers, compression and TLS of the OTLP
+// exporter.
+func WithMetricOTLP(c OTLPConfig) MeterOption {
+	return func(o *meterOptions) {
+		o.otlp = c
+	}
+}
+
+// WithMetricExportStats measures the duration and payload size of the OTLP
+// metric exports with s.
+func WithMetricExportStats(s *ExportStats) MeterOption {
+	return func(o *meterOptions) {
+		o.stats = s
+	}
+}
+
+// WithMetricFileCollector sends OTLP metrics to c instead of the collector
+// at the OTLP endpoint.
+func WithMetricFileCollector(c *FileCollector) MeterOption {
+	return func(o *meterOptions) {
+		o.collector = c
+	}
+}
+
+// WithMetricMirror also exports metrics to m if it mirrors metrics.
+func WithMetricMirror(m Mirror) MeterOption {
+	return func(o *meterOptions) {
+		o.mirror = m
+	}
+}
+
+// WithCardinalityReporter adds the manual reader of c to the meter provider.
+func WithCardinalityReporter(c *CardinalityReporter) MeterOption {
+	return func(o *meterOptions) {
+		o.cardinality = c
+	}
 }
 
 // InitMeterProvider initializes the OpenTelemetry meter provider.
 // It configures an OTLP gRPC exporter and sets up the global meter provider.
-func InitMeterProvider(ctx context.Context, serviceName, otlpEndpoint, environment string) (*sdkmetric.MeterProvider, error) {
+// The exporter connection is registered with conns for health reporting.
+func InitMeterProvider(ctx context.Context, serviceName, otlpEndpoint, environment string, conns *ExporterConns, opts ...MeterOption) (*sdkmetric.MeterProvider, error) {
+	o := meterOptions{temporality: TemporalityCumulative, exportInterval: defaultExportInterval, otlp: defaultOTLPConfig}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	temporality, err := TemporalitySelector(o.temporality)
+	if err != nil {
+		return nil, err
+	}
+
+	views, err := HistogramViews(o.histogram, o.durationBoundaries)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create OTLP gRPC exporter
-	conn, err := grpc.NewClient(otlpEndpoint,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	conn, headers, err := conns.dial("metrics", otlpEndpoint, o.collector, o.otlp, o.stats)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+		return nil, err
 	}
 
-	exporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn))
+	var exporter sdkmetric.Exporter
+	exporter, err = otlpmetricgrpc.New(ctx,
+		otlpmetricgrpc.WithGRPCConn(conn),
+		otlpmetricgrpc.WithHeaders(headers),
+		otlpmetricgrpc.WithTemporalitySelector(temporality),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create metric exporter: %w", err)
 	}
+	exporter = o.stats.wrapMetrics("metrics", exporter)
+	if o.degradation != nil {
+		exporter, err = o.degradation.WrapMetricExporter(exporter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.mirror.enabled("metrics") {
+		exporter, err = o.mirror.mirrorMetrics(ctx, exporter, conns, o.stats)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Create resource with service information
 	res, err := resource.Merge(
@@ -45,6 +180,7 @@ func InitMeterProvider(ctx context.Context, serviceName, otlpEndpoint, environme
 		resource.NewWithAttributes(
 			semconv.SchemaURL,
 			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(buildinfo.Version),
 			semconv.DeploymentEnvironment(environment),
 		),
 	)
@@ -52,13 +188,18 @@ func InitMeterProvider(ctx context.Context, serviceName, otlpEndpoint, environme
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Create meter provider with periodic reader (10 second interval)
-	mp := sdkmetric.NewMeterProvider(
+	// Create meter provider with periodic reader
+	providerOpts := []sdkmetric.Option{
 		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter,
-			sdkmetric.WithInterval(10*time.Second),
+			sdkmetric.WithInterval(o.exportInterval),
 		)),
 		sdkmetric.WithResource(res),
-	)
+		sdkmetric.WithView(views...),
+	}
+	if o.cardinality != nil {
+		providerOpts = append(providerOpts, sdkmetric.WithReader(o.cardinality.reader))
+	}
+	mp := sdkmetric.NewMeterProvider(providerOpts...)
 
 	// Set global meter provider
 	otel.SetMeterProvider(mp)
@@ -67,11 +208,138 @@ func InitMeterProvider(ctx context.Context, serviceName, otlpEndpoint, environme
 }
 
 // NewMetrics creates and registers custom metrics instruments.
-func NewMetrics(meter metric.Meter, taskCountFunc func() int64) (*Metrics, error) {
+// HTTP server metrics follow the OpenTelemetry semantic conventions
+// (http.server.request.duration et al.) unless legacyHTTP is set, in which
+// case the original http_requests_total/http_request_duration_seconds
+// names and attributes are kept for existing dashboards.
+// taskStateFunc reports the number of tasks per lifecycle state.
+func NewMetrics(meter metric.Meter, taskCountFunc func() int64, taskStateFunc func() map[string]int64, legacyHTTP bool) (*Metrics, error) {
 	m := &Metrics{
 		taskCountFunc: taskCountFunc,
+		taskStateFunc: taskStateFunc,
+		legacyHTTP:    legacyHTTP,
 	}
 
+	var err error
+	if legacyHTTP {
+		err = m.initLegacyHTTPMetrics(meter)
+	} else {
+		err = m.initHTTPMetrics(meter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Observable gauge for current task count
+	m.TasksGauge, err = meter.Int64ObservableGauge(
+		"tasks_total",
+		metric.WithDescription("Current number of tasks in the system"),
+		metric.WithUnit("{task}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(m.taskCountFunc())
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tasks gauge: %w", err)
+	}
+
+	// Observable gauge for tasks per lifecycle state (open, done, archived)
+	m.TaskStateGauge, err = meter.Int64ObservableGauge(
+		"tasks_by_state",
+		metric.WithDescription("Current number of tasks per lifecycle state"),
+		metric.WithUnit("{task}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for state, n := range m.taskStateFunc() {
+				o.Observe(n, metric.WithAttributes(attribute.String("task.state", state)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task state gauge: %w", err)
+	}
+
+	return m, nil
+}
+
+// OtherAssignees is the task.assignee attribute value under which
+// RegisterAssigneeGauge sums up the assignees beyond the top N.
+const OtherAssignees = "other"
+
+// RegisterAssigneeGauge registers an observable gauge reporting the open
+// tasks of the topN assignees with the most open tasks. To bound the
+// cardinality, the open tasks of all other assignees are reported as
+// "other".
+func RegisterAssigneeGauge(meter metric.Meter, openByAssignee func() map[string]int64, topN int) error {
+	_, err := meter.Int64ObservableGauge(
+		"tasks_open_by_assignee",
+		metric.WithDescription("Current number of open tasks per assignee"),
+		metric.WithUnit("{task}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			counts := openByAssignee()
+			assignees := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+				return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+			})
+
+			var other int64
+			for i, assignee := range assignees {
+				if i >= topN {
+					other += counts[assignee]
+					continue
+				}
+				o.Observe(counts[assignee], metric.WithAttributes(attribute.String("task.assignee", assignee)))
+			}
+			if other > 0 {
+				o.Observe(other, metric.WithAttributes(attribute.String("task.assignee", OtherAssignees)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create assignee gauge: %w", err)
+	}
+	return nil
+}
+
+func (m *Metrics) initHTTPMetrics(meter metric.Meter) error {
+	var err error
+
+	// Histogram for request duration (semconv http.server.request.duration)
+	m.RequestDuration, err = meter.Float64Histogram(
+		"http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create request duration histogram: %w", err)
+	}
+
+	// Up-down counter for in-flight requests (semconv http.server.active_requests)
+	m.ActiveRequests, err = meter.Int64UpDownCounter(
+		"http.server.active_requests",
+		metric.WithDescription("Number of active HTTP server requests"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create active requests counter: %w", err)
+	}
+
+	// Histogram for response body size (semconv http.server.response.body.size)
+	m.ResponseSize, err = meter.Int64Histogram(
+		"http.server.response.body.size",
+		metric.WithDescription("Size of HTTP server response bodies"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create response size histogram: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Metrics) initLegacyHTTPMetrics(meter metric.Meter) error {
 	var err error
 
 	// Counter for total HTTP requests
@@ -81,7 +349,7 @@ func NewMetrics(meter metric.Meter, taskCountFunc func() int64) (*Metrics, error
 		metric.WithUnit("{request}"),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request counter: %w", err)
+		return fmt.Errorf("failed to create request counter: %w", err)
 	}
 
 	// Histogram for request duration
@@ -92,22 +360,45 @@ func NewMetrics(meter metric.Meter, taskCountFunc func() int64) (*Metrics, error
 		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request duration histogram: %w", err)
+		return fmt.Errorf("failed to create request duration histogram: %w", err)
 	}
 
-	// Observable gauge for current task count
-	m.TasksGauge, err = meter.Int64ObservableGauge(
-		"tasks_total",
-		metric.WithDescription("Current number of tasks in the system"),
-		metric.WithUnit("{task}"),
-		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
-			o.Observe(m.taskCountFunc())
-			return nil
-		}),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create tasks gauge: %w", err)
+	return nil
+}
+
+// RequestStarted records the start of an HTTP request.
+func (m *Metrics) RequestStarted(ctx context.Context, method string) {
+	if m.legacyHTTP {
+		return
 	}
+	m.ActiveRequests.Add(ctx, 1, metric.WithAttributes(semconv.HTTPRequestMethodKey.String(method)))
+}
 
-	return m, nil
+// RequestFinished records a completed HTTP request with the extra attributes
+// (e.g. api.version). An empty route is omitted from the attributes, as the
+// semantic conventions require for unmatched requests.
+func (m *Metrics) RequestFinished(ctx context.Context, method, route string, status int, duration time.Duration, responseSize int64, extra ...attribute.KeyValue) {
+	if m.legacyHTTP {
+		attrs := metric.WithAttributes(append([]attribute.KeyValue{
+			attribute.String("http.method", method),
+			attribute.String("http.route", route),
+			attribute.Int("http.status_code", status),
+		}, extra...)...)
+		m.RequestCounter.Add(ctx, 1, attrs)
+		m.RequestDuration.Record(ctx, duration.Seconds(), attrs)
+		return
+	}
+
+	kvs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.HTTPResponseStatusCode(status),
+	}
+	if route != "" {
+		kvs = append(kvs, semconv.HTTPRoute(route))
+	}
+	attrs := metric.WithAttributes(append(kvs, extra...)...)
+
+	m.ActiveRequests.Add(ctx, -1, metric.WithAttributes(semconv.HTTPRequestMethodKey.String(method)))
+	m.RequestDuration.Record(ctx, duration.Seconds(), attrs)
+	m.ResponseSize.Record(ctx, responseSize, attrs)
 }
@@ -0,0 +1,371 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// mirrorSuffix is appended to the signal of mirror connections in
+// ExporterConns.
+const mirrorSuffix = "-mirror"
+
+const (
+	// mirrorTimeout bounds each mirror export, which no longer has the
+	// context of the export it copies once the primary has returned.
+	mirrorTimeout = 10 * time.Second
+	// maxMirrorExports is the number of mirror exports of a signal in
+	// flight at once. Exports beyond it are dropped.
+	maxMirrorExports = 4
+)
+
+// Mirror is a second OTLP endpoint receiving a copy of the telemetry of
+// the listed signals (traces, metrics, logs), e.g. a backend being
+// evaluated next to the main one. A mirror without endpoint is disabled.
+//
+// Every export goes to the primary exporter and returns with its result;
+// a copy of the batch is sent to the mirror in the background, bounded by
+// mirrorTimeout. Mirror failures are reported to the OpenTelemetry error
+// handler and counted on Meter, but neither fail the export nor trigger
+// the telemetry fallback. Mirror exports aren't retried and at most
+// maxMirrorExports are in flight per signal, so an unavailable mirror
+// doesn't hold up the primary.
+type Mirror struct {
+	Endpoint string
+	Signals  []string
+	OTLP     OTLPConfig
+	// Meter records the failed mirror exports. It is usually the global
+	// meter, as the exporters are created before the meter provider.
+	Meter metric.Meter
+}
+
+// enabled reports whether m mirrors signal.
+func (m Mirror) enabled(signal string) bool {
+	return m.Endpoint != "" && slices.Contains(m.Signals, signal)
+}
+
+// mirrorExports sends the exports of a signal to the mirror without
+// holding up the primary.
+type mirrorExports struct {
+	signal   string
+	slots    chan struct{}
+	wg       sync.WaitGroup
+	failures metric.Int64Counter
+}
+
+func (m Mirror) exports(signal string) (*mirrorExports, error) {
+	meter := m.Meter
+	if meter == nil {
+		meter = noop.NewMeterProvider().Meter("")
+	}
+	failures, err := meter.Int64Counter(
+		"otlp_mirror_export_failures_total",
+		metric.WithDescription("Mirror exports that failed or were dropped, by signal and reason"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mirror export failure counter: %w", err)
+	}
+	return &mirrorExports{
+		signal:   signal,
+		slots:    make(chan struct{}, maxMirrorExports),
+		failures: failures,
+	}, nil
+}
+
+// export starts mirror in the background and returns the error of
+// primary. mirror must not use data the caller may reuse once primary
+// has returned.
+func (x *mirrorExports) export(ctx context.Context, primary, mirror func(context.Context) error) error {
+	select {
+	case x.slots <- struct{}{}:
+		x.wg.Go(func() { // async:ok: telemetry must not trace itself
+			defer func() { <-x.slots }()
+			mctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mirrorTimeout)
+			defer cancel()
+			if err := mirror(mctx); err != nil {
+				x.fail(mctx, "error", fmt.Errorf("failed to export %s to mirror: %w", x.signal, err))
+			}
+		})
+	default:
+		x.fail(ctx, "busy", fmt.Errorf("dropped %s mirror export: %d exports in flight", x.signal, maxMirrorExports))
+	}
+	return primary(ctx)
+}
+
+func (x *mirrorExports) fail(ctx context.Context, reason string, err error) {
+	otel.Handle(err)
+	x.failures.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("signal", x.signal),
+		attribute.String("reason", reason),
+	))
+}
+
+// wait waits until the mirror exports in flight have finished or ctx is
+// done.
+func (x *mirrorExports) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() { // async:ok: telemetry must not trace itself
+		x.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for %s mirror exports: %w", x.signal, ctx.Err())
+	}
+}
+
+// mirrorSpans returns an exporter sending spans to primary and the mirror.
+func (m Mirror) mirrorSpans(ctx context.Context, primary sdktrace.SpanExporter, conns *ExporterConns, stats *ExportStats) (sdktrace.SpanExporter, error) {
+	conn, headers, err := conns.dial("traces"+mirrorSuffix, m.Endpoint, nil, m.OTLP, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	mirror, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithGRPCConn(conn),
+		otlptracegrpc.WithHeaders(headers),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mirror trace exporter: %w", err)
+	}
+	exports, err := m.exports("traces")
+	if err != nil {
+		return nil, err
+	}
+	return &mirrorSpanExporter{primary: primary, mirror: stats.wrapSpans("traces"+mirrorSuffix, mirror), exports: exports}, nil
+}
+
+type mirrorSpanExporter struct {
+	primary sdktrace.SpanExporter
+	mirror  sdktrace.SpanExporter
+	exports *mirrorExports
+}
+
+func (e *mirrorSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	// The span processor reuses the slice, the spans themselves are immutable
+	copied := slices.Clone(spans)
+	return e.exports.export(ctx,
+		func(ctx context.Context) error { return e.primary.ExportSpans(ctx, spans) },
+		func(ctx context.Context) error { return e.mirror.ExportSpans(ctx, copied) },
+	)
+}
+
+func (e *mirrorSpanExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.primary.Shutdown(ctx), e.exports.wait(ctx), e.mirror.Shutdown(ctx))
+}
+
+// mirrorMetrics returns an exporter sending metrics to primary and the
+// mirror. Temporality and aggregation are taken from primary.
+func (m Mirror) mirrorMetrics(ctx context.Context, primary sdkmetric.Exporter, conns *ExporterConns, stats *ExportStats) (sdkmetric.Exporter, error) {
+	conn, headers, err := conns.dial("metrics"+mirrorSuffix, m.Endpoint, nil, m.OTLP, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	mirror, err := otlpmetricgrpc.New(ctx,
+		otlpmetricgrpc.WithGRPCConn(conn),
+		otlpmetricgrpc.WithHeaders(headers),
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{Enabled: false}),
+		otlpmetricgrpc.WithTemporalitySelector(primary.Temporality),
+		otlpmetricgrpc.WithAggregationSelector(primary.Aggregation),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mirror metric exporter: %w", err)
+	}
+	exports, err := m.exports("metrics")
+	if err != nil {
+		return nil, err
+	}
+	return &mirrorMetricExporter{Exporter: primary, mirror: stats.wrapMetrics("metrics"+mirrorSuffix, mirror), exports: exports}, nil
+}
+
+type mirrorMetricExporter struct {
+	sdkmetric.Exporter
+	mirror  sdkmetric.Exporter
+	exports *mirrorExports
+}
+
+func (e *mirrorMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	// The reader and the aggregations reuse rm and its data points
+	copied := cloneResourceMetrics(rm)
+	return e.exports.export(ctx,
+		func(ctx context.Context) error { return e.Exporter.Export(ctx, rm) },
+		func(ctx context.Context) error { return e.mirror.Export(ctx, copied) },
+	)
+}
+
+// ForceFlush flushes the primary and returns its error. The mirror exports
+// in flight are awaited too, but their failures are only reported.
+func (e *mirrorMetricExporter) ForceFlush(ctx context.Context) error {
+	err := e.Exporter.ForceFlush(ctx)
+	if merr := errors.Join(e.exports.wait(ctx), e.mirror.ForceFlush(ctx)); merr != nil {
+		otel.Handle(merr)
+	}
+	return err
+}
+
+func (e *mirrorMetricExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.exports.wait(ctx), e.mirror.Shutdown(ctx))
+}
+
+// mirrorLogs returns an exporter sending log records to primary and the
+// mirror.
+func (m Mirror) mirrorLogs(ctx context.Context, primary sdklog.Exporter, conns *ExporterConns, stats *ExportStats) (sdklog.Exporter, error) {
+	conn, headers, err := conns.dial("logs"+mirrorSuffix, m.Endpoint, nil, m.OTLP, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	mirror, err := otlploggrpc.New(ctx,
+		otlploggrpc.WithGRPCConn(conn),
+		otlploggrpc.WithHeaders(headers),
+		otlploggrpc.WithRetry(otlploggrpc.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mirror log exporter: %w", err)
+	}
+	exports, err := m.exports("logs")
+	if err != nil {
+		return nil, err
+	}
+	return &mirrorLogExporter{Exporter: primary, mirror: stats.wrapLogs("logs"+mirrorSuffix, mirror), exports: exports}, nil
+}
+
+type mirrorLogExporter struct {
+	sdklog.Exporter
+	mirror  sdklog.Exporter
+	exports *mirrorExports
+}
+
+func (e *mirrorLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	// Exporters must not retain the records once Export returns
+	copied := make([]sdklog.Record, len(records))
+	for i := range records {
+		copied[i] = records[i].Clone()
+	}
+	return e.exports.export(ctx,
+		func(ctx context.Context) error { return e.Exporter.Export(ctx, records) },
+		func(ctx context.Context) error { return e.mirror.Export(ctx, copied) },
+	)
+}
+
+// ForceFlush flushes the primary and returns its error. The mirror exports
+// in flight are awaited too, but their failures are only reported.
+func (e *mirrorLogExporter) ForceFlush(ctx context.Context) error {
+	err := e.Exporter.ForceFlush(ctx)
+	if merr := errors.Join(e.exports.wait(ctx), e.mirror.ForceFlush(ctx)); merr != nil {
+		otel.Handle(merr)
+	}
+	return err
+}
+
+func (e *mirrorLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.exports.wait(ctx), e.mirror.Shutdown(ctx))
+}
+
+// cloneResourceMetrics returns a deep copy of rm. The resource, scopes and
+// attribute sets are immutable and shared.
+func cloneResourceMetrics(rm *metricdata.ResourceMetrics) *metricdata.ResourceMetrics {
+	c := &metricdata.ResourceMetrics{Resource: rm.Resource, ScopeMetrics: slices.Clone(rm.ScopeMetrics)}
+	for i := range c.ScopeMetrics {
+		sm := &c.ScopeMetrics[i]
+		sm.Metrics = slices.Clone(sm.Metrics)
+		for j := range sm.Metrics {
+			sm.Metrics[j].Data = cloneAggregation(sm.Metrics[j].Data)
+		}
+	}
+	return c
+}
+
+func cloneAggregation(a metricdata.Aggregation) metricdata.Aggregation {
+	switch a := a.(type) {
+	case metricdata.Gauge[int64]:
+		a.DataPoints = cloneDataPoints(a.DataPoints)
+		return a
+	case metricdata.Gauge[float64]:
+		a.DataPoints = cloneDataPoints(a.DataPoints)
+		return a
+	case metricdata.Sum[int64]:
+		a.DataPoints = cloneDataPoints(a.DataPoints)
+		return a
+	case metricdata.Sum[float64]:
+		a.DataPoints = cloneDataPoints(a.DataPoints)
+		return a
+	case metricdata.Histogram[int64]:
+		a.DataPoints = cloneHistogramDataPoints(a.DataPoints)
+		return a
+	case metricdata.Histogram[float64]:
+		a.DataPoints = cloneHistogramDataPoints(a.DataPoints)
+		return a
+	case metricdata.ExponentialHistogram[int64]:
+		a.DataPoints = cloneExponentialHistogramDataPoints(a.DataPoints)
+		return a
+	case metricdata.ExponentialHistogram[float64]:
+		a.DataPoints = cloneExponentialHistogramDataPoints(a.DataPoints)
+		return a
+	case metricdata.Summary:
+		a.DataPoints = slices.Clone(a.DataPoints)
+		for i := range a.DataPoints {
+			a.DataPoints[i].QuantileValues = slices.Clone(a.DataPoints[i].QuantileValues)
+		}
+		return a
+	default:
+		return a
+	}
+}
+
+func cloneDataPoints[N int64 | float64](dps []metricdata.DataPoint[N]) []metricdata.DataPoint[N] {
+	dps = slices.Clone(dps)
+	for i := range dps {
+		dps[i].Exemplars = cloneExemplars(dps[i].Exemplars)
+	}
+	return dps
+}
+
+func cloneHistogramDataPoints[N int64 | float64](dps []metricdata.HistogramDataPoint[N]) []metricdata.HistogramDataPoint[N] {
+	dps = slices.Clone(dps)
+	for i := range dps {
+		dps[i].Bounds = slices.Clone(dps[i].Bounds)
+		dps[i].BucketCounts = slices.Clone(dps[i].BucketCounts)
+		dps[i].Exemplars = cloneExemplars(dps[i].Exemplars)
+	}
+	return dps
+}
+
+func cloneExponentialHistogramDataPoints[N int64 | float64](dps []metricdata.ExponentialHistogramDataPoint[N]) []metricdata.ExponentialHistogramDataPoint[N] {
+	dps = slices.Clone(dps)
+	for i := range dps {
+		dps[i].PositiveBucket.Counts = slices.Clone(dps[i].PositiveBucket.Counts)
+		dps[i].NegativeBucket.Counts = slices.Clone(dps[i].NegativeBucket.Counts)
+		dps[i].Exemplars = cloneExemplars(dps[i].Exemplars)
+	}
+	return dps
+}
+
+func cloneExemplars[N int64 | float64](ex []metricdata.Exemplar[N]) []metricdata.Exemplar[N] {
+	ex = slices.Clone(ex)
+	for i := range ex {
+		ex[i].FilteredAttributes = slices.Clone(ex[i].FilteredAttributes)
+		ex[i].SpanID = slices.Clone(ex[i].SpanID)
+		ex[i].TraceID = slices.Clone(ex[i].TraceID)
+	}
+	return ex
+}
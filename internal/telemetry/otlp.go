@@ -0,0 +1,104 @@
+package telemetry
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// Supported OTLP compressions.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// OTLPConfig configures the connection of an OTLP gRPC exporter.
+type OTLPConfig struct {
+	// Headers are comma-separated key=value pairs with URL-encoded values
+	// (OTEL_EXPORTER_OTLP_HEADERS format), sent as gRPC metadata with every
+	// export, e.g. "authorization=Bearer%20token,api-key=secret".
+	Headers string
+	// Compression is gzip or none (the default).
+	Compression string
+	// Insecure disables TLS.
+	Insecure bool
+}
+
+// defaultOTLPConfig is the plaintext connection without headers used when no
+// OTLPConfig is set.
+var defaultOTLPConfig = OTLPConfig{Insecure: true}
+
+// headers parses c.Headers.
+func (c OTLPConfig) headers() (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(c.Headers, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid OTLP header %q", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of OTLP header %q: %w", key, err)
+		}
+		headers[strings.ToLower(key)] = value
+	}
+	return headers, nil
+}
+
+// dialOptions returns the transport credentials and compressor of c.
+func (c OTLPConfig) dialOptions() ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	if c.Insecure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})))
+	}
+
+	switch c.Compression {
+	case "", CompressionNone:
+	case CompressionGzip:
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	default:
+		return nil, fmt.Errorf("unsupported OTLP compression %q", c.Compression)
+	}
+	return opts, nil
+}
+
+// dialOTLP connects an OTLP gRPC exporter to collector, or to endpoint if
+// collector is nil, with the options of c and extra, and returns the headers
+// to send with every export. The connection to the file collector is always
+// plaintext.
+func dialOTLP(endpoint string, collector *FileCollector, c OTLPConfig, extra ...grpc.DialOption) (*grpc.ClientConn, map[string]string, error) {
+	headers, err := c.headers()
+	if err != nil {
+		return nil, nil, err
+	}
+	if collector != nil {
+		c.Insecure = true
+	}
+	opts, err := c.dialOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	opts = append(opts, extra...)
+
+	if collector != nil {
+		conn, err := collector.Dial(opts...)
+		return conn, headers, err
+	}
+	conn, err := grpc.NewClient(endpoint, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+	}
+	return conn, headers, nil
+}
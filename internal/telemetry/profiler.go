@@ -0,0 +1,123 @@
+package telemetry
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/grafana/pyroscope-go"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/buildinfo"
+)
+
+// Rates of the mutex and block profiles when they are enabled: on average
+// one in mutexProfileFraction contention events and one blocking event per
+// blockProfileRate nanoseconds are sampled.
+const (
+	mutexProfileFraction = 5
+	blockProfileRate     = 5
+)
+
+// Profiling configures continuous profiling, the fourth signal next to
+// traces, metrics and logs.
+type Profiling struct {
+	// ServerAddress is the URL of the Pyroscope server profiles are pushed
+	// to, e.g. http://pyroscope:4040.
+	ServerAddress string
+	// ProfileTypes are the pprof profile types to collect, e.g. cpu or
+	// inuse_space; empty collects the CPU and memory profiles.
+	ProfileTypes []string
+	// UploadInterval is the period of each profile.
+	UploadInterval time.Duration
+	// BasicAuthUser and BasicAuthPassword authenticate to the server if
+	// set, e.g. to Grafana Cloud Profiles.
+	BasicAuthUser     string
+	BasicAuthPassword string
+}
+
+// profileTypes are the profile types Profiling.ProfileTypes may name.
+var profileTypes = map[string]pyroscope.ProfileType{
+	string(pyroscope.ProfileCPU):           pyroscope.ProfileCPU,
+	string(pyroscope.ProfileInuseObjects):  pyroscope.ProfileInuseObjects,
+	string(pyroscope.ProfileAllocObjects):  pyroscope.ProfileAllocObjects,
+	string(pyroscope.ProfileInuseSpace):    pyroscope.ProfileInuseSpace,
+	string(pyroscope.ProfileAllocSpace):    pyroscope.ProfileAllocSpace,
+	string(pyroscope.ProfileGoroutines):    pyroscope.ProfileGoroutines,
+	string(pyroscope.ProfileMutexCount):    pyroscope.ProfileMutexCount,
+	string(pyroscope.ProfileMutexDuration): pyroscope.ProfileMutexDuration,
+	string(pyroscope.ProfileBlockCount):    pyroscope.ProfileBlockCount,
+	string(pyroscope.ProfileBlockDuration): pyroscope.ProfileBlockDuration,
+}
+
+// Profiler continuously profiles the process and pushes the profiles to
+// Pyroscope.
+type Profiler struct {
+	profiler *pyroscope.Profiler
+}
+
+// StartProfiler starts profiling the process as serviceName. The profiles
+// are tagged with the service version and environment, with the label
+// names of the service.version and deployment.environment resource
+// attributes, so they can be matched with the other signals. Enabling the
+// mutex or block profile types turns on their sampling in the runtime.
+func StartProfiler(serviceName, environment string, p Profiling, logger *slog.Logger) (*Profiler, error) {
+	types := make([]pyroscope.ProfileType, 0, len(p.ProfileTypes))
+	for _, name := range p.ProfileTypes {
+		t, ok := profileTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile type %q", name)
+		}
+		switch t {
+		case pyroscope.ProfileMutexCount, pyroscope.ProfileMutexDuration:
+			runtime.SetMutexProfileFraction(mutexProfileFraction)
+		case pyroscope.ProfileBlockCount, pyroscope.ProfileBlockDuration:
+			runtime.SetBlockProfileRate(blockProfileRate)
+		}
+		types = append(types, t)
+	}
+
+	profiler, err := pyroscope.Start(pyroscope.Config{
+		ApplicationName: serviceName,
+		Tags: map[string]string{
+			"service_version":        buildinfo.Version,
+			"deployment_environment": environment,
+		},
+		ServerAddress:     p.ServerAddress,
+		BasicAuthUser:     p.BasicAuthUser,
+		BasicAuthPassword: p.BasicAuthPassword,
+		UploadRate:        p.UploadInterval,
+		ProfileTypes:      types,
+		Logger:            profilerLogger{logger: logger},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start profiler: %w", err)
+	}
+	return &Profiler{profiler: profiler}, nil
+}
+
+// Stop stops profiling after uploading the current profile.
+func (p *Profiler) Stop() error {
+	if err := p.profiler.Stop(); err != nil {
+		return fmt.Errorf("failed to stop profiler: %w", err)
+	}
+	return nil
+}
+
+// profilerLogger adapts a slog.Logger to the logger of the Pyroscope
+// client. Its info messages are logged at debug level, since the client
+// logs every upload.
+type profilerLogger struct {
+	logger *slog.Logger
+}
+
+func (l profilerLogger) Infof(format string, args ...any) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l profilerLogger) Debugf(format string, args ...any) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l profilerLogger) Errorf(format string, args ...any) {
+	l.logger.Error(fmt.Sprintf(format, args...))
+}
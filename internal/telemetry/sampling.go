@@ -0,0 +1,244 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// adaptiveMinRequests is the number of requests an evaluation window needs
+// for its burn rate to count; quieter windows count as normal load.
+const adaptiveMinRequests = 10
+
+// AdaptiveSampling configures an AdaptiveSampler.
+type AdaptiveSampling struct {
+	// BaseRatio is sampled under normal load, MaxRatio while the SLO burns.
+	BaseRatio float64
+	MaxRatio  float64
+	// SLOTarget is the objective for both errors and latency, e.g. 0.99
+	// for 99% of requests succeeding within LatencyThreshold.
+	SLOTarget        float64
+	LatencyThreshold time.Duration
+	// BurnRate is the rate of error budget consumption, relative to the
+	// sustainable rate, from which the ratio is raised.
+	BurnRate float64
+	// Interval is the evaluation window.
+	Interval time.Duration
+}
+
+// AdaptiveSampler samples new traces with a ratio that follows the SLO burn
+// rate of the served requests: when the share of failed (5xx) or slow
+// requests burns the error budget at BurnRate or faster, the ratio jumps to
+// MaxRatio so incidents are fully traced; under normal load it halves every
+// interval until it is back at BaseRatio. Use it with sdktrace.ParentBased.
+type AdaptiveSampler struct {
+	cfg AdaptiveSampling
+
+	sampler atomic.Pointer[sdktrace.Sampler]
+	ratio   atomic.Uint64 // math.Float64bits of the current ratio
+
+	mu       sync.Mutex
+	requests int64
+	failed   int64
+	slow     int64
+}
+
+// NewAdaptiveSampler creates a new AdaptiveSampler starting at
+// c.BaseRatio.
+func NewAdaptiveSampler(c AdaptiveSampling) (*AdaptiveSampler, error) {
+	if c.BaseRatio < 0 || c.MaxRatio > 1 || c.BaseRatio > c.MaxRatio {
+		return nil, fmt.Errorf("invalid adaptive sampling ratios %g..%g", c.BaseRatio, c.MaxRatio)
+	}
+	if c.SLOTarget <= 0 || c.SLOTarget >= 1 {
+		return nil, fmt.Errorf("invalid adaptive sampling SLO target %g", c.SLOTarget)
+	}
+	if c.Interval <= 0 {
+		return nil, fmt.Errorf("invalid adaptive sampling interval %s", c.Interval)
+	}
+	s := &AdaptiveSampler{cfg: c}
+	s.setRatio(c.BaseRatio)
+	return s, nil
+}
+
+// ShouldSample samples by trace ID with the current ratio.
+func (s *AdaptiveSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return (*s.sampler.Load()).ShouldSample(p)
+}
+
+// Description returns the sampler name with the current ratio.
+func (s *AdaptiveSampler) Description() string {
+	return fmt.Sprintf("AdaptiveSampler{%g}", s.Ratio())
+}
+
+// Ratio returns the current sampling ratio.
+func (s *AdaptiveSampler) Ratio() float64 {
+	return math.Float64frombits(s.ratio.Load())
+}
+
+func (s *AdaptiveSampler) setRatio(ratio float64) {
+	sampler := sdktrace.TraceIDRatioBased(ratio)
+	s.sampler.Store(&sampler)
+	s.ratio.Store(math.Float64bits(ratio))
+}
+
+// Observe records a served request with its duration and whether it failed.
+func (s *AdaptiveSampler) Observe(duration time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if failed {
+		s.failed++
+	}
+	if duration > s.cfg.LatencyThreshold {
+		s.slow++
+	}
+}
+
+// Run evaluates the observed requests every interval until ctx is done.
+func (s *AdaptiveSampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.evaluate(ctx)
+		}
+	}
+}
+
+// evaluate adjusts the ratio to the burn rate of the requests observed
+// since the last evaluation and logs changes.
+func (s *AdaptiveSampler) evaluate(ctx context.Context) {
+	s.mu.Lock()
+	requests, failed, slow := s.requests, s.failed, s.slow
+	s.requests, s.failed, s.slow = 0, 0, 0
+	s.mu.Unlock()
+
+	var errorRate, slowRate, burn float64
+	if requests >= adaptiveMinRequests {
+		budget := 1 - s.cfg.SLOTarget
+		errorRate = float64(failed) / float64(requests)
+		slowRate = float64(slow) / float64(requests)
+		burn = max(errorRate, slowRate) / budget
+	}
+
+	// Snap to the base ratio once close, so a base of 0 is reached
+	from := s.Ratio()
+	to := max(from/2, s.cfg.BaseRatio)
+	if to-s.cfg.BaseRatio < 0.001 {
+		to = s.cfg.BaseRatio
+	}
+	if burn >= s.cfg.BurnRate {
+		to = s.cfg.MaxRatio
+	}
+	if to == from {
+		return
+	}
+	s.setRatio(to)
+
+	logging.FromContext(ctx).InfoContext(ctx, "trace sampling ratio changed",
+		slog.Float64("from", from),
+		slog.Float64("to", to),
+		slog.Int64("requests", requests),
+		slog.Float64("error_rate", errorRate),
+		slog.Float64("slow_rate", slowRate),
+		slog.Float64("burn_rate", burn),
+	)
+}
+
+// RegisterSamplingRatioGauge registers an observable gauge reporting the
+// current ratio of s.
+func RegisterSamplingRatioGauge(meter metric.Meter, s *AdaptiveSampler) error {
+	_, err := meter.Float64ObservableGauge(
+		"trace_sampling_ratio",
+		metric.WithDescription("Current sampling ratio of new traces of the adaptive sampler"),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(s.Ratio())
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create sampling ratio gauge: %w", err)
+	}
+	return nil
+}
+
+// TenantSampling returns the sampling ratio of the tenant a new trace is
+// started for, read from ctx, and false if the tenant has none of its own.
+type TenantSampling func(ctx context.Context) (tenant string, ratio float64, ok bool)
+
+// tenantSampler samples new traces of tenants with their own ratio by trace
+// ID with that ratio, recording tenant.id and tenant.sample_ratio on the
+// root span, and all other traces with base.
+type tenantSampler struct {
+	base   sdktrace.Sampler
+	policy TenantSampling
+}
+
+// ShouldSample implements sdktrace.Sampler.
+func (s tenantSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	tenant, ratio, ok := s.policy(p.ParentContext)
+	if !ok {
+		return s.base.ShouldSample(p)
+	}
+	result := sdktrace.TraceIDRatioBased(ratio).ShouldSample(p)
+	result.Attributes = append(result.Attributes,
+		UserString("tenant.id", tenant),
+		attribute.Float64("tenant.sample_ratio", ratio),
+	)
+	return result
+}
+
+// Description implements sdktrace.Sampler.
+func (s tenantSampler) Description() string {
+	return fmt.Sprintf("TenantSampler{%s}", s.base.Description())
+}
+
+type forcedSamplingKey struct{}
+
+// WithForcedSampling returns a context in which new spans are sampled
+// regardless of the configured sampler and the sampling decision of the
+// parent.
+func WithForcedSampling(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcedSamplingKey{}, true)
+}
+
+// forceSampler samples spans started with a context of WithForcedSampling
+// without a sampled parent, recording sampling.forced on them, and leaves
+// all other spans to base; their children follow them as sampled parents.
+type forceSampler struct {
+	base sdktrace.Sampler
+}
+
+// ShouldSample implements sdktrace.Sampler.
+func (s forceSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	parent := trace.SpanContextFromContext(p.ParentContext)
+	if forced, _ := p.ParentContext.Value(forcedSamplingKey{}).(bool); !forced || parent.IsSampled() {
+		return s.base.ShouldSample(p)
+	}
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordAndSample,
+		Attributes: []attribute.KeyValue{attribute.Bool("sampling.forced", true)},
+		Tracestate: parent.TraceState(),
+	}
+}
+
+// Description implements sdktrace.Sampler.
+func (s forceSampler) Description() string {
+	return fmt.Sprintf("ForceSampler{%s}", s.base.Description())
+}
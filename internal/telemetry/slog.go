@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceHandler is a slog.Handler that adds the trace_id and span_id of the
+// active span, plus a fixed set of resource attributes, to every record
+// before passing it on. It makes records from any logger correlatable,
+// including ones from libraries that know nothing about OpenTelemetry.
+type TraceHandler struct {
+	next slog.Handler
+}
+
+// NewTraceHandler wraps next. The attributes of res are added to every
+// record; res may be nil.
+func NewTraceHandler(next slog.Handler, res *resource.Resource) *TraceHandler {
+	if res != nil {
+		attrs := make([]slog.Attr, 0, res.Len())
+		for _, kv := range res.Attributes() {
+			attrs = append(attrs, slog.String(string(kv.Key), kv.Value.Emit()))
+		}
+		next = next.WithAttrs(attrs)
+	}
+	return &TraceHandler{next: next}
+}
+
+// Enabled reports whether the wrapped handler handles records at level.
+func (h *TraceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds the span context of ctx to the record, if any.
+func (h *TraceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r = r.Clone()
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a TraceHandler whose wrapped handler has attrs.
+func (h *TraceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &TraceHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup returns a TraceHandler whose wrapped handler has the group.
+func (h *TraceHandler) WithGroup(name string) slog.Handler {
+	return &TraceHandler{next: h.next.WithGroup(name)}
+}
+
+// LevelHandler is a slog.Handler that drops records below a minimum level
+// before passing them on, for handlers without a level of their own such as
+// the OpenTelemetry bridge.
+type LevelHandler struct {
+	level slog.Leveler
+	next  slog.Handler
+}
+
+// NewLevelHandler wraps next, dropping records below level.
+func NewLevelHandler(level slog.Leveler, next slog.Handler) *LevelHandler {
+	return &LevelHandler{level: level, next: next}
+}
+
+// Enabled reports whether level is at least the minimum level and the
+// wrapped handler handles it.
+func (h *LevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record on.
+func (h *LevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a LevelHandler whose wrapped handler has attrs.
+func (h *LevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LevelHandler{level: h.level, next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup returns a LevelHandler whose wrapped handler has the group.
+func (h *LevelHandler) WithGroup(name string) slog.Handler {
+	return &LevelHandler{level: h.level, next: h.next.WithGroup(name)}
+}
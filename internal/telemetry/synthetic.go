@@ -0,0 +1,57 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SyntheticKey is the baggage member and span attribute marking synthetic
+// traffic, such as the warm-up requests on startup.
+const SyntheticKey = "synthetic"
+
+// WithSynthetic returns a context whose baggage marks the work started with
+// it as synthetic. The baggage is propagated with outgoing requests, so
+// downstream services can tell synthetic traffic apart as well.
+func WithSynthetic(ctx context.Context) (context.Context, error) {
+	member, err := baggage.NewMember(SyntheticKey, "true")
+	if err != nil {
+		return ctx, fmt.Errorf("failed to create synthetic baggage member: %w", err)
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to add synthetic baggage member: %w", err)
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// IsSynthetic reports whether the baggage of ctx marks synthetic traffic.
+func IsSynthetic(ctx context.Context) bool {
+	return baggage.FromContext(ctx).Member(SyntheticKey).Value() == "true"
+}
+
+// SyntheticProcessor is a span processor adding synthetic=true to every
+// span started in a context marked with WithSynthetic, so synthetic traffic
+// can be filtered out of (or into) trace searches at any span.
+type SyntheticProcessor struct{}
+
+var _ sdktrace.SpanProcessor = SyntheticProcessor{}
+
+// OnStart marks s as synthetic if its parent context is.
+func (SyntheticProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if IsSynthetic(parent) {
+		s.SetAttributes(attribute.Bool(SyntheticKey, true))
+	}
+}
+
+// OnEnd does nothing; ended spans are read-only.
+func (SyntheticProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing.
+func (SyntheticProcessor) Shutdown(context.Context) error { return nil }
+
+// ForceFlush does nothing.
+func (SyntheticProcessor) ForceFlush(context.Context) error { return nil }
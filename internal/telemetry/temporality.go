@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"fmt"
+	"strings"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Supported metric temporality preferences, matching the values of
+// OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE.
+const (
+	TemporalityCumulative = "cumulative"
+	TemporalityDelta      = "delta"
+	TemporalityLowMemory  = "lowmemory"
+)
+
+// TemporalitySelector returns the temporality selector for a preference.
+//
+// With "delta", monotonic instruments (counters, histograms and observable
+// counters) are exported as deltas, while up-down counters stay cumulative
+// because a delta of a non-monotonic sum can't be meaningfully re-aggregated.
+// "lowmemory" additionally keeps observable counters cumulative so the SDK
+// doesn't have to remember the previous observation.
+func TemporalitySelector(preference string) (sdkmetric.TemporalitySelector, error) {
+	switch strings.ToLower(preference) {
+	case "", TemporalityCumulative:
+		return sdkmetric.DefaultTemporalitySelector, nil
+	case TemporalityDelta:
+		return deltaTemporality, nil
+	case TemporalityLowMemory:
+		return lowMemoryTemporality, nil
+	default:
+		return nil, fmt.Errorf("unsupported metric temporality %q", preference)
+	}
+}
+
+func deltaTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindCounter,
+		sdkmetric.InstrumentKindHistogram,
+		sdkmetric.InstrumentKindObservableCounter:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
+
+func lowMemoryTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindCounter,
+		sdkmetric.InstrumentKindHistogram:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
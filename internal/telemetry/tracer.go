@@ -3,31 +3,231 @@ package telemetry
 import (
 	"context"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/hiroki-koketsu/go-otel-sample/internal/buildinfo"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
+// Supported trace exporter types.
+const (
+	TraceExporterOTLP             = "otlp"
+	TraceExporterZipkin           = "zipkin"
+	TraceExporterJaegerThriftHTTP = "jaeger-thrift-http"
+)
+
+// Default endpoints for the non-OTLP trace exporters.
+const (
+	defaultZipkinEndpoint = "http://localhost:9411/api/v2/spans"
+	defaultJaegerEndpoint = "http://localhost:14268/api/traces"
+)
+
+type tracerOptions struct {
+	exporter         string
+	exporterEndpoint string
+	batch            BatchConfig
+	limits           SpanLimits
+	degradation      *Degradation
+	collector        *FileCollector
+	otlp             OTLPConfig
+	mirror           Mirror
+	drops            *DropReporter
+	stats            *ExportStats
+	sampleRatio      float64
+	adaptive         *AdaptiveSampler
+	tenantSampling   TenantSampling
+	deployment       string
+}
+
+// SpanLimits bounds the size of each span. Zero values keep the SDK
+// defaults (which honour the OTEL_SPAN_* environment variables).
+type SpanLimits struct {
+	// AttributeCount is the maximum number of attributes per span.
+	AttributeCount int
+	// AttributeValueLength is the maximum length of string attribute values.
+	AttributeValueLength int
+	// EventCount is the maximum number of events per span.
+	EventCount int
+}
+
+func (l SpanLimits) sdkLimits() sdktrace.SpanLimits {
+	limits := sdktrace.NewSpanLimits()
+	if l.AttributeCount > 0 {
+		limits.AttributeCountLimit = l.AttributeCount
+	}
+	if l.AttributeValueLength > 0 {
+		limits.AttributeValueLengthLimit = l.AttributeValueLength
+	}
+	if l.EventCount > 0 {
+		limits.EventCountLimit = l.EventCount
+	}
+	return limits
+}
+
+// BatchConfig tunes the export queue of spans or log records. Zero values
+// keep the defaults of the SDK batch processors (5s timeout for spans, 1s for
+// log records, 512 items per export, 2048 queued items).
+type BatchConfig struct {
+	// Timeout is the maximum delay between two exports.
+	Timeout time.Duration
+	// MaxExportBatchSize is the maximum number of items per export.
+	MaxExportBatchSize int
+	// MaxQueueSize is the maximum number of items buffered for export.
+	MaxQueueSize int
+	// Blocking makes span.End and log calls wait for queue space instead of
+	// dropping the item when the queue is full.
+	Blocking bool
+	// BlockTimeout bounds the wait of Blocking; an item still not queued
+	// after it is dropped. 0 waits until there is space.
+	BlockTimeout time.Duration
+}
+
+// TracerOption configures InitTracerProvider.
+type TracerOption func(*tracerOptions)
+
+// WithTraceExporter selects the trace exporter type. The endpoint is only used
+// by the zipkin and jaeger-thrift-http exporters; an empty endpoint selects the
+// exporter's default.
+func WithTraceExporter(exporter, endpoint string) TracerOption {
+	return func(o *tracerOptions) {
+		o.exporter = exporter
+		o.exporterEndpoint = endpoint
+	}
+}
+
+// WithSampleRatio samples the given fraction of new traces. Spans with a
+// parent follow the sampling decision of the parent, so traces continued
+// from other services stay complete.
+func WithSampleRatio(ratio float64) TracerOption {
+	return func(o *tracerOptions) {
+		o.sampleRatio = ratio
+	}
+}
+
+// WithAdaptiveSampler samples new traces with s instead of a fixed ratio.
+// As with WithSampleRatio, spans with a parent follow the parent.
+func WithAdaptiveSampler(s *AdaptiveSampler) TracerOption {
+	return func(o *tracerOptions) {
+		o.adaptive = s
+	}
+}
+
+// WithTenantSampling samples the new traces of tenants with their own
+// ratio, as returned by policy, instead of the ratio of the provider.
+func WithTenantSampling(policy TenantSampling) TracerOption {
+	return func(o *tracerOptions) {
+		o.tenantSampling = policy
+	}
+}
+
+// WithBatchConfig tunes the export queue of spans, e.g. to demonstrate
+// dropped spans or backpressure under load.
+func WithBatchConfig(c BatchConfig) TracerOption {
+	return func(o *tracerOptions) {
+		o.batch = c
+	}
+}
+
+// WithTraceDropReporter counts and reports spans dropped because the export
+// queue is full.
+func WithTraceDropReporter(d *DropReporter) TracerOption {
+	return func(o *tracerOptions) {
+		o.drops = d
+	}
+}
+
+// WithTraceExportStats measures the duration and payload size of the OTLP
+// span exports with s.
+func WithTraceExportStats(s *ExportStats) TracerOption {
+	return func(o *tracerOptions) {
+		o.stats = s
+	}
+}
+
+// WithSpanLimits bounds the number of attributes and events per span and
+// the length of attribute values.
+func WithSpanLimits(l SpanLimits) TracerOption {
+	return func(o *tracerOptions) {
+		o.limits = l
+	}
+}
+
+// WithDeploymentAttributes adds deployment metadata read from environment
+// variables to every span with a DeploymentProcessor. spec is a
+// comma-separated list of attribute=ENV_VAR pairs, e.g.
+// "cloud.region=DEPLOYMENT_REGION,service.instance.id=HOSTNAME"; attributes
+// whose variable is empty are left out. An empty spec adds nothing.
+func WithDeploymentAttributes(spec string) TracerOption {
+	return func(o *tracerOptions) {
+		o.deployment = spec
+	}
+}
+
+// WithTraceFallback exports spans to the fallback of d while the exporter
+// is failing.
+func WithTraceFallback(d *Degradation) TracerOption {
+	return func(o *tracerOptions) {
+		o.degradation = d
+	}
+}
+
+// WithTraceOTLP configures the headers, compression and TLS of the OTLP
+// exporter.
+func WithTraceOTLP(c OTLPConfig) TracerOption {
+	return func(o *tracerOptions) {
+		o.otlp = c
+	}
+}
+
+// WithTraceFileCollector sends OTLP spans to c instead of the collector at
+// the OTLP endpoint. It has no effect on the zipkin and jaeger-thrift-http
+// exporters.
+func WithTraceFileCollector(c *FileCollector) TracerOption {
+	return func(o *tracerOptions) {
+		o.collector = c
+	}
+}
+
+// WithTraceMirror also exports spans to m if it mirrors traces.
+func WithTraceMirror(m Mirror) TracerOption {
+	return func(o *tracerOptions) {
+		o.mirror = m
+	}
+}
+
 // InitTracerProvider initializes the OpenTelemetry tracer provider.
-// It configures an OTLP gRPC exporter and sets up the global tracer provider.
-func InitTracerProvider(ctx context.Context, serviceName, otlpEndpoint, environment string) (*sdktrace.TracerProvider, error) {
-	// Create OTLP gRPC exporter
-	conn, err := grpc.NewClient(otlpEndpoint,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+// It configures an OTLP gRPC exporter (or the exporter selected via
+// WithTraceExporter) and sets up the global tracer provider.
+// The OTLP exporter connection is registered with conns for health reporting.
+func InitTracerProvider(ctx context.Context, serviceName, otlpEndpoint, environment string, conns *ExporterConns, opts ...TracerOption) (*sdktrace.TracerProvider, error) {
+	o := tracerOptions{exporter: TraceExporterOTLP, otlp: defaultOTLPConfig, sampleRatio: 1}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+	exporter, err := newTraceExporter(ctx, otlpEndpoint, conns, o)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		return nil, err
+	}
+	if o.degradation != nil {
+		exporter, err = o.degradation.WrapSpanExporter(exporter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.mirror.enabled("traces") {
+		exporter, err = o.mirror.mirrorSpans(ctx, exporter, conns, o.stats)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Create resource with service information
@@ -36,6 +236,7 @@ func InitTracerProvider(ctx context.Context, serviceName, otlpEndpoint, environm
 		resource.NewWithAttributes(
 			semconv.SchemaURL,
 			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(buildinfo.Version),
 			semconv.DeploymentEnvironment(environment),
 		),
 	)
@@ -43,12 +244,34 @@ func InitTracerProvider(ctx context.Context, serviceName, otlpEndpoint, environm
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Create tracer provider with batch span processor
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+	deployment, err := deploymentAttributes(o.deployment, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	var sampler sdktrace.Sampler = sdktrace.TraceIDRatioBased(o.sampleRatio)
+	if o.adaptive != nil {
+		sampler = o.adaptive
+	}
+	if o.tenantSampling != nil {
+		sampler = tenantSampler{base: sampler, policy: o.tenantSampling}
+	}
+
+	// Create tracer provider with a batching export queue, preceded by the
+	// processors marking synthetic spans and enriching spans with
+	// deployment metadata. Spans forced to be sampled (WithForcedSampling)
+	// bypass the sampler and the decision of their parent.
+	tpOpts := []sdktrace.TracerProviderOption{
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()), // Sample everything for learning
-	)
+		sdktrace.WithRawSpanLimits(o.limits.sdkLimits()),
+		sdktrace.WithSampler(forceSampler{base: sdktrace.ParentBased(sampler)}),
+		sdktrace.WithSpanProcessor(SyntheticProcessor{}),
+	}
+	if len(deployment) > 0 {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(NewDeploymentProcessor(deployment...)))
+	}
+	tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(newSpanQueueProcessor(exporter, o.batch, o.drops)))
+	tp := sdktrace.NewTracerProvider(tpOpts...)
 
 	// Set global tracer provider
 	otel.SetTracerProvider(tp)
@@ -61,3 +284,48 @@ func InitTracerProvider(ctx context.Context, serviceName, otlpEndpoint, environm
 
 	return tp, nil
 }
+
+func newTraceExporter(ctx context.Context, otlpEndpoint string, conns *ExporterConns, o tracerOptions) (sdktrace.SpanExporter, error) {
+	switch o.exporter {
+	case TraceExporterOTLP:
+		// Create OTLP gRPC exporter
+		conn, headers, err := conns.dial("traces", otlpEndpoint, o.collector, o.otlp, o.stats)
+		if err != nil {
+			return nil, err
+		}
+
+		exporter, err := otlptracegrpc.New(ctx,
+			otlptracegrpc.WithGRPCConn(conn),
+			otlptracegrpc.WithHeaders(headers),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		}
+		return o.stats.wrapSpans("traces", exporter), nil
+
+	case TraceExporterZipkin:
+		endpoint := o.exporterEndpoint
+		if endpoint == "" {
+			endpoint = defaultZipkinEndpoint
+		}
+		exporter, err := zipkin.New(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zipkin exporter: %w", err)
+		}
+		return exporter, nil
+
+	case TraceExporterJaegerThriftHTTP:
+		endpoint := o.exporterEndpoint
+		if endpoint == "" {
+			endpoint = defaultJaegerEndpoint
+		}
+		exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(endpoint)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create jaeger exporter: %w", err)
+		}
+		return exporter, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter %q", o.exporter)
+	}
+}
@@ -0,0 +1,127 @@
+package tenant
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/tenant")
+
+// Timeouts of the Redis limiter. A call taking longer than redisTimeout
+// fails, and Redis isn't asked again for redisRetryAfter after a failure, so
+// an unreachable Redis adds little latency to requests.
+const (
+	redisTimeout    = 100 * time.Millisecond
+	redisRetryAfter = 5 * time.Second
+)
+
+// ErrLimiterUnavailable is returned by RedisLimiter.Allow while Redis isn't
+// asked after a failure.
+var ErrLimiterUnavailable = errors.New("rate limiter unavailable")
+
+// slidingWindow takes a request from the sliding window log of KEYS[1]:
+// the sorted set of the request times (in microseconds, from the Redis
+// clock so replicas agree) within the last ARGV[1] microseconds. The
+// request ARGV[3] is added if fewer than ARGV[2] are in the window. It
+// returns 1 if added, and otherwise 0 and the microseconds until the oldest
+// request leaves the window.
+var slidingWindow = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local window = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+	return {1, 0}
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {0, tonumber(oldest[2]) + window - now}
+`)
+
+// RedisLimiter is a Limiter enforcing the rate limits of tenants across all
+// replicas with a sliding window log in Redis. A policy allows Burst
+// requests (see Policy) within the window of Burst / RateLimit seconds,
+// which averages to the same rate as the local token bucket.
+type RedisLimiter struct {
+	client *redis.Client
+	prefix string
+
+	// downUntil is when Redis is asked again after a failure, in Unix
+	// nanoseconds.
+	downUntil atomic.Int64
+}
+
+// NewRedisLimiter creates a new RedisLimiter for the Redis server at url,
+// keeping the window of a tenant in the key prefix + tenant. The
+// connection isn't checked, so the service starts while Redis is down.
+func NewRedisLimiter(url, prefix string) (*RedisLimiter, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+	}
+	opts.ContextTimeoutEnabled = true
+	return &RedisLimiter{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+// Allow implements Limiter.
+func (l *RedisLimiter) Allow(ctx context.Context, d Decision) (bool, time.Duration, error) {
+	if time.Now().UnixNano() < l.downUntil.Load() {
+		return false, 0, ErrLimiterUnavailable
+	}
+
+	ctx, span := tracer.Start(ctx, "RedisLimiter.Allow",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemRedis,
+			semconv.DBOperationName("EVALSHA"),
+			attribute.Float64("tenant.rate_limit", d.Policy.RateLimit),
+		),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	limit := math.Floor(d.Policy.burst())
+	window := time.Duration(limit / d.Policy.RateLimit * float64(time.Second))
+	res, err := slidingWindow.Run(ctx, l.client, []string{l.prefix + d.Tenant},
+		window.Microseconds(), int64(limit), newMember()).Int64Slice()
+	if err != nil {
+		l.downUntil.Store(time.Now().Add(redisRetryAfter).UnixNano())
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to take from rate limit")
+		return false, 0, fmt.Errorf("failed to take from rate limit: %w", err)
+	}
+
+	allowed := res[0] == 1
+	span.SetAttributes(attribute.Bool("tenant.rate_limited", !allowed))
+	return allowed, time.Duration(res[1]) * time.Microsecond, nil
+}
+
+// Close closes the Redis client.
+func (l *RedisLimiter) Close() error {
+	return l.client.Close()
+}
+
+// newMember returns a unique sorted set member for a request, since
+// requests in the same microsecond must not replace each other.
+func newMember() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
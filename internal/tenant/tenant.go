@@ -0,0 +1,283 @@
+// Package tenant holds the per-tenant policies of the API: the trace
+// sampling ratio and the request rate limit of each tenant. A default
+// policy applies to tenants without an override; overrides are loaded from
+// a JSON file and can be changed at runtime through the admin API.
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"time"
+)
+
+// Header is the request header naming the tenant of the caller.
+const Header = "X-Tenant-ID"
+
+// DefaultName is the tenant name used to change the default policy through
+// Policies.Set.
+const DefaultName = "default"
+
+// maxLimiters bounds the number of rate limiters kept, since any tenant
+// name can be sent. When reached, the limiters are reset.
+const maxLimiters = 10000
+
+// ErrInvalidPolicy is returned for a policy with a sample ratio outside
+// [0, 1] or a negative rate limit or burst.
+var ErrInvalidPolicy = errors.New("invalid tenant policy")
+
+// Policy is the sampling and rate limit policy of a tenant.
+type Policy struct {
+	// SampleRatio is the fraction of the tenant's new traces that are
+	// sampled. Nil keeps the ratio of the tracer provider.
+	SampleRatio *float64 `json:"sample_ratio,omitempty"`
+	// RateLimit is the number of requests per second the tenant may send,
+	// 0 for unlimited, and Burst the number it may send at once. A Burst
+	// of 0 allows one second worth of requests.
+	RateLimit float64 `json:"rate_limit"`
+	Burst     int     `json:"burst,omitempty"`
+}
+
+// validate reports whether p is valid.
+func (p Policy) validate() error {
+	if p.SampleRatio != nil && (*p.SampleRatio < 0 || *p.SampleRatio > 1) {
+		return fmt.Errorf("%w: sample ratio %g", ErrInvalidPolicy, *p.SampleRatio)
+	}
+	if p.RateLimit < 0 || p.Burst < 0 {
+		return fmt.Errorf("%w: rate limit %g, burst %d", ErrInvalidPolicy, p.RateLimit, p.Burst)
+	}
+	return nil
+}
+
+// burst returns the bucket size of p.
+func (p Policy) burst() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+	return max(p.RateLimit, 1)
+}
+
+// Snapshot is a snapshot of the configured policies.
+type Snapshot struct {
+	Default Policy            `json:"default"`
+	Tenants map[string]Policy `json:"tenants"`
+}
+
+// Decision is the policy applied to a request of a tenant.
+type Decision struct {
+	Tenant string
+	Policy Policy
+	// Override is true if the tenant has its own policy rather than the
+	// default one.
+	Override bool
+}
+
+// PolicyName returns "override" or "default", as recorded on spans.
+func (d Decision) PolicyName() string {
+	if d.Override {
+		return "override"
+	}
+	return "default"
+}
+
+// Limiter enforces the rate limits of tenants shared by all replicas of
+// the service, e.g. a RedisLimiter. It returns an error if it can't take the
+// request from the limit.
+type Limiter interface {
+	Allow(ctx context.Context, d Decision) (bool, time.Duration, error)
+}
+
+// Policies holds the policies of all tenants and enforces their rate
+// limits. Policies can be changed at runtime.
+type Policies struct {
+	mu       sync.RWMutex
+	def      Policy
+	tenants  map[string]Policy
+	limiters map[string]*bucket
+	shared   Limiter
+}
+
+// NewPolicies creates a new Policies with the default policy def and the
+// per-tenant overrides.
+func NewPolicies(def Policy, overrides map[string]Policy) (*Policies, error) {
+	if err := def.validate(); err != nil {
+		return nil, err
+	}
+	for name, p := range overrides {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+	}
+	tenants := make(map[string]Policy, len(overrides))
+	maps.Copy(tenants, overrides)
+	return &Policies{
+		def:      def,
+		tenants:  tenants,
+		limiters: make(map[string]*bucket),
+	}, nil
+}
+
+// LoadFile reads per-tenant overrides from a JSON file mapping tenant names
+// to policies.
+func LoadFile(file string) (map[string]Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant policies file: %w", err)
+	}
+	var overrides map[string]Policy
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant policies file: %w", err)
+	}
+	return overrides, nil
+}
+
+// Lookup returns the policy applied to tenant.
+func (p *Policies) Lookup(tenant string) Decision {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if policy, ok := p.tenants[tenant]; ok {
+		return Decision{Tenant: tenant, Policy: policy, Override: true}
+	}
+	return Decision{Tenant: tenant, Policy: p.def}
+}
+
+// SampleRatio returns the tenant of ctx and the sampling ratio of its
+// policy, and false if ctx carries no tenant or the policy keeps the ratio
+// of the tracer provider. It is a telemetry.TenantSampling.
+func (p *Policies) SampleRatio(ctx context.Context) (string, float64, bool) {
+	tenant, ok := FromContext(ctx)
+	if !ok {
+		return "", 0, false
+	}
+	d := p.Lookup(tenant)
+	if d.Policy.SampleRatio == nil {
+		return "", 0, false
+	}
+	return tenant, *d.Policy.SampleRatio, true
+}
+
+// Snapshot returns the configured policies.
+func (p *Policies) Snapshot() Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return Snapshot{Default: p.def, Tenants: maps.Clone(p.tenants)}
+}
+
+// Set changes the policy of tenant, or the default policy for DefaultName.
+// The local rate limits of affected tenants restart with a full bucket.
+func (p *Policies) Set(tenant string, policy Policy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if tenant == DefaultName {
+		p.def = policy
+		clear(p.limiters)
+		return nil
+	}
+	p.tenants[tenant] = policy
+	delete(p.limiters, tenant)
+	return nil
+}
+
+// Delete removes the override of tenant, which falls back to the default
+// policy. It reports whether the tenant had an override.
+func (p *Policies) Delete(tenant string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.tenants[tenant]; !ok {
+		return false
+	}
+	delete(p.tenants, tenant)
+	delete(p.limiters, tenant)
+	return true
+}
+
+// SetLimiter makes Allow enforce the rate limits with the shared limiter l
+// rather than per process. It must be called before Allow.
+func (p *Policies) SetLimiter(l Limiter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.shared = l
+}
+
+// Allow takes a request of tenant from its rate limit. If the limit is
+// reached, it returns false and the time until the next request is allowed.
+// If the shared limiter fails, the limit is enforced per process instead and
+// the error of the shared limiter is returned along with the result.
+func (p *Policies) Allow(ctx context.Context, d Decision) (bool, time.Duration, error) {
+	if d.Policy.RateLimit == 0 {
+		return true, 0, nil
+	}
+
+	var sharedErr error
+	if p.shared != nil {
+		ok, retryAfter, err := p.shared.Allow(ctx, d)
+		if err == nil {
+			return ok, retryAfter, nil
+		}
+		sharedErr = err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.limiters[d.Tenant]
+	if !ok {
+		if len(p.limiters) >= maxLimiters {
+			clear(p.limiters)
+		}
+		b = newBucket(d.Policy.RateLimit, d.Policy.burst())
+		p.limiters[d.Tenant] = b
+	}
+	ok, retryAfter := b.take(time.Now())
+	return ok, retryAfter, sharedErr
+}
+
+// bucket is a token bucket refilled at rate tokens per second up to size.
+type bucket struct {
+	rate   float64
+	size   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate, size float64) *bucket {
+	return &bucket{rate: rate, size: size, tokens: size, last: time.Now()}
+}
+
+// take takes a token at now, or returns false and the time until one is
+// available.
+func (b *bucket) take(now time.Time) (bool, time.Duration) {
+	b.tokens = min(b.size, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the tenant name.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// FromContext returns the tenant of ctx, and false if ctx carries none.
+func FromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
@@ -0,0 +1,107 @@
+package tracetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Check is a named assertion on the exported telemetry.
+type Check struct {
+	Name string
+	Fn   func(t *Telemetry) error
+}
+
+// Eventually loads the telemetry with load and runs checks until all of
+// them pass or timeout expires, since exports arrive asynchronously. It
+// returns the failures of the last attempt.
+func Eventually(ctx context.Context, timeout time.Duration, load func(context.Context) (*Telemetry, error), checks []Check) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		err := run(ctx, load, checks)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func run(ctx context.Context, load func(context.Context) (*Telemetry, error), checks []Check) error {
+	t, err := load(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, c := range checks {
+		if err := c.Fn(t); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Find returns the first span of spans named name.
+func Find(spans []Span, name string) (Span, error) {
+	for _, s := range spans {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Span{}, fmt.Errorf("no %q span", name)
+}
+
+// Root returns the span of spans without a parent, which must be the only
+// one.
+func Root(spans []Span) (Span, error) {
+	var roots []Span
+	for _, s := range spans {
+		if s.ParentSpanID == "" {
+			roots = append(roots, s)
+		}
+	}
+	if len(roots) != 1 {
+		return Span{}, fmt.Errorf("%d root spans, want 1", len(roots))
+	}
+	return roots[0], nil
+}
+
+// ChildOf returns an error unless child's parent is parent.
+func ChildOf(child, parent Span) error {
+	if child.TraceID != parent.TraceID || child.ParentSpanID != parent.SpanID {
+		return fmt.Errorf("%q is not a child of %q", child.Name, parent.Name)
+	}
+	return nil
+}
+
+// DescendantOf returns an error unless span is below ancestor in spans.
+func DescendantOf(spans []Span, span, ancestor Span) error {
+	byID := make(map[string]Span, len(spans))
+	for _, s := range spans {
+		byID[s.SpanID] = s
+	}
+	for s, ok := span, true; ok; s, ok = byID[s.ParentSpanID] {
+		if s.ParentSpanID == ancestor.SpanID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not below %q", span.Name, ancestor.Name)
+}
+
+// HasAttribute returns an error unless attrs has key set to want.
+func HasAttribute(attrs map[string]string, key, want string) error {
+	got, ok := attrs[key]
+	if !ok {
+		return fmt.Errorf("attribute %s is missing", key)
+	}
+	if got != want {
+		return fmt.Errorf("attribute %s is %q, want %q", key, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+//go:build integration
+
+package tracetest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var collectorImage = flag.String("collector-image", "otel/opentelemetry-collector-contrib:0.114.0", "collector image, the same as in the observability stack")
+
+// collectorConfig receives OTLP over gRPC and writes each signal to its own
+// file in /out with the file exporter.
+const collectorConfig = `receivers:
+  otlp:
+    protocols:
+      grpc:
+        endpoint: 0.0.0.0:4317
+
+exporters:
+  file/traces:
+    path: /out/traces.jsonl
+    flush_interval: 100ms
+  file/metrics:
+    path: /out/metrics.jsonl
+    flush_interval: 100ms
+  file/logs:
+    path: /out/logs.jsonl
+    flush_interval: 100ms
+
+service:
+  pipelines:
+    traces:
+      receivers: [otlp]
+      exporters: [file/traces]
+    metrics:
+      receivers: [otlp]
+      exporters: [file/metrics]
+    logs:
+      receivers: [otlp]
+      exporters: [file/logs]
+`
+
+// signalFiles are the files the collector writes in /out.
+var signalFiles = []string{"traces.jsonl", "metrics.jsonl", "logs.jsonl"}
+
+// startCollector starts a collector container, which is terminated when
+// the test ends. It returns the host:port of its OTLP gRPC receiver and a
+// function loading what it has exported so far.
+func startCollector(t *testing.T) (string, func(context.Context) (*Telemetry, error)) {
+	t.Helper()
+	skipWithoutDocker(t)
+	ctx := t.Context()
+
+	// The collector runs as a non-root user that can't create files in
+	// /out, so the output files are created writable up front.
+	files := []testcontainers.ContainerFile{{
+		Reader:            strings.NewReader(collectorConfig),
+		ContainerFilePath: "/etc/otelcol/config.yaml",
+		FileMode:          0o644,
+	}}
+	for _, name := range signalFiles {
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            strings.NewReader(""),
+			ContainerFilePath: "/out/" + name,
+			FileMode:          0o666,
+		})
+	}
+
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        *collectorImage,
+			Cmd:          []string{"--config", "/etc/otelcol/config.yaml"},
+			ExposedPorts: []string{"4317/tcp"},
+			Files:        files,
+			WaitingFor: wait.ForAll(
+				wait.ForLog("Everything is ready"),
+				wait.ForListeningPort("4317/tcp"),
+			),
+		},
+		Started: true,
+	})
+	t.Cleanup(func() {
+		if c == nil {
+			return
+		}
+		if err := c.Terminate(context.WithoutCancel(ctx)); err != nil {
+			t.Logf("failed to terminate collector: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to start collector: %v", err)
+	}
+
+	endpoint, err := c.PortEndpoint(ctx, "4317/tcp", "")
+	if err != nil {
+		t.Fatalf("failed to get collector endpoint: %v", err)
+	}
+
+	// Each load copies the files out of the container, so it also works
+	// with a remote Docker host.
+	dir := t.TempDir()
+	load := func(ctx context.Context) (*Telemetry, error) {
+		var errs []error
+		for _, name := range signalFiles {
+			errs = append(errs, copyFromContainer(ctx, c, "/out/"+name, filepath.Join(dir, name)))
+		}
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
+		}
+		return Load(dir)
+	}
+	return endpoint, load
+}
+
+// skipWithoutDocker skips the test unless a Docker host is available.
+// testcontainers panics when it can't find one.
+func skipWithoutDocker(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("Docker is not available: %v", r)
+		}
+	}()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
+
+// copyFromContainer copies the file at src in c to dst.
+func copyFromContainer(ctx context.Context, c testcontainers.Container, src, dst string) error {
+	r, err := c.CopyFileFromContainer(ctx, src)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s from collector: %w", src, err)
+	}
+	defer r.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to copy %s from collector: %w", src, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,254 @@
+package tracetest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Span kinds as numbered in OTLP.
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+// Span is an exported span with its resource and scope.
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Kind         int
+	Scope        string
+	Attributes   map[string]string
+	Resource     map[string]string
+}
+
+// Metric is an exported metric with its resource and scope. Only the name
+// is kept; the data points aren't checked.
+type Metric struct {
+	Name     string
+	Scope    string
+	Resource map[string]string
+}
+
+// LogRecord is an exported log record with its resource and scope.
+type LogRecord struct {
+	TraceID    string
+	SpanID     string
+	Body       string
+	Severity   string
+	Scope      string
+	Attributes map[string]string
+	Resource   map[string]string
+}
+
+// Telemetry is everything exported to the files of a collector.
+type Telemetry struct {
+	Spans   []Span
+	Metrics []Metric
+	Logs    []LogRecord
+}
+
+// Trace returns the spans of the trace with the given hex ID.
+func (t *Telemetry) Trace(traceID string) []Span {
+	var spans []Span
+	for _, s := range t.Spans {
+		if s.TraceID == traceID {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Load reads the OTLP JSON lines of traces.jsonl, metrics.jsonl and
+// logs.jsonl in dir, as written by the collector's file exporter or by
+// telemetry.FileCollector. Missing files are treated as empty, since the
+// collector only creates them on the first export.
+func Load(dir string) (*Telemetry, error) {
+	var t Telemetry
+
+	err := readLines(filepath.Join(dir, "traces.jsonl"), func(line []byte) error {
+		var data struct {
+			ResourceSpans []struct {
+				Resource   resource `json:"resource"`
+				ScopeSpans []struct {
+					Scope scope `json:"scope"`
+					Spans []struct {
+						TraceID      string     `json:"traceId"`
+						SpanID       string     `json:"spanId"`
+						ParentSpanID string     `json:"parentSpanId"`
+						Name         string     `json:"name"`
+						Kind         int        `json:"kind"`
+						Attributes   attributes `json:"attributes"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal(line, &data); err != nil {
+			return err
+		}
+		for _, rs := range data.ResourceSpans {
+			res := rs.Resource.Attributes.Map()
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					t.Spans = append(t.Spans, Span{
+						TraceID:      s.TraceID,
+						SpanID:       s.SpanID,
+						ParentSpanID: s.ParentSpanID,
+						Name:         s.Name,
+						Kind:         s.Kind,
+						Scope:        ss.Scope.Name,
+						Attributes:   s.Attributes.Map(),
+						Resource:     res,
+					})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read traces: %w", err)
+	}
+
+	err = readLines(filepath.Join(dir, "metrics.jsonl"), func(line []byte) error {
+		var data struct {
+			ResourceMetrics []struct {
+				Resource     resource `json:"resource"`
+				ScopeMetrics []struct {
+					Scope   scope `json:"scope"`
+					Metrics []struct {
+						Name string `json:"name"`
+					} `json:"metrics"`
+				} `json:"scopeMetrics"`
+			} `json:"resourceMetrics"`
+		}
+		if err := json.Unmarshal(line, &data); err != nil {
+			return err
+		}
+		for _, rm := range data.ResourceMetrics {
+			res := rm.Resource.Attributes.Map()
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					t.Metrics = append(t.Metrics, Metric{Name: m.Name, Scope: sm.Scope.Name, Resource: res})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+
+	err = readLines(filepath.Join(dir, "logs.jsonl"), func(line []byte) error {
+		var data struct {
+			ResourceLogs []struct {
+				Resource  resource `json:"resource"`
+				ScopeLogs []struct {
+					Scope      scope `json:"scope"`
+					LogRecords []struct {
+						TraceID      string     `json:"traceId"`
+						SpanID       string     `json:"spanId"`
+						SeverityText string     `json:"severityText"`
+						Body         anyValue   `json:"body"`
+						Attributes   attributes `json:"attributes"`
+					} `json:"logRecords"`
+				} `json:"scopeLogs"`
+			} `json:"resourceLogs"`
+		}
+		if err := json.Unmarshal(line, &data); err != nil {
+			return err
+		}
+		for _, rl := range data.ResourceLogs {
+			res := rl.Resource.Attributes.Map()
+			for _, sl := range rl.ScopeLogs {
+				for _, r := range sl.LogRecords {
+					t.Logs = append(t.Logs, LogRecord{
+						TraceID:    r.TraceID,
+						SpanID:     r.SpanID,
+						Body:       r.Body.String(),
+						Severity:   r.SeverityText,
+						Scope:      sl.Scope.Name,
+						Attributes: r.Attributes.Map(),
+						Resource:   res,
+					})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	return &t, nil
+}
+
+// readLines calls fn with each non-empty line of the file at path.
+func readLines(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+type resource struct {
+	Attributes attributes `json:"attributes"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type attributes []struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+// Map returns the attributes with their values formatted as strings.
+func (a attributes) Map() map[string]string {
+	m := make(map[string]string, len(a))
+	for _, kv := range a {
+		m[kv.Key] = kv.Value.String()
+	}
+	return m
+}
+
+// anyValue is an OTLP AnyValue: an object with a single field such as
+// stringValue or intValue.
+type anyValue map[string]json.RawMessage
+
+// String returns the value as a string; 64-bit integers are already
+// strings in OTLP JSON.
+func (v anyValue) String() string {
+	for _, raw := range v {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+		return strings.TrimSpace(string(raw))
+	}
+	return ""
+}
@@ -0,0 +1,336 @@
+//go:build integration
+
+package tracetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/app"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/config"
+)
+
+// Resource attributes the server under test is started with.
+const (
+	serviceName = "tracetest"
+	environment = "tracetest"
+	adminToken  = "tracetest"
+)
+
+// telemetryTimeout is how long to wait for the expected telemetry.
+const telemetryTimeout = 30 * time.Second
+
+// TestTrace runs the server against a collector container, makes API calls
+// and checks the exported spans, metrics and logs end to end.
+func TestTrace(t *testing.T) {
+	endpoint, load := startCollector(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", endpoint)
+	testTrace(t, load)
+}
+
+// TestTraceInProcess runs the same checks against the server's own file
+// collector, where Docker isn't available.
+func TestTraceInProcess(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TELEMETRY_FILE_DIR", dir)
+	testTrace(t, func(context.Context) (*Telemetry, error) { return Load(dir) })
+}
+
+func testTrace(t *testing.T, load func(context.Context) (*Telemetry, error)) {
+	c := startServer(t)
+
+	s, err := runScenario(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("create trace %s, get trace %s, not found trace %s", s.createTrace, s.getTrace, s.notFoundTrace)
+
+	if err := Eventually(t.Context(), telemetryTimeout, load, s.checks()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startServer starts the application with the test configuration and
+// returns a client for it. The server is stopped when the test ends.
+func startServer(t *testing.T) *client {
+	t.Helper()
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{
+		"SERVER_PORT":             strconv.Itoa(port),
+		"OTEL_SERVICE_NAME":       serviceName,
+		"ENVIRONMENT":             environment,
+		"ADMIN_TOKEN":             adminToken,
+		"SCHEDULER_ENABLED":       "false",
+		"ATTACHMENT_DIR":          filepath.Join(t.TempDir(), "attachments"),
+		"METRICS_EXPORT_INTERVAL": "1s",
+	} {
+		t.Setenv(key, value)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("invalid configuration: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	a, err := app.New(t.Context(), cfg, logger)
+	if err != nil {
+		t.Fatalf("failed to initialize application: %v", err)
+	}
+
+	ctx, stop := context.WithCancel(context.WithoutCancel(t.Context()))
+	done := make(chan error, 1)
+	go func() { done <- a.Run(ctx) }() // async:ok: the server under test
+	t.Cleanup(func() {
+		stop()
+		if err := <-done; err != nil {
+			t.Logf("server stopped: %v", err)
+		}
+	})
+
+	c := &client{base: fmt.Sprintf("http://127.0.0.1:%d", port)}
+	if err := c.waitReady(t.Context(), 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// scenario holds the trace IDs of the API calls made by runScenario.
+type scenario struct {
+	createTrace   string
+	getTrace      string
+	notFoundTrace string
+}
+
+// runScenario creates a task, gets it, gets a missing task and flushes the
+// server's telemetry.
+func runScenario(c *client) (*scenario, error) {
+	var s scenario
+
+	var task struct {
+		ID string `json:"id"`
+	}
+	resp, err := c.do(http.MethodPost, "/api/v1/tasks", `{"title":"trace test"}`, http.StatusCreated, &task)
+	if err != nil {
+		return nil, err
+	}
+	s.createTrace = resp.Header.Get("X-Trace-Id")
+
+	resp, err = c.do(http.MethodGet, "/api/v1/tasks/"+task.ID, "", http.StatusOK, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.getTrace = resp.Header.Get("X-Trace-Id")
+
+	resp, err = c.do(http.MethodGet, "/api/v1/tasks/missing", "", http.StatusNotFound, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.notFoundTrace = resp.Header.Get("X-Trace-Id")
+
+	if _, err := c.do(http.MethodPost, "/api/v1/admin/telemetry/flush", "", http.StatusOK, nil); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// checks returns the assertions on the telemetry of the scenario.
+func (s *scenario) checks() []Check {
+	return []Check{
+		{Name: "resource", Fn: checkResource},
+		{Name: "create trace", Fn: func(t *Telemetry) error {
+			spans := t.Trace(s.createTrace)
+			server, err := serverSpan(spans, "POST /api/v1/tasks", "201")
+			if err != nil {
+				return err
+			}
+			handler, err := Find(spans, "TaskHandler.Create")
+			if err != nil {
+				return err
+			}
+			svc, err := Find(spans, "TaskService.Create")
+			if err != nil {
+				return err
+			}
+			repo, err := Find(spans, "TaskRepository.Create")
+			if err != nil {
+				return err
+			}
+			return errors.Join(
+				ChildOf(handler, server),
+				ChildOf(svc, handler),
+				DescendantOf(spans, repo, svc),
+			)
+		}},
+		{Name: "get trace", Fn: func(t *Telemetry) error {
+			spans := t.Trace(s.getTrace)
+			server, err := serverSpan(spans, "GET /api/v1/tasks/{id}", "200")
+			if err != nil {
+				return err
+			}
+			handler, err := Find(spans, "TaskHandler.GetByID")
+			if err != nil {
+				return err
+			}
+			return ChildOf(handler, server)
+		}},
+		{Name: "not found trace", Fn: func(t *Telemetry) error {
+			spans := t.Trace(s.notFoundTrace)
+			if _, err := serverSpan(spans, "GET /api/v1/tasks/{id}", "404"); err != nil {
+				return err
+			}
+			handler, err := Find(spans, "TaskHandler.GetByID")
+			if err != nil {
+				return err
+			}
+			return HasAttribute(handler.Attributes, "error.type", "not_found")
+		}},
+		{Name: "metrics", Fn: func(t *Telemetry) error {
+			var errs []error
+			for _, name := range []string{"http.server.request.duration", "db_client_operation_duration_seconds"} {
+				found := false
+				for _, m := range t.Metrics {
+					found = found || m.Name == name
+				}
+				if !found {
+					errs = append(errs, fmt.Errorf("metric %s not exported", name))
+				}
+			}
+			return errors.Join(errs...)
+		}},
+		{Name: "log correlation", Fn: func(t *Telemetry) error {
+			spans := make(map[string]bool)
+			for _, span := range t.Trace(s.createTrace) {
+				spans[span.SpanID] = true
+			}
+			for _, r := range t.Logs {
+				if r.TraceID == s.createTrace && spans[r.SpanID] {
+					return nil
+				}
+			}
+			return errors.New("no log record correlated with a span of the create trace")
+		}},
+	}
+}
+
+// checkResource checks that the server exported all signals with its
+// resource attributes.
+func checkResource(t *Telemetry) error {
+	var resources []map[string]string
+	if len(t.Spans) == 0 || len(t.Metrics) == 0 || len(t.Logs) == 0 {
+		return fmt.Errorf("got %d spans, %d metrics and %d log records, want some of each", len(t.Spans), len(t.Metrics), len(t.Logs))
+	}
+	for _, s := range t.Spans {
+		resources = append(resources, s.Resource)
+	}
+	for _, m := range t.Metrics {
+		resources = append(resources, m.Resource)
+	}
+	for _, r := range t.Logs {
+		resources = append(resources, r.Resource)
+	}
+	for _, res := range resources {
+		if err := errors.Join(
+			HasAttribute(res, "service.name", serviceName),
+			HasAttribute(res, "deployment.environment", environment),
+		); err != nil {
+			return err
+		}
+		if res["service.version"] == "" {
+			return errors.New("attribute service.version is missing")
+		}
+	}
+	return nil
+}
+
+// serverSpan returns the root span of spans, checking that it is the
+// server span of route with the given status. otelhttp still records the
+// status with the pre-stable semantic conventions.
+func serverSpan(spans []Span, name, status string) (Span, error) {
+	root, err := Root(spans)
+	if err != nil {
+		return root, err
+	}
+	if root.Name != name || root.Kind != SpanKindServer {
+		return root, fmt.Errorf("root span is %q (kind %d), want server span %q", root.Name, root.Kind, name)
+	}
+	return root, HasAttribute(root.Attributes, "http.status_code", status)
+}
+
+// freePort returns a TCP port that is free on the loopback interface.
+func freePort() (int, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port, nil
+}
+
+// client calls the API of the server under test.
+type client struct {
+	base string
+}
+
+// waitReady waits until the server answers health checks.
+func (c *client) waitReady(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := http.Get(c.base + "/health")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server not ready after %s", timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// do makes a request and checks its status, decoding the response into out
+// if it isn't nil.
+func (c *client) do(method, path, body string, status int, out any) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base+path, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		return nil, fmt.Errorf("%s %s: got status %d, want %d", method, path, resp.StatusCode, status)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("%s %s: failed to decode response: %w", method, path, err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,82 @@
+// Dashboard for the task API. Every request is listed with its trace ID,
+// linked to the trace UI when a trace URL template is configured.
+(function () {
+  "use strict";
+
+  const api = "/api/v1/tasks";
+  const traceURLTemplate = document.querySelector('meta[name="trace-url-template"]').content;
+
+  // request calls the API and records the request with its trace ID.
+  async function request(method, url, body) {
+    const opts = { method: method, headers: {} };
+    if (body !== undefined) {
+      opts.headers["Content-Type"] = "application/json";
+      opts.body = JSON.stringify(body);
+    }
+    const resp = await fetch(url, opts);
+    record(method + " " + url, resp.status, resp.headers.get("X-Trace-Id"));
+    const data = resp.status === 204 ? null : await resp.json();
+    if (!resp.ok) {
+      throw new Error((data && data.detail) || resp.statusText);
+    }
+    return data;
+  }
+
+  function record(name, status, traceID) {
+    const row = document.createElement("tr");
+    row.append(cell(name), cell(String(status)), traceCell(traceID));
+    if (status >= 400) {
+      row.className = "error";
+    }
+    document.getElementById("requests").prepend(row);
+  }
+
+  function traceCell(traceID) {
+    const td = cell("");
+    td.className = "trace";
+    if (!traceID) {
+      td.textContent = "-";
+    } else if (traceURLTemplate) {
+      const a = document.createElement("a");
+      a.href = traceURLTemplate.replaceAll("{trace_id}", traceID);
+      a.target = "_blank";
+      a.rel = "noopener";
+      a.textContent = traceID;
+      td.append(a);
+    } else {
+      td.textContent = traceID;
+    }
+    return td;
+  }
+
+  function cell(text) {
+    const td = document.createElement("td");
+    td.textContent = text;
+    return td;
+  }
+
+  async function load() {
+    const tasks = await request("GET", api);
+    const body = document.getElementById("tasks");
+    body.replaceChildren(...tasks.map(function (t) {
+      const row = document.createElement("tr");
+      row.append(cell(t.title), cell(t.description || ""), cell(t.done ? "yes" : "no"),
+        cell(new Date(t.created_at).toLocaleString()));
+      return row;
+    }));
+  }
+
+  document.getElementById("create").addEventListener("submit", async function (e) {
+    e.preventDefault();
+    const form = e.target;
+    try {
+      await request("POST", api, { title: form.title.value, description: form.description.value });
+      form.reset();
+      await load();
+    } catch (err) {
+      console.error(err);
+    }
+  });
+
+  load().catch(console.error);
+})();
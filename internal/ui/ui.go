@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed static
+var static embed.FS
+
+// TraceIDPlaceholder is replaced by the trace ID in trace URL templates.
+const TraceIDPlaceholder = "{trace_id}"
+
+// Handler serves the embedded dashboard: a single page listing and creating
+// tasks through the API that shows the trace ID of every request.
+type Handler struct {
+	index            *template.Template
+	assets           http.Handler
+	traceURLTemplate string
+}
+
+// NewHandler creates a new Handler. traceURLTemplate links trace IDs to a
+// trace UI, e.g. http://localhost:16686/trace/{trace_id}; an empty template
+// shows trace IDs without links.
+func NewHandler(traceURLTemplate string) (*Handler, error) {
+	index, err := template.ParseFS(static, "static/index.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ui template: %w", err)
+	}
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ui assets: %w", err)
+	}
+	return &Handler{
+		index:            index,
+		assets:           http.StripPrefix("/ui/", http.FileServerFS(assets)),
+		traceURLTemplate: traceURLTemplate,
+	}, nil
+}
+
+// Routes returns the chi router serving the page at / and its assets below
+// /ui/.
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.Index)
+	r.Get("/ui/*", h.assets.ServeHTTP)
+
+	return r
+}
+
+// Index renders the page.
+func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = h.index.Execute(w, struct{ TraceURLTemplate string }{h.traceURLTemplate})
+}
@@ -0,0 +1,164 @@
+// Package warmup sends a burst of synthetic task API requests through the
+// in-process HTTP handler on startup, so dashboards show traffic right
+// after a deploy. All spans of the warm-up carry synthetic=true.
+package warmup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/fanout"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/logging"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/hiroki-koketsu/go-otel-sample/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/warmup")
+
+// Actor is the user ID the warm-up requests are made as.
+const Actor = "warmup"
+
+// tasksPath is the path of the task API the warm-up exercises.
+const tasksPath = "/api/v1/tasks"
+
+// Config configures a warm-up.
+type Config struct {
+	// Iterations is the number of create, get, update, list and delete
+	// cycles, each on a task of its own.
+	Iterations int
+	// Concurrency is the number of cycles run at a time.
+	Concurrency int
+}
+
+// Result counts the requests of a warm-up and those that failed.
+type Result struct {
+	Requests int64
+	Failures int64
+}
+
+// Run sends the warm-up requests to h and returns their counts. Cycles
+// whose task could not be created are cut short; other failures are
+// counted and logged. Run stops early when ctx is done.
+func Run(ctx context.Context, h http.Handler, c Config) Result {
+	logger := logging.FromContext(ctx)
+
+	ctx, err := telemetry.WithSynthetic(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to mark warm-up as synthetic", slog.Any("error", err))
+	}
+
+	ctx, span := tracer.Start(ctx, "WarmUp.Run", trace.WithAttributes(
+		attribute.Int("warmup.iterations", c.Iterations),
+		attribute.Int("warmup.concurrency", c.Concurrency),
+	))
+	defer span.End()
+
+	start := time.Now()
+	w := &warmer{handler: h}
+	g, gctx := fanout.New(ctx, c.Concurrency)
+	for i := range c.Iterations {
+		if gctx.Err() != nil {
+			break
+		}
+		g.Go("WarmUp.Cycle", func(ctx context.Context) error {
+			w.cycle(ctx, i)
+			return nil
+		}, trace.WithAttributes(attribute.Int("warmup.iteration", i)))
+	}
+	_ = g.Wait()
+
+	result := Result{Requests: w.requests.Load(), Failures: w.failures.Load()}
+	span.SetAttributes(
+		attribute.Int64("warmup.requests", result.Requests),
+		attribute.Int64("warmup.failures", result.Failures),
+	)
+	logger.InfoContext(ctx, "warm-up finished",
+		slog.Int64("requests", result.Requests),
+		slog.Int64("failures", result.Failures),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return result
+}
+
+// warmer sends the requests of the cycles and counts them.
+type warmer struct {
+	handler http.Handler
+
+	requests atomic.Int64
+	failures atomic.Int64
+}
+
+// cycle creates a task, reads, updates and lists it, and deletes it again.
+func (w *warmer) cycle(ctx context.Context, i int) {
+	var task model.Task
+	if !w.do(ctx, http.MethodPost, tasksPath, model.CreateTaskRequest{
+		Title:       fmt.Sprintf("warm-up %d", i),
+		Description: "synthetic task created on startup",
+	}, &task) {
+		return
+	}
+
+	done := true
+	path := tasksPath + "/" + task.ID
+	w.do(ctx, http.MethodGet, path, nil, nil)
+	w.do(ctx, http.MethodPut, path, model.UpdateTaskRequest{Done: &done}, nil)
+	w.do(ctx, http.MethodGet, tasksPath+"?limit=10", nil, nil)
+	w.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// do sends a request with body as JSON to the handler, decoding a
+// successful response into out if it is not nil. It reports whether the
+// request succeeded.
+func (w *warmer) do(ctx context.Context, method, path string, body, out any) bool {
+	logger := logging.FromContext(ctx)
+	w.requests.Add(1)
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			w.failures.Add(1)
+			logger.WarnContext(ctx, "failed to encode warm-up request", slog.Any("error", err))
+			return false
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(data))
+	if err != nil {
+		w.failures.Add(1)
+		logger.WarnContext(ctx, "failed to create warm-up request", slog.Any("error", err))
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", Actor)
+
+	rec := httptest.NewRecorder()
+	w.handler.ServeHTTP(rec, req)
+
+	if rec.Code >= http.StatusBadRequest {
+		w.failures.Add(1)
+		logger.WarnContext(ctx, "warm-up request failed",
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.Int("status", rec.Code),
+		)
+		return false
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			w.failures.Add(1)
+			logger.WarnContext(ctx, "failed to decode warm-up response", slog.Any("error", err))
+			return false
+		}
+	}
+	return true
+}
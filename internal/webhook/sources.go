@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"encoding/json"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+)
+
+// sources holds the supported webhook sources by name, as in the URL of
+// their endpoint.
+var sources = map[string]*source{
+	"github":  github,
+	"generic": generic,
+}
+
+// github receives GitHub repository webhooks: an opened issue creates a task
+// with the title of the issue. Ping events, sent when the webhook is set up,
+// are validated but create nothing.
+var github = &source{
+	signatureHeader: "X-Hub-Signature-256",
+	eventHeader:     "X-GitHub-Event",
+	deliveryHeader:  "X-GitHub-Delivery",
+	schemas: mustCompile("github", map[string]string{
+		"ping": `{
+			"type": "object",
+			"required": ["zen", "hook_id"],
+			"properties": {
+				"zen": {"type": "string"},
+				"hook_id": {"type": "integer"}
+			}
+		}`,
+		"issues": `{
+			"type": "object",
+			"required": ["action", "issue", "repository"],
+			"properties": {
+				"action": {"type": "string", "minLength": 1},
+				"issue": {
+					"type": "object",
+					"required": ["number", "title", "html_url"],
+					"properties": {
+						"number": {"type": "integer"},
+						"title": {"type": "string", "minLength": 1},
+						"body": {"type": ["string", "null"]},
+						"html_url": {"type": "string"}
+					}
+				},
+				"repository": {
+					"type": "object",
+					"required": ["full_name"],
+					"properties": {
+						"full_name": {"type": "string"}
+					}
+				}
+			}
+		}`,
+	}),
+	task: func(event string, body []byte) (string, *model.CreateTaskRequest, error) {
+		if event != "issues" {
+			return event, nil, nil
+		}
+		var p struct {
+			Action string `json:"action"`
+			Issue  struct {
+				Title   string `json:"title"`
+				Body    string `json:"body"`
+				HTMLURL string `json:"html_url"`
+			} `json:"issue"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return event, nil, err
+		}
+		event += "." + p.Action
+		if p.Action != "opened" {
+			return event, nil, nil
+		}
+		description := p.Issue.HTMLURL
+		if p.Issue.Body != "" {
+			description = p.Issue.Body + "\n\n" + description
+		}
+		return event, &model.CreateTaskRequest{Title: p.Issue.Title, Description: description}, nil
+	},
+}
+
+// generic receives webhooks of services without a source of their own,
+// which send a task.requested event with the task to create.
+var generic = &source{
+	signatureHeader: "X-Webhook-Signature",
+	eventHeader:     "X-Webhook-Event",
+	deliveryHeader:  "X-Webhook-Delivery",
+	schemas: mustCompile("generic", map[string]string{
+		"task.requested": `{
+			"type": "object",
+			"required": ["title"],
+			"additionalProperties": false,
+			"properties": {
+				"title": {"type": "string", "minLength": 1},
+				"description": {"type": "string"}
+			}
+		}`,
+	}),
+	task: func(event string, body []byte) (string, *model.CreateTaskRequest, error) {
+		var req model.CreateTaskRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return event, nil, err
+		}
+		return event, &req, nil
+	},
+}
@@ -0,0 +1,211 @@
+// Package webhook receives the webhooks other services send to create
+// tasks. A delivery is accepted if its body is signed with the secret
+// shared with its source (HMAC-SHA256) and its payload matches the JSON
+// Schema of its event; the events of each source that create a task are
+// mapped to a task creation request.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hiroki-koketsu/go-otel-sample/internal/model"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/hiroki-koketsu/go-otel-sample/internal/webhook")
+
+// Webhook errors.
+var (
+	ErrUnknownSource    = errors.New("unknown webhook source")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidPayload   = errors.New("invalid webhook payload")
+)
+
+// signaturePrefix prefixes the hex HMAC-SHA256 of the body in the signature
+// header.
+const signaturePrefix = "sha256="
+
+// Delivery is a verified and validated webhook delivery.
+type Delivery struct {
+	Source string
+	// Event is the event of the delivery, qualified by its action if the
+	// source sends one, e.g. issues.opened.
+	Event string
+	// ID identifies the delivery, if the source sends one.
+	ID string
+	// Task is the task the event creates, nil if it creates none.
+	Task *model.CreateTaskRequest
+}
+
+// Receiver verifies and parses the deliveries of the sources it has secrets
+// for.
+type Receiver struct {
+	secrets map[string][]byte
+}
+
+// NewReceiver creates a new Receiver accepting deliveries of the sources
+// named in secrets, signed with their secret.
+func NewReceiver(secrets map[string]string) (*Receiver, error) {
+	r := &Receiver{secrets: make(map[string][]byte, len(secrets))}
+	for name, secret := range secrets {
+		if _, ok := sources[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSource, name)
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("empty secret of webhook source %s", name)
+		}
+		r.secrets[name] = []byte(secret)
+	}
+	return r, nil
+}
+
+// ParseSecrets parses comma-separated source=secret pairs, e.g.
+// "github=s3cret,generic=t0ken".
+func ParseSecrets(s string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, secret, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, errors.New("invalid webhook secret, must be source=secret")
+		}
+		secrets[strings.TrimSpace(name)] = strings.TrimSpace(secret)
+	}
+	return secrets, nil
+}
+
+// Accepts reports whether the receiver accepts deliveries of source.
+func (r *Receiver) Accepts(source string) bool {
+	_, ok := r.secrets[source]
+	return ok
+}
+
+// Receive verifies the signature of the delivery of source with header and
+// body, and validates and parses its payload. The verification and the
+// validation are traced as spans of their own.
+func (r *Receiver) Receive(ctx context.Context, source string, header http.Header, body []byte) (Delivery, error) {
+	s, ok := sources[source]
+	secret, enabled := r.secrets[source]
+	if !ok || !enabled {
+		return Delivery{}, fmt.Errorf("%w: %s", ErrUnknownSource, source)
+	}
+
+	d := Delivery{
+		Source: source,
+		Event:  header.Get(s.eventHeader),
+		ID:     header.Get(s.deliveryHeader),
+	}
+	if err := verify(ctx, s, secret, header, body); err != nil {
+		return d, err
+	}
+	if err := s.parse(ctx, &d, body); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// verify checks the signature of body in the signature header of s.
+func verify(ctx context.Context, s *source, secret []byte, header http.Header, body []byte) error {
+	_, span := tracer.Start(ctx, "webhook.VerifySignature",
+		trace.WithAttributes(attribute.String("webhook.signature_header", s.signatureHeader)),
+	)
+	defer span.End()
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(header.Get(s.signatureHeader), signaturePrefix))
+	if err != nil || len(sig) == 0 {
+		span.SetStatus(codes.Error, "missing or malformed signature")
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		span.SetStatus(codes.Error, "signature mismatch")
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// source describes the webhooks of a sender: the headers of its deliveries
+// and the schemas of its events.
+type source struct {
+	signatureHeader string
+	eventHeader     string
+	deliveryHeader  string
+	// schemas holds the compiled schema of every event; other events are
+	// accepted but create no task.
+	schemas map[string]*jsonschema.Schema
+	// task maps the validated payload of event to the qualified event name
+	// and the task to create, nil if it creates none.
+	task func(event string, body []byte) (string, *model.CreateTaskRequest, error)
+}
+
+// parse validates body against the schema of the event of d and sets the
+// task it creates.
+func (s *source) parse(ctx context.Context, d *Delivery, body []byte) error {
+	_, span := tracer.Start(ctx, "webhook.ValidatePayload",
+		trace.WithAttributes(attribute.String("webhook.event", d.Event)),
+	)
+	defer span.End()
+
+	schema, ok := s.schemas[d.Event]
+	if !ok {
+		span.SetAttributes(attribute.Bool("webhook.event_supported", false))
+		return nil
+	}
+	span.SetAttributes(attribute.Bool("webhook.event_supported", true))
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		span.SetStatus(codes.Error, "malformed payload")
+		return fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+	if err := schema.Validate(doc); err != nil {
+		span.SetStatus(codes.Error, "payload doesn't match schema")
+		return fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+
+	event, task, err := s.task(d.Event, body)
+	if err != nil {
+		span.SetStatus(codes.Error, "malformed payload")
+		return fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+	d.Event = event
+	d.Task = task
+	span.SetAttributes(attribute.Bool("webhook.creates_task", task != nil))
+	return nil
+}
+
+// mustCompile compiles the JSON Schemas of the events of source name. The
+// schemas are static, so a failure is a programming error.
+func mustCompile(name string, schemas map[string]string) map[string]*jsonschema.Schema {
+	c := jsonschema.NewCompiler()
+	compiled := make(map[string]*jsonschema.Schema, len(schemas))
+	for event, schema := range schemas {
+		id := "urn:go-otel-sample:webhooks:" + name + ":" + event
+		doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schema))
+		if err != nil {
+			panic(fmt.Sprintf("failed to decode webhook schema %s: %v", id, err))
+		}
+		if err := c.AddResource(id, doc); err != nil {
+			panic(fmt.Sprintf("failed to add webhook schema %s: %v", id, err))
+		}
+		if compiled[event], err = c.Compile(id); err != nil {
+			panic(fmt.Sprintf("failed to compile webhook schema %s: %v", id, err))
+		}
+	}
+	return compiled
+}
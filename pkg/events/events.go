@@ -0,0 +1,59 @@
+// Package events defines the task events published to message brokers: a
+// typed payload per event type and schema version, the JSON Schemas of
+// every version, and the encoding used on the wire. It has no dependencies
+// on the service, so consumers in other modules can decode events with it.
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type identifies the kind of task event.
+type Type string
+
+const (
+	TypeTaskCreated Type = "task.created"
+	TypeTaskUpdated Type = "task.updated"
+	TypeTaskDeleted Type = "task.deleted"
+
+	TypeTaskArchived   Type = "task.archived"
+	TypeTaskUnarchived Type = "task.unarchived"
+
+	TypeTaskAssigned Type = "task.assigned"
+
+	TypeTaskClaimed  Type = "task.claimed"
+	TypeTaskReleased Type = "task.released"
+)
+
+// Payload is the data specific to one version of an event type, such as
+// TaskCreatedV1.
+type Payload interface {
+	EventType() Type
+	EventVersion() int
+}
+
+// Event is a task lifecycle event published to a message broker. Type and
+// Version are those of Payload, whose fields are encoded next to the
+// others (see Marshal).
+type Event struct {
+	ID        string    `json:"id"`
+	Type      Type      `json:"type"`
+	Version   int       `json:"version"`
+	TaskID    string    `json:"task_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Payload   Payload   `json:"-"`
+}
+
+// New creates an event for a task with payload p.
+func New(taskID string, p Payload) Event {
+	return Event{
+		ID:        uuid.New().String(),
+		Type:      p.EventType(),
+		Version:   p.EventVersion(),
+		TaskID:    taskID,
+		Timestamp: time.Now(),
+		Payload:   p,
+	}
+}
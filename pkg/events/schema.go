@@ -0,0 +1,295 @@
+package events
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// SchemaVersion is the version of the schemas events are published with.
+// It is bumped whenever an event changes incompatibly, together with new
+// payload types for the version; the schemas and payloads of earlier
+// versions stay in the registry, so consumers keep accepting events
+// published before an upgrade.
+const SchemaVersion = 1
+
+// Event schema errors.
+var (
+	ErrUnknownSchema = errors.New("unknown event schema")
+	ErrInvalidEvent  = errors.New("invalid event")
+)
+
+// Schema is the JSON Schema of one version of an event type, e.g.
+// task.created.v1.
+type Schema struct {
+	Name        string         `json:"name"`
+	Type        Type           `json:"type"`
+	Version     int            `json:"version"`
+	Description string         `json:"description"`
+	Schema      map[string]any `json:"schema"`
+
+	compiled *jsonschema.Schema
+	decode   func(data []byte) (Payload, error)
+}
+
+// schemaKey identifies a Schema in the registry.
+type schemaKey struct {
+	typ     Type
+	version int
+}
+
+// typeDefinition describes the payload of an event type in a schema
+// version. withTask is false for events that carry no task, such as
+// deletions.
+type typeDefinition struct {
+	typ         Type
+	version     int
+	description string
+	withTask    bool
+	decode      func(data []byte) (Payload, error)
+}
+
+// define returns the definition of the payload type P.
+func define[P Payload](description string, withTask bool) typeDefinition {
+	var p P
+	return typeDefinition{
+		typ:         p.EventType(),
+		version:     p.EventVersion(),
+		description: description,
+		withTask:    withTask,
+		decode: func(data []byte) (Payload, error) {
+			var p P
+			err := json.Unmarshal(data, &p)
+			return p, err
+		},
+	}
+}
+
+// definitions lists the payload of every event type and version.
+var definitions = []typeDefinition{
+	define[TaskCreatedV1]("A task was created.", true),
+	define[TaskUpdatedV1]("A task was updated; task is the task after the update.", true),
+	define[TaskDeletedV1]("A task was deleted.", false),
+	define[TaskArchivedV1]("A task was archived.", true),
+	define[TaskUnarchivedV1]("A task was unarchived.", true),
+	define[TaskAssignedV1]("A task was assigned or unassigned.", true),
+	define[TaskClaimedV1]("A task was claimed by a worker.", true),
+	define[TaskReleasedV1]("The lease of a task was released.", true),
+}
+
+// schemaBuilders build the schemas of each version.
+var schemaBuilders = map[int]func(name string, d typeDefinition) map[string]any{
+	1: schemaV1,
+}
+
+// registry holds the schemas of every event type and version.
+var registry = mustNewRegistry()
+
+// taskSchemaV1 is the schema of TaskV1. Fields beyond the required ones
+// may be added without a new version.
+var taskSchemaV1 = map[string]any{
+	"type":     "object",
+	"required": []string{"id", "title", "done", "created_at", "updated_at"},
+	"properties": map[string]any{
+		"id":          map[string]any{"type": "string", "minLength": 1},
+		"title":       map[string]any{"type": "string"},
+		"description": map[string]any{"type": "string"},
+		"done":        map[string]any{"type": "boolean"},
+		"created_at":  map[string]any{"type": "string", "format": "date-time"},
+		"updated_at":  map[string]any{"type": "string", "format": "date-time"},
+		"archived_at": map[string]any{"type": "string", "format": "date-time"},
+		"owner":       map[string]any{"type": "string"},
+		"tenant":      map[string]any{"type": "string"},
+		"assignee":    map[string]any{"type": "string"},
+		"depends_on":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	},
+}
+
+// schemaV1 returns the JSON Schema of a version 1 event of d.
+func schemaV1(name string, d typeDefinition) map[string]any {
+	s := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         schemaID(name),
+		"title":       name,
+		"description": d.description,
+		"type":        "object",
+		"required":    []string{"id", "type", "version", "task_id", "timestamp"},
+		"properties": map[string]any{
+			"id":        map[string]any{"type": "string", "minLength": 1},
+			"type":      map[string]any{"const": string(d.typ)},
+			"version":   map[string]any{"const": 1},
+			"task_id":   map[string]any{"type": "string", "minLength": 1},
+			"timestamp": map[string]any{"type": "string", "format": "date-time"},
+			"task":      taskSchemaV1,
+		},
+	}
+	if d.withTask {
+		s["required"] = append(s["required"].([]string), "task")
+	}
+	return s
+}
+
+// schemaID returns the $id of the schema named name.
+func schemaID(name string) string {
+	return "urn:go-otel-sample:events:" + name
+}
+
+// schemaRegistry holds the compiled schemas, ordered by type and version.
+type schemaRegistry struct {
+	schemas []*Schema
+	byKey   map[schemaKey]*Schema
+}
+
+// mustNewRegistry compiles the schemas of definitions. The schemas are
+// static, so a failure is a programming error.
+func mustNewRegistry() *schemaRegistry {
+	r := &schemaRegistry{byKey: make(map[schemaKey]*Schema)}
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	for _, d := range definitions {
+		name := fmt.Sprintf("%s.v%d", d.typ, d.version)
+		build, ok := schemaBuilders[d.version]
+		if !ok {
+			panic(fmt.Sprintf("no schema builder for event schema %s", name))
+		}
+		s := &Schema{Name: name, Type: d.typ, Version: d.version, Description: d.description, Schema: build(name, d), decode: d.decode}
+
+		// Round-trip the schema through JSON, since the compiler expects
+		// decoded JSON values
+		data, err := json.Marshal(s.Schema)
+		if err != nil {
+			panic(fmt.Sprintf("failed to encode event schema %s: %v", name, err))
+		}
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			panic(fmt.Sprintf("failed to decode event schema %s: %v", name, err))
+		}
+		if err := c.AddResource(schemaID(name), doc); err != nil {
+			panic(fmt.Sprintf("failed to add event schema %s: %v", name, err))
+		}
+		if s.compiled, err = c.Compile(schemaID(name)); err != nil {
+			panic(fmt.Sprintf("failed to compile event schema %s: %v", name, err))
+		}
+
+		r.schemas = append(r.schemas, s)
+		r.byKey[schemaKey{d.typ, d.version}] = s
+	}
+	slices.SortFunc(r.schemas, func(a, b *Schema) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.Version, b.Version))
+	})
+	return r
+}
+
+// Schemas returns the schemas of every event type and version, ordered by
+// type and version.
+func Schemas() []*Schema {
+	return registry.schemas
+}
+
+// LookupSchema returns the schema named name, e.g. task.created.v1.
+func LookupSchema(name string) (*Schema, bool) {
+	for _, s := range registry.schemas {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Marshal encodes e as a JSON object holding the fields of e and of its
+// payload, after validating it against the schema of its type and version.
+func Marshal(e Event) ([]byte, error) {
+	if e.Payload == nil {
+		return nil, fmt.Errorf("%w: %s.v%d has no payload", ErrInvalidEvent, e.Type, e.Version)
+	}
+	if e.Payload.EventType() != e.Type || e.Payload.EventVersion() != e.Version {
+		return nil, fmt.Errorf("%w: %s.v%d has a %s.v%d payload", ErrInvalidEvent, e.Type, e.Version, e.Payload.EventType(), e.Payload.EventVersion())
+	}
+
+	fields, err := objectFields(e)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := objectFields(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(fields, payload)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := validate(e.Type, e.Version, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Unmarshal decodes an event and its payload from JSON after validating it
+// against the schema of its type and version. Events published before
+// versioning, which have no version, are read as version 1.
+func Unmarshal(data []byte) (Event, error) {
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Event{}, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	if e.Version == 0 {
+		e.Version = 1
+		fields := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return Event{}, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+		}
+		fields["version"] = json.RawMessage("1")
+		var err error
+		if data, err = json.Marshal(fields); err != nil {
+			return Event{}, err
+		}
+	}
+
+	s, err := validate(e.Type, e.Version, data)
+	if err != nil {
+		return Event{}, err
+	}
+	if e.Payload, err = s.decode(data); err != nil {
+		return Event{}, fmt.Errorf("%w: %s: %w", ErrInvalidEvent, s.Name, err)
+	}
+	return e, nil
+}
+
+// objectFields encodes v, which must encode as a JSON object, and returns
+// its fields.
+func objectFields(v any) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// validate validates the encoded event data against the schema of t and
+// version, and returns the schema.
+func validate(t Type, version int, data []byte) (*Schema, error) {
+	s, ok := registry.byKey[schemaKey{t, version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s.v%d", ErrUnknownSchema, t, version)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	if err := s.compiled.Validate(doc); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidEvent, s.Name, err)
+	}
+	return s, nil
+}
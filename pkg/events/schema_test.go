@@ -0,0 +1,85 @@
+package events
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestRoundTrip encodes an event of every payload type and decodes it
+// back into the same typed payload.
+func TestRoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	task := TaskV1{ID: "t1", Title: "task", CreatedAt: now, UpdatedAt: now, DependsOn: []string{"t0"}}
+
+	payloads := []Payload{
+		TaskCreatedV1{Task: task},
+		TaskUpdatedV1{Task: task},
+		TaskDeletedV1{},
+		TaskArchivedV1{Task: task},
+		TaskUnarchivedV1{Task: task},
+		TaskAssignedV1{Task: task},
+		TaskClaimedV1{Task: task},
+		TaskReleasedV1{Task: task},
+	}
+	if len(payloads) != len(definitions) {
+		t.Fatalf("got %d payloads, want one per definition (%d)", len(payloads), len(definitions))
+	}
+
+	for _, p := range payloads {
+		t.Run(string(p.EventType()), func(t *testing.T) {
+			e := New(task.ID, p)
+			data, err := Marshal(e)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			got, err := Unmarshal(data)
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got.ID != e.ID || got.Type != e.Type || got.Version != e.Version || got.TaskID != e.TaskID {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, e)
+			}
+			if !reflect.DeepEqual(got.Payload, p) {
+				t.Errorf("Unmarshal() payload = %#v, want %#v", got.Payload, p)
+			}
+		})
+	}
+}
+
+// TestMarshalInvalid checks that events not matching their schema or
+// payload aren't encoded.
+func TestMarshalInvalid(t *testing.T) {
+	missingTask := New("t1", TaskCreatedV1{})
+	mismatched := New("t1", TaskDeletedV1{})
+	mismatched.Type = TypeTaskCreated
+
+	for name, e := range map[string]Event{
+		"no payload":       {ID: "e1", Type: TypeTaskDeleted, Version: 1, TaskID: "t1"},
+		"payload mismatch": mismatched,
+		"invalid task":     missingTask,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Marshal(e); !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("Marshal() error = %v, want ErrInvalidEvent", err)
+			}
+		})
+	}
+}
+
+// TestUnmarshalUnversioned reads an event published before versioning as
+// version 1.
+func TestUnmarshalUnversioned(t *testing.T) {
+	data := []byte(`{"id":"e1","type":"task.deleted","task_id":"t1","timestamp":"2024-01-01T00:00:00Z"}`)
+	e, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if e.Version != 1 {
+		t.Errorf("Version = %d, want 1", e.Version)
+	}
+	if _, ok := e.Payload.(TaskDeletedV1); !ok {
+		t.Errorf("Payload = %T, want TaskDeletedV1", e.Payload)
+	}
+}
@@ -0,0 +1,82 @@
+package events
+
+import "time"
+
+// TaskV1 is the task carried by version 1 events.
+type TaskV1 struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Done        bool       `json:"done"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Tenant      string     `json:"tenant,omitempty"`
+	Assignee    string     `json:"assignee,omitempty"`
+	DependsOn   []string   `json:"depends_on,omitempty"`
+}
+
+// TaskCreatedV1 is version 1 of task.created.
+type TaskCreatedV1 struct {
+	Task TaskV1 `json:"task"`
+}
+
+func (TaskCreatedV1) EventType() Type   { return TypeTaskCreated }
+func (TaskCreatedV1) EventVersion() int { return 1 }
+
+// TaskUpdatedV1 is version 1 of task.updated. Task is the task after the
+// update.
+type TaskUpdatedV1 struct {
+	Task TaskV1 `json:"task"`
+}
+
+func (TaskUpdatedV1) EventType() Type   { return TypeTaskUpdated }
+func (TaskUpdatedV1) EventVersion() int { return 1 }
+
+// TaskDeletedV1 is version 1 of task.deleted. It carries no task.
+type TaskDeletedV1 struct{}
+
+func (TaskDeletedV1) EventType() Type   { return TypeTaskDeleted }
+func (TaskDeletedV1) EventVersion() int { return 1 }
+
+// TaskArchivedV1 is version 1 of task.archived.
+type TaskArchivedV1 struct {
+	Task TaskV1 `json:"task"`
+}
+
+func (TaskArchivedV1) EventType() Type   { return TypeTaskArchived }
+func (TaskArchivedV1) EventVersion() int { return 1 }
+
+// TaskUnarchivedV1 is version 1 of task.unarchived.
+type TaskUnarchivedV1 struct {
+	Task TaskV1 `json:"task"`
+}
+
+func (TaskUnarchivedV1) EventType() Type   { return TypeTaskUnarchived }
+func (TaskUnarchivedV1) EventVersion() int { return 1 }
+
+// TaskAssignedV1 is version 1 of task.assigned, sent when a task is
+// assigned or unassigned.
+type TaskAssignedV1 struct {
+	Task TaskV1 `json:"task"`
+}
+
+func (TaskAssignedV1) EventType() Type   { return TypeTaskAssigned }
+func (TaskAssignedV1) EventVersion() int { return 1 }
+
+// TaskClaimedV1 is version 1 of task.claimed.
+type TaskClaimedV1 struct {
+	Task TaskV1 `json:"task"`
+}
+
+func (TaskClaimedV1) EventType() Type   { return TypeTaskClaimed }
+func (TaskClaimedV1) EventVersion() int { return 1 }
+
+// TaskReleasedV1 is version 1 of task.released.
+type TaskReleasedV1 struct {
+	Task TaskV1 `json:"task"`
+}
+
+func (TaskReleasedV1) EventType() Type   { return TypeTaskReleased }
+func (TaskReleasedV1) EventVersion() int { return 1 }